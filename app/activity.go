@@ -0,0 +1,61 @@
+package app
+
+import (
+	"context"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+// DefaultActivityInterval — период вывода текущих запросов с -watch-activity
+const DefaultActivityInterval = 5 * time.Second
+
+// maxActivityQuery — сколько символов запроса попадает в строку активности
+const maxActivityQuery = 200
+
+// WithWatchActivity включает периодический вывод запроса, который сейчас выполняет мигратор, и времени его
+// выполнения. Работает с хранилищами, реализующими storage.ActivityWatcher; 0 отключает вывод.
+func WithWatchActivity(interval time.Duration) Option {
+	return func(app *Application) {
+		app.activityInterval = interval
+	}
+}
+
+// watchActivity запускает наблюдение за сессией мигратора в фоне и возвращает функцию, которая его останавливает
+func (app *Application) watchActivity(ctx context.Context) (stop func()) {
+	if app.activityInterval <= 0 {
+		return func() {}
+	}
+
+	watcher, ok := app.sqlStorage.(storage.ActivityWatcher)
+	if !ok {
+		app.logger.Warn("Activity watching is not supported by this storage")
+		return func() {}
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		if err := watcher.WatchActivity(ctx, app.activityInterval, app.reportActivity); err != nil {
+			app.logger.Warn("Error in watchActivity: %v", err)
+		}
+	}()
+
+	return func() {
+		cancel()
+		wg.Wait()
+	}
+}
+
+func (app *Application) reportActivity(activity storage.Activity) {
+	query := strings.Join(strings.Fields(activity.Query), " ")
+	if runes := []rune(query); len(runes) > maxActivityQuery {
+		query = string(runes[:maxActivityQuery]) + "..."
+	}
+
+	app.logger.Info("Activity: pid %d %s for %s: %s", activity.PID, activity.State, activity.Elapsed.Round(time.Second), query)
+}
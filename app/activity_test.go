@@ -0,0 +1,78 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// watchingStorage сообщает одну запись активности и считает запуски наблюдения
+type watchingStorage struct {
+	storage.MockSqlStorage
+	mu      sync.Mutex
+	watched int
+	stopped bool
+}
+
+func (s *watchingStorage) WatchActivity(ctx context.Context, interval time.Duration, report func(storage.Activity)) error {
+	s.mu.Lock()
+	s.watched++
+	s.mu.Unlock()
+
+	report(storage.Activity{PID: 42, State: "active", Query: "CREATE INDEX\n  users_email_idx ON users (email);", Elapsed: 90 * time.Second})
+	<-ctx.Done()
+
+	s.mu.Lock()
+	s.stopped = true
+	s.mu.Unlock()
+	return nil
+}
+
+// syncBuffer — буфер лога, в который пишут и Up, и фоновое наблюдение
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchActivityDuringUp(t *testing.T) {
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_up.sql"), []byte("SELECT 1;"), 0644))
+
+	var out syncBuffer
+	sqlStorage := &watchingStorage{}
+	app := New(logger.NewWithOutput(&out, "info"), sqlStorage, WithWatchActivity(time.Millisecond))
+
+	assert.NoError(t, app.Up(context.Background(), migrationDir))
+	assert.Equal(t, 1, sqlStorage.watched)
+	assert.True(t, sqlStorage.stopped, "Expected watching to stop when up finishes")
+	assert.Contains(t, out.String(), "Activity: pid 42 active for 1m30s: CREATE INDEX users_email_idx ON users (email);")
+}
+
+func TestWatchActivityDisabled(t *testing.T) {
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_up.sql"), []byte("SELECT 1;"), 0644))
+
+	sqlStorage := &watchingStorage{}
+	assert.NoError(t, New(logger.NewNop(), sqlStorage).Up(context.Background(), migrationDir))
+	assert.Zero(t, sqlStorage.watched)
+}
@@ -0,0 +1,117 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/juliazadorozhnaya/sql-migrator/processes"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+var (
+	ErrAmendApplied   = errors.New("cannot amend a migration that is already applied, create a new one instead")
+	ErrNothingToAmend = errors.New("no migrations to amend")
+)
+
+// Amend переименовывает файлы последней миграции в name, сохраняя версию. Нужен, пока миграция пишется
+// и еще не применена: примененную миграцию переименовать нельзя, потому что ее имя уже записано в базе.
+// Возвращает новые пути файлов.
+func (app *Application) Amend(ctx context.Context, filePath, name string) ([]string, error) {
+	if isRemotePath(filePath) {
+		app.logger.Error("Cannot amend migrations in %s", filePath)
+		return nil, ErrRemoteSource
+	}
+
+	if name == "" || strings.ContainsAny(name, `/\`) {
+		app.logger.Error("Error in Amend: %v: %q", ErrInvalidMigrationName, name)
+		return nil, ErrInvalidMigrationName
+	}
+
+	files, version, err := app.lastMigrationFiles(filePath)
+	if err != nil {
+		app.logger.Error("Error in Amend: %v", err)
+		return nil, err
+	}
+
+	err = app.runSingleCommand(ctx, func(migrator *processes.Migrator, ctx context.Context) error {
+		migration, err := app.sqlStorage.SelectMigrationByVersion(ctx, version)
+		if errors.Is(err, storage.ErrMigrationNotFound) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		switch migration.GetStatus() {
+		case storage.StatusSuccess, storage.StatusOutOfOrder, storage.StatusProcess:
+			return fmt.Errorf("%w: %s version %d is %s", ErrAmendApplied, migration.GetName(), version, migration.GetStatus())
+		}
+		return nil
+	})
+	if err != nil {
+		app.logger.Error("Error in Amend: %v", err)
+		return nil, err
+	}
+
+	renames := make(map[string]string, len(files))
+	for _, file := range files {
+		renamed, err := app.convention.rename(file, name)
+		if err != nil {
+			return nil, err
+		}
+		if _, err := os.Stat(path.Join(filePath, renamed)); err == nil && renamed != file {
+			app.logger.Error("Error in Amend: %s already exists", renamed)
+			return nil, fmt.Errorf("%w: %s", os.ErrExist, renamed)
+		}
+		renames[file] = renamed
+	}
+
+	amended := make([]string, 0, len(files))
+	for _, file := range files {
+		from, to := path.Join(filePath, file), path.Join(filePath, renames[file])
+		if err := os.Rename(from, to); err != nil {
+			app.logger.Error("Error in Amend: %v", err)
+			return amended, err
+		}
+		app.logger.Info("%s renamed to %s", from, to)
+		amended = append(amended, to)
+	}
+
+	return amended, nil
+}
+
+// lastMigrationFiles возвращает файлы миграции с наибольшей версией и эту версию
+func (app *Application) lastMigrationFiles(filePath string) ([]string, int, error) {
+	entries, err := os.ReadDir(filePath)
+	if err != nil {
+		return nil, 0, err
+	}
+
+	versions := make(map[int][]string)
+	last := 0
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") || !app.convention.isCandidate(entry.Name()) {
+			continue
+		}
+
+		parsed, err := app.convention.parse(entry.Name())
+		if err != nil {
+			return nil, 0, fmt.Errorf("%w: %s", err, entry.Name())
+		}
+
+		versions[parsed.version] = append(versions[parsed.version], entry.Name())
+		if parsed.version > last {
+			last = parsed.version
+		}
+	}
+
+	if len(versions) == 0 {
+		return nil, 0, ErrNothingToAmend
+	}
+
+	return versions[last], last, nil
+}
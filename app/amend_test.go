@@ -0,0 +1,76 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeMigrations(t *testing.T, dir string, files ...string) {
+	for _, name := range files {
+		assert.NoError(t, os.WriteFile(path.Join(dir, name), []byte("SELECT 1;"), 0644))
+	}
+}
+
+func TestAmendRenamesLastMigration(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigrations(t, migrationDir, "00001_create_users_up.sql", "00001_create_users_down.sql", "00002_add_emial_up.sql", "00002_add_emial_down.sql")
+
+	mockStorage := &storage.MockSqlStorage{}
+	assert.NoError(t, mockStorage.InsertMigration(context.Background(), storage.NewMigration("create_users", storage.StatusSuccess, 1, time.Now())))
+
+	app := New(logger.New(), mockStorage)
+	files, err := app.Amend(context.Background(), migrationDir, "add_email")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{path.Join(migrationDir, "00002_add_email_up.sql"), path.Join(migrationDir, "00002_add_email_down.sql")}, files)
+	for _, file := range files {
+		assert.FileExists(t, file)
+	}
+	assert.NoFileExists(t, path.Join(migrationDir, "00002_add_emial_up.sql"))
+	assert.FileExists(t, path.Join(migrationDir, "00001_create_users_up.sql"), "Expected earlier migrations to stay untouched")
+}
+
+func TestAmendRefusesAppliedMigration(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigrations(t, migrationDir, "00001_create_users_up.sql", "00002_add_emial_up.sql")
+
+	mockStorage := &storage.MockSqlStorage{}
+	app := New(logger.New(), mockStorage)
+	assert.NoError(t, app.Up(context.Background(), migrationDir))
+
+	files, err := app.Amend(context.Background(), migrationDir, "add_email")
+	assert.ErrorIs(t, err, ErrAmendApplied)
+	assert.Empty(t, files)
+	assert.FileExists(t, path.Join(migrationDir, "00002_add_emial_up.sql"))
+
+	assert.NoError(t, app.Down(context.Background(), migrationDir))
+	_, err = app.Amend(context.Background(), migrationDir, "add_email")
+	assert.NoError(t, err, "Expected a rolled back migration to be amendable")
+	assert.FileExists(t, path.Join(migrationDir, "00002_add_email_up.sql"))
+}
+
+func TestAmendFlywayConvention(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigrations(t, migrationDir, "V1__create_users.sql", "U1__create_users.sql")
+
+	app := New(logger.New(), &storage.MockSqlStorage{}, WithConvention(FlywayConvention))
+	files, err := app.Amend(context.Background(), migrationDir, "create_accounts")
+	assert.NoError(t, err)
+	assert.ElementsMatch(t, []string{path.Join(migrationDir, "V1__create_accounts.sql"), path.Join(migrationDir, "U1__create_accounts.sql")}, files)
+}
+
+func TestAmendWithoutMigrations(t *testing.T) {
+	app := New(logger.New(), &storage.MockSqlStorage{})
+
+	_, err := app.Amend(context.Background(), t.TempDir(), "add_email")
+	assert.ErrorIs(t, err, ErrNothingToAmend)
+
+	_, err = app.Amend(context.Background(), t.TempDir(), "../add_email")
+	assert.ErrorIs(t, err, ErrInvalidMigrationName)
+}
@@ -4,135 +4,831 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"io/fs"
 	"os"
 	"os/exec"
 	"path"
+	"plugin"
 	"regexp"
+	"sort"
 	"strconv"
 	"strings"
+	"time"
 
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/juliazadorozhnaya/sql-migrator/audit"
+	"github.com/juliazadorozhnaya/sql-migrator/lint"
 	"github.com/juliazadorozhnaya/sql-migrator/logger"
 	"github.com/juliazadorozhnaya/sql-migrator/processes"
 	"github.com/juliazadorozhnaya/sql-migrator/storage"
 )
 
 type App interface {
-	Create(name, path string, migrationType string)
-	Up(path string)
-	Down(path string)
-	Redo(path string)
-	Status()
-	DbVersion()
+	Create(ctx context.Context, name, path string, migrationType string) ([]string, error)
+	Amend(ctx context.Context, path, name string) ([]string, error)
+	Format(ctx context.Context, path string, dryRun bool) ([]Rename, error)
+	Up(ctx context.Context, path string) error
+	UpToTag(ctx context.Context, path, tag string) error
+	UpN(ctx context.Context, path string, n int) error
+	UpFrom(ctx context.Context, path string, from int) error
+	Down(ctx context.Context, path string) error
+	Redo(ctx context.Context, path string) error
+	Skip(ctx context.Context, path string, version int) error
+	ApplyOutOfOrder(ctx context.Context, path, name string, version int) error
+	DryRun(ctx context.Context, path, mode string) error
+	Plan(ctx context.Context, path string, w io.Writer) error
+	Doctor(ctx context.Context, path string, w io.Writer, prior ...DoctorCheck) error
+	VerifyConnection(ctx context.Context, w io.Writer) error
+	Status(ctx context.Context, path string, opts processes.StatusOptions) error
+	StatusToFile(ctx context.Context, path, out string, opts processes.StatusOptions) error
+	DbVersion(ctx context.Context) error
+	History(ctx context.Context) error
+	Cleanup(ctx context.Context, confirm bool) error
+	Check(ctx context.Context, path string) (bool, error)
+	Diff(ctx context.Context, other storage.SqlStorage) error
+	Lint(ctx context.Context, path string) error
+	Squash(ctx context.Context, path, dsn string) error
+	Export(ctx context.Context, w io.Writer) error
+	Watch(ctx context.Context, path string) error
+	GenerateFromSchema(ctx context.Context, schemaFile, path, name string) error
+	Import(ctx context.Context, path string, r io.Reader) error
 }
 
 type Application struct {
 	logger     logger.Logger
 	sqlStorage storage.SqlStorage
+	idempotent bool
+	force      bool
+	batch      bool
+	mkdir      bool
+	seedFile   bool
+	seeds      bool
+	fileMode   os.FileMode
+	dirMode    os.FileMode
+	lang       string
+	convention Convention
+	// continueOnError — Up применяет все, что удается, и сообщает обо всех упавших версиях
+	continueOnError bool
+	txMode          string
+	transaction     bool
+	only            string
+	resume          bool
+	recordSQL       bool
+	tracerProvider  trace.TracerProvider
+	audit           *audit.Writer
+	createLock      bool
+	expandEnv       bool
+	notifyChannel   string
+	location        *time.Location
+	templates       map[string]Template
+	sqlTemplate     string
+	emptyFiles      bool
+	// activityInterval — период вывода текущих запросов мигратора во время выполнения миграций; 0 — не выводить
+	activityInterval time.Duration
+}
+
+type Option func(*Application)
+
+// WithIdempotent включает повторно запускаемый режим Up, пропускающий уже примененные версии
+func WithIdempotent(idempotent bool) Option {
+	return func(app *Application) {
+		app.idempotent = idempotent
+	}
 }
 
 var (
 	ErrInvalidMigrationName = errors.New("invalid migration name")
+	ErrInvalidPluginSymbol  = errors.New("plugin symbol must be func(context.Context) error")
+	ErrRedoSingleFile       = errors.New("redo is not supported for a single migration file")
+	ErrDirNotExist          = errors.New("directory does not exist, pass -mkdir to create it")
+	ErrInvalidFileMode      = errors.New("file mode must be an octal permission such as 0644")
+	ErrRemoteGoMigration    = errors.New("go migrations are not allowed from remote sources")
+	ErrRemoteSource         = errors.New("remote migration sources are read-only")
+	ErrLintFailed           = errors.New("migrations have lint errors")
+	ErrVersionConflict      = errors.New("version is already used by another migration")
+	ErrMixedMigration       = errors.New("version mixes sql and go migration files, use one kind per version")
+	ErrSeedNotSQL           = errors.New("seed files can be created only for sql migrations")
+	ErrVersionGap           = errors.New("migration versions must be contiguous from 1, rename the files to close the gap")
+
+	regGetVersion = regexp.MustCompile(`^\d+`)
+)
+
+// WithForce разрешает команды, которые по умолчанию отказываются перезаписывать данные
+func WithForce(force bool) Option {
+	return func(app *Application) {
+		app.force = force
+	}
+}
+
+// WithBatch включает пакетную запись статусов без промежуточных переходов
+func WithBatch(batch bool) Option {
+	return func(app *Application) {
+		app.batch = batch
+	}
+}
 
-	regGetVersion         = regexp.MustCompile(`^\d+`)
-	regGetUpMigration     = regexp.MustCompile(`^.+_up\.sql$`)
-	regGetDownMigration   = regexp.MustCompile(`^.+_down\.sql$`)
-	regGetUpGoMigration   = regexp.MustCompile(`^.+_up\.go$`)
-	regGetDownGoMigration = regexp.MustCompile(`^.+_down\.go$`)
+const (
+	// DefaultFileMode — права новых файлов миграций
+	DefaultFileMode os.FileMode = 0644
+	// DefaultDirMode — права директории миграций, создаваемой с -mkdir
+	DefaultDirMode os.FileMode = 0755
 )
 
-func New(logger logger.Logger, sqlStorage storage.SqlStorage) *Application {
-	return &Application{
+// WithFileMode задает права новых файлов миграций
+func WithFileMode(mode os.FileMode) Option {
+	return func(app *Application) {
+		app.fileMode = mode
+	}
+}
+
+// WithDirMode задает права директорий, которые создает мигратор
+func WithDirMode(mode os.FileMode) Option {
+	return func(app *Application) {
+		app.dirMode = mode
+	}
+}
+
+// ParseFileMode разбирает права, записанные восьмеричной строкой ("0664"); пустая строка дает defaultMode
+func ParseFileMode(value string, defaultMode os.FileMode) (os.FileMode, error) {
+	if value == "" {
+		return defaultMode, nil
+	}
+
+	mode, err := strconv.ParseUint(value, 8, 32)
+	if err != nil || mode > uint64(os.ModePerm) {
+		return 0, fmt.Errorf("%w: %s", ErrInvalidFileMode, value)
+	}
+	return os.FileMode(mode), nil
+}
+
+// WithSeedFile добавляет к файлам, создаваемым Create, файл 00001_name_seed.sql для тестовых данных
+func WithSeedFile(seedFile bool) Option {
+	return func(app *Application) {
+		app.seedFile = seedFile
+	}
+}
+
+// WithSeeds применяет seed-файлы после up-шага миграции; в production не включается
+func WithSeeds(seeds bool) Option {
+	return func(app *Application) {
+		app.seeds = seeds
+	}
+}
+
+// WithMkdir разрешает Create создавать отсутствующую директорию миграций
+func WithMkdir(mkdir bool) Option {
+	return func(app *Application) {
+		app.mkdir = mkdir
+	}
+}
+
+// WithLang задает язык заголовков таблиц status и history
+func WithLang(lang string) Option {
+	return func(app *Application) {
+		app.lang = lang
+	}
+}
+
+// WithContinueOnError включает режим Up, который не останавливается на первой упавшей миграции
+func WithContinueOnError(continueOnError bool) Option {
+	return func(app *Application) {
+		app.continueOnError = continueOnError
+	}
+}
+
+// WithTxMode задает границы транзакций Up: processes.TxModePerMigration или processes.TxModeAll
+func WithTxMode(txMode string) Option {
+	return func(app *Application) {
+		app.txMode = txMode
+	}
+}
+
+// WithOnly ограничивает Up миграциями одного вида: processes.KindSQL или processes.KindGo
+func WithOnly(kind string) Option {
+	return func(app *Application) {
+		app.only = kind
+	}
+}
+
+// WithResume включает восстановление учета прерванных миграций по их probe-запросу, см. processes.Migrator.SetResume
+func WithResume(resume bool) Option {
+	return func(app *Application) {
+		app.resume = resume
+	}
+}
+
+// WithRecordSQL записывает выполненный SQL миграций в таблицу учета, см. processes.Migrator.SetRecordSQL
+func WithRecordSQL(recordSQL bool) Option {
+	return func(app *Application) {
+		app.recordSQL = recordSQL
+	}
+}
+
+// WithTracerProvider включает спаны OpenTelemetry для Up и Down, см. processes.Migrator.SetTracerProvider
+func WithTracerProvider(provider trace.TracerProvider) Option {
+	return func(app *Application) {
+		app.tracerProvider = provider
+	}
+}
+
+// WithAudit включает журнал действий в JSON Lines, см. processes.Migrator.SetAudit
+func WithAudit(writer *audit.Writer) Option {
+	return func(app *Application) {
+		app.audit = writer
+	}
+}
+
+// WithTransaction выполняет каждую Go-миграцию вместе с записью ее статуса в одной транзакции
+func WithTransaction(transaction bool) Option {
+	return func(app *Application) {
+		app.transaction = transaction
+	}
+}
+
+// WithNotifyChannel задает канал NOTIFY, в который Up сообщает новую версию базы
+func WithNotifyChannel(channel string) Option {
+	return func(app *Application) {
+		app.notifyChannel = channel
+	}
+}
+
+// WithTimezone задает часовой пояс вывода времени в status и history; nil — UTC
+func WithTimezone(location *time.Location) Option {
+	return func(app *Application) {
+		app.location = location
+	}
+}
+
+// WithExpandEnv включает подстановку переменных окружения ${VAR} в SQL миграций
+func WithExpandEnv(expandEnv bool) Option {
+	return func(app *Application) {
+		app.expandEnv = expandEnv
+	}
+}
+
+// WithConvention задает схему именования файлов миграций при чтении директории
+func WithConvention(convention Convention) Option {
+	return func(app *Application) {
+		app.convention = convention
+	}
+}
+
+func New(logger logger.Logger, sqlStorage storage.SqlStorage, opts ...Option) *Application {
+	app := &Application{
 		logger:     logger,
 		sqlStorage: sqlStorage,
+		convention: DefaultConvention,
+		fileMode:   DefaultFileMode,
+		dirMode:    DefaultDirMode,
+	}
+
+	for _, opt := range opts {
+		opt(app)
 	}
+
+	return app
 }
 
-func (app *Application) Create(name, filePath, migrationType string) {
+// Create создает файлы новой миграции и возвращает их пути. При ошибке возвращаются пути уже созданных файлов.
+func (app *Application) Create(ctx context.Context, name, filePath, migrationType string) ([]string, error) {
+	if isRemotePath(filePath) {
+		app.logger.Error("Cannot create migrations in %s", filePath)
+		return nil, ErrRemoteSource
+	}
+
+	tmpl, err := app.template(migrationType)
+	if err != nil {
+		app.logger.Error("Cannot create %s migration: %v", migrationType, err)
+		return nil, err
+	}
+
+	if app.seedFile && tmpl.Ext != "sql" {
+		app.logger.Error("Cannot create a seed file for %s migration", migrationType)
+		return nil, ErrSeedNotSQL
+	}
+
+	if err := app.ensureDir(filePath); err != nil {
+		app.logger.Error("Failed to prepare directory: %v", err)
+		return nil, err
+	}
+
+	unlock, err := app.lockCreate(ctx, filePath)
+	if err != nil {
+		app.logger.Error("Failed to lock directory: %v", err)
+		return nil, err
+	}
+	defer unlock()
+
 	files, err := os.ReadDir(filePath)
 	if err != nil {
-		app.logger.Fatal("Failed to read directory: ", err)
-		return
+		app.logger.Error("Failed to read directory: %v", err)
+		return nil, err
 	}
 
 	lastVersion := getLastVersion(files, app.logger)
 	if lastVersion < 0 {
-		return
+		return nil, ErrInvalidMigrationName
 	}
 
 	lastVersion++
 
-	if err := createMigrationFiles(filePath, lastVersion, name, app.logger, migrationType); err != nil {
-		app.logger.Fatal("Failed to create migration files: ", err)
+	data := templateData{Version: lastVersion, Name: name, Date: app.today()}
+	created, err := createMigrationFiles(filePath, data, app.logger, tmpl, app.fileMode)
+	if err != nil {
+		app.logger.Error("Failed to create migration files: %v", err)
+		return created, err
+	}
+
+	if app.seedFile {
+		seedFile := path.Join(filePath, fmt.Sprintf("%05d_%s_seed.sql", lastVersion, name))
+		if err := writeFile(seedFile, []byte(seedTemplate), app.fileMode); err != nil {
+			app.logger.Error("Failed to create seed file: %v", err)
+			return created, err
+		}
+		app.logger.Info(seedFile + " created")
+		created = append(created, seedFile)
+	}
+
+	return created, nil
+}
+
+// seedTemplate — содержимое нового seed-файла. Seed применяется при каждом накате версии, в том числе после отката,
+// поэтому данные должны вставляться идемпотентно.
+const seedTemplate = `-- Seed data, applied after up only with -with-seeds.
+-- Keep it idempotent: INSERT ... ON CONFLICT DO NOTHING.
+`
+
+// today возвращает дату для заготовок миграций в часовом поясе WithTimezone, по умолчанию в UTC
+func (app *Application) today() string {
+	now := time.Now().UTC()
+	if app.location != nil {
+		now = now.In(app.location)
+	}
+	return now.Format("2006-01-02")
+}
+
+// ensureDir проверяет, что директория миграций существует, и создает ее, если разрешено WithMkdir
+func (app *Application) ensureDir(filePath string) error {
+	_, err := os.Stat(filePath)
+	if err == nil || !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	if !app.mkdir {
+		return fmt.Errorf("%w: %s", ErrDirNotExist, filePath)
+	}
+
+	app.logger.Info("Creating migrations directory %s", filePath)
+	return makeDir(filePath, app.dirMode)
+}
+
+// makeDir создает директорию с родителями; права выставляются явно, чтобы на них не влиял umask
+func makeDir(dir string, mode os.FileMode) error {
+	if err := os.MkdirAll(dir, mode); err != nil {
+		return err
+	}
+	return os.Chmod(dir, mode)
+}
+
+// writeFile записывает файл с правами mode независимо от umask
+func writeFile(name string, content []byte, mode os.FileMode) error {
+	if err := os.WriteFile(name, content, mode); err != nil {
+		return err
 	}
+	return os.Chmod(name, mode)
 }
 
-func (app *Application) Up(filePath string) {
-	app.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+func (app *Application) Up(ctx context.Context, filePath string) error {
+	version, single, err := app.singleFileVersion(filePath)
+	if err != nil {
+		app.logger.Error("Error in Up: %v", err)
+		return err
+	}
+
+	return app.runMigrations(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) (processes.Result, error) {
+		if single {
+			return migrator.Apply(ctx, version)
+		}
 		return migrator.Up(ctx)
 	})
 }
 
-func (app *Application) Down(filePath string) {
-	app.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+// UpToTag применяет миграции до последней миграции с тегом tag включительно
+func (app *Application) UpToTag(ctx context.Context, filePath, tag string) error {
+	return app.runMigrations(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) (processes.Result, error) {
+		return migrator.UpToTag(ctx, tag)
+	})
+}
+
+// UpN применяет только n ближайших ожидающих миграций
+func (app *Application) UpN(ctx context.Context, filePath string, n int) error {
+	return app.runMigrations(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) (processes.Result, error) {
+		return migrator.UpN(ctx, n)
+	})
+}
+
+// UpFrom применяет миграции начиная с версии from, не сверяясь с версией в базе; требует force
+func (app *Application) UpFrom(ctx context.Context, filePath string, from int) error {
+	return app.runMigrations(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) (processes.Result, error) {
+		return migrator.UpFrom(ctx, from)
+	})
+}
+
+func (app *Application) Down(ctx context.Context, filePath string) error {
+	version, single, err := app.singleFileVersion(filePath)
+	if err != nil {
+		app.logger.Error("Error in Down: %v", err)
+		return err
+	}
+
+	return app.runMigrations(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) (processes.Result, error) {
+		if single {
+			return migrator.Revert(ctx, version)
+		}
 		return migrator.Down(ctx)
 	})
 }
 
-func (app *Application) Redo(filePath string) {
-	app.runMigrations(filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+func (app *Application) Redo(ctx context.Context, filePath string) error {
+	_, single, err := app.singleFileVersion(filePath)
+	if err != nil {
+		app.logger.Error("Error in Redo: %v", err)
+		return err
+	}
+	if single {
+		app.logger.Error("Redo is not supported for a single migration file")
+		return ErrRedoSingleFile
+	}
+
+	return app.runMigrations(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) (processes.Result, error) {
 		return migrator.Redo(ctx)
 	})
 }
 
-func (app *Application) Status() {
-	app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
-		return migrator.Status(ctx)
+// Skip отмечает версию как пропущенную: Up не будет ее применять
+func (app *Application) Skip(ctx context.Context, filePath string, version int) error {
+	return app.runMigrations(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) (processes.Result, error) {
+		return migrator.Skip(ctx, version)
+	})
+}
+
+// ApplyOutOfOrder применяет одну миграцию, заданную именем или версией, в обход очереди. Требует WithForce.
+func (app *Application) ApplyOutOfOrder(ctx context.Context, filePath, name string, version int) error {
+	return app.runMigrations(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) (processes.Result, error) {
+		if name != "" {
+			var err error
+			if version, err = migrator.FindVersion(name); err != nil {
+				app.logger.Error("Migration %s not found", name)
+				return processes.Result{}, err
+			}
+		}
+		return migrator.ApplyOutOfOrder(ctx, version)
+	})
+}
+
+// DryRun показывает, что применит Up, не сохраняя изменений; mode — processes.DryRunPrint или processes.DryRunValidate
+func (app *Application) DryRun(ctx context.Context, filePath, mode string) error {
+	return app.runLoadedCommand(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		return migrator.DryRun(ctx, mode, nil)
 	})
 }
 
+// Plan пишет в w план Up в виде JSON, не применяя миграции
+func (app *Application) Plan(ctx context.Context, filePath string, w io.Writer) error {
+	return app.runLoadedCommand(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		return migrator.WritePlan(ctx, w)
+	})
+}
+
+// Status выводит статусы миграций; миграции из filePath нужны, чтобы показать их описания
+func (app *Application) Status(ctx context.Context, filePath string, opts processes.StatusOptions) error {
+	return app.runLoadedCommand(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		return migrator.Status(ctx, opts)
+	})
+}
+
+// StatusToFile записывает статусы миграций в файл out, создавая недостающие каталоги.
+// Файл заменяется целиком через переименование, поэтому читатели не видят частично записанный вывод.
+func (app *Application) StatusToFile(ctx context.Context, filePath, out string, opts processes.StatusOptions) error {
+	if err := os.MkdirAll(path.Dir(out), app.dirMode); err != nil {
+		app.logger.Error("Failed to create directory for %s: %v", out, err)
+		return err
+	}
+
+	file, err := os.CreateTemp(path.Dir(out), "."+path.Base(out)+".*")
+	if err != nil {
+		app.logger.Error("Failed to create status file %s: %v", out, err)
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	opts.Output = file
+	err = app.Status(ctx, filePath, opts)
+	if closeErr := file.Close(); err == nil && closeErr != nil {
+		app.logger.Error("Failed to write status file %s: %v", out, closeErr)
+		err = closeErr
+	}
+	// Ожидающие миграции не мешают записать файл: вывод полный, ошибка нужна только для кода выхода
+	pending := errors.Is(err, processes.ErrPendingMigrations)
+	if err != nil && !pending {
+		return err
+	}
+
+	if err := os.Chmod(file.Name(), app.fileMode); err != nil {
+		app.logger.Error("Failed to write status file %s: %v", out, err)
+		return err
+	}
+	if err := os.Rename(file.Name(), out); err != nil {
+		app.logger.Error("Failed to write status file %s: %v", out, err)
+		return err
+	}
+	if pending {
+		return err
+	}
+	return nil
+}
+
 // DbVersion выводит текущую версию базы данных
-func (app *Application) DbVersion() {
-	app.runSingleCommand(func(migrator *processes.Migrator, ctx context.Context) error {
+func (app *Application) DbVersion(ctx context.Context) error {
+	return app.runSingleCommand(ctx, func(migrator *processes.Migrator, ctx context.Context) error {
 		return migrator.DbVersion(ctx)
 	})
 }
 
-func (app *Application) runMigrations(filePath string, migrationFunc func(*processes.Migrator, context.Context) error) {
-	migrator := processes.New(app.sqlStorage, app.logger)
-	migrations, err := getMigrations(filePath)
+// History выводит журнал всех смен статусов миграций
+func (app *Application) History(ctx context.Context) error {
+	return app.runSingleCommand(ctx, func(migrator *processes.Migrator, ctx context.Context) error {
+		return migrator.History(ctx)
+	})
+}
+
+// Cleanup удаляет строки упавших и незавершенных запусков; без confirm только показывает их
+func (app *Application) Cleanup(ctx context.Context, confirm bool) error {
+	return app.runSingleCommand(ctx, func(migrator *processes.Migrator, ctx context.Context) error {
+		_, err := migrator.Cleanup(ctx, confirm)
+		return err
+	})
+}
+
+// Check проверяет доступность базы и возвращает true, если применены все миграции из filePath
+func (app *Application) Check(ctx context.Context, filePath string) (bool, error) {
+	upToDate := false
+	err := app.runLoadedCommand(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		result, err := migrator.Check(ctx)
+		if err != nil {
+			return err
+		}
+
+		upToDate = result.UpToDate()
+		if upToDate {
+			app.logger.Info("Database is up to date at version %d", result.Version)
+		} else {
+			app.logger.Warn("Database is behind by %d migrations: version %d, latest %d", result.Behind(), result.Version, result.Latest)
+		}
+		return nil
+	})
+
+	return upToDate, err
+}
+
+// Diff выводит версии, которые есть только в одной из двух баз, и версии с разными статусами
+func (app *Application) Diff(ctx context.Context, other storage.SqlStorage) error {
+	return app.runSingleCommand(ctx, func(migrator *processes.Migrator, ctx context.Context) error {
+		if err := other.Connect(ctx); err != nil {
+			app.logger.Error("Failed to connect to other database: %v", err)
+			return err
+		}
+		defer other.Close()
+
+		result, err := migrator.Diff(ctx, other)
+		if err != nil {
+			return err
+		}
+
+		if result.Equal() {
+			app.logger.Info("Databases have the same migration state")
+			return nil
+		}
+
+		for _, migration := range result.OnlyThis {
+			app.logger.Warn("Version %d %s is only in the first database with status %s", migration.GetVersion(), migration.GetName(), migration.GetStatus())
+		}
+		for _, migration := range result.OnlyOther {
+			app.logger.Warn("Version %d %s is only in the other database with status %s", migration.GetVersion(), migration.GetName(), migration.GetStatus())
+		}
+		for _, mismatch := range result.Mismatched {
+			app.logger.Warn("Version %d %s has status %s in the first database and %s in the other", mismatch.Version, mismatch.Name, mismatch.Status, mismatch.OtherStatus)
+		}
+		return nil
+	})
+}
+
+// Lint проверяет SQL миграций из filePath правилами линтера; подключение к базе не требуется
+func (app *Application) Lint(ctx context.Context, filePath string) error {
+	migrations, err := getMigrations(filePath, app.convention, app.logger)
 	if err != nil {
-		app.logger.Fatal("Failed to get migrations: ", err)
-		return
+		app.logger.Error("Failed to get migrations: %v", err)
+		return err
+	}
+
+	versions := make([]int, 0, len(migrations))
+	for version := range migrations {
+		versions = append(versions, version)
 	}
+	sort.Ints(versions)
 
-	for _, migration := range migrations {
-		migrator.Create(migration.Name, migration.Up, migration.Down, migration.UpGo, migration.DownGo)
+	linter := lint.New()
+	var issues []lint.Issue
+	for _, version := range versions {
+		migration := migrations[version]
+		issues = append(issues, linter.Lint(version, migration.Name, migration.Up, migration.Down)...)
 	}
 
-	ctx := context.Background()
-	if err := migrator.Connect(ctx); err != nil {
-		app.logger.Fatal("Failed to connect to database: ", err)
-		return
+	for _, issue := range issues {
+		if issue.Severity == lint.SeverityError {
+			app.logger.Error(issue.String())
+		} else {
+			app.logger.Warn(issue.String())
+		}
+	}
+	app.logger.Info("Linted %d migrations, found %d issues", len(migrations), len(issues))
+
+	if lint.HasErrors(issues) {
+		return ErrLintFailed
+	}
+	return nil
+}
+
+// Export пишет историю примененных миграций в виде SQL, пригодного для заполнения новой базы
+func (app *Application) Export(ctx context.Context, w io.Writer) error {
+	return app.runSingleCommand(ctx, func(migrator *processes.Migrator, ctx context.Context) error {
+		return migrator.Export(ctx, w)
+	})
+}
+
+// Import загружает историю миграций, выгруженную командой export
+func (app *Application) Import(ctx context.Context, filePath string, r io.Reader) error {
+	return app.runLoadedCommand(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		return migrator.Import(ctx, r)
+	})
+}
+
+func (app *Application) runMigrations(ctx context.Context, filePath string, migrationFunc func(*processes.Migrator, context.Context) (processes.Result, error)) error {
+	return app.runLoadedCommand(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		stop := app.watchActivity(ctx)
+		result, err := migrationFunc(migrator, ctx)
+		stop()
+		app.logger.Info(formatResult(result))
+		if err != nil && len(result.Failed) > 0 {
+			return &MigrationError{Version: result.Failed[0], Err: err}
+		}
+		return err
+	})
+}
+
+// runLoadedCommand выполняет команду мигратора, которому нужны миграции из filePath.
+// Отмененный ctx прерывает команду до чтения миграций и подключения к базе.
+func (app *Application) runLoadedCommand(ctx context.Context, filePath string, commandFunc func(*processes.Migrator, context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+
+	migrator := app.newMigrator()
+
+	_, single, err := app.singleFileVersion(filePath)
+	if err != nil {
+		app.logger.Error("Failed to get migrations: %v", err)
+		return err
+	}
+
+	migrations, err := getMigrations(filePath, app.convention, app.logger)
+	if err != nil {
+		app.logger.Error("Failed to get migrations: %v", err)
+		return err
+	}
+
+	sorted, err := sortedMigrations(migrations, !single)
+	if err != nil {
+		app.logger.Error("Failed to get migrations: %v", err)
+		return err
+	}
+	for _, migration := range sorted {
+		if app.expandEnv {
+			if err := expandMigrationEnv(&migration); err != nil {
+				app.logger.Error("Failed to expand environment in migration %s: %v", migration.Name, err)
+				return err
+			}
+		}
+		migrator.AddMigration(migration)
+	}
+
+	if err := app.connect(ctx, migrator); err != nil {
+		return err
 	}
 	defer migrator.Close(ctx)
 
-	if err := migrationFunc(migrator, ctx); err != nil {
-		app.logger.Error("Migration failed: ", err)
+	return commandFunc(migrator, ctx)
+}
+
+// sortedMigrations возвращает миграции по возрастанию версий. Мигратор считает версию номером миграции
+// в списке, поэтому при contiguous версии каталога должны идти подряд с 1: пропуск (1, 2, 5) — ошибка,
+// а не перенумерация, иначе номера в таблице учета разойдутся с версиями в именах файлов.
+func sortedMigrations(migrations map[int]*storage.Migration, contiguous bool) ([]storage.Migration, error) {
+	versions := make([]int, 0, len(migrations))
+	for version := range migrations {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	result := make([]storage.Migration, 0, len(versions))
+	for i, version := range versions {
+		if contiguous && version != i+1 {
+			return nil, fmt.Errorf("%w: expected version %d, found %d %s", ErrVersionGap, i+1, version, migrations[version].Name)
+		}
+		result = append(result, *migrations[version])
+	}
+
+	return result, nil
+}
+
+func expandMigrationEnv(migration *storage.Migration) error {
+	var err error
+	if migration.Up, err = expandEnv(migration.Up); err != nil {
+		return err
 	}
+	if migration.Down, err = expandEnv(migration.Down); err != nil {
+		return err
+	}
+	migration.Seed, err = expandEnv(migration.Seed)
+	return err
+}
+
+// connectTimeout ограничивает подключение, чтобы недоступный хост давал ошибку, а не зависание
+const connectTimeout = 10 * time.Second
+
+// connect подключается к базе с ограничением по времени; причина ошибки уже описана хранилищем
+func (app *Application) connect(ctx context.Context, migrator *processes.Migrator) error {
+	connectCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	defer cancel()
+
+	if err := migrator.Connect(connectCtx); err != nil {
+		app.logger.Error("Failed to connect to database: %v", err)
+		return err
+	}
+	return nil
 }
 
-func (app *Application) runSingleCommand(commandFunc func(*processes.Migrator, context.Context) error) {
+func (app *Application) newMigrator() *processes.Migrator {
 	migrator := processes.New(app.sqlStorage, app.logger)
-	ctx := context.Background()
-	if err := migrator.Connect(ctx); err != nil {
-		app.logger.Fatal("Failed to connect to database: ", err)
-		return
+	migrator.SetIdempotent(app.idempotent)
+	migrator.SetForce(app.force)
+	migrator.SetBatch(app.batch)
+	migrator.SetLang(app.lang)
+	migrator.SetContinueOnError(app.continueOnError)
+	migrator.SetTxMode(app.txMode)
+	migrator.SetTransaction(app.transaction)
+	migrator.SetOnly(app.only)
+	migrator.SetResume(app.resume)
+	migrator.SetRecordSQL(app.recordSQL)
+	migrator.SetSeeds(app.seeds)
+	migrator.SetNotifyChannel(app.notifyChannel)
+	migrator.SetTimezone(app.location)
+	migrator.SetTracerProvider(app.tracerProvider)
+	migrator.SetAudit(app.audit)
+
+	return migrator
+}
+
+// formatResult собирает итоговую строку, по которой удобно искать результат запуска в логах CI
+func formatResult(result processes.Result) string {
+	summary := fmt.Sprintf("Summary: applied %d, rolled back %d, skipped %d, failed %d, db version %d, elapsed %s",
+		result.Applied, result.RolledBack, result.Skipped, len(result.Failed), result.Version, result.Elapsed.Round(time.Millisecond))
+
+	if len(result.Failed) > 0 {
+		versions := make([]string, 0, len(result.Failed))
+		for _, version := range result.Failed {
+			versions = append(versions, strconv.Itoa(version))
+		}
+		summary += ", failed versions: " + strings.Join(versions, ", ")
 	}
-	defer migrator.Close(ctx)
 
-	if err := commandFunc(migrator, ctx); err != nil {
-		app.logger.Error("Command failed: ", err)
+	return summary
+}
+
+func (app *Application) runSingleCommand(ctx context.Context, commandFunc func(*processes.Migrator, context.Context) error) error {
+	if err := ctx.Err(); err != nil {
+		return err
 	}
+
+	migrator := app.newMigrator()
+	if err := app.connect(ctx, migrator); err != nil {
+		return err
+	}
+	defer migrator.Close(ctx)
+
+	return commandFunc(migrator, ctx)
 }
 
 func getLastVersion(files []os.DirEntry, logger logger.Logger) int {
@@ -157,181 +853,289 @@ func getLastVersion(files []os.DirEntry, logger logger.Logger) int {
 	return lastVersion
 }
 
-func createMigrationFiles(filePath string, version int, name string, logger logger.Logger, migrationType string) error {
-	switch migrationType {
-	case "sql":
-		upFile := path.Join(filePath, fmt.Sprintf("%05d_%s_up.sql", version, name))
-		err := os.WriteFile(upFile, nil, 0644)
+func createMigrationFiles(filePath string, data templateData, logger logger.Logger, tmpl Template, fileMode os.FileMode) ([]string, error) {
+	created := make([]string, 0, 2)
+	for _, step := range []struct{ direction, content string }{{directionUp, tmpl.Up}, {directionDown, tmpl.Down}} {
+		data.Direction = step.direction
+		content, err := renderTemplate(step.content, data)
 		if err != nil {
-			return err
+			return created, err
 		}
-		logger.Info(upFile + " created")
 
-		downFile := path.Join(filePath, fmt.Sprintf("%05d_%s_down.sql", version, name))
-		err = os.WriteFile(downFile, nil, 0644)
-		if err != nil {
-			return err
+		file := path.Join(filePath, fmt.Sprintf("%05d_%s_%s.%s", data.Version, data.Name, step.direction, tmpl.Ext))
+		if err := writeFile(file, content, fileMode); err != nil {
+			return created, err
 		}
-		logger.Info(downFile + " created")
-	case "go":
-		upFile := path.Join(filePath, fmt.Sprintf("%05d_%s_up.go", version, name))
-		upContent := fmt.Sprintf(`package main
+		logger.Info(file + " created")
+		created = append(created, file)
+	}
+	return created, nil
+}
 
-import (
-	"context"
-	"github.com/juliazadorozhnaya/sql-migrator/storage"
-)
+func getMigrations(filePath string, convention Convention, logger logger.Logger) (map[int]*storage.Migration, error) {
+	if isRemotePath(filePath) {
+		fsys, err := newRemoteFS(filePath)
+		if err != nil {
+			return nil, err
+		}
+		return readMigrationDir(fsys, "", filePath, convention, logger)
+	}
 
-func Up(ctx context.Context) error {
-	db, ok := ctx.Value("db").(*storage.SqlStorage)
-	if !ok {
-		return fmt.Errorf("could not get database connection from context")
+	info, err := os.Stat(filePath)
+	if err != nil {
+		return nil, err
 	}
 
-	sql := "
-		CREATE TABLE IF NOT EXISTS users (
-		id SERIAL PRIMARY KEY,
-		username VARCHAR(255) NOT NULL,
-		email VARCHAR(255) NOT NULL UNIQUE,
-		created_at TIMESTAMP NOT NULL DEFAULT NOW()
-		);"
+	if !info.IsDir() {
+		dir, fileName := path.Split(filePath)
+		if dir == "" {
+			dir = "."
+		}
 
-	if err := db.Migrate(ctx, sql); err != nil {
-		return fmt.Errorf("could not execute migration: %v", err)
+		migrations := make(map[int]*storage.Migration)
+		if err := addMigrationFile(migrations, convention, os.DirFS(dir), dir, fileName); err != nil {
+			return nil, err
+		}
+		return migrations, nil
 	}
 
-	fmt.Println("Migration Up applied: users table created")
-	return nil
+	return readMigrationDir(os.DirFS(filePath), filePath, filePath, convention, logger)
+}
+
+// readMigrationDir читает миграции директории и предупреждает, если в ней нет ни одной миграции
+func readMigrationDir(fsys fs.FS, localDir, source string, convention Convention, logger logger.Logger) (map[int]*storage.Migration, error) {
+	migrations, err := readMigrations(fsys, localDir, convention, logger)
+	if err == nil && len(migrations) == 0 {
+		logger.Warn("No migrations found in %s", source)
+	}
+	return migrations, err
 }
-`)
-		err := os.WriteFile(upFile, []byte(upContent), 0644)
+
+// readMigrations читает миграции из корня fsys. localDir — путь к нему на диске,
+// для удаленных источников он пустой и Go-миграции не допускаются.
+func readMigrations(fsys fs.FS, localDir string, convention Convention, logger logger.Logger) (map[int]*storage.Migration, error) {
+	files, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(files))
+	for _, file := range files {
+		names[file.Name()] = true
+	}
+
+	migrations := make(map[int]*storage.Migration)
+	seen := make(map[int]migrationFile)
+	for _, file := range files {
+		if file.IsDir() || strings.HasPrefix(file.Name(), ".") || !convention.isCandidate(file.Name()) {
+			logger.Debug("Skipping non-migration file %s", file.Name())
+			continue
+		}
+		// Рядом с собранным плагином исходник не запускается через go run: шаг загружается из .so
+		if strings.HasSuffix(file.Name(), ".go") && names[pluginName(file.Name())] {
+			logger.Debug("Skipping go source %s of plugin %s", file.Name(), pluginName(file.Name()))
+			continue
+		}
+
+		parsed, err := convention.parse(file.Name())
 		if err != nil {
-			return err
+			return nil, err
+		}
+		if previous, ok := seen[parsed.version]; ok {
+			if err := checkSameMigration(previous, parsed); err != nil {
+				return nil, fmt.Errorf("%s: %w", file.Name(), err)
+			}
 		}
-		logger.Info(upFile + " created")
+		seen[parsed.version] = parsed
 
-		downFile := path.Join(filePath, fmt.Sprintf("%05d_%s_down.go", version, name))
-		downContent := fmt.Sprintf(`package main
+		if err := addMigrationFile(migrations, convention, fsys, localDir, file.Name()); err != nil {
+			return nil, err
+		}
+	}
 
-import (
-	"context"
-	"github.com/juliazadorozhnaya/sql-migrator/storage"
-)
+	return migrations, nil
+}
 
-func Down(ctx context.Context) error {
-	db, ok := ctx.Value("db").(*storage.SqlStorage)
-	if !ok {
-		return fmt.Errorf("could not get database connection from context")
+// singleFileVersion возвращает версию миграции, если путь указывает на отдельный файл, а не на директорию.
+// Файл, имя которого не подходит под схему именования, — ошибка, а не повод читать путь как директорию.
+func (app *Application) singleFileVersion(filePath string) (int, bool, error) {
+	info, err := os.Stat(filePath)
+	if err != nil || info.IsDir() {
+		return 0, false, nil
 	}
 
-	sql := "DROP TABLE IF EXISTS users;""
-
-	if err := db.Migrate(ctx, sql); err != nil {
-		return fmt.Errorf("could not execute migration: %v", err)
+	file, err := app.convention.parse(path.Base(filePath))
+	if err != nil {
+		return 0, false, fmt.Errorf("%w: %s does not match the %s file naming convention", ErrInvalidMigrationName, path.Base(filePath), app.convention.Name)
 	}
 
-	fmt.Println("Migration Down applied: users table dropped")
-	return nil
+	return file.version, true, nil
 }
-`)
-		err = os.WriteFile(downFile, []byte(downContent), 0644)
-		if err != nil {
-			return err
+
+func addMigrationFile(migrations map[int]*storage.Migration, convention Convention, fsys fs.FS, localDir, fileName string) error {
+	file, err := convention.parse(fileName)
+	if err != nil {
+		return err
+	}
+
+	migration, ok := migrations[file.version]
+	if !ok {
+		migration = &storage.Migration{
+			Version: file.version,
+			Name:    file.name,
 		}
-		logger.Info(downFile + " created")
+	}
+
+	switch {
+	case (file.ext == "so" || file.ext == "go") && localDir == "":
+		err = fmt.Errorf("%s: %w", fileName, ErrRemoteGoMigration)
+	case file.ext == "so":
+		err = addPluginMigrationFile(migration, file, path.Join(localDir, fileName))
+	case file.ext == "go":
+		addGoMigrationFile(migration, file, localDir, fileName)
 	default:
-		return errors.New("unsupported migration type")
+		err = addSQLMigrationFile(migration, file, fsys, fileName)
 	}
+	if err != nil {
+		return err
+	}
+
+	migrations[file.version] = migration
 	return nil
 }
 
-func getMigrations(filePath string) (map[int]*storage.Migration, error) {
-	files, err := os.ReadDir(filePath)
-	if err != nil {
-		return nil, err
+// checkSameMigration проверяет, что файл дополняет миграцию той же версии, а не конфликтует с ней:
+// у нее то же имя и тот же вид шагов. Иначе поля миграции перезаписывались бы в зависимости от порядка файлов.
+func checkSameMigration(seen, file migrationFile) error {
+	if seen.name != file.name {
+		return fmt.Errorf("%w: version %d is %s", ErrVersionConflict, file.version, seen.name)
 	}
 
-	migrations := make(map[int]*storage.Migration)
+	if isGoFile(seen) != isGoFile(file) {
+		return fmt.Errorf("%w: version %d", ErrMixedMigration, file.version)
+	}
 
-	for _, file := range files {
-		strVersion := regGetVersion.FindString(file.Name())
+	if seen.direction == directionSeed || file.direction == directionSeed {
+		if seen.direction == file.direction {
+			return fmt.Errorf("%w: version %d has several seed files", ErrVersionConflict, file.version)
+		}
+		return nil
+	}
 
-		if strVersion != "" {
-			version, err := strconv.Atoi(strVersion)
-			if err != nil {
-				return nil, err
-			}
+	if seen.direction == file.direction || seen.direction == "" || file.direction == "" {
+		return fmt.Errorf("%w: version %d has several files for the same step", ErrVersionConflict, file.version)
+	}
 
-			parts := strings.Split(file.Name(), "_")
-			if len(parts) != 3 {
-				return nil, ErrInvalidMigrationName
-			}
+	return nil
+}
 
-			sql, err := os.ReadFile(path.Join(filePath, file.Name()))
-			if err != nil {
-				return nil, err
-			}
+func isGoFile(file migrationFile) bool {
+	return file.ext == "go" || file.ext == "so"
+}
 
-			if regGetUpMigration.MatchString(file.Name()) {
-				if _, ok := migrations[version]; ok {
-					migrations[version].Up = string(sql)
-				} else {
-					migrations[version] = &storage.Migration{
-						Version: version,
-						Name:    parts[1],
-						Up:      string(sql),
-					}
-				}
-			} else if regGetDownMigration.MatchString(file.Name()) {
-				if _, ok := migrations[version]; ok {
-					migrations[version].Down = string(sql)
-				} else {
-					migrations[version] = &storage.Migration{
-						Version: version,
-						Name:    parts[1],
-						Down:    string(sql),
-					}
-				}
-			} else if regGetUpGoMigration.MatchString(file.Name()) {
-				if _, ok := migrations[version]; ok {
-					migrations[version].UpGo = func(ctx context.Context) error {
-						return runGoMigration(filePath, file.Name())
-					}
-				} else {
-					migrations[version] = &storage.Migration{
-						Version: version,
-						Name:    parts[1],
-						UpGo: func(ctx context.Context) error {
-							return runGoMigration(filePath, file.Name())
-						},
-					}
-				}
-			} else if regGetDownGoMigration.MatchString(file.Name()) {
-				if _, ok := migrations[version]; ok {
-					migrations[version].DownGo = func(ctx context.Context) error {
-						return runGoMigration(filePath, file.Name())
-					}
-				} else {
-					migrations[version] = &storage.Migration{
-						Version: version,
-						Name:    parts[1],
-						DownGo: func(ctx context.Context) error {
-							return runGoMigration(filePath, file.Name())
-						},
-					}
-				}
-			} else {
-				return nil, ErrInvalidMigrationName
-			}
-		}
+func addPluginMigrationFile(migration *storage.Migration, file migrationFile, filePath string) error {
+	var err error
+	if file.direction == directionDown {
+		migration.DownGo, err = loadPluginMigration(filePath, "Down")
+	} else {
+		migration.UpGo, err = loadPluginMigration(filePath, "Up")
 	}
 
-	return migrations, nil
+	return err
 }
 
-func runGoMigration(filePath, fileName string) error {
-	cmd := exec.Command("go", "run", path.Join(filePath, fileName))
+func addGoMigrationFile(migration *storage.Migration, file migrationFile, filePath, fileName string) {
+	run := func(ctx context.Context) error {
+		return runGoMigration(ctx, filePath, fileName)
+	}
+
+	if file.direction == directionDown {
+		migration.DownGo = run
+	} else {
+		migration.UpGo = run
+	}
+}
+
+// runGoMigration запускает исходник Go-миграции через go run. Отдельный процесс не видит хранилище мигратора,
+// поэтому подключение передается ему в storage.DSNEnv и storage.TableEnv, см. storage.RunFromEnv.
+func runGoMigration(ctx context.Context, filePath, fileName string) error {
+	cmd := exec.CommandContext(ctx, "go", "run", path.Join(filePath, fileName))
+	cmd.Env = append(os.Environ(), goRunEnv(ctx)...)
 	cmd.Stdout = os.Stdout
 	cmd.Stderr = os.Stderr
 	return cmd.Run()
 }
+
+// goRunEnv возвращает переменные окружения с подключением хранилища, которое мигратор передал шагу в ctx
+func goRunEnv(ctx context.Context) []string {
+	db, err := storage.FromContext(ctx)
+	if err != nil {
+		return nil
+	}
+
+	env := []string{storage.TableEnv + "=" + db.TableName()}
+	if conn, ok := db.(interface{ ConnString() string }); ok {
+		env = append(env, storage.DSNEnv+"="+conn.ConnString())
+	}
+	return env
+}
+
+// pluginName возвращает имя плагина, который собирается из исходника Go-миграции fileName
+func pluginName(fileName string) string {
+	return strings.TrimSuffix(fileName, ".go") + ".so"
+}
+
+func addSQLMigrationFile(migration *storage.Migration, file migrationFile, fsys fs.FS, fileName string) error {
+	sql, err := fs.ReadFile(fsys, fileName)
+	if err != nil {
+		return err
+	}
+
+	switch file.direction {
+	case directionUp:
+		migration.Up = string(sql)
+	case directionDown:
+		migration.Down = string(sql)
+	case directionSeed:
+		migration.Seed = string(sql)
+		return nil
+	default:
+		migration.Up, migration.Down, err = splitMarkedSQL(string(sql))
+		if err != nil {
+			return fmt.Errorf("%s: %w", fileName, err)
+		}
+	}
+
+	// Метаданные читаются из up-файла или из общего файла с обоими шагами
+	if file.direction != directionDown {
+		if err := applyMetadata(migration, string(sql)); err != nil {
+			return fmt.Errorf("%s: %w", fileName, err)
+		}
+	}
+
+	return nil
+}
+
+// loadPluginMigration загружает шаг миграции из плагина, собранного с -buildmode=plugin.
+// Пакет plugin поддерживается только на Linux (и macOS/FreeBSD) при включенном cgo,
+// плагин должен быть собран той же версией Go, что и мигратор.
+func loadPluginMigration(filePath, symbolName string) (func(ctx context.Context) error, error) {
+	p, err := plugin.Open(filePath)
+	if err != nil {
+		return nil, fmt.Errorf("open plugin %s: %w", filePath, err)
+	}
+
+	symbol, err := p.Lookup(symbolName)
+	if err != nil {
+		return nil, fmt.Errorf("lookup %s in plugin %s: %w", symbolName, filePath, err)
+	}
+
+	switch fn := symbol.(type) {
+	case func(context.Context) error:
+		return fn, nil
+	case *func(context.Context) error:
+		return *fn, nil
+	default:
+		return nil, fmt.Errorf("%s in plugin %s: %w", symbolName, filePath, ErrInvalidPluginSymbol)
+	}
+}
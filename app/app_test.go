@@ -2,11 +2,15 @@ package app
 
 import (
 	"context"
-	"fmt"
+	"encoding/json"
 	"os"
+	"os/exec"
+	"path"
 	"testing"
+	"time"
 
 	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/processes"
 	"github.com/juliazadorozhnaya/sql-migrator/storage"
 	"github.com/stretchr/testify/assert"
 )
@@ -18,16 +22,15 @@ func TestCreateMigrationFiles(t *testing.T) {
 
 	migrationDir := "../migrations"
 	migrationName := "create_users"
+	os.MkdirAll(migrationDir, os.ModePerm)
 
-	app.Create(migrationName, migrationDir, "sql")
-
-	upFile := fmt.Sprintf("%s/00001_%s_up.sql", migrationDir, migrationName)
-	downFile := fmt.Sprintf("%s/00001_%s_down.sql", migrationDir, migrationName)
-	assert.FileExists(t, upFile, "Expected Up migration file to be created")
-	assert.FileExists(t, downFile, "Expected Down migration file to be created")
-
-	os.Remove(upFile)
-	os.Remove(downFile)
+	files, err := app.Create(context.Background(), migrationName, migrationDir, "sql")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{path.Join(migrationDir, "00001_create_users_up.sql"), path.Join(migrationDir, "00001_create_users_down.sql")}, files)
+	for _, file := range files {
+		assert.FileExists(t, file, "Expected migration file to be created")
+		os.Remove(file)
+	}
 }
 
 func TestUpMigration(t *testing.T) {
@@ -37,16 +40,19 @@ func TestUpMigration(t *testing.T) {
 
 	migrationDir := "../migrations"
 	migrationName := "create_users"
+	os.MkdirAll(migrationDir, os.ModePerm)
 
-	app.Create(migrationName, migrationDir, "sql")
-	app.Up(migrationDir)
+	files, _ := app.Create(context.Background(), migrationName, migrationDir, "sql")
+	app.Up(context.Background(), migrationDir)
 
 	migrations, _ := mockStorage.SelectMigrations(context.Background())
 	assert.Equal(t, 1, len(migrations), "Expected one migration")
 	assert.Equal(t, "create_users", migrations[0].GetName(), "Expected migration name to be 'create_users'")
+	assert.Equal(t, storage.StatusSuccess, migrations[0].GetStatus(), "Expected migration to be applied")
 
-	os.Remove(fmt.Sprintf("%s/00001_%s_up.sql", migrationDir, migrationName))
-	os.Remove(fmt.Sprintf("%s/00001_%s_down.sql", migrationDir, migrationName))
+	for _, file := range files {
+		os.Remove(file)
+	}
 }
 
 func TestDownMigration(t *testing.T) {
@@ -56,14 +62,434 @@ func TestDownMigration(t *testing.T) {
 
 	migrationDir := "../migrations"
 	migrationName := "create_users"
+	os.MkdirAll(migrationDir, os.ModePerm)
 
-	app.Create(migrationName, migrationDir, "sql")
-	app.Down(migrationDir)
+	files, _ := app.Create(context.Background(), migrationName, migrationDir, "sql")
+	app.Up(context.Background(), migrationDir)
+	app.Down(context.Background(), migrationDir)
 
 	migrations, _ := mockStorage.SelectMigrations(context.Background())
 	assert.Equal(t, 1, len(migrations), "Expected one migration")
 	assert.Equal(t, "create_users", migrations[0].GetName(), "Expected migration name to be 'create_users'")
+	assert.Equal(t, storage.StatusCancel, migrations[0].GetStatus(), "Expected migration to be rolled back")
+
+	for _, file := range files {
+		os.Remove(file)
+	}
+}
+
+func TestUpSingleMigrationFile(t *testing.T) {
+	logger := logger.New()
+	mockStorage := &storage.MockSqlStorage{}
+	app := New(logger, mockStorage)
+
+	migrationFile := path.Join(t.TempDir(), "00012_hotfix_up.sql")
+	err := os.WriteFile(migrationFile, []byte("UPDATE users SET email = lower(email);"), 0644)
+	assert.NoError(t, err)
+
+	app.Up(context.Background(), migrationFile)
+
+	migrations, _ := mockStorage.SelectMigrations(context.Background())
+	assert.Equal(t, 1, len(migrations), "Expected one migration")
+	assert.Equal(t, 12, migrations[0].GetVersion(), "Expected version to be taken from the file name")
+	assert.Equal(t, "hotfix", migrations[0].GetName())
+	assert.Equal(t, storage.StatusSuccess, migrations[0].GetStatus())
+	assert.Equal(t, []string{"UPDATE users SET email = lower(email);"}, mockStorage.Executed())
+}
+
+func TestDownSingleMigrationFile(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	app := New(logger.New(), mockStorage)
+
+	migrationDir := t.TempDir()
+	writeMigrations(t, migrationDir, "00012_hotfix_up.sql", "00012_hotfix_down.sql")
+	upFile, downFile := path.Join(migrationDir, "00012_hotfix_up.sql"), path.Join(migrationDir, "00012_hotfix_down.sql")
+
+	assert.ErrorIs(t, app.Down(ctx, downFile), processes.ErrNotApplied, "Expected a pending migration not to be rolled back")
+	assert.Empty(t, mockStorage.Executed())
+
+	assert.NoError(t, app.Up(ctx, upFile))
+	assert.ErrorIs(t, app.Down(ctx, upFile), processes.ErrNoDownStep, "Expected an up-only file to be rejected instead of recording cancel")
+
+	assert.NoError(t, app.Down(ctx, downFile))
+	migration, err := mockStorage.SelectMigrationByVersion(ctx, 12)
+	assert.NoError(t, err)
+	assert.Equal(t, storage.StatusCancel, migration.GetStatus())
+	assert.Len(t, mockStorage.Executed(), 2)
+}
+
+func TestSingleFileWithInvalidNameIsRejected(t *testing.T) {
+	migrationFile := path.Join(t.TempDir(), "hotfix.sql")
+	assert.NoError(t, os.WriteFile(migrationFile, []byte("SELECT 1;"), 0644))
+
+	mockStorage := &storage.MockSqlStorage{}
+	app := New(logger.New(), mockStorage)
+	for name, run := range map[string]func(context.Context, string) error{"up": app.Up, "down": app.Down, "redo": app.Redo} {
+		err := run(context.Background(), migrationFile)
+		assert.ErrorIs(t, err, ErrInvalidMigrationName, name)
+		assert.ErrorContains(t, err, "hotfix.sql does not match the default file naming convention", name)
+	}
+	assert.Empty(t, mockStorage.Executed())
+}
+
+func TestGetMigrationsRejectsInvalidFileName(t *testing.T) {
+	migrationFile := path.Join(t.TempDir(), "hotfix.sql")
+	err := os.WriteFile(migrationFile, []byte("SELECT 1;"), 0644)
+	assert.NoError(t, err)
+
+	_, err = getMigrations(migrationFile, DefaultConvention, logger.New())
+	assert.ErrorIs(t, err, ErrInvalidMigrationName)
+}
+
+func TestGetMigrationsSkipsNonMigrationFiles(t *testing.T) {
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"00001_create_users_up.sql":     "CREATE TABLE users (id SERIAL PRIMARY KEY);",
+		"00001_create_users_down.sql":   "DROP TABLE users;",
+		"00001_create_users_up.sql.bak": "CREATE TABLE old_users (id SERIAL PRIMARY KEY);",
+		"00001_notes.md":                "# notes",
+		"README.md":                     "# migrations",
+		".00002_hidden_up.sql":          "SELECT 1;",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644))
+	}
+
+	migrations, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(migrations), "Expected only the real migration to be loaded")
+	assert.Equal(t, "create_users", migrations[1].Name)
+	assert.Equal(t, "CREATE TABLE users (id SERIAL PRIMARY KEY);", migrations[1].Up)
+	assert.Equal(t, "DROP TABLE users;", migrations[1].Down)
+}
+
+func TestGetMigrationsRejectsMalformedMigration(t *testing.T) {
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_up.sql"), []byte("SELECT 1;"), 0644))
+
+	_, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.ErrorIs(t, err, ErrInvalidMigrationName)
+}
+
+func TestCreateInExistingDirectory(t *testing.T) {
+	migrationDir := t.TempDir()
+	app := New(logger.New(), &storage.MockSqlStorage{})
+
+	_, err := app.Create(context.Background(), "create_users", migrationDir, "sql")
+	assert.NoError(t, err)
+	assert.FileExists(t, path.Join(migrationDir, "00001_create_users_up.sql"))
+	assert.FileExists(t, path.Join(migrationDir, "00001_create_users_down.sql"))
+}
+
+func TestCreateMissingDirectory(t *testing.T) {
+	migrationDir := path.Join(t.TempDir(), "db", "migrations")
+	app := New(logger.New(), &storage.MockSqlStorage{})
+
+	_, err := app.Create(context.Background(), "create_users", migrationDir, "sql")
+	assert.ErrorIs(t, err, ErrDirNotExist)
+	assert.NoDirExists(t, migrationDir)
+}
+
+func TestCreateMakesMissingDirectory(t *testing.T) {
+	migrationDir := path.Join(t.TempDir(), "db", "migrations")
+	app := New(logger.New(), &storage.MockSqlStorage{}, WithMkdir(true))
+
+	_, err := app.Create(context.Background(), "create_users", migrationDir, "sql")
+	assert.NoError(t, err)
+	assert.DirExists(t, migrationDir)
+	assert.FileExists(t, path.Join(migrationDir, "00001_create_users_up.sql"))
+}
+
+func TestLintReportsErrors(t *testing.T) {
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_up.sql"), []byte("CREATE TABLE users (id SERIAL PRIMARY KEY);"), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_down.sql"), []byte("DROP TABLE users;"), 0644))
+
+	app := New(logger.New(), &storage.MockSqlStorage{})
+	assert.NoError(t, app.Lint(context.Background(), migrationDir), "Expected warnings not to fail lint")
+
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00002_cleanup_up.sql"), []byte("TRUNCATE users;"), 0644))
+	assert.ErrorIs(t, app.Lint(context.Background(), migrationDir), ErrLintFailed)
+}
+
+func TestEmptyDirectory(t *testing.T) {
+	ctx := context.Background()
+	migrationDir := t.TempDir()
+	mockStorage := &storage.MockSqlStorage{}
+	app := New(logger.New(), mockStorage)
+
+	assert.NoError(t, app.Up(ctx, migrationDir), "Expected up on an empty directory to do nothing")
+	assert.Empty(t, mockStorage.Executed())
+
+	mockStorage.InsertMigration(ctx, storage.NewMigration("create_users", storage.StatusSuccess, 1, time.Now()))
+	assert.ErrorIs(t, app.Down(ctx, migrationDir), processes.ErrNoMigrations)
+	assert.ErrorIs(t, app.Redo(ctx, migrationDir), processes.ErrNoMigrations)
+}
+
+func TestSquashReplacesAppliedFiles(t *testing.T) {
+	defer func(dump func(context.Context, string, ...string) (string, error)) { dumpSchema = dump }(dumpSchema)
+	dumpSchema = func(ctx context.Context, dsn string, excludeTables ...string) (string, error) {
+		return "CREATE TABLE users (id integer);\nCREATE TABLE posts (id integer);\n", nil
+	}
+
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"00001_create_users_up.sql":   "CREATE TABLE users (id integer);",
+		"00001_create_users_down.sql": "DROP TABLE users;",
+		"00002_create_posts_up.sql":   "CREATE TABLE posts (id integer);",
+		"00003_add_tags_up.sql":       "CREATE TABLE tags (id integer);",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644))
+	}
+
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	mockStorage.InsertMigration(ctx, storage.NewMigration("create_users", storage.StatusSuccess, 1, time.Now()))
+	mockStorage.InsertMigration(ctx, storage.NewMigration("create_posts", storage.StatusSuccess, 2, time.Now()))
+
+	app := New(logger.New(), mockStorage)
+	assert.NoError(t, app.Squash(ctx, migrationDir, "postgres://localhost/db"))
+
+	assert.FileExists(t, path.Join(migrationDir, squashedDir, "00001_create_users_down.sql"))
+	assert.FileExists(t, path.Join(migrationDir, squashedDir, "00002_create_posts_up.sql"))
+	assert.FileExists(t, path.Join(migrationDir, "00002_add_tags_up.sql"), "Expected later migrations to follow the baseline version")
+	assert.NoFileExists(t, path.Join(migrationDir, "00003_add_tags_up.sql"))
+
+	migrations, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(migrations))
+	assert.Contains(t, migrations[1].Up, "CREATE TABLE posts")
+	assert.Contains(t, migrations[1].Down, processes.IrreversibleMarker)
+	assert.Equal(t, "add_tags", migrations[2].Name)
+
+	assert.NoError(t, app.Up(ctx, migrationDir))
+	assert.Equal(t, []string{"CREATE TABLE tags (id integer);"}, mockStorage.Executed(), "Expected only the migration after the baseline to run")
+}
+
+func TestExpandEnv(t *testing.T) {
+	t.Setenv("TABLESPACE", "fast_ssd")
+
+	sql := "CREATE TABLE users (id integer) TABLESPACE ${TABLESPACE}; -- ${MISSING}\n" +
+		"INSERT INTO notes VALUES ('${TABLESPACE}');\n" +
+		"CREATE FUNCTION f(integer) RETURNS text AS $$ SELECT '${X}' || $1 $$ LANGUAGE sql;"
+	expanded, err := expandEnv(sql)
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE TABLE users (id integer) TABLESPACE fast_ssd; -- ${MISSING}\n"+
+		"INSERT INTO notes VALUES ('${TABLESPACE}');\n"+
+		"CREATE FUNCTION f(integer) RETURNS text AS $$ SELECT '${X}' || $1 $$ LANGUAGE sql;", expanded)
+
+	_, err = expandEnv("GRANT SELECT ON users TO ${MIGRATOR_UNSET_ROLE};")
+	assert.ErrorIs(t, err, ErrUnsetVariable)
+}
+
+func TestUpExpandsEnvOnlyWhenEnabled(t *testing.T) {
+	t.Setenv("TABLESPACE", "fast_ssd")
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_up.sql"), []byte("CREATE TABLE users (id integer) TABLESPACE ${TABLESPACE};"), 0644))
+
+	mockStorage := &storage.MockSqlStorage{}
+	assert.NoError(t, New(logger.New(), mockStorage).Up(context.Background(), migrationDir))
+	assert.Equal(t, []string{"CREATE TABLE users (id integer) TABLESPACE ${TABLESPACE};"}, mockStorage.Executed(), "Expected expansion to be off by default")
+
+	mockStorage = &storage.MockSqlStorage{}
+	assert.NoError(t, New(logger.New(), mockStorage, WithExpandEnv(true)).Up(context.Background(), migrationDir))
+	assert.Equal(t, []string{"CREATE TABLE users (id integer) TABLESPACE fast_ssd;"}, mockStorage.Executed())
+}
+
+func TestUpExpandsEnvInSeeds(t *testing.T) {
+	t.Setenv("ADMIN_EMAIL", "admin@example.com")
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_up.sql"), []byte("CREATE TABLE users (email text);"), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_seed.sql"), []byte("INSERT INTO users VALUES (${ADMIN_EMAIL});"), 0644))
+
+	mockStorage := &storage.MockSqlStorage{}
+	assert.NoError(t, New(logger.New(), mockStorage, WithExpandEnv(true), WithSeeds(true)).Up(context.Background(), migrationDir))
+	assert.Equal(t, []string{"CREATE TABLE users (email text);", "INSERT INTO users VALUES (admin@example.com);"}, mockStorage.Executed())
+}
+
+func TestStatusToFileWritesJSON(t *testing.T) {
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_up.sql"), []byte("CREATE TABLE users (id integer);"), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00002_create_posts_up.sql"), []byte("CREATE TABLE posts (id integer);"), 0644))
+
+	mockStorage := &storage.MockSqlStorage{}
+	mockStorage.InsertMigration(context.Background(), storage.NewMigration("create_users", storage.StatusSuccess, 1, time.Now()))
+	app := New(logger.New(), mockStorage)
+
+	out := path.Join(t.TempDir(), "reports", "status.json")
+	assert.NoError(t, app.StatusToFile(context.Background(), migrationDir, out, processes.StatusOptions{JSON: true}))
+
+	content, err := os.ReadFile(out)
+	assert.NoError(t, err)
+
+	var entries []map[string]interface{}
+	assert.NoError(t, json.Unmarshal(content, &entries))
+	assert.Equal(t, 1, len(entries))
+	assert.Equal(t, "create_users", entries[0]["name"])
+	assert.Equal(t, storage.StatusSuccess, entries[0]["status"])
+	assert.Equal(t, float64(1), entries[0]["version"])
+
+	files, _ := os.ReadDir(path.Dir(out))
+	assert.Equal(t, 1, len(files), "Expected no temporary files to be left behind")
+}
+
+func TestStatusToFileUsesConfiguredModes(t *testing.T) {
+	app := New(logger.New(), &storage.MockSqlStorage{}, WithFileMode(0640), WithDirMode(0750))
+
+	out := path.Join(t.TempDir(), "reports", "status.json")
+	assert.NoError(t, app.StatusToFile(context.Background(), t.TempDir(), out, processes.StatusOptions{JSON: true}))
+
+	info, err := os.Stat(path.Dir(out))
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+
+	info, err = os.Stat(out)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0640), info.Mode().Perm())
+}
+
+func TestStatusToFileReportsWriteErrors(t *testing.T) {
+	blocker := path.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(blocker, nil, 0644))
+
+	app := New(logger.New(), &storage.MockSqlStorage{})
+	assert.Error(t, app.StatusToFile(context.Background(), t.TempDir(), path.Join(blocker, "status.json"), processes.StatusOptions{}))
+}
+
+func TestGetMigrationsRejectsConflictingVersions(t *testing.T) {
+	cases := map[string]struct {
+		files    []string
+		expected error
+	}{
+		"different names":  {[]string{"00003_x_up.sql", "00003_y_up.go"}, ErrVersionConflict},
+		"sql and go":       {[]string{"00003_x_up.sql", "00003_x_down.go"}, ErrMixedMigration},
+		"same step twice":  {[]string{"00003_x_up.sql", "00003_x.sql"}, ErrVersionConflict},
+		"go before sql":    {[]string{"00003_x_down.go", "00003_x_down.sql"}, ErrMixedMigration},
+		"sql up and down":  {[]string{"00003_x_up.sql", "00003_x_down.sql"}, nil},
+		"distinct version": {[]string{"00003_x_up.sql", "00004_y_up.sql"}, nil},
+	}
+
+	for name, c := range cases {
+		migrationDir := t.TempDir()
+		for _, file := range c.files {
+			assert.NoError(t, os.WriteFile(path.Join(migrationDir, file), []byte("-- +migrate Up\nSELECT 1;"), 0644))
+		}
+
+		_, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+		if c.expected == nil {
+			assert.NoError(t, err, name)
+		} else {
+			assert.ErrorIs(t, err, c.expected, name)
+		}
+	}
+}
+
+const testGoRunSource = `package main
+
+import "os"
+
+func main() {
+	if os.Getenv("MIGRATOR_TABLE") != "schema_migrations" {
+		os.Exit(1)
+	}
+}
+`
+
+func TestGoSourceRunsThroughGoRun(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain is not available")
+	}
+
+	migrationDir := t.TempDir()
+	writeMigrations(t, migrationDir, "00001_create_users_up.sql")
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00002_add_email_up.go"), []byte(testGoRunSource), 0644))
+
+	migrations, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.NotNil(t, migrations[2].UpGo, "Expected a go source without a plugin to run through go run")
+	assert.Nil(t, migrations[2].DownGo)
+
+	ctx := storage.NewContext(context.Background(), &storage.MockSqlStorage{})
+	assert.NoError(t, migrations[2].UpGo(ctx), "Expected the table name to be passed in the environment")
+	assert.Error(t, migrations[2].UpGo(context.Background()), "Expected no connection env without a storage in ctx")
+}
+
+func TestCreateUsesConfiguredModes(t *testing.T) {
+	migrationDir := path.Join(t.TempDir(), "migrations")
+	app := New(logger.New(), &storage.MockSqlStorage{}, WithMkdir(true), WithFileMode(0664), WithDirMode(0750))
+
+	_, err := app.Create(context.Background(), "create_users", migrationDir, "sql")
+	assert.NoError(t, err)
+
+	info, err := os.Stat(migrationDir)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0750), info.Mode().Perm())
+
+	for _, name := range []string{"00001_create_users_up.sql", "00001_create_users_down.sql"} {
+		info, err := os.Stat(path.Join(migrationDir, name))
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0664), info.Mode().Perm(), name)
+	}
+}
+
+func TestParseFileMode(t *testing.T) {
+	mode, err := ParseFileMode("", DefaultFileMode)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0644), mode)
+
+	mode, err = ParseFileMode("0664", DefaultFileMode)
+	assert.NoError(t, err)
+	assert.Equal(t, os.FileMode(0664), mode)
+
+	for _, value := range []string{"rw-r--r--", "0999", "01777"} {
+		_, err = ParseFileMode(value, DefaultFileMode)
+		assert.ErrorIs(t, err, ErrInvalidFileMode, value)
+	}
+}
+
+func TestCancelledContextStopsCommands(t *testing.T) {
+	migrationDir := t.TempDir()
+	os.WriteFile(path.Join(migrationDir, "00001_create_users_up.sql"), []byte("CREATE TABLE users (id SERIAL PRIMARY KEY);"), 0644)
+	mockStorage := &storage.MockSqlStorage{}
+	app := New(logger.New(), mockStorage)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	assert.ErrorIs(t, app.Up(ctx, migrationDir), context.Canceled)
+	assert.ErrorIs(t, app.History(ctx), context.Canceled)
+	assert.Empty(t, mockStorage.Executed(), "Expected nothing to run with a cancelled context")
+
+	_, err := mockStorage.SelectMigrations(context.Background())
+	assert.ErrorIs(t, err, storage.ErrMigrationNotFound)
+}
+
+func TestGappedVersionsAreRejected(t *testing.T) {
+	ctx := context.Background()
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"00001_create_users_up.sql":    "CREATE TABLE users (id SERIAL PRIMARY KEY);",
+		"00001_create_users_down.sql":  "DROP TABLE users;",
+		"00002_create_orders_up.sql":   "CREATE TABLE orders (id SERIAL PRIMARY KEY);",
+		"00002_create_orders_down.sql": "DROP TABLE orders;",
+		"00005_add_email_up.sql":       "ALTER TABLE users ADD COLUMN email TEXT;",
+		"00005_add_email_down.sql":     "ALTER TABLE users DROP COLUMN email;",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644))
+	}
+
+	mockStorage := &storage.MockSqlStorage{}
+	app := New(logger.New(), mockStorage)
+	err := app.Up(ctx, migrationDir)
+	assert.ErrorIs(t, err, ErrVersionGap)
+	assert.ErrorContains(t, err, "expected version 3, found 5 add_email")
+	assert.Empty(t, mockStorage.Executed(), "Expected no migration to run when versions have a gap")
 
-	os.Remove(fmt.Sprintf("%s/00001_%s_up.sql", migrationDir, migrationName))
-	os.Remove(fmt.Sprintf("%s/00001_%s_down.sql", migrationDir, migrationName))
+	_, err = mockStorage.SelectMigrations(ctx)
+	assert.ErrorIs(t, err, storage.ErrMigrationNotFound)
 }
@@ -0,0 +1,202 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const (
+	directionUp   = "up"
+	directionDown = "down"
+	// directionSeed — файл с тестовыми данными, который применяется после up только с WithSeeds
+	directionSeed = "seed"
+)
+
+var (
+	ErrUnknownConvention = errors.New("unknown migration file convention")
+	ErrMissingUpSection  = errors.New("migration file has no up section")
+)
+
+// Convention описывает схему именования файлов миграций: по какому шаблону из имени
+// берутся версия, имя и направление. Файлы, подходящие под candidate, но не под pattern, считаются ошибкой.
+type Convention struct {
+	Name       string
+	candidate  *regexp.Regexp
+	pattern    *regexp.Regexp
+	directions map[string]string
+}
+
+var (
+	// DefaultConvention — 00001_name_up.sql / 00001_name_down.sql, а также Go-файлы и плагины.
+	// Один файл 00001_name.sql содержит оба шага, разделенные комментариями -- +migrate Up и -- +migrate Down.
+	// 00001_name_seed.sql — необязательные данные для окружений разработки.
+	DefaultConvention = Convention{
+		Name:       "default",
+		candidate:  regexp.MustCompile(`^(.+_(up|down|seed)\.(sql|go|so)|\d+_.+\.sql)$`),
+		pattern:    regexp.MustCompile(`^(?P<version>\d+)_(?P<name>.+?)(?:_(?P<direction>up|down|seed))?\.(?P<ext>sql|go|so)$`),
+		directions: map[string]string{"up": directionUp, "down": directionDown, "seed": directionSeed},
+	}
+
+	// FlywayConvention — V1__name.sql для наката и U1__name.sql для отката
+	FlywayConvention = Convention{
+		Name:       "flyway",
+		candidate:  regexp.MustCompile(`^[VU].*\.sql$`),
+		pattern:    regexp.MustCompile(`^(?P<direction>[VU])(?P<version>\d+)__(?P<name>.+)\.(?P<ext>sql)$`),
+		directions: map[string]string{"V": directionUp, "U": directionDown},
+	}
+
+	// GooseConvention — один файл 00001_name.sql, шаги разделены комментариями -- +goose Up и -- +goose Down
+	GooseConvention = Convention{
+		Name:      "goose",
+		candidate: regexp.MustCompile(`^\d.*\.sql$`),
+		pattern:   regexp.MustCompile(`^(?P<version>\d+)_(?P<name>.+)\.(?P<ext>sql)$`),
+	}
+
+	conventions = []Convention{DefaultConvention, FlywayConvention, GooseConvention}
+)
+
+// ConventionByName возвращает схему именования по имени из конфигурации; пустое имя — схема по умолчанию
+func ConventionByName(name string) (Convention, error) {
+	if name == "" {
+		return DefaultConvention, nil
+	}
+
+	for _, convention := range conventions {
+		if strings.EqualFold(convention.Name, name) {
+			return convention, nil
+		}
+	}
+
+	return Convention{}, fmt.Errorf("%w: %s", ErrUnknownConvention, name)
+}
+
+// migrationFile — сведения о миграции, извлеченные из имени файла
+type migrationFile struct {
+	version   int
+	name      string
+	direction string // пустое направление — файл содержит оба шага
+	ext       string
+}
+
+// isCandidate проверяет, что имя файла похоже на файл миграции этой схемы
+func (c Convention) isCandidate(fileName string) bool {
+	return c.candidate.MatchString(fileName)
+}
+
+func (c Convention) parse(fileName string) (migrationFile, error) {
+	match := c.pattern.FindStringSubmatch(fileName)
+	if match == nil {
+		return migrationFile{}, ErrInvalidMigrationName
+	}
+
+	var file migrationFile
+	for i, group := range c.pattern.SubexpNames() {
+		switch group {
+		case "version":
+			version, err := strconv.Atoi(match[i])
+			if err != nil {
+				return migrationFile{}, err
+			}
+			file.version = version
+		case "name":
+			file.name = match[i]
+		case "direction":
+			file.direction = c.directions[match[i]]
+		case "ext":
+			file.ext = match[i]
+		}
+	}
+
+	// 00001_up.sql — up-файл без имени, а не общий файл с именем "up"
+	if file.direction == "" && c.directions[file.name] != "" {
+		return migrationFile{}, ErrInvalidMigrationName
+	}
+
+	return file, nil
+}
+
+// rename возвращает имя файла миграции, в котором имя миграции заменено на name, а версия и направление сохранены
+func (c Convention) rename(fileName, name string) (string, error) {
+	match := c.pattern.FindStringSubmatchIndex(fileName)
+	if match == nil {
+		return "", ErrInvalidMigrationName
+	}
+
+	group := c.pattern.SubexpIndex("name")
+	return fileName[:match[2*group]] + name + fileName[match[2*group+1]:], nil
+}
+
+// renumber возвращает имя файла миграции с версией version; ширина номера с ведущими нулями сохраняется
+func (c Convention) renumber(fileName string, version int) (string, error) {
+	match := c.pattern.FindStringSubmatchIndex(fileName)
+	if match == nil {
+		return "", ErrInvalidMigrationName
+	}
+
+	group := c.pattern.SubexpIndex("version")
+	start, end := match[2*group], match[2*group+1]
+	return fileName[:start] + fmt.Sprintf("%0*d", end-start, version) + fileName[end:], nil
+}
+
+// files возвращает имена и содержимое файлов миграции с обоими шагами в формате этой схемы
+func (c Convention) files(version int, name, up, down string) map[string]string {
+	switch c.Name {
+	case FlywayConvention.Name:
+		return map[string]string{
+			fmt.Sprintf("V%d__%s.sql", version, name): up,
+			fmt.Sprintf("U%d__%s.sql", version, name): down,
+		}
+	case GooseConvention.Name:
+		return map[string]string{
+			fmt.Sprintf("%05d_%s.sql", version, name): "-- +goose Up\n" + up + "\n-- +goose Down\n" + down,
+		}
+	default:
+		return map[string]string{
+			fmt.Sprintf("%05d_%s_up.sql", version, name):   up,
+			fmt.Sprintf("%05d_%s_down.sql", version, name): down,
+		}
+	}
+}
+
+// splitMarkedSQL делит файл с обоими шагами на up- и down-части по аннотациям
+// goose (-- +goose Up) или sql-migrate (-- +migrate Up)
+func splitMarkedSQL(content string) (string, string, error) {
+	var up, down strings.Builder
+	var current *strings.Builder
+	hasUp := false
+
+	scanner := bufio.NewScanner(strings.NewReader(content))
+	scanner.Buffer(make([]byte, 0, 64*1024), len(content)+1)
+	for scanner.Scan() {
+		line := scanner.Text()
+		switch strings.TrimSpace(line) {
+		case "-- +goose Up", "-- +migrate Up":
+			current = &up
+			hasUp = true
+			continue
+		case "-- +goose Down", "-- +migrate Down":
+			current = &down
+			continue
+		case "-- +goose StatementBegin", "-- +goose StatementEnd", "-- +migrate StatementBegin", "-- +migrate StatementEnd":
+			continue
+		}
+
+		if current != nil {
+			current.WriteString(line)
+			current.WriteString("\n")
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", "", err
+	}
+
+	if !hasUp {
+		return "", "", ErrMissingUpSection
+	}
+
+	return strings.TrimSpace(up.String()), strings.TrimSpace(down.String()), nil
+}
@@ -0,0 +1,104 @@
+package app
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMigrationsFlywayConvention(t *testing.T) {
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"V1__create_users.sql": "CREATE TABLE users (id SERIAL PRIMARY KEY);",
+		"U1__create_users.sql": "DROP TABLE users;",
+		"V2__add_email.sql":    "ALTER TABLE users ADD COLUMN email TEXT;",
+		"00003_ignored_up.sql": "SELECT 1;",
+		"README.md":            "# migrations",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644))
+	}
+
+	migrations, err := getMigrations(migrationDir, FlywayConvention, logger.New())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(migrations))
+	assert.Equal(t, "create_users", migrations[1].Name)
+	assert.Equal(t, "CREATE TABLE users (id SERIAL PRIMARY KEY);", migrations[1].Up)
+	assert.Equal(t, "DROP TABLE users;", migrations[1].Down)
+	assert.Equal(t, "add_email", migrations[2].Name)
+	assert.Equal(t, "ALTER TABLE users ADD COLUMN email TEXT;", migrations[2].Up)
+	assert.Empty(t, migrations[2].Down)
+}
+
+func TestGetMigrationsFlywayRejectsMalformedName(t *testing.T) {
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "V1_create_users.sql"), []byte("SELECT 1;"), 0644))
+
+	_, err := getMigrations(migrationDir, FlywayConvention, logger.New())
+	assert.ErrorIs(t, err, ErrInvalidMigrationName)
+}
+
+func TestGetMigrationsGooseConvention(t *testing.T) {
+	migrationDir := t.TempDir()
+	content := `-- +goose Up
+-- +goose StatementBegin
+CREATE TABLE users (id SERIAL PRIMARY KEY);
+-- +goose StatementEnd
+
+-- +goose Down
+DROP TABLE users;
+`
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users.sql"), []byte(content), 0644))
+
+	migrations, err := getMigrations(migrationDir, GooseConvention, logger.New())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(migrations))
+	assert.Equal(t, "create_users", migrations[1].Name)
+	assert.Equal(t, "CREATE TABLE users (id SERIAL PRIMARY KEY);", migrations[1].Up)
+	assert.Equal(t, "DROP TABLE users;", migrations[1].Down)
+}
+
+func TestGetMigrationsGooseRequiresUpSection(t *testing.T) {
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users.sql"), []byte("CREATE TABLE users ();"), 0644))
+
+	_, err := getMigrations(migrationDir, GooseConvention, logger.New())
+	assert.ErrorIs(t, err, ErrMissingUpSection)
+}
+
+func TestGetMigrationsDefaultConventionSingleFile(t *testing.T) {
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"00001_create_users_up.sql":   "CREATE TABLE users (id SERIAL PRIMARY KEY);",
+		"00001_create_users_down.sql": "DROP TABLE users;",
+		"00002_create_posts.sql":      "-- +migrate Up\nCREATE TABLE posts (id SERIAL PRIMARY KEY);\n\n-- +migrate Down\nDROP TABLE posts;\n",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644))
+	}
+
+	migrations, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(migrations))
+	assert.Equal(t, "create_users", migrations[1].Name)
+	assert.Equal(t, "DROP TABLE users;", migrations[1].Down)
+	assert.Equal(t, "create_posts", migrations[2].Name)
+	assert.Equal(t, "CREATE TABLE posts (id SERIAL PRIMARY KEY);", migrations[2].Up)
+	assert.Equal(t, "DROP TABLE posts;", migrations[2].Down)
+}
+
+func TestConventionByName(t *testing.T) {
+	convention, err := ConventionByName("")
+	assert.NoError(t, err)
+	assert.Equal(t, DefaultConvention.Name, convention.Name)
+
+	convention, err = ConventionByName("Flyway")
+	assert.NoError(t, err)
+	assert.Equal(t, FlywayConvention.Name, convention.Name)
+
+	_, err = ConventionByName("liquibase")
+	assert.ErrorIs(t, err, ErrUnknownConvention)
+}
@@ -0,0 +1,73 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"time"
+)
+
+// CreateLockFile — файл в каталоге миграций, который держит Create, пока выбирает версию и пишет файлы
+const CreateLockFile = ".migrator-create.lock"
+
+const (
+	// createLockTimeout — сколько Create ждет освобождения файла блокировки
+	createLockTimeout = 30 * time.Second
+	// createLockStale — возраст, после которого файл блокировки считается оставленным упавшим процессом
+	createLockStale = time.Minute
+	createLockPoll  = 50 * time.Millisecond
+)
+
+var ErrCreateLocked = errors.New("another create holds the lock file in the migrations directory")
+
+// WithCreateLock сериализует Create через файл блокировки в каталоге миграций, чтобы одновременные Create
+// в общем каталоге (например, на сетевом диске) получили разные версии. Файл создается с O_EXCL,
+// который, в отличие от flock, работает и на сетевых файловых системах.
+func WithCreateLock(createLock bool) Option {
+	return func(app *Application) {
+		app.createLock = createLock
+	}
+}
+
+// lockCreate берет файл блокировки каталога и возвращает функцию, которая его освобождает.
+// Без WithCreateLock ничего не делает.
+func (app *Application) lockCreate(ctx context.Context, filePath string) (func(), error) {
+	if !app.createLock {
+		return func() {}, nil
+	}
+
+	lockFile := path.Join(filePath, CreateLockFile)
+	ctx, cancel := context.WithTimeout(ctx, createLockTimeout)
+	defer cancel()
+
+	for {
+		file, err := os.OpenFile(lockFile, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+		if err == nil {
+			hostname, _ := os.Hostname()
+			fmt.Fprintf(file, "%s %d\n", hostname, os.Getpid())
+			file.Close()
+			return func() {
+				if err := os.Remove(lockFile); err != nil {
+					app.logger.Warn("Failed to remove %s: %v", lockFile, err)
+				}
+			}, nil
+		}
+		if !errors.Is(err, os.ErrExist) {
+			return nil, err
+		}
+
+		if info, statErr := os.Stat(lockFile); statErr == nil && time.Since(info.ModTime()) > createLockStale {
+			app.logger.Warn("Removing stale %s left since %s", lockFile, info.ModTime().Format(time.RFC3339))
+			os.Remove(lockFile)
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil, fmt.Errorf("%w: %s", ErrCreateLocked, lockFile)
+		case <-time.After(createLockPoll):
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConcurrentCreateGetsDistinctVersions(t *testing.T) {
+	migrationDir := t.TempDir()
+
+	const creates = 4
+	var (
+		wg      sync.WaitGroup
+		mu      sync.Mutex
+		created []string
+	)
+	for i := 0; i < creates; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			app := New(logger.NewNop(), &storage.MockSqlStorage{}, WithCreateLock(true))
+			files, err := app.Create(context.Background(), "add_column", migrationDir, "sql")
+			assert.NoError(t, err)
+
+			mu.Lock()
+			created = append(created, files...)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	versions := make(map[string]bool)
+	for _, file := range created {
+		versions[regGetVersion.FindString(path.Base(file))] = true
+	}
+	assert.Equal(t, map[string]bool{"00001": true, "00002": true, "00003": true, "00004": true}, versions)
+	assert.Len(t, created, 2*creates)
+	assert.NoFileExists(t, path.Join(migrationDir, CreateLockFile), "Expected the lock file to be removed")
+}
+
+func TestCreateRemovesStaleLock(t *testing.T) {
+	migrationDir := t.TempDir()
+	lockFile := path.Join(migrationDir, CreateLockFile)
+	assert.NoError(t, os.WriteFile(lockFile, []byte("crashed 1\n"), 0644))
+	stale := time.Now().Add(-2 * createLockStale)
+	assert.NoError(t, os.Chtimes(lockFile, stale, stale))
+
+	app := New(logger.NewNop(), &storage.MockSqlStorage{}, WithCreateLock(true))
+	_, err := app.Create(context.Background(), "add_column", migrationDir, "sql")
+	assert.NoError(t, err)
+	assert.NoFileExists(t, lockFile)
+}
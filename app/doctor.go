@@ -0,0 +1,154 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+var ErrDoctorFailed = errors.New("some doctor checks failed")
+
+// Названия проверок doctor
+const (
+	CheckConfig        = "config parses"
+	CheckDirReadable   = "migrations directory is readable"
+	CheckDirWritable   = "migrations directory is writable"
+	CheckReachable     = "database is reachable"
+	CheckCreateTable   = "migrations table can be created"
+	CheckLock          = "advisory lock can be acquired and released"
+	CheckServerVersion = "server version can be read"
+)
+
+// DoctorCheck — результат одной проверки doctor. Пропущенная проверка не выполнялась, потому что не прошла та,
+// от которой она зависит.
+type DoctorCheck struct {
+	Name    string
+	Err     error
+	Skipped bool
+	Hint    string
+}
+
+// Doctor проверяет типичные причины неудачного запуска: доступ к каталогу миграций, подключение к базе,
+// создание таблицы учета, блокировку и чтение версии сервера, и пишет в w список с подсказками.
+// prior — проверки, уже выполненные вызывающим, например разбор конфига; они выводятся первыми.
+// Если хотя бы одна проверка не прошла, возвращается ErrDoctorFailed.
+func (app *Application) Doctor(ctx context.Context, filePath string, w io.Writer, prior ...DoctorCheck) error {
+	checks := append(prior, app.checkDirectory(filePath)...)
+	checks = append(checks, app.checkDatabase(ctx)...)
+
+	if err := WriteDoctorReport(w, checks); err != nil {
+		app.logger.Error("Error in Doctor: %v", err)
+		return err
+	}
+
+	for _, check := range checks {
+		if check.Err != nil {
+			return ErrDoctorFailed
+		}
+	}
+	return nil
+}
+
+func (app *Application) checkDirectory(filePath string) []DoctorCheck {
+	if isRemotePath(filePath) {
+		return []DoctorCheck{{Name: CheckDirReadable, Skipped: true, Hint: "remote sources are read when a command runs"}}
+	}
+
+	readable := DoctorCheck{Name: CheckDirReadable}
+	if _, err := os.ReadDir(filePath); err != nil {
+		readable.Err = err
+		readable.Hint = "create the directory with -mkdir or fix -path or dir in the config"
+		return []DoctorCheck{readable, {Name: CheckDirWritable, Skipped: true}}
+	}
+
+	writable := DoctorCheck{Name: CheckDirWritable}
+	file, err := os.CreateTemp(filePath, ".doctor-*")
+	if err != nil {
+		writable.Err = err
+		writable.Hint = "create needs write access to the directory; other commands only read it"
+	} else {
+		file.Close()
+		os.Remove(file.Name())
+	}
+
+	return []DoctorCheck{readable, writable}
+}
+
+// checkDatabase проверяет базу по шагам: Connect подключается и создает таблицу учета, поэтому
+// ошибки подключения отделяются от ошибок создания таблицы по виду
+func (app *Application) checkDatabase(ctx context.Context) []DoctorCheck {
+	reachable := DoctorCheck{Name: CheckReachable}
+	createTable := DoctorCheck{Name: CheckCreateTable}
+	lock := DoctorCheck{Name: CheckLock}
+	serverVersion := DoctorCheck{Name: CheckServerVersion}
+
+	connectCtx, cancel := context.WithTimeout(ctx, connectTimeout)
+	err := app.sqlStorage.Connect(connectCtx)
+	cancel()
+
+	switch {
+	case err == nil:
+		defer app.sqlStorage.Close()
+	case isConnectError(err):
+		reachable.Err = err
+		reachable.Hint = "check host, port, credentials and database name in -dsn or dsn in the config"
+		createTable.Skipped, lock.Skipped, serverVersion.Skipped = true, true, true
+		return []DoctorCheck{reachable, createTable, lock, serverVersion}
+	default:
+		createTable.Err = err
+		createTable.Hint = fmt.Sprintf("grant the role CREATE on the schema of %s or create the table in advance", app.sqlStorage.TableName())
+		lock.Skipped, serverVersion.Skipped = true, true
+		return []DoctorCheck{reachable, createTable, lock, serverVersion}
+	}
+
+	if err := app.sqlStorage.Lock(ctx); err != nil {
+		lock.Err = err
+		lock.Hint = "wait for the other migrator to finish or use -wait-for-lock; a permission error means the role may not call pg_advisory_lock"
+	} else if err := app.sqlStorage.Unlock(ctx); err != nil {
+		lock.Err = err
+		lock.Hint = "the lock is released when the connection closes, but check the server log"
+	}
+
+	if _, err := app.sqlStorage.ServerVersion(ctx); err != nil {
+		serverVersion.Err = err
+		serverVersion.Hint = "migrations with a min-server header cannot run until server_version_num can be read"
+	}
+
+	return []DoctorCheck{reachable, createTable, lock, serverVersion}
+}
+
+// isConnectError проверяет, что Connect не дошел до сервера или тот отказал в подключении
+func isConnectError(err error) bool {
+	return errors.Is(err, storage.ErrHostUnreachable) ||
+		errors.Is(err, storage.ErrAuthFailed) ||
+		errors.Is(err, storage.ErrDatabaseNotExist) ||
+		errors.Is(err, storage.ErrInvalidConnString) ||
+		errors.Is(err, context.DeadlineExceeded)
+}
+
+// WriteDoctorReport пишет результаты проверок по строке на каждую, с подсказкой под непройденной
+func WriteDoctorReport(w io.Writer, checks []DoctorCheck) error {
+	for _, check := range checks {
+		var line string
+		switch {
+		case check.Skipped:
+			line = "[skip] " + check.Name
+		case check.Err != nil:
+			line = fmt.Sprintf("[fail] %s: %v", check.Name, check.Err)
+		default:
+			line = "[ok]   " + check.Name
+		}
+		if check.Hint != "" && (check.Err != nil || check.Skipped) {
+			line += "\n       hint: " + check.Hint
+		}
+
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
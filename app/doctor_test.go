@@ -0,0 +1,144 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// faultyStorage возвращает заданные ошибки из Connect, Lock и ServerVersion
+type faultyStorage struct {
+	storage.MockSqlStorage
+	connectErr error
+	lockErr    error
+	versionErr error
+}
+
+func (s *faultyStorage) Connect(ctx context.Context) error {
+	return s.connectErr
+}
+
+func (s *faultyStorage) Lock(ctx context.Context) error {
+	return s.lockErr
+}
+
+func (s *faultyStorage) ServerVersion(ctx context.Context) (int, error) {
+	return 0, s.versionErr
+}
+
+// doctorResults возвращает результаты проверок по названию: ok, fail или skip
+func doctorResults(t *testing.T, app *Application, dir string) (map[string]string, error) {
+	var output bytes.Buffer
+	err := app.Doctor(context.Background(), dir, &output, DoctorCheck{Name: CheckConfig})
+
+	results := make(map[string]string)
+	for _, line := range strings.Split(output.String(), "\n") {
+		if !strings.HasPrefix(line, "[") {
+			continue
+		}
+		result, name, _ := strings.Cut(strings.TrimPrefix(line, "["), "]")
+		name, _, _ = strings.Cut(strings.TrimSpace(name), ":")
+		results[name] = result
+	}
+	return results, err
+}
+
+func TestDoctorAllChecksPass(t *testing.T) {
+	results, err := doctorResults(t, New(logger.NewNop(), &faultyStorage{}), t.TempDir())
+	assert.NoError(t, err)
+	assert.Equal(t, map[string]string{
+		CheckConfig:        "ok",
+		CheckDirReadable:   "ok",
+		CheckDirWritable:   "ok",
+		CheckReachable:     "ok",
+		CheckCreateTable:   "ok",
+		CheckLock:          "ok",
+		CheckServerVersion: "ok",
+	}, results)
+}
+
+func TestDoctorReportsFailures(t *testing.T) {
+	missingDir := path.Join(t.TempDir(), "missing")
+	errDenied := errors.New("permission denied for schema public")
+
+	tests := []struct {
+		name     string
+		storage  *faultyStorage
+		dir      string
+		expected map[string]string
+	}{
+		{
+			name:     "missing directory",
+			storage:  &faultyStorage{},
+			dir:      missingDir,
+			expected: map[string]string{CheckDirReadable: "fail", CheckDirWritable: "skip", CheckReachable: "ok"},
+		},
+		{
+			name:     "unreachable database",
+			storage:  &faultyStorage{connectErr: storage.ErrHostUnreachable},
+			expected: map[string]string{CheckReachable: "fail", CheckCreateTable: "skip", CheckLock: "skip", CheckServerVersion: "skip"},
+		},
+		{
+			name:     "no create permission",
+			storage:  &faultyStorage{connectErr: errDenied},
+			expected: map[string]string{CheckReachable: "ok", CheckCreateTable: "fail", CheckLock: "skip"},
+		},
+		{
+			name:     "lock denied",
+			storage:  &faultyStorage{lockErr: errDenied},
+			expected: map[string]string{CheckCreateTable: "ok", CheckLock: "fail", CheckServerVersion: "ok"},
+		},
+		{
+			name:     "server version unreadable",
+			storage:  &faultyStorage{versionErr: errDenied},
+			expected: map[string]string{CheckLock: "ok", CheckServerVersion: "fail"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := tt.dir
+			if dir == "" {
+				dir = t.TempDir()
+			}
+
+			results, err := doctorResults(t, New(logger.NewNop(), tt.storage), dir)
+			assert.ErrorIs(t, err, ErrDoctorFailed)
+			for name, result := range tt.expected {
+				assert.Equal(t, result, results[name], name)
+			}
+		})
+	}
+}
+
+func TestDoctorReadOnlyDirectory(t *testing.T) {
+	if os.Geteuid() == 0 {
+		t.Skip("root can write to read-only directories")
+	}
+
+	dir := t.TempDir()
+	assert.NoError(t, os.Chmod(dir, 0555))
+	defer os.Chmod(dir, 0755)
+
+	results, err := doctorResults(t, New(logger.NewNop(), &faultyStorage{}), dir)
+	assert.ErrorIs(t, err, ErrDoctorFailed)
+	assert.Equal(t, "ok", results[CheckDirReadable])
+	assert.Equal(t, "fail", results[CheckDirWritable])
+}
+
+func TestWriteDoctorReportHints(t *testing.T) {
+	var output bytes.Buffer
+	assert.NoError(t, WriteDoctorReport(&output, []DoctorCheck{
+		{Name: CheckConfig},
+		{Name: CheckReachable, Err: storage.ErrAuthFailed, Hint: "check credentials"},
+	}))
+	assert.Equal(t, "[ok]   "+CheckConfig+"\n[fail] "+CheckReachable+": "+storage.ErrAuthFailed.Error()+"\n       hint: check credentials\n", output.String())
+}
@@ -0,0 +1,56 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+const (
+	ErrorFormatText = "text"
+	ErrorFormatJSON = "json"
+)
+
+var ErrUnknownErrorFormat = errors.New("unknown error format")
+
+// MigrationError — ошибка команды, упавшей на конкретной версии миграции
+type MigrationError struct {
+	Version int
+	Err     error
+}
+
+func (e *MigrationError) Error() string {
+	return fmt.Sprintf("migration version %d: %v", e.Version, e.Err)
+}
+
+func (e *MigrationError) Unwrap() error {
+	return e.Err
+}
+
+// commandError — структура ошибки для вывода в формате json
+type commandError struct {
+	Error   string `json:"error"`
+	Command string `json:"command"`
+	Version int    `json:"version,omitempty"`
+}
+
+// WriteError выводит ошибку команды в формате text или json
+func WriteError(w io.Writer, format, command string, err error) error {
+	switch format {
+	case "", ErrorFormatText:
+		_, writeErr := fmt.Fprintf(w, "Command %s failed: %v\n", command, err)
+		return writeErr
+	case ErrorFormatJSON:
+		output := commandError{Error: err.Error(), Command: command}
+
+		var migrationErr *MigrationError
+		if errors.As(err, &migrationErr) {
+			output.Version = migrationErr.Version
+		}
+
+		return json.NewEncoder(w).Encode(output)
+	default:
+		return fmt.Errorf("%w: %s", ErrUnknownErrorFormat, format)
+	}
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/processes"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteErrorJSON(t *testing.T) {
+	var buf bytes.Buffer
+	err := &MigrationError{Version: 5, Err: errors.New("syntax error")}
+
+	assert.NoError(t, WriteError(&buf, ErrorFormatJSON, "up", err))
+
+	var output map[string]interface{}
+	assert.NoError(t, json.Unmarshal(buf.Bytes(), &output))
+	assert.Equal(t, map[string]interface{}{
+		"error":   "migration version 5: syntax error",
+		"command": "up",
+		"version": float64(5),
+	}, output)
+}
+
+func TestWriteErrorJSONWithoutVersion(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.NoError(t, WriteError(&buf, ErrorFormatJSON, "status", errors.New("connection refused")))
+	assert.JSONEq(t, `{"error":"connection refused","command":"status"}`, buf.String())
+}
+
+func TestWriteErrorText(t *testing.T) {
+	var buf bytes.Buffer
+
+	assert.NoError(t, WriteError(&buf, ErrorFormatText, "up", errors.New("connection refused")))
+	assert.Equal(t, "Command up failed: connection refused\n", buf.String())
+	assert.ErrorIs(t, WriteError(&buf, "xml", "up", errors.New("connection refused")), ErrUnknownErrorFormat)
+}
+
+func TestFailedMigrationReturnsError(t *testing.T) {
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_backfill_up.sql"), []byte("SELECT 1;"), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_backfill_down.sql"), []byte(processes.IrreversibleMarker), 0644))
+
+	app := New(logger.New(), &storage.MockSqlStorage{})
+	assert.NoError(t, app.Up(context.Background(), migrationDir))
+
+	err := app.Down(context.Background(), migrationDir)
+	assert.ErrorIs(t, err, processes.ErrIrreversibleMigration)
+
+	var migrationErr *MigrationError
+	assert.True(t, errors.As(err, &migrationErr))
+	assert.Equal(t, 1, migrationErr.Version)
+}
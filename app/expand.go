@@ -0,0 +1,65 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrUnsetVariable = errors.New("environment variable referenced in migration is not set")
+
+	regEnvReference = regexp.MustCompile(`^\$\{([A-Za-z_][A-Za-z0-9_]*)\}`)
+	regDollarTag    = regexp.MustCompile(`^\$([A-Za-z_][A-Za-z0-9_]*)?\$`)
+)
+
+// expandEnv подставляет переменные окружения вида ${VAR} в SQL миграции.
+// Строки в кавычках, комментарии и $$-блоки не меняются, а форма $VAR не поддерживается,
+// чтобы не задеть параметры $1 и тела функций. Незаданная переменная — ошибка, а не пустая строка.
+func expandEnv(sql string) (string, error) {
+	var result strings.Builder
+
+	for i := 0; i < len(sql); {
+		rest := sql[i:]
+		var literal string
+
+		switch {
+		case strings.HasPrefix(rest, "--"):
+			literal = untilAfter(rest, 2, "\n")
+		case strings.HasPrefix(rest, "/*"):
+			literal = untilAfter(rest, 2, "*/")
+		case rest[0] == '\'':
+			literal = untilAfter(rest, 1, "'")
+		case regEnvReference.MatchString(rest):
+			match := regEnvReference.FindStringSubmatch(rest)
+			value, ok := os.LookupEnv(match[1])
+			if !ok {
+				return "", fmt.Errorf("%w: %s", ErrUnsetVariable, match[1])
+			}
+			result.WriteString(value)
+			i += len(match[0])
+			continue
+		case regDollarTag.MatchString(rest):
+			tag := regDollarTag.FindString(rest)
+			literal = untilAfter(rest, len(tag), tag)
+		default:
+			literal = rest[:1]
+		}
+
+		result.WriteString(literal)
+		i += len(literal)
+	}
+
+	return result.String(), nil
+}
+
+// untilAfter возвращает префикс s до конца первого вхождения end, которое ищется после from байт
+func untilAfter(s string, from int, end string) string {
+	idx := strings.Index(s[from:], end)
+	if idx < 0 {
+		return s
+	}
+	return s[:from+idx+len(end)]
+}
@@ -0,0 +1,181 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/juliazadorozhnaya/sql-migrator/processes"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+var (
+	ErrFormatConvention   = errors.New("fmt supports only the default file convention")
+	ErrRecordedNameChange = errors.New("rename would change the name recorded for an applied migration")
+
+	// regLooseMigration — имя файла в схеме по умолчанию без учета регистра и ширины версии: 1_x_UP.sql
+	regLooseMigration = regexp.MustCompile(`(?i)^(\d+)_(.+?)(?:_(up|down|seed))?\.(sql|go|so)$`)
+)
+
+// Rename — переименование файла миграции, которое выполняет или предлагает Format
+type Rename struct {
+	From string
+	To   string
+}
+
+// Format приводит имена файлов миграций к каноническому виду схемы по умолчанию: версия из пяти цифр,
+// имя, направление и расширение в нижнем регистре. Имена примененных миграций записаны в базе, поэтому
+// если переименование изменит записанное имя, Format ничего не переименовывает и возвращает ErrRecordedNameChange.
+// С dryRun только возвращает и печатает план.
+func (app *Application) Format(ctx context.Context, filePath string, dryRun bool) ([]Rename, error) {
+	if isRemotePath(filePath) {
+		app.logger.Error("Cannot format migrations in %s", filePath)
+		return nil, ErrRemoteSource
+	}
+	if app.convention.Name != DefaultConvention.Name {
+		app.logger.Error("Error in Format: %v", ErrFormatConvention)
+		return nil, ErrFormatConvention
+	}
+
+	renames, names, err := planRenames(filePath)
+	if err != nil {
+		app.logger.Error("Error in Format: %v", err)
+		return nil, err
+	}
+
+	err = app.runSingleCommand(ctx, func(migrator *processes.Migrator, ctx context.Context) error {
+		return app.checkRecordedNames(ctx, names)
+	})
+	if err != nil {
+		app.logger.Error("Error in Format: %v", err)
+		return nil, err
+	}
+
+	for _, rename := range renames {
+		if dryRun {
+			app.logger.Info("Would rename %s to %s", rename.From, rename.To)
+			continue
+		}
+
+		if err := os.Rename(path.Join(filePath, rename.From), path.Join(filePath, rename.To)); err != nil {
+			app.logger.Error("Error in Format: %v", err)
+			return nil, err
+		}
+		app.logger.Info("Renamed %s to %s", rename.From, rename.To)
+	}
+
+	if len(renames) == 0 {
+		app.logger.Info("All migration file names are canonical")
+	}
+
+	return renames, nil
+}
+
+// planRenames возвращает переименования и новые имена миграций по версиям в файлах.
+// Два файла, которые станут одним, и занятые целевые имена считаются ошибкой.
+func planRenames(filePath string) ([]Rename, map[int]string, error) {
+	entries, err := os.ReadDir(filePath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	existing := make(map[string]bool, len(entries))
+	for _, entry := range entries {
+		existing[entry.Name()] = true
+	}
+
+	var renames []Rename
+	names := make(map[int]string)
+	targets := make(map[string]string)
+	for _, entry := range entries {
+		if entry.IsDir() || strings.HasPrefix(entry.Name(), ".") {
+			continue
+		}
+
+		canonical, version, name, ok := canonicalName(entry.Name())
+		if !ok {
+			continue
+		}
+
+		if previous, ok := names[version]; ok && previous != name {
+			return nil, nil, fmt.Errorf("%w: version %d is used by %s and %s", ErrVersionConflict, version, previous, name)
+		}
+		names[version] = name
+
+		if other, ok := targets[canonical]; ok {
+			return nil, nil, fmt.Errorf("%w: %s and %s both become %s", ErrVersionConflict, other, entry.Name(), canonical)
+		}
+		targets[canonical] = entry.Name()
+
+		if canonical == entry.Name() {
+			continue
+		}
+		if existing[canonical] {
+			return nil, nil, fmt.Errorf("%w: %s", os.ErrExist, canonical)
+		}
+		renames = append(renames, Rename{From: entry.Name(), To: canonical})
+	}
+
+	sort.Slice(renames, func(i, j int) bool { return renames[i].From < renames[j].From })
+	return renames, names, nil
+}
+
+// canonicalName возвращает каноническое имя файла миграции, ее версию и имя
+func canonicalName(fileName string) (string, int, string, bool) {
+	match := regLooseMigration.FindStringSubmatch(fileName)
+	if match == nil {
+		return "", 0, "", false
+	}
+
+	var version int
+	if _, err := fmt.Sscan(match[1], &version); err != nil {
+		return "", 0, "", false
+	}
+
+	name := strings.ToLower(match[2])
+	canonical := fmt.Sprintf("%05d_%s", version, name)
+	if match[3] != "" {
+		canonical += "_" + strings.ToLower(match[3])
+	}
+	return canonical + "." + strings.ToLower(match[4]), version, name, true
+}
+
+// checkRecordedNames сверяет новые имена с записанными в базе. Мигратор нумерует миграции подряд,
+// поэтому строка учета n-й по порядку версии — это версия n.
+func (app *Application) checkRecordedNames(ctx context.Context, names map[int]string) error {
+	recorded, err := app.sqlStorage.SelectMigrations(ctx)
+	if errors.Is(err, storage.ErrMigrationNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	versions := make([]int, 0, len(names))
+	for version := range names {
+		versions = append(versions, version)
+	}
+	sort.Ints(versions)
+
+	var conflicts []string
+	for _, migration := range recorded {
+		index := migration.GetVersion() - 1
+		if index < 0 || index >= len(versions) {
+			continue
+		}
+		if name := names[versions[index]]; name != migration.GetName() {
+			conflicts = append(conflicts, fmt.Sprintf("version %d %s -> %s", versions[index], migration.GetName(), name))
+		}
+	}
+
+	if len(conflicts) > 0 {
+		sort.Strings(conflicts)
+		return fmt.Errorf("%w: %s", ErrRecordedNameChange, strings.Join(conflicts, ", "))
+	}
+	return nil
+}
@@ -0,0 +1,79 @@
+package app
+
+import (
+	"context"
+	"path"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormatRenamesToCanonicalNames(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigrations(t, migrationDir, "1_Create_Users_UP.sql", "1_Create_Users_DOWN.sql", "00002_add_email_up.sql", "3_Seed_Data.SQL", "notes.txt")
+
+	app := New(logger.NewNop(), &storage.MockSqlStorage{})
+
+	renames, err := app.Format(context.Background(), migrationDir, true)
+	assert.NoError(t, err)
+	expected := []Rename{
+		{From: "1_Create_Users_DOWN.sql", To: "00001_create_users_down.sql"},
+		{From: "1_Create_Users_UP.sql", To: "00001_create_users_up.sql"},
+		{From: "3_Seed_Data.SQL", To: "00003_seed_data.sql"},
+	}
+	assert.Equal(t, expected, renames)
+	assert.FileExists(t, path.Join(migrationDir, "1_Create_Users_UP.sql"), "Expected dry run not to rename files")
+
+	renames, err = app.Format(context.Background(), migrationDir, false)
+	assert.NoError(t, err)
+	assert.Equal(t, expected, renames)
+	for _, rename := range renames {
+		assert.NoFileExists(t, path.Join(migrationDir, rename.From))
+		assert.FileExists(t, path.Join(migrationDir, rename.To))
+	}
+	assert.FileExists(t, path.Join(migrationDir, "notes.txt"))
+
+	renames, err = app.Format(context.Background(), migrationDir, false)
+	assert.NoError(t, err)
+	assert.Empty(t, renames, "Expected canonical names to stay as they are")
+}
+
+func TestFormatRefusesRecordedNameChange(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigrations(t, migrationDir, "1_create_users_up.sql", "2_Add_Email_up.sql")
+
+	app := New(logger.NewNop(), &storage.MockSqlStorage{})
+	assert.NoError(t, app.Up(context.Background(), migrationDir))
+
+	renames, err := app.Format(context.Background(), migrationDir, false)
+	assert.ErrorIs(t, err, ErrRecordedNameChange)
+	assert.Empty(t, renames)
+	assert.FileExists(t, path.Join(migrationDir, "1_create_users_up.sql"), "Expected no file to be renamed")
+	assert.FileExists(t, path.Join(migrationDir, "2_Add_Email_up.sql"))
+
+}
+
+func TestFormatPadsAppliedAndRenamesPending(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigrations(t, migrationDir, "1_create_users_up.sql", "2_Add_Email_up.sql")
+
+	app := New(logger.NewNop(), &storage.MockSqlStorage{})
+	assert.NoError(t, app.UpN(context.Background(), migrationDir, 1))
+
+	renames, err := app.Format(context.Background(), migrationDir, false)
+	assert.NoError(t, err, "Expected padding the version to keep the recorded name")
+	assert.Equal(t, []Rename{
+		{From: "1_create_users_up.sql", To: "00001_create_users_up.sql"},
+		{From: "2_Add_Email_up.sql", To: "00002_add_email_up.sql"},
+	}, renames)
+}
+
+func TestFormatConflictingFiles(t *testing.T) {
+	migrationDir := t.TempDir()
+	writeMigrations(t, migrationDir, "1_create_users_up.sql", "00001_create_users_up.sql")
+
+	_, err := New(logger.NewNop(), &storage.MockSqlStorage{}).Format(context.Background(), migrationDir, true)
+	assert.ErrorIs(t, err, ErrVersionConflict)
+}
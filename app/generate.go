@@ -0,0 +1,81 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/juliazadorozhnaya/sql-migrator/processes"
+	"github.com/juliazadorozhnaya/sql-migrator/schema"
+)
+
+// GenerateFromSchema сравнивает таблицы и колонки из schemaFile со схемой базы и пишет в dir
+// черновик миграции, приводящей базу к файлу. Миграция не применяется: ее нужно проверить вручную.
+func (app *Application) GenerateFromSchema(ctx context.Context, schemaFile, dir, name string) error {
+	if isRemotePath(dir) {
+		app.logger.Error("Cannot create migrations in %s", dir)
+		return ErrRemoteSource
+	}
+
+	if err := app.ensureDir(dir); err != nil {
+		app.logger.Error("Failed to prepare directory: %v", err)
+		return err
+	}
+
+	content, err := os.ReadFile(schemaFile)
+	if err != nil {
+		app.logger.Error("Failed to read schema file: %v", err)
+		return err
+	}
+
+	tables, err := schema.Parse(string(content))
+	if err != nil {
+		app.logger.Error("Failed to parse schema file: %v", err)
+		return err
+	}
+
+	var plan schema.Plan
+	err = app.runSingleCommand(ctx, func(migrator *processes.Migrator, ctx context.Context) error {
+		columns, err := app.sqlStorage.SelectColumns(ctx)
+		if err != nil {
+			return err
+		}
+		plan = schema.Diff(tables, columns)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if plan.Empty() {
+		app.logger.Info("Database already matches %s, nothing to generate", schemaFile)
+		return nil
+	}
+
+	migrations, err := getMigrations(dir, app.convention, app.logger)
+	if err != nil {
+		app.logger.Error("Failed to get migrations: %v", err)
+		return err
+	}
+	version := 1
+	for existing := range migrations {
+		if existing >= version {
+			version = existing + 1
+		}
+	}
+
+	header := fmt.Sprintf("-- description: generated from %s, review before applying\n\n", path.Base(schemaFile))
+	up := header + strings.Join(plan.Up, "\n") + "\n"
+	down := strings.Join(plan.Down, "\n") + "\n"
+	for fileName, fileContent := range app.convention.files(version, name, up, down) {
+		if err := writeFile(path.Join(dir, fileName), []byte(fileContent), app.fileMode); err != nil {
+			app.logger.Error("Failed to write migration: %v", err)
+			return err
+		}
+		app.logger.Info("%s created", path.Join(dir, fileName))
+	}
+
+	return nil
+}
@@ -0,0 +1,40 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/schema"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGenerateFromSchema(t *testing.T) {
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_up.sql"), []byte("CREATE TABLE users (id integer);"), 0644))
+
+	schemaFile := path.Join(t.TempDir(), "schema.sql")
+	assert.NoError(t, os.WriteFile(schemaFile, []byte("CREATE TABLE users (id integer NOT NULL, email text);"), 0644))
+
+	mockStorage := &storage.MockSqlStorage{}
+	mockStorage.SetColumns([]schema.Column{{Table: "users", Name: "id", Type: "integer"}})
+	app := New(logger.New(), mockStorage)
+
+	assert.NoError(t, app.GenerateFromSchema(context.Background(), schemaFile, migrationDir, "sync_schema"))
+
+	migrations, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.NoError(t, err)
+	assert.Equal(t, "sync_schema", migrations[2].Name)
+	assert.Equal(t, "generated from schema.sql, review before applying", migrations[2].Description)
+	assert.Contains(t, migrations[2].Up, "ALTER TABLE users ADD COLUMN email text;")
+	assert.Equal(t, "ALTER TABLE users DROP COLUMN email;\n", migrations[2].Down)
+
+	mockStorage.SetColumns([]schema.Column{{Table: "users", Name: "id", Type: "integer"}, {Table: "users", Name: "email", Type: "text", Nullable: true}})
+	assert.NoError(t, app.GenerateFromSchema(context.Background(), schemaFile, migrationDir, "sync_again"))
+	migrations, err = getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(migrations), "Expected nothing to be generated when the database matches")
+}
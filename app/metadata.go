@@ -0,0 +1,91 @@
+package app
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+var ErrInvalidMetadata = errors.New("invalid migration metadata")
+
+// applyMetadata разбирает заголовок SQL-файла из комментариев вида
+//
+//	-- description: добавляет таблицу пользователей
+//	-- author: julia
+//	-- requires: 3,4
+//	-- tag: release-2024.1
+//	-- migrator:min-server 14
+//	-- migrator:probe SELECT to_regclass('users') IS NOT NULL
+//
+// Заголовок заканчивается на первой строке, которая не является комментарием.
+func applyMetadata(migration *storage.Migration, sql string) error {
+	scanner := bufio.NewScanner(strings.NewReader(sql))
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue
+		}
+		if !strings.HasPrefix(line, "--") {
+			break
+		}
+
+		key, value, ok := strings.Cut(strings.TrimSpace(strings.TrimPrefix(line, "--")), ":")
+		if !ok {
+			continue
+		}
+		value = strings.TrimSpace(value)
+
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "description":
+			migration.Description = value
+		case "author":
+			migration.Author = value
+		case "tag":
+			migration.Tag = value
+		case "requires":
+			requires, err := parseRequires(value)
+			if err != nil {
+				return err
+			}
+			migration.Requires = requires
+		case "migrator":
+			switch directive, arg, _ := strings.Cut(value, " "); directive {
+			case "min-server":
+				minServer, err := strconv.Atoi(strings.TrimSpace(arg))
+				if err != nil || minServer <= 0 {
+					return fmt.Errorf("%w: min-server %q", ErrInvalidMetadata, arg)
+				}
+				migration.MinServer = minServer
+			case "probe":
+				if strings.TrimSpace(arg) == "" {
+					return fmt.Errorf("%w: empty probe", ErrInvalidMetadata)
+				}
+				migration.Probe = strings.TrimSpace(arg)
+			}
+		}
+	}
+
+	return scanner.Err()
+}
+
+func parseRequires(value string) ([]int, error) {
+	var requires []int
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		version, err := strconv.Atoi(part)
+		if err != nil || version <= 0 {
+			return nil, fmt.Errorf("%w: requires %q", ErrInvalidMetadata, value)
+		}
+		requires = append(requires, version)
+	}
+
+	return requires, nil
+}
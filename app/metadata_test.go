@@ -0,0 +1,116 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/processes"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetMigrationsParsesMetadata(t *testing.T) {
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"00001_create_users_up.sql":   "CREATE TABLE users (id SERIAL PRIMARY KEY);",
+		"00002_create_posts_up.sql":   "-- description: posts written by users\n-- author: julia\n-- requires: 1\n-- tag: release-2024.1\n\nCREATE TABLE posts (id SERIAL PRIMARY KEY);\n-- requires: 5",
+		"00002_create_posts_down.sql": "-- description: ignored\nDROP TABLE posts;",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644))
+	}
+
+	migrations, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.NoError(t, err)
+	assert.Empty(t, migrations[1].Description)
+	assert.Empty(t, migrations[1].Requires)
+	assert.Equal(t, "posts written by users", migrations[2].Description)
+	assert.Equal(t, "julia", migrations[2].Author)
+	assert.Equal(t, []int{1}, migrations[2].Requires, "Expected header to end at the first statement")
+	assert.Equal(t, "release-2024.1", migrations[2].Tag)
+}
+
+func TestGetMigrationsRejectsInvalidRequires(t *testing.T) {
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_up.sql"), []byte("-- requires: 1, two\nSELECT 1;"), 0644))
+
+	_, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.ErrorIs(t, err, ErrInvalidMetadata)
+}
+
+func TestUpAbortsOnMissingPrerequisite(t *testing.T) {
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"00001_create_users_up.sql": "SELECT 1;",
+		"00002_create_posts_up.sql": "-- requires: 1\nSELECT 2;",
+		"00003_add_tags_up.sql":     "-- requires: 2\nSELECT 3;",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644))
+	}
+
+	mockStorage := &storage.MockSqlStorage{}
+	app := New(logger.New(), mockStorage, WithForce(true))
+
+	err := app.ApplyOutOfOrder(context.Background(), migrationDir, "add_tags", 0)
+	assert.ErrorIs(t, err, processes.ErrMissingPrerequisite)
+	assert.Contains(t, err.Error(), "add_tags version 3 requires version 2")
+	assert.Empty(t, mockStorage.Executed())
+
+	assert.NoError(t, app.Up(context.Background(), migrationDir))
+	assert.Equal(t, []string{"SELECT 1;", "-- requires: 1\nSELECT 2;", "-- requires: 2\nSELECT 3;"}, mockStorage.Executed())
+}
+
+func TestUpChecksMinServerVersion(t *testing.T) {
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"00001_create_users_up.sql": "CREATE TABLE users (id SERIAL PRIMARY KEY);",
+		"00002_unique_nulls_up.sql": "-- migrator:min-server 15\nCREATE UNIQUE INDEX users_id ON users (id) NULLS NOT DISTINCT;",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644))
+	}
+
+	migrations, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.NoError(t, err)
+	assert.Equal(t, 0, migrations[1].MinServer)
+	assert.Equal(t, 15, migrations[2].MinServer)
+
+	mockStorage := &storage.MockSqlStorage{}
+	mockStorage.SetServerVersion(140010)
+	app := New(logger.New(), mockStorage)
+
+	err = app.Up(context.Background(), migrationDir)
+	assert.ErrorIs(t, err, processes.ErrServerTooOld)
+	assert.Contains(t, err.Error(), "unique_nulls version 2 requires Postgres 15 or newer")
+	assert.Equal(t, []string{files["00001_create_users_up.sql"]}, mockStorage.Executed(), "Expected the guard to stop before running the migration")
+
+	mockStorage.SetServerVersion(150004)
+	assert.NoError(t, app.Up(context.Background(), migrationDir))
+	assert.Equal(t, []string{files["00001_create_users_up.sql"], files["00002_unique_nulls_up.sql"]}, mockStorage.Executed())
+}
+
+func TestGetMigrationsRejectsInvalidMinServer(t *testing.T) {
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_up.sql"), []byte("-- migrator:min-server fourteen\nSELECT 1;"), 0644))
+
+	_, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.ErrorIs(t, err, ErrInvalidMetadata)
+}
+
+func TestGetMigrationsParsesProbe(t *testing.T) {
+	migrationDir := t.TempDir()
+	content := "-- migrator:probe SELECT to_regclass('public.users')::text IS NOT NULL\nCREATE TABLE users ();"
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_up.sql"), []byte(content), 0644))
+
+	migrations, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.NoError(t, err)
+	assert.Equal(t, "SELECT to_regclass('public.users')::text IS NOT NULL", migrations[1].Probe)
+
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_up.sql"), []byte("-- migrator:probe\nSELECT 1;"), 0644))
+	_, err = getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.ErrorIs(t, err, ErrInvalidMetadata)
+}
@@ -0,0 +1,98 @@
+//go:build linux && cgo
+
+package app
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+const testPluginSource = `package main
+
+import (
+	"context"
+	"errors"
+)
+
+func Up(ctx context.Context) error {
+	return errors.New("plugin up executed")
+}
+`
+
+func TestGetMigrationsLoadsPlugin(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain is not available")
+	}
+
+	sourceDir := t.TempDir()
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(sourceDir, "go.mod"), []byte("module testplugin\n"), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(sourceDir, "main.go"), []byte(testPluginSource), 0644))
+
+	cmd := exec.Command("go", "build", "-buildmode=plugin",
+		"-o", path.Join(migrationDir, "00001_create_users_up.so"), ".")
+	cmd.Dir = sourceDir
+	output, err := cmd.CombinedOutput()
+	if !assert.NoError(t, err, string(output)) {
+		return
+	}
+
+	migrations, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	if !assert.NoError(t, err) {
+		return
+	}
+	assert.Equal(t, 1, len(migrations))
+	assert.Equal(t, "create_users", migrations[1].Name)
+	assert.NotNil(t, migrations[1].UpGo)
+	assert.Nil(t, migrations[1].DownGo)
+	assert.EqualError(t, migrations[1].UpGo(context.Background()), "plugin up executed")
+}
+
+func TestGetMigrationsRejectsBrokenPlugin(t *testing.T) {
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_up.so"), []byte("not a plugin"), 0644))
+
+	_, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.Error(t, err)
+}
+
+func TestScaffoldedGoMigrationRunsAsPlugin(t *testing.T) {
+	if _, err := exec.LookPath("go"); err != nil {
+		t.Skip("go toolchain is not available")
+	}
+
+	ctx := context.Background()
+	// исходник плагина должен лежать в модуле мигратора, чтобы импортировать его storage
+	migrationDir, err := os.MkdirTemp(".", "plugin-migrations-")
+	if !assert.NoError(t, err) {
+		return
+	}
+	defer os.RemoveAll(migrationDir)
+
+	mockStorage := &storage.MockSqlStorage{}
+	app := New(logger.NewNop(), mockStorage)
+	files, err := app.Create(ctx, "create_users", migrationDir, "go")
+	if !assert.NoError(t, err) {
+		return
+	}
+
+	for _, file := range files {
+		cmd := exec.Command("go", "build", "-buildmode=plugin", "-o", pluginName(path.Base(file)), path.Base(file))
+		cmd.Dir = migrationDir
+		output, err := cmd.CombinedOutput()
+		if !assert.NoError(t, err, string(output)) {
+			return
+		}
+	}
+
+	assert.NoError(t, app.Up(ctx, migrationDir))
+	assert.Len(t, mockStorage.Executed(), 1, "Expected the plugin to run its SQL through the storage from ctx")
+	assert.Contains(t, mockStorage.Executed()[0], "CREATE TABLE IF NOT EXISTS users")
+}
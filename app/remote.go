@@ -0,0 +1,223 @@
+package app
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+var (
+	ErrRemoteRequest  = errors.New("remote source request failed")
+	ErrRemoteTooLarge = errors.New("remote response exceeds the size limit")
+	ErrS3Credentials  = errors.New("s3 bucket denied anonymous access, s3 credentials are not supported, only buckets with public read")
+
+	regHref = regexp.MustCompile(`href="([^"?#]+)"`)
+
+	// maxRemoteSize — наибольший размер ответа удаленного источника: файла миграции или страницы со списком.
+	// Ответ больше 32 МБ не читается целиком в память, а возвращается ErrRemoteTooLarge; переменная для тестов
+	maxRemoteSize int64 = 32 << 20
+
+	// s3Endpoint возвращает адрес бакета; вынесен в переменную, чтобы тесты могли подменить его
+	s3Endpoint = func(bucket string) string {
+		return "https://" + bucket + ".s3.amazonaws.com"
+	}
+)
+
+// isRemotePath проверяет, что путь к миграциям указывает на http(s) или s3
+func isRemotePath(filePath string) bool {
+	return strings.HasPrefix(filePath, "http://") ||
+		strings.HasPrefix(filePath, "https://") ||
+		strings.HasPrefix(filePath, "s3://")
+}
+
+// remoteFS — fs.FS поверх http(s) или публичного s3-бакета. Список файлов запрашивается при ReadDir,
+// содержимое файла скачивается только при его открытии.
+type remoteFS struct {
+	client  *http.Client
+	baseURL string
+	list    func() ([]string, error)
+	// s3 — источник s3://: запросы к нему идут без подписи, и отказ в доступе означает приватный бакет
+	s3 bool
+}
+
+func newRemoteFS(filePath string) (*remoteFS, error) {
+	u, err := url.Parse(filePath)
+	if err != nil {
+		return nil, err
+	}
+
+	rfs := &remoteFS{client: &http.Client{Timeout: 30 * time.Second}}
+
+	if u.Scheme == "s3" {
+		prefix := strings.Trim(u.Path, "/")
+		if prefix != "" {
+			prefix += "/"
+		}
+		endpoint := s3Endpoint(u.Host)
+		rfs.s3 = true
+		rfs.baseURL = endpoint + "/" + prefix
+		rfs.list = func() ([]string, error) {
+			return rfs.listS3(endpoint, prefix)
+		}
+		return rfs, nil
+	}
+
+	rfs.baseURL = strings.TrimSuffix(u.String(), "/") + "/"
+	rfs.list = rfs.listHTML
+	return rfs, nil
+}
+
+func (rfs *remoteFS) Open(name string) (fs.File, error) {
+	if !fs.ValidPath(name) || name == "." || strings.Contains(name, "/") {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: fs.ErrInvalid}
+	}
+
+	content, err := rfs.get(rfs.baseURL + url.PathEscape(name))
+	if err != nil {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: err}
+	}
+
+	return &remoteFile{Reader: bytes.NewReader(content), info: remoteFileInfo{name: name, size: int64(len(content))}}, nil
+}
+
+func (rfs *remoteFS) ReadDir(name string) ([]fs.DirEntry, error) {
+	if name != "." {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: fs.ErrNotExist}
+	}
+
+	names, err := rfs.list()
+	if err != nil {
+		return nil, &fs.PathError{Op: "readdir", Path: name, Err: err}
+	}
+
+	sort.Strings(names)
+	entries := make([]fs.DirEntry, 0, len(names))
+	for _, fileName := range names {
+		entries = append(entries, fs.FileInfoToDirEntry(remoteFileInfo{name: fileName}))
+	}
+	return entries, nil
+}
+
+// listHTML разбирает страницу со списком файлов, которую отдают http.FileServer, nginx autoindex и подобные
+func (rfs *remoteFS) listHTML() ([]string, error) {
+	content, err := rfs.get(rfs.baseURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var names []string
+	seen := make(map[string]bool)
+	for _, match := range regHref.FindAllStringSubmatch(string(content), -1) {
+		href, err := url.PathUnescape(match[1])
+		if err != nil || strings.HasSuffix(href, "/") {
+			continue
+		}
+
+		fileName := path.Base(href)
+		if !seen[fileName] {
+			seen[fileName] = true
+			names = append(names, fileName)
+		}
+	}
+	return names, nil
+}
+
+type s3ListResult struct {
+	Contents []struct {
+		Key string `xml:"Key"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// listS3 получает список объектов через ListObjectsV2; поддерживаются только бакеты с публичным чтением
+func (rfs *remoteFS) listS3(endpoint, prefix string) ([]string, error) {
+	var names []string
+	token := ""
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {prefix}, "delimiter": {"/"}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+
+		content, err := rfs.get(endpoint + "/?" + query.Encode())
+		if err != nil {
+			return nil, err
+		}
+
+		var result s3ListResult
+		if err := xml.Unmarshal(content, &result); err != nil {
+			return nil, err
+		}
+
+		for _, object := range result.Contents {
+			if fileName := strings.TrimPrefix(object.Key, prefix); fileName != "" && !strings.Contains(fileName, "/") {
+				names = append(names, fileName)
+			}
+		}
+
+		if !result.IsTruncated {
+			return names, nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+func (rfs *remoteFS) get(rawURL string) ([]byte, error) {
+	resp, err := rfs.client.Get(rawURL)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if rfs.s3 && (resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden) {
+		return nil, fmt.Errorf("%w: GET %s: %s", ErrS3Credentials, rawURL, resp.Status)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%w: GET %s: %s", ErrRemoteRequest, rawURL, resp.Status)
+	}
+
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxRemoteSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(content)) > maxRemoteSize {
+		return nil, fmt.Errorf("%w: GET %s: more than %d bytes", ErrRemoteTooLarge, rawURL, maxRemoteSize)
+	}
+	return content, nil
+}
+
+type remoteFile struct {
+	*bytes.Reader
+	info remoteFileInfo
+}
+
+func (f *remoteFile) Stat() (fs.FileInfo, error) {
+	return f.info, nil
+}
+
+func (f *remoteFile) Close() error {
+	return nil
+}
+
+type remoteFileInfo struct {
+	name string
+	size int64
+}
+
+func (i remoteFileInfo) Name() string       { return i.name }
+func (i remoteFileInfo) Size() int64        { return i.size }
+func (i remoteFileInfo) Mode() fs.FileMode  { return 0444 }
+func (i remoteFileInfo) ModTime() time.Time { return time.Time{} }
+func (i remoteFileInfo) IsDir() bool        { return false }
+func (i remoteFileInfo) Sys() interface{}   { return nil }
@@ -0,0 +1,137 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"strings"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func newMigrationServer(t *testing.T, files map[string]string) *httptest.Server {
+	migrationDir := t.TempDir()
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644))
+	}
+
+	server := httptest.NewServer(http.StripPrefix("/migrations/", http.FileServer(http.Dir(migrationDir))))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestGetMigrationsFromHTTP(t *testing.T) {
+	server := newMigrationServer(t, map[string]string{
+		"00001_create_users_up.sql":   "CREATE TABLE users (id SERIAL PRIMARY KEY);",
+		"00001_create_users_down.sql": "DROP TABLE users;",
+		"00002_add_email_up.sql":      "ALTER TABLE users ADD COLUMN email TEXT;",
+		"README.md":                   "# migrations",
+	})
+
+	migrations, err := getMigrations(server.URL+"/migrations/", DefaultConvention, logger.New())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(migrations))
+	assert.Equal(t, "create_users", migrations[1].Name)
+	assert.Equal(t, "CREATE TABLE users (id SERIAL PRIMARY KEY);", migrations[1].Up)
+	assert.Equal(t, "DROP TABLE users;", migrations[1].Down)
+	assert.Equal(t, "ALTER TABLE users ADD COLUMN email TEXT;", migrations[2].Up)
+}
+
+func TestUpFromHTTP(t *testing.T) {
+	server := newMigrationServer(t, map[string]string{
+		"00001_create_users_up.sql": "CREATE TABLE users (id SERIAL PRIMARY KEY);",
+	})
+
+	mockStorage := &storage.MockSqlStorage{}
+	app := New(logger.New(), mockStorage)
+	assert.NoError(t, app.Up(context.Background(), server.URL+"/migrations"))
+	assert.Equal(t, []string{"CREATE TABLE users (id SERIAL PRIMARY KEY);"}, mockStorage.Executed())
+	_, err := app.Create(context.Background(), "add_email", server.URL+"/migrations", "sql")
+	assert.ErrorIs(t, err, ErrRemoteSource)
+}
+
+func TestGetMigrationsRejectsRemoteGoMigrations(t *testing.T) {
+	server := newMigrationServer(t, map[string]string{
+		"00001_create_users_up.go": "package main",
+	})
+
+	_, err := getMigrations(server.URL+"/migrations/", DefaultConvention, logger.New())
+	assert.ErrorIs(t, err, ErrRemoteGoMigration)
+}
+
+func TestGetMigrationsFromS3(t *testing.T) {
+	objects := map[string]string{
+		"db/00001_create_users_up.sql":   "CREATE TABLE users (id SERIAL PRIMARY KEY);",
+		"db/00001_create_users_down.sql": "DROP TABLE users;",
+	}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("list-type") == "2" {
+			assert.Equal(t, "db/", r.URL.Query().Get("prefix"))
+			fmt.Fprint(w, `<ListBucketResult><IsTruncated>false</IsTruncated>`)
+			for key := range objects {
+				fmt.Fprintf(w, "<Contents><Key>%s</Key></Contents>", key)
+			}
+			fmt.Fprint(w, `</ListBucketResult>`)
+			return
+		}
+
+		content, ok := objects[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+		fmt.Fprint(w, content)
+	}))
+	defer server.Close()
+
+	endpoint := s3Endpoint
+	s3Endpoint = func(bucket string) string { return server.URL }
+	defer func() { s3Endpoint = endpoint }()
+
+	migrations, err := getMigrations("s3://migrations-bucket/db", DefaultConvention, logger.New())
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(migrations))
+	assert.Equal(t, "CREATE TABLE users (id SERIAL PRIMARY KEY);", migrations[1].Up)
+	assert.Equal(t, "DROP TABLE users;", migrations[1].Down)
+}
+
+func TestGetMigrationsFromPrivateS3Bucket(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "<Error><Code>AccessDenied</Code></Error>", http.StatusForbidden)
+	}))
+	defer server.Close()
+
+	endpoint := s3Endpoint
+	s3Endpoint = func(bucket string) string { return server.URL }
+	defer func() { s3Endpoint = endpoint }()
+
+	_, err := getMigrations("s3://private-bucket/db", DefaultConvention, logger.New())
+	assert.ErrorIs(t, err, ErrS3Credentials)
+}
+
+func TestGetMigrationsFromUnavailableServer(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := getMigrations(server.URL+"/migrations/", DefaultConvention, logger.New())
+	assert.ErrorIs(t, err, ErrRemoteRequest)
+}
+
+func TestGetMigrationsRejectsOversizedResponse(t *testing.T) {
+	server := newMigrationServer(t, map[string]string{
+		"00001_create_users_up.sql": "CREATE TABLE users (id SERIAL PRIMARY KEY);",
+	})
+
+	limit := maxRemoteSize
+	maxRemoteSize = 16
+	defer func() { maxRemoteSize = limit }()
+
+	_, err := getMigrations(server.URL+"/migrations/", DefaultConvention, logger.New())
+	assert.ErrorIs(t, err, ErrRemoteTooLarge)
+}
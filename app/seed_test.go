@@ -0,0 +1,62 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateWithSeedFile(t *testing.T) {
+	ctx := context.Background()
+	migrationDir := t.TempDir()
+	app := New(logger.New(), &storage.MockSqlStorage{}, WithSeedFile(true))
+
+	files, err := app.Create(ctx, "create_users", migrationDir, "sql")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{
+		path.Join(migrationDir, "00001_create_users_up.sql"),
+		path.Join(migrationDir, "00001_create_users_down.sql"),
+		path.Join(migrationDir, "00001_create_users_seed.sql"),
+	}, files)
+	for _, file := range files {
+		assert.FileExists(t, file)
+	}
+
+	files, err = app.Create(ctx, "create_orders", migrationDir, "sql")
+	assert.NoError(t, err)
+	assert.Equal(t, path.Join(migrationDir, "00002_create_orders_seed.sql"), files[2], "Expected the seed file not to take a version")
+
+	_, err = app.Create(ctx, "create_plugin", migrationDir, "go")
+	assert.ErrorIs(t, err, ErrSeedNotSQL)
+}
+
+func TestSeedsAppliedOnlyWhenEnabled(t *testing.T) {
+	ctx := context.Background()
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"00001_create_users_up.sql":   "CREATE TABLE users (id SERIAL PRIMARY KEY);",
+		"00001_create_users_down.sql": "DROP TABLE users;",
+		"00001_create_users_seed.sql": "INSERT INTO users (id) VALUES (1) ON CONFLICT DO NOTHING;",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644))
+	}
+
+	migrations, err := getMigrations(migrationDir, DefaultConvention, logger.New())
+	assert.NoError(t, err)
+	assert.Equal(t, "create_users", migrations[1].Name)
+	assert.Equal(t, files["00001_create_users_seed.sql"], migrations[1].Seed)
+
+	production := &storage.MockSqlStorage{}
+	assert.NoError(t, New(logger.New(), production).Up(ctx, migrationDir))
+	assert.Equal(t, []string{files["00001_create_users_up.sql"]}, production.Executed(), "Expected seeds to be skipped by default")
+
+	development := &storage.MockSqlStorage{}
+	assert.NoError(t, New(logger.New(), development, WithSeeds(true)).Up(ctx, migrationDir))
+	assert.Equal(t, []string{files["00001_create_users_up.sql"], files["00001_create_users_seed.sql"]}, development.Executed())
+}
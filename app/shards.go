@@ -0,0 +1,46 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+)
+
+var ErrShardsFailed = errors.New("command failed on some databases")
+
+// Shard — одна из баз с общим набором миграций; у каждой свое хранилище, а значит своя блокировка и соединение
+type Shard struct {
+	Name string
+	App  App
+}
+
+// RunShards по очереди выполняет run для каждой базы и выводит итог.
+// При failFast обход останавливается на первой ошибке, иначе продолжается, а ошибка перечисляет все упавшие базы.
+func RunShards(logger logger.Logger, shards []Shard, failFast bool, run func(App) error) error {
+	var failed []string
+	done := 0
+
+	for i, shard := range shards {
+		logger.Info("Database %d of %d: %s", i+1, len(shards), shard.Name)
+
+		if err := run(shard.App); err != nil {
+			logger.Error("Error in database %s: %v", shard.Name, err)
+			failed = append(failed, shard.Name)
+			if failFast {
+				break
+			}
+			continue
+		}
+		done++
+	}
+
+	logger.Info("Databases completed: %d of %d", done, len(shards))
+	if len(failed) > 0 {
+		if skipped := len(shards) - done - len(failed); skipped > 0 {
+			logger.Warn("Databases not attempted after failure: %d", skipped)
+		}
+		return fmt.Errorf("%w: %v", ErrShardsFailed, failed)
+	}
+	return nil
+}
@@ -0,0 +1,69 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeShardMigrations(t *testing.T) string {
+	migrationDir := t.TempDir()
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00001_create_users_up.sql"), []byte("CREATE TABLE users (id integer);"), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00002_create_posts_up.sql"), []byte("CREATE TABLE posts (id integer);"), 0644))
+	return migrationDir
+}
+
+func TestRunShardsAppliesEveryDatabase(t *testing.T) {
+	migrationDir := writeShardMigrations(t)
+	first, second := &storage.MockSqlStorage{}, &storage.MockSqlStorage{}
+	shards := []Shard{
+		{Name: "first", App: New(logger.New(), first)},
+		{Name: "second", App: New(logger.New(), second)},
+	}
+
+	err := RunShards(logger.New(), shards, true, func(app App) error { return app.Up(context.Background(), migrationDir) })
+	assert.NoError(t, err)
+
+	for _, shard := range []*storage.MockSqlStorage{first, second} {
+		migrations, _ := shard.SelectMigrations(context.Background())
+		assert.Equal(t, 2, len(migrations))
+		assert.Equal(t, []string{"CREATE TABLE users (id integer);", "CREATE TABLE posts (id integer);"}, shard.Executed())
+	}
+}
+
+func TestRunShardsFailFast(t *testing.T) {
+	migrationDir := writeShardMigrations(t)
+
+	for _, failFast := range []bool{true, false} {
+		first, second := &storage.MockSqlStorage{}, &storage.MockSqlStorage{}
+		shards := []Shard{
+			{Name: "first", App: New(logger.New(), first)},
+			{Name: "second", App: New(logger.New(), second)},
+		}
+		calls := 0
+
+		err := RunShards(logger.New(), shards, failFast, func(app App) error {
+			calls++
+			if calls == 1 {
+				return app.Up(context.Background(), path.Join(migrationDir, "missing"))
+			}
+			return app.Up(context.Background(), migrationDir)
+		})
+		assert.ErrorIs(t, err, ErrShardsFailed)
+		assert.Contains(t, err.Error(), "[first]")
+		assert.Empty(t, first.Executed())
+
+		if failFast {
+			assert.Equal(t, 1, calls, "Expected fail-fast to stop after the first failed database")
+			assert.Empty(t, second.Executed())
+		} else {
+			assert.Equal(t, 2, calls)
+			assert.Equal(t, 2, len(second.Executed()), "Expected the remaining database to be migrated")
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"sort"
+
+	"github.com/juliazadorozhnaya/sql-migrator/processes"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+const (
+	// squashedDir — поддиректория, куда переносятся свернутые файлы миграций; поддиректории при чтении пропускаются
+	squashedDir  = "squashed"
+	baselineName = "baseline"
+)
+
+// dumpSchema выгружает схему базы без данных через pg_dump. Переменная, чтобы тесты обходились без pg_dump.
+var dumpSchema = func(ctx context.Context, dsn string, excludeTables ...string) (string, error) {
+	args := []string{"--schema-only", "--no-owner", "--no-privileges", "--dbname", dsn}
+	for _, table := range excludeTables {
+		args = append(args, "--exclude-table", table)
+	}
+
+	output, err := exec.CommandContext(ctx, "pg_dump", args...).Output()
+	if err != nil {
+		return "", fmt.Errorf("pg_dump: %w", err)
+	}
+	return string(output), nil
+}
+
+// Squash заменяет примененные миграции одной базовой миграцией со схемой из pg_dump:
+// старые файлы переносятся в поддиректорию squashed, а учет в базе сворачивается в одну версию
+func (app *Application) Squash(ctx context.Context, filePath, dsn string) error {
+	if isRemotePath(filePath) {
+		return ErrRemoteSource
+	}
+
+	schema, err := dumpSchema(ctx, dsn, app.sqlStorage.TableName(), storage.EventsTableName(app.sqlStorage.TableName()))
+	if err != nil {
+		app.logger.Error("Failed to dump schema: %v", err)
+		return err
+	}
+
+	return app.runLoadedCommand(ctx, filePath, func(migrator *processes.Migrator, ctx context.Context) error {
+		_, err := migrator.Squash(ctx, baselineName, func(version int) error {
+			return app.writeBaseline(filePath, version, schema)
+		})
+		return err
+	})
+}
+
+// writeBaseline переносит файлы первых version миграций в squashed и пишет на их место базовую миграцию
+func (app *Application) writeBaseline(dir string, version int, schema string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	byVersion := make(map[int][]string)
+	for _, entry := range entries {
+		if entry.IsDir() || !app.convention.isCandidate(entry.Name()) {
+			continue
+		}
+		file, err := app.convention.parse(entry.Name())
+		if err != nil {
+			return err
+		}
+		byVersion[file.version] = append(byVersion[file.version], entry.Name())
+	}
+
+	fileVersions := make([]int, 0, len(byVersion))
+	for fileVersion := range byVersion {
+		fileVersions = append(fileVersions, fileVersion)
+	}
+	sort.Ints(fileVersions)
+
+	if version > len(fileVersions) {
+		return fmt.Errorf("%w: db version %d, %d migrations in %s", processes.ErrUnexpectedMigrationVersion, version, len(fileVersions), dir)
+	}
+	if err := makeDir(path.Join(dir, squashedDir), app.dirMode); err != nil {
+		return err
+	}
+	for _, fileVersion := range fileVersions[:version] {
+		for _, name := range byVersion[fileVersion] {
+			if err := os.Rename(path.Join(dir, name), path.Join(dir, squashedDir, name)); err != nil {
+				return err
+			}
+		}
+	}
+
+	// версии после базовой сдвигаются так же, как их строки в таблице учета, чтобы номера шли подряд с 1
+	for i, fileVersion := range fileVersions[version:] {
+		for _, name := range byVersion[fileVersion] {
+			renamed, err := app.convention.renumber(name, i+2)
+			if err != nil {
+				return err
+			}
+			if err := os.Rename(path.Join(dir, name), path.Join(dir, renamed)); err != nil {
+				return err
+			}
+		}
+	}
+
+	up := fmt.Sprintf("-- description: baseline squashed from %d migrations\n\n%s", version, schema)
+	down := processes.IrreversibleMarker + "\n"
+	for name, content := range app.convention.files(1, baselineName, up, down) {
+		if err := writeFile(path.Join(dir, name), []byte(content), app.fileMode); err != nil {
+			return err
+		}
+		app.logger.Info("%s created", path.Join(dir, name))
+	}
+
+	return nil
+}
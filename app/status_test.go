@@ -0,0 +1,84 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"os"
+	"path"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/processes"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusVerboseAnnotatesFiles(t *testing.T) {
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"00001_create_users_up.sql":   "CREATE TABLE users (id integer);",
+		"00001_create_users_down.sql": "DROP TABLE users;",
+		"00002_create_posts_up.sql":   "CREATE TABLE posts (id integer);",
+		"00002_create_posts_down.sql": "DROP TABLE posts;",
+		"00003_create_tags_up.sql":    "CREATE TABLE tags (id integer);",
+	}
+	for name, content := range files {
+		assert.NoError(t, os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644))
+	}
+
+	mockStorage := &storage.MockSqlStorage{}
+	assert.NoError(t, New(logger.NewNop(), mockStorage, WithRecordSQL(true)).Up(context.Background(), migrationDir))
+
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, "00002_create_posts_up.sql"), []byte("CREATE TABLE posts (id bigint);"), 0644))
+	assert.NoError(t, os.Remove(path.Join(migrationDir, "00002_create_posts_down.sql")))
+	assert.NoError(t, os.Remove(path.Join(migrationDir, "00003_create_tags_up.sql")))
+
+	var output bytes.Buffer
+	app := New(logger.NewNop(), mockStorage)
+	assert.NoError(t, app.Status(context.Background(), migrationDir, processes.StatusOptions{JSON: true, Verbose: true, Output: &output}))
+
+	var entries []struct {
+		Name     string `json:"name"`
+		UpFile   string `json:"up_file"`
+		DownFile string `json:"down_file"`
+		Checksum string `json:"checksum"`
+	}
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &entries))
+
+	annotations := make(map[string][3]string, len(entries))
+	for _, entry := range entries {
+		annotations[entry.Name] = [3]string{entry.UpFile, entry.DownFile, entry.Checksum}
+	}
+	assert.Equal(t, map[string][3]string{
+		"create_users": {processes.FilePresent, processes.FilePresent, processes.ChecksumMatch},
+		"create_posts": {processes.FilePresent, processes.FileMissing, processes.ChecksumChanged},
+		"create_tags":  {processes.FileMissing, processes.FileMissing, processes.ChecksumUnknown},
+	}, annotations)
+
+	output.Reset()
+	assert.NoError(t, app.Status(context.Background(), migrationDir, processes.StatusOptions{JSON: true, Output: &output}))
+	assert.NotContains(t, output.String(), "up_file", "Expected file annotations only in verbose mode")
+}
+
+func TestStatusFailOnPending(t *testing.T) {
+	ctx := context.Background()
+	migrationDir := t.TempDir()
+	writeMigrations(t, migrationDir, "00001_create_users_up.sql", "00002_create_posts_up.sql")
+
+	app := New(logger.NewNop(), &storage.MockSqlStorage{})
+	assert.NoError(t, app.UpN(ctx, migrationDir, 1))
+
+	opts := processes.StatusOptions{FailOnPending: true, Output: &bytes.Buffer{}}
+	assert.ErrorIs(t, app.Status(ctx, migrationDir, opts), processes.ErrPendingMigrations)
+	assert.NoError(t, app.Status(ctx, migrationDir, processes.StatusOptions{Output: &bytes.Buffer{}}), "Expected pending migrations to be ignored without the flag")
+
+	out := path.Join(t.TempDir(), "status.txt")
+	assert.ErrorIs(t, app.StatusToFile(ctx, migrationDir, out, opts), processes.ErrPendingMigrations)
+	content, err := os.ReadFile(out)
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "create_users", "Expected the status file to be written before failing")
+
+	assert.NoError(t, app.Up(ctx, migrationDir))
+	assert.NoError(t, app.Status(ctx, migrationDir, opts))
+}
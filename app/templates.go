@@ -0,0 +1,272 @@
+package app
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+)
+
+// Template — заготовка файлов миграции одного типа. Up и Down — шаблоны text/template,
+// в которые подставляются {{.Version}}, {{.Name}}, {{.Direction}} и {{.Date}}
+type Template struct {
+	Ext  string
+	Up   string
+	Down string
+}
+
+type templateData struct {
+	Version   int
+	Name      string
+	Direction string
+	// Date — дата создания в формате 2006-01-02, в часовом поясе WithTimezone
+	Date string
+}
+
+var (
+	ErrUnsupportedMigrationType = errors.New("unsupported migration type")
+	ErrInvalidTemplate          = errors.New("template directory must contain one up and one down file")
+
+	templatesMu sync.RWMutex
+	templates   = map[string]Template{
+		"sql": {Ext: "sql", Up: defaultSQLTemplate, Down: defaultSQLTemplate},
+		"go":  {Ext: "go", Up: goUpTemplate, Down: goDownTemplate},
+	}
+)
+
+// RegisterTemplate добавляет тип миграции или заменяет заготовку уже известного типа
+func RegisterTemplate(migrationType string, tmpl Template) {
+	templatesMu.Lock()
+	defer templatesMu.Unlock()
+
+	templates[migrationType] = tmpl
+}
+
+// LookupTemplate возвращает зарегистрированную заготовку типа миграции
+func LookupTemplate(migrationType string) (Template, bool) {
+	templatesMu.RLock()
+	defer templatesMu.RUnlock()
+
+	tmpl, ok := templates[migrationType]
+	return tmpl, ok
+}
+
+// LoadTemplates читает заготовки из директории: каждая поддиректория — тип миграции
+// с файлами up.<ext> и down.<ext>, например procedure/up.sql и procedure/down.sql
+func LoadTemplates(dir string) (map[string]Template, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	loaded := make(map[string]Template)
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+
+		tmpl, err := loadTemplate(path.Join(dir, entry.Name()))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", entry.Name(), err)
+		}
+		loaded[entry.Name()] = tmpl
+	}
+
+	return loaded, nil
+}
+
+func loadTemplate(dir string) (Template, error) {
+	files, err := os.ReadDir(dir)
+	if err != nil {
+		return Template{}, err
+	}
+
+	var tmpl Template
+	var up, down bool
+	for _, file := range files {
+		if file.IsDir() {
+			continue
+		}
+
+		base, ext, ok := strings.Cut(file.Name(), ".")
+		if !ok || (base != directionUp && base != directionDown) {
+			continue
+		}
+		if tmpl.Ext != "" && tmpl.Ext != ext {
+			return Template{}, ErrInvalidTemplate
+		}
+
+		content, err := os.ReadFile(path.Join(dir, file.Name()))
+		if err != nil {
+			return Template{}, err
+		}
+
+		tmpl.Ext = ext
+		if base == directionUp {
+			tmpl.Up, up = string(content), true
+		} else {
+			tmpl.Down, down = string(content), true
+		}
+	}
+
+	if !up || !down {
+		return Template{}, ErrInvalidTemplate
+	}
+
+	return tmpl, nil
+}
+
+// WithTemplates добавляет заготовки типов миграций только для этого приложения, поверх зарегистрированных
+func WithTemplates(templates map[string]Template) Option {
+	return func(app *Application) {
+		app.templates = templates
+	}
+}
+
+// WithSQLTemplate задает шаблон text/template, которым Create заполняет up- и down-файлы типа sql
+// вместо встроенного; направление файла доступно как {{.Direction}}
+func WithSQLTemplate(content string) Option {
+	return func(app *Application) {
+		app.sqlTemplate = content
+	}
+}
+
+// WithEmptyFiles создает файлы типа sql пустыми, без заготовки
+func WithEmptyFiles(empty bool) Option {
+	return func(app *Application) {
+		app.emptyFiles = empty
+	}
+}
+
+// template ищет заготовку сначала среди переданных через WithTemplates, затем в общем реестре
+func (app *Application) template(migrationType string) (Template, error) {
+	tmpl, ok := app.templates[migrationType]
+	if !ok {
+		tmpl, ok = LookupTemplate(migrationType)
+	}
+	if !ok {
+		return Template{}, fmt.Errorf("%w: %s", ErrUnsupportedMigrationType, migrationType)
+	}
+
+	if migrationType == "sql" {
+		switch {
+		case app.emptyFiles:
+			tmpl.Up, tmpl.Down = "", ""
+		case app.sqlTemplate != "":
+			tmpl.Up, tmpl.Down = app.sqlTemplate, app.sqlTemplate
+		}
+	}
+
+	return tmpl, nil
+}
+
+func renderTemplate(content string, data templateData) ([]byte, error) {
+	tmpl, err := template.New("migration").Parse(content)
+	if err != nil {
+		return nil, err
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+const defaultSQLTemplate = `-- Migration {{.Version}} {{.Name}}, {{.Direction}}
+-- Created {{.Date}}
+--
+-- description:
+-- author:
+
+-- Write the {{.Direction}} statements below.
+`
+
+const goUpTemplate = `// Migration {{.Version}} {{.Name}}, {{.Direction}}
+//
+// The migrator runs this file with go run and passes the database in MIGRATOR_DSN.
+// If <this file>.so is built next to it (go build -buildmode=plugin -o <this file>.so <this file>.go),
+// the migrator loads Up from the plugin instead and passes its own storage in ctx.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+func Up(ctx context.Context) error {
+	db, err := storage.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get database connection from context: %w", err)
+	}
+
+	sql := "CREATE TABLE IF NOT EXISTS users (" +
+		"id SERIAL PRIMARY KEY, " +
+		"username VARCHAR(255) NOT NULL, " +
+		"email VARCHAR(255) NOT NULL UNIQUE, " +
+		"created_at TIMESTAMP NOT NULL DEFAULT NOW());"
+
+	if err := db.Migrate(ctx, sql); err != nil {
+		return fmt.Errorf("could not execute migration: %w", err)
+	}
+
+	fmt.Println("Migration Up applied: users table created")
+	return nil
+}
+
+// main runs Up when the migrator starts this file with go run; a plugin build does not use it.
+func main() {
+	if err := storage.RunFromEnv(context.Background(), Up); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`
+
+const goDownTemplate = `// Migration {{.Version}} {{.Name}}, {{.Direction}}
+//
+// The migrator runs this file with go run and passes the database in MIGRATOR_DSN.
+// If <this file>.so is built next to it (go build -buildmode=plugin -o <this file>.so <this file>.go),
+// the migrator loads Down from the plugin instead and passes its own storage in ctx.
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+func Down(ctx context.Context) error {
+	db, err := storage.FromContext(ctx)
+	if err != nil {
+		return fmt.Errorf("could not get database connection from context: %w", err)
+	}
+
+	sql := "DROP TABLE IF EXISTS users;"
+
+	if err := db.Migrate(ctx, sql); err != nil {
+		return fmt.Errorf("could not execute migration: %w", err)
+	}
+
+	fmt.Println("Migration Down applied: users table dropped")
+	return nil
+}
+
+// main runs Down when the migrator starts this file with go run; a plugin build does not use it.
+func main() {
+	if err := storage.RunFromEnv(context.Background(), Down); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(1)
+	}
+}
+`
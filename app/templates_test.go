@@ -0,0 +1,156 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCreateRegisteredType(t *testing.T) {
+	registered, _ := LookupTemplate("procedure")
+	defer func() {
+		templatesMu.Lock()
+		defer templatesMu.Unlock()
+		if registered.Ext == "" {
+			delete(templates, "procedure")
+		} else {
+			templates["procedure"] = registered
+		}
+	}()
+
+	RegisterTemplate("procedure", Template{
+		Ext:  "sql",
+		Up:   "CREATE OR REPLACE PROCEDURE {{.Name}}() LANGUAGE plpgsql AS $$ BEGIN END $$; -- version {{.Version}}",
+		Down: "DROP PROCEDURE IF EXISTS {{.Name}}();",
+	})
+
+	ctx := context.Background()
+	migrationDir := t.TempDir()
+	db := &storage.MockSqlStorage{}
+	app := New(logger.New(), db)
+
+	files, err := app.Create(ctx, "refresh_stats", migrationDir, "procedure")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{path.Join(migrationDir, "00001_refresh_stats_up.sql"), path.Join(migrationDir, "00001_refresh_stats_down.sql")}, files)
+
+	up, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE OR REPLACE PROCEDURE refresh_stats() LANGUAGE plpgsql AS $$ BEGIN END $$; -- version 1", string(up))
+
+	down, err := os.ReadFile(files[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "DROP PROCEDURE IF EXISTS refresh_stats();", string(down))
+
+	assert.NoError(t, app.Up(ctx, migrationDir))
+	assert.Equal(t, []string{string(up)}, db.Executed())
+}
+
+func TestCreateFromTemplatesDir(t *testing.T) {
+	templatesDir := t.TempDir()
+	viewDir := path.Join(templatesDir, "view")
+	assert.NoError(t, os.Mkdir(viewDir, 0755))
+	assert.NoError(t, os.WriteFile(path.Join(viewDir, "up.sql"), []byte("CREATE VIEW {{.Name}} AS SELECT 1;"), 0644))
+	assert.NoError(t, os.WriteFile(path.Join(viewDir, "down.sql"), []byte("DROP VIEW {{.Name}};"), 0644))
+
+	loaded, err := LoadTemplates(templatesDir)
+	assert.NoError(t, err)
+	assert.Equal(t, Template{Ext: "sql", Up: "CREATE VIEW {{.Name}} AS SELECT 1;", Down: "DROP VIEW {{.Name}};"}, loaded["view"])
+
+	ctx := context.Background()
+	migrationDir := t.TempDir()
+	app := New(logger.New(), &storage.MockSqlStorage{}, WithTemplates(loaded))
+
+	files, err := app.Create(ctx, "active_users", migrationDir, "view")
+	assert.NoError(t, err)
+	up, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "CREATE VIEW active_users AS SELECT 1;", string(up))
+
+	files, err = app.Create(ctx, "create_users", migrationDir, "sql")
+	assert.NoError(t, err, "Expected built-in types to stay available")
+	assert.Equal(t, path.Join(migrationDir, "00002_create_users_up.sql"), files[0])
+}
+
+func TestLoadTemplatesRequiresUpAndDown(t *testing.T) {
+	templatesDir := t.TempDir()
+	assert.NoError(t, os.Mkdir(path.Join(templatesDir, "view"), 0755))
+	assert.NoError(t, os.WriteFile(path.Join(templatesDir, "view", "up.sql"), []byte("SELECT 1;"), 0644))
+
+	_, err := LoadTemplates(templatesDir)
+	assert.ErrorIs(t, err, ErrInvalidTemplate)
+}
+
+func TestCreateUnknownType(t *testing.T) {
+	migrationDir := t.TempDir()
+	app := New(logger.New(), &storage.MockSqlStorage{})
+
+	created, err := app.Create(context.Background(), "refresh_stats", migrationDir, "procedure")
+	assert.ErrorIs(t, err, ErrUnsupportedMigrationType)
+	assert.Empty(t, created)
+
+	files, err := os.ReadDir(migrationDir)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestCreateSQLScaffold(t *testing.T) {
+	ctx := context.Background()
+	location, err := time.LoadLocation("Asia/Tokyo")
+	assert.NoError(t, err)
+	today := time.Now().In(location).Format("2006-01-02")
+
+	migrationDir := t.TempDir()
+	files, err := New(logger.NewNop(), &storage.MockSqlStorage{}, WithTimezone(location)).Create(ctx, "create_users", migrationDir, "sql")
+	assert.NoError(t, err)
+	up, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "-- Migration 1 create_users, up\n-- Created "+today+"\n--\n-- description:\n-- author:\n\n-- Write the up statements below.\n", string(up))
+	down, err := os.ReadFile(files[1])
+	assert.NoError(t, err)
+	assert.Contains(t, string(down), "-- Migration 1 create_users, down\n")
+
+	custom := "-- {{.Direction}} of {{.Name}} ({{.Version}})\nBEGIN;\nCOMMIT;\n"
+	files, err = New(logger.NewNop(), &storage.MockSqlStorage{}, WithSQLTemplate(custom)).Create(ctx, "add_email", migrationDir, "sql")
+	assert.NoError(t, err)
+	up, err = os.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.Equal(t, "-- up of add_email (2)\nBEGIN;\nCOMMIT;\n", string(up))
+	down, err = os.ReadFile(files[1])
+	assert.NoError(t, err)
+	assert.Equal(t, "-- down of add_email (2)\nBEGIN;\nCOMMIT;\n", string(down))
+
+	files, err = New(logger.NewNop(), &storage.MockSqlStorage{}, WithSQLTemplate(custom), WithEmptyFiles(true)).Create(ctx, "add_phone", migrationDir, "sql")
+	assert.NoError(t, err)
+	for _, file := range files {
+		content, err := os.ReadFile(file)
+		assert.NoError(t, err)
+		assert.Empty(t, content, "Expected -empty to win over the template")
+	}
+
+	files, err = New(logger.NewNop(), &storage.MockSqlStorage{}, WithEmptyFiles(true)).Create(ctx, "add_plugin", migrationDir, "go")
+	assert.NoError(t, err)
+	content, err := os.ReadFile(files[0])
+	assert.NoError(t, err)
+	assert.Contains(t, string(content), "func Up(ctx context.Context) error", "Expected -empty to leave go templates alone")
+}
+
+func TestSQLScaffoldIsValidMigration(t *testing.T) {
+	ctx := context.Background()
+	migrationDir := t.TempDir()
+	db := &storage.MockSqlStorage{}
+	app := New(logger.NewNop(), db)
+
+	_, err := app.Create(ctx, "create_users", migrationDir, "sql")
+	assert.NoError(t, err)
+	assert.NoError(t, app.Up(ctx, migrationDir))
+
+	migrations, err := db.SelectMigrations(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, storage.StatusSuccess, migrations[0].GetStatus())
+}
@@ -0,0 +1,54 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"io"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+var (
+	ErrMissingPrivileges     = errors.New("the role is missing privileges the migrator needs")
+	ErrPrivilegesUnsupported = errors.New("storage cannot check privileges")
+	ErrPrivilegeNotGranted   = errors.New("not granted")
+)
+
+// VerifyConnection подключается к базе, не создавая таблиц учета, и пишет в w по строке на каждое нужное
+// мигратору право, с командой GRANT под недостающим. Так ошибки прав видны до запуска, а не посреди миграций.
+// Если хотя бы одного права нет, возвращается ErrMissingPrivileges.
+func (app *Application) VerifyConnection(ctx context.Context, w io.Writer) error {
+	checker, ok := app.sqlStorage.(storage.PrivilegeChecker)
+	if !ok {
+		app.logger.Error("Error in VerifyConnection: %v", ErrPrivilegesUnsupported)
+		return ErrPrivilegesUnsupported
+	}
+
+	privileges, err := checker.CheckPrivileges(ctx)
+	defer app.sqlStorage.Close()
+	if err != nil {
+		app.logger.Error("Error in VerifyConnection: %v", err)
+		return err
+	}
+
+	checks := make([]DoctorCheck, 0, len(privileges))
+	missing := false
+	for _, privilege := range privileges {
+		check := DoctorCheck{Name: privilege.Name, Hint: privilege.Hint}
+		if !privilege.Granted {
+			check.Err = ErrPrivilegeNotGranted
+			missing = true
+		}
+		checks = append(checks, check)
+	}
+
+	if err := WriteDoctorReport(w, checks); err != nil {
+		app.logger.Error("Error in VerifyConnection: %v", err)
+		return err
+	}
+
+	if missing {
+		return ErrMissingPrivileges
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package app
+
+import (
+	"bytes"
+	"context"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// privilegedStorage отвечает на CheckPrivileges заданным списком прав
+type privilegedStorage struct {
+	storage.MockSqlStorage
+	privileges []storage.Privilege
+}
+
+func (s *privilegedStorage) CheckPrivileges(ctx context.Context) ([]storage.Privilege, error) {
+	return s.privileges, nil
+}
+
+func TestVerifyConnectionReportsMissingPrivileges(t *testing.T) {
+	db := &privilegedStorage{privileges: []storage.Privilege{
+		{Name: "CREATE on schema public", Granted: true, Hint: "GRANT CREATE ON SCHEMA public TO deploy"},
+		{Name: "INSERT on schema_migrations", Hint: "GRANT INSERT ON schema_migrations TO deploy"},
+	}}
+
+	var output bytes.Buffer
+	err := New(logger.NewNop(), db).VerifyConnection(context.Background(), &output)
+	assert.ErrorIs(t, err, ErrMissingPrivileges)
+	assert.Equal(t, "[ok]   CREATE on schema public\n"+
+		"[fail] INSERT on schema_migrations: not granted\n"+
+		"       hint: GRANT INSERT ON schema_migrations TO deploy\n", output.String())
+
+	db.privileges[1].Granted = true
+	output.Reset()
+	assert.NoError(t, New(logger.NewNop(), db).VerifyConnection(context.Background(), &output))
+	assert.NotContains(t, output.String(), "[fail]")
+}
+
+func TestVerifyConnectionNeedsPrivilegeChecker(t *testing.T) {
+	err := New(logger.NewNop(), &storage.MockSqlStorage{}).VerifyConnection(context.Background(), &bytes.Buffer{})
+	assert.ErrorIs(t, err, ErrPrivilegesUnsupported)
+}
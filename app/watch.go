@@ -0,0 +1,122 @@
+package app
+
+import (
+	"context"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDebounce — пауза после последнего события файловой системы, после которой запускается Up
+const watchDebounce = 500 * time.Millisecond
+
+// Watch — режим только для локальной разработки: следит за директорией миграций и запускает Up,
+// когда в ней появляются миграции с непустыми up- и down-шагами. Работает до отмены ctx.
+func (app *Application) Watch(ctx context.Context, filePath string) error {
+	if isRemotePath(filePath) {
+		app.logger.Error("Cannot watch %s", filePath)
+		return ErrRemoteSource
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		app.logger.Error("Failed to start watcher: %v", err)
+		return err
+	}
+	defer watcher.Close()
+
+	if err := watcher.Add(filePath); err != nil {
+		app.logger.Error("Failed to watch %s: %v", filePath, err)
+		return err
+	}
+
+	names := make(chan string)
+	go func() {
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				if !event.Has(fsnotify.Create) && !event.Has(fsnotify.Write) && !event.Has(fsnotify.Rename) {
+					continue
+				}
+				select {
+				case names <- path.Base(event.Name):
+				case <-ctx.Done():
+					return
+				}
+			case err, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				app.logger.Warn("Watcher error: %v", err)
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	app.logger.Warn("Watching %s for new migrations; watch mode is meant for local development only", filePath)
+	return app.watchLoop(ctx, filePath, names, watchDebounce)
+}
+
+// watchLoop копит имена измененных файлов и после паузы debounce без новых событий запускает Up,
+// если все затронутые миграции полные. Ошибка Up не останавливает наблюдение.
+func (app *Application) watchLoop(ctx context.Context, filePath string, names <-chan string, debounce time.Duration) error {
+	changed := make(map[string]bool)
+	timer := time.NewTimer(debounce)
+	timer.Stop()
+	defer timer.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case name := <-names:
+			if strings.HasPrefix(name, ".") || !app.convention.isCandidate(name) {
+				continue
+			}
+			changed[name] = true
+			timer.Reset(debounce)
+		case <-timer.C:
+			if len(changed) == 0 || !app.migrationsComplete(filePath, changed) {
+				continue
+			}
+			changed = make(map[string]bool)
+
+			if err := app.Up(ctx, filePath); err != nil {
+				app.logger.Error("Error in Watch: %v", err)
+			}
+		}
+	}
+}
+
+// migrationsComplete проверяет, что у миграций измененных файлов есть и up-, и down-шаг
+func (app *Application) migrationsComplete(filePath string, changed map[string]bool) bool {
+	migrations, err := getMigrations(filePath, app.convention, app.logger)
+	if err != nil {
+		app.logger.Warn("Waiting for valid migrations: %v", err)
+		return false
+	}
+
+	for name := range changed {
+		file, err := app.convention.parse(name)
+		if err != nil {
+			continue
+		}
+
+		migration, ok := migrations[file.version]
+		if !ok {
+			continue
+		}
+		if (migration.Up == "" && migration.UpGo == nil) || (migration.Down == "" && migration.DownGo == nil) {
+			app.logger.Info("Waiting for both steps of migration %s", migration.Name)
+			return false
+		}
+	}
+
+	return true
+}
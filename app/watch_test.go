@@ -0,0 +1,48 @@
+package app
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWatchLoopRunsUpOnceAfterDebounce(t *testing.T) {
+	migrationDir := t.TempDir()
+	mockStorage := &storage.MockSqlStorage{}
+	app := New(logger.New(), mockStorage)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	names := make(chan string)
+	done := make(chan error)
+	go func() { done <- app.watchLoop(ctx, migrationDir, names, 20*time.Millisecond) }()
+
+	upFile := "00001_create_users_up.sql"
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, upFile), []byte("CREATE TABLE users (id integer);"), 0644))
+	names <- upFile
+	time.Sleep(100 * time.Millisecond)
+	assert.Empty(t, mockStorage.Executed(), "Expected up to wait for the down file")
+
+	downFile := "00001_create_users_down.sql"
+	assert.NoError(t, os.WriteFile(path.Join(migrationDir, downFile), []byte("DROP TABLE users;"), 0644))
+	for i := 0; i < 5; i++ {
+		names <- downFile
+		names <- upFile
+	}
+	names <- "notes.md"
+	time.Sleep(100 * time.Millisecond)
+	assert.Equal(t, []string{"CREATE TABLE users (id integer);"}, mockStorage.Executed(), "Expected a single up after the burst of events")
+
+	cancel()
+	assert.NoError(t, <-done)
+}
+
+func TestWatchRejectsRemotePath(t *testing.T) {
+	app := New(logger.New(), &storage.MockSqlStorage{})
+	assert.ErrorIs(t, app.Watch(context.Background(), "https://example.com/migrations"), ErrRemoteSource)
+}
@@ -0,0 +1,95 @@
+// Package audit пишет журнал действий мигратора в формате JSON Lines: одна запись на каждый переход статуса.
+// Журнал не зависит от уровня и формата логгера и предназначен для разбора внешними системами.
+package audit
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Syslog — значение audit_file, при котором записи уходят в системный syslog вместо файла
+const Syslog = "syslog"
+
+// FileMode — права файла журнала, если его создает мигратор
+const FileMode = 0o640
+
+var ErrSyslogUnsupported = errors.New("syslog audit sink is not supported on this platform")
+
+// Record — одна запись журнала
+type Record struct {
+	Command   string    `json:"command"`
+	Version   int       `json:"version"`
+	Name      string    `json:"name"`
+	Direction string    `json:"direction,omitempty"`
+	Status    string    `json:"status"`
+	Actor     string    `json:"actor"`
+	Timestamp time.Time `json:"timestamp"`
+}
+
+// Writer дописывает записи в приемник. Команда и пользователь задаются один раз на запуск.
+// Методы безопасны для вызова из нескольких горутин.
+type Writer struct {
+	mu      sync.Mutex
+	w       io.Writer
+	command string
+	actor   string
+}
+
+func New(w io.Writer, command, actor string) *Writer {
+	return &Writer{w: w, command: command, actor: actor}
+}
+
+// Open открывает приемник sink: Syslog или путь к файлу, который дописывается и создается при необходимости
+func Open(sink, command, actor string) (*Writer, error) {
+	if strings.EqualFold(sink, Syslog) {
+		w, err := openSyslog()
+		if err != nil {
+			return nil, err
+		}
+		return New(w, command, actor), nil
+	}
+
+	file, err := os.OpenFile(sink, os.O_WRONLY|os.O_APPEND|os.O_CREATE, FileMode)
+	if err != nil {
+		return nil, err
+	}
+	return New(file, command, actor), nil
+}
+
+// Write дописывает запись одной строкой; пустые команда, пользователь и время заполняются значениями запуска
+func (w *Writer) Write(record Record) error {
+	if record.Command == "" {
+		record.Command = w.command
+	}
+	if record.Actor == "" {
+		record.Actor = w.actor
+	}
+	if record.Timestamp.IsZero() {
+		record.Timestamp = time.Now()
+	}
+	record.Timestamp = record.Timestamp.UTC()
+
+	line, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	_, err = w.w.Write(append(line, '\n'))
+	return err
+}
+
+// Close закрывает приемник, если его открыл Open
+func (w *Writer) Close() error {
+	if closer, ok := w.w.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
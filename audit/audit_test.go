@@ -0,0 +1,31 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWriteAppendsJSONLines(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "audit.jsonl")
+	at := time.Date(2024, 3, 1, 15, 30, 0, 0, time.FixedZone("UTC+3", 3*60*60))
+
+	for _, status := range []string{"process", "success"} {
+		writer, err := Open(file, "up", "alice")
+		assert.NoError(t, err)
+		assert.NoError(t, writer.Write(Record{Version: 3, Name: "add_index", Direction: "up", Status: status, Timestamp: at}))
+		assert.NoError(t, writer.Close())
+	}
+
+	content, err := os.ReadFile(file)
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSuffix(string(content), "\n"), "\n")
+	assert.Equal(t, []string{
+		`{"command":"up","version":3,"name":"add_index","direction":"up","status":"process","actor":"alice","timestamp":"2024-03-01T12:30:00Z"}`,
+		`{"command":"up","version":3,"name":"add_index","direction":"up","status":"success","actor":"alice","timestamp":"2024-03-01T12:30:00Z"}`,
+	}, lines, "Expected the file to be appended to, not truncated, and times to be in UTC")
+}
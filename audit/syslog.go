@@ -0,0 +1,12 @@
+//go:build !windows && !plan9
+
+package audit
+
+import (
+	"io"
+	"log/syslog"
+)
+
+func openSyslog() (io.Writer, error) {
+	return syslog.New(syslog.LOG_INFO|syslog.LOG_AUTH, "sql-migrator")
+}
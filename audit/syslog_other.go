@@ -0,0 +1,9 @@
+//go:build windows || plan9
+
+package audit
+
+import "io"
+
+func openSyslog() (io.Writer, error) {
+	return nil, ErrSyslogUnsupported
+}
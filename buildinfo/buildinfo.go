@@ -0,0 +1,43 @@
+// Package buildinfo хранит версию сборки мигратора. Значения подставляются при сборке:
+//
+//	go build -ldflags "-X github.com/juliazadorozhnaya/sql-migrator/buildinfo.Version=v1.2.0 \
+//		-X github.com/juliazadorozhnaya/sql-migrator/buildinfo.Commit=$(git rev-parse --short HEAD) \
+//		-X github.com/juliazadorozhnaya/sql-migrator/buildinfo.Date=$(date -u +%Y-%m-%dT%H:%M:%SZ)"
+package buildinfo
+
+import "fmt"
+
+const unknown = "dev"
+
+var (
+	Version = unknown
+	Commit  = unknown
+	Date    = unknown
+)
+
+// Info — версия, коммит и дата сборки бинарника
+type Info struct {
+	Version string
+	Commit  string
+	Date    string
+}
+
+// Get возвращает сведения о сборке; незаданные через -ldflags поля равны "dev"
+func Get() Info {
+	return Info{
+		Version: orUnknown(Version),
+		Commit:  orUnknown(Commit),
+		Date:    orUnknown(Date),
+	}
+}
+
+func (i Info) String() string {
+	return fmt.Sprintf("sql-migrator %s (commit %s, built %s)", i.Version, i.Commit, i.Date)
+}
+
+func orUnknown(value string) string {
+	if value == "" {
+		return unknown
+	}
+	return value
+}
@@ -0,0 +1,23 @@
+package buildinfo
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetDefaultsToDev(t *testing.T) {
+	assert.Equal(t, Info{Version: "dev", Commit: "dev", Date: "dev"}, Get())
+}
+
+func TestGetUsesLinkerVariables(t *testing.T) {
+	defer func(version, commit, date string) {
+		Version, Commit, Date = version, commit, date
+	}(Version, Commit, Date)
+
+	Version, Commit, Date = "v1.2.0", "abc1234", ""
+
+	info := Get()
+	assert.Equal(t, Info{Version: "v1.2.0", Commit: "abc1234", Date: "dev"}, info)
+	assert.Equal(t, "sql-migrator v1.2.0 (commit abc1234, built dev)", info.String())
+}
@@ -1,38 +1,105 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
 	"github.com/spf13/viper"
 )
 
+var ErrEmptyDSNFile = errors.New("dsn file is empty")
+
 type Config struct {
-	MigratorOpt *Migrator
-	LoggerOpt   *Logger
+	MigratorOpt *Migrator `mapstructure:"migrator"`
+	LoggerOpt   *Logger   `mapstructure:"logger"`
 }
 
 type Migrator struct {
-	DSN       string
-	Dir       string
-	Type      string
-	TableName string
+	DSN           string   `mapstructure:"dsn"`
+	DSNs          []string `mapstructure:"dsns"`
+	DSNFile       string   `mapstructure:"dsn_file"`
+	Dir           string   `mapstructure:"dir"`
+	Type          string   `mapstructure:"type"`
+	TemplatesDir  string   `mapstructure:"templates_dir"`
+	SQLTemplate   string   `mapstructure:"sql_template"`
+	CreateLock    bool     `mapstructure:"create_lock"`
+	TableName     string   `mapstructure:"table_name"`
+	SSLMode       string   `mapstructure:"ssl_mode"`
+	SSLRootCert   string   `mapstructure:"ssl_root_cert"`
+	SSLCert       string   `mapstructure:"ssl_cert"`
+	SSLKey        string   `mapstructure:"ssl_key"`
+	MaxConns      int32    `mapstructure:"max_conns"`
+	Convention    string   `mapstructure:"convention"`
+	Transaction   bool     `mapstructure:"transaction"`
+	LockMode      string   `mapstructure:"lock_mode"`
+	LockKey       int64    `mapstructure:"lock_key"`
+	LockPerDB     bool     `mapstructure:"lock_per_database"`
+	PsqlPath      string   `mapstructure:"psql_path"`
+	TxMode        string   `mapstructure:"tx_mode"`
+	NotifyChannel string   `mapstructure:"notify_channel"`
+	FileMode      string   `mapstructure:"file_mode"`
+	DirMode       string   `mapstructure:"dir_mode"`
+	Timezone      string   `mapstructure:"timezone"`
+	AppName       string   `mapstructure:"app_name"`
+	AuditFile     string   `mapstructure:"audit_file"`
 }
 
 type Logger struct {
-	Level string
+	Level string `mapstructure:"level"`
 }
 
 func LoadConfig(configPath string) (*Config, error) {
-	viper.SetConfigFile(configPath)
+	return LoadConfigForEnv(configPath, "")
+}
+
+// LoadConfigForEnv читает базовый конфиг и накладывает поверх него конфиг окружения env,
+// лежащий рядом: для config.yaml и env prod это config.prod.yaml. Пустой env — только базовый конфиг.
+func LoadConfigForEnv(configPath, env string) (*Config, error) {
+	v := viper.New()
+	v.SetConfigFile(configPath)
 
-	if err := viper.ReadInConfig(); err != nil {
+	if err := v.ReadInConfig(); err != nil {
 		return nil, fmt.Errorf("error reading config file: %w", err)
 	}
 
+	if env != "" {
+		envPath := EnvConfigPath(configPath, env)
+		v.SetConfigFile(envPath)
+		if err := v.MergeInConfig(); err != nil {
+			return nil, fmt.Errorf("error reading config file %s: %w", envPath, err)
+		}
+	}
+
 	var config Config
 
-	if err := viper.Unmarshal(&config); err != nil {
+	if err := v.Unmarshal(&config); err != nil {
 		return nil, fmt.Errorf("error unmarshaling config: %w", err)
 	}
 
 	return &config, nil
 }
+
+// EnvConfigPath возвращает путь конфига окружения env рядом с базовым: config.yaml -> config.<env>.yaml
+func EnvConfigPath(configPath, env string) string {
+	ext := filepath.Ext(configPath)
+	return strings.TrimSuffix(configPath, ext) + "." + env + ext
+}
+
+// ReadDSNFile читает строку подключения из файла, например из секрета Docker или Kubernetes,
+// отбрасывая пробелы и перевод строки по краям
+func ReadDSNFile(dsnFile string) (string, error) {
+	content, err := os.ReadFile(dsnFile)
+	if err != nil {
+		return "", fmt.Errorf("error reading dsn file: %w", err)
+	}
+
+	dsn := strings.TrimSpace(string(content))
+	if dsn == "" {
+		return "", fmt.Errorf("%w: %s", ErrEmptyDSNFile, dsnFile)
+	}
+
+	return dsn, nil
+}
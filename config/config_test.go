@@ -0,0 +1,75 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const baseConfig = `migrator:
+  dsn: postgres://localhost/dev
+  dir: ./migrations
+  table_name: schema_migrations
+logger:
+  level: DEBUG
+`
+
+const prodConfig = `migrator:
+  dsn: postgres://db.prod/app
+logger:
+  level: ERROR
+`
+
+func writeConfigs(t *testing.T) string {
+	dir := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.yaml"), []byte(baseConfig), 0644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "config.prod.yaml"), []byte(prodConfig), 0644))
+	return filepath.Join(dir, "config.yaml")
+}
+
+func TestLoadConfigForEnvMergesOverride(t *testing.T) {
+	configPath := writeConfigs(t)
+
+	config, err := LoadConfigForEnv(configPath, "prod")
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://db.prod/app", config.MigratorOpt.DSN)
+	assert.Equal(t, "ERROR", config.LoggerOpt.Level)
+	assert.Equal(t, "./migrations", config.MigratorOpt.Dir, "Expected keys missing from the env config to come from the base config")
+	assert.Equal(t, "schema_migrations", config.MigratorOpt.TableName)
+}
+
+func TestLoadConfigForEnvWithoutEnv(t *testing.T) {
+	configPath := writeConfigs(t)
+
+	config, err := LoadConfigForEnv(configPath, "")
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://localhost/dev", config.MigratorOpt.DSN)
+	assert.Equal(t, "DEBUG", config.LoggerOpt.Level)
+
+	_, err = LoadConfigForEnv(configPath, "staging")
+	assert.Error(t, err, "Expected a missing env config to fail instead of silently using the base config")
+}
+
+func TestEnvConfigPath(t *testing.T) {
+	assert.Equal(t, "conf/config.prod.yaml", EnvConfigPath("conf/config.yaml", "prod"))
+	assert.Equal(t, "config.staging.toml", EnvConfigPath("config.toml", "staging"))
+}
+
+func TestReadDSNFile(t *testing.T) {
+	dsnFile := filepath.Join(t.TempDir(), "dsn")
+	assert.NoError(t, os.WriteFile(dsnFile, []byte("  postgres://user:secret@db/app\n"), 0600))
+
+	dsn, err := ReadDSNFile(dsnFile)
+	assert.NoError(t, err)
+	assert.Equal(t, "postgres://user:secret@db/app", dsn)
+
+	_, err = ReadDSNFile(filepath.Join(t.TempDir(), "missing"))
+	assert.ErrorIs(t, err, os.ErrNotExist)
+	assert.Contains(t, err.Error(), "error reading dsn file")
+
+	assert.NoError(t, os.WriteFile(dsnFile, []byte("\n"), 0600))
+	_, err = ReadDSNFile(dsnFile)
+	assert.ErrorIs(t, err, ErrEmptyDSNFile)
+}
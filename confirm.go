@@ -0,0 +1,60 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+var ErrNotConfirmed = errors.New("destructive command was not confirmed, pass -yes to run it without a prompt")
+
+// destructiveCommands — команды, которые могут потерять данные и требуют подтверждения
+var destructiveCommands = map[string]bool{
+	"down":    true,
+	"redo":    true,
+	"cleanup": true,
+}
+
+var (
+	// confirmInput и confirmOutput — откуда читается ответ и куда пишется вопрос; переменные, чтобы тесты подменяли stdin
+	confirmInput  io.Reader = os.Stdin
+	confirmOutput io.Writer = os.Stdout
+	// isTerminal проверяет, что stdout — терминал и вопрос увидит человек
+	isTerminal = func() bool {
+		info, err := os.Stdout.Stat()
+		return err == nil && info.Mode()&os.ModeCharDevice != 0
+	}
+)
+
+// confirmDestructive спрашивает подтверждение перед разрушительной командой. С -yes вопрос не задается,
+// а без терминала команда не выполняется, чтобы автоматизация не зависла и не потеряла данные молча.
+func confirmDestructive(command string, yes bool) error {
+	if !destructiveCommands[command] || yes {
+		return nil
+	}
+
+	if !isTerminal() || !confirm(fmt.Sprintf("Command %s can lose data. Continue?", command)) {
+		return fmt.Errorf("%w: %s", ErrNotConfirmed, command)
+	}
+	return nil
+}
+
+// confirm задает вопрос prompt и возвращает true только на ответ y или yes
+func confirm(prompt string) bool {
+	fmt.Fprintf(confirmOutput, "%s [y/N]: ", prompt)
+
+	answer, err := bufio.NewReader(confirmInput).ReadString('\n')
+	if err != nil && answer == "" {
+		return false
+	}
+
+	switch strings.ToLower(strings.TrimSpace(answer)) {
+	case "y", "yes":
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,51 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func withConfirmInput(t *testing.T, input string, terminal bool) *bytes.Buffer {
+	oldInput, oldOutput, oldTerminal := confirmInput, confirmOutput, isTerminal
+	t.Cleanup(func() { confirmInput, confirmOutput, isTerminal = oldInput, oldOutput, oldTerminal })
+
+	var output bytes.Buffer
+	confirmInput = strings.NewReader(input)
+	confirmOutput = &output
+	isTerminal = func() bool { return terminal }
+	return &output
+}
+
+func TestConfirmAcceptsYes(t *testing.T) {
+	for _, answer := range []string{"y\n", "YES\n", " yes "} {
+		output := withConfirmInput(t, answer, true)
+		assert.True(t, confirm("Continue?"), "Expected %q to confirm", answer)
+		assert.Equal(t, "Continue? [y/N]: ", output.String())
+	}
+}
+
+func TestConfirmDeclinesByDefault(t *testing.T) {
+	for _, answer := range []string{"\n", "n\n", "maybe\n", ""} {
+		withConfirmInput(t, answer, true)
+		assert.False(t, confirm("Continue?"), "Expected %q to decline", answer)
+	}
+}
+
+func TestConfirmDestructive(t *testing.T) {
+	withConfirmInput(t, "y\n", true)
+	assert.NoError(t, confirmDestructive("down", false))
+
+	withConfirmInput(t, "n\n", true)
+	assert.ErrorIs(t, confirmDestructive("redo", false), ErrNotConfirmed)
+
+	output := withConfirmInput(t, "y\n", false)
+	assert.ErrorIs(t, confirmDestructive("cleanup", false), ErrNotConfirmed, "Expected a non-terminal run to refuse without -yes")
+	assert.Empty(t, output.String(), "Expected no prompt without a terminal")
+
+	withConfirmInput(t, "", false)
+	assert.NoError(t, confirmDestructive("down", true))
+	assert.NoError(t, confirmDestructive("up", false))
+}
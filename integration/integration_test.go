@@ -4,17 +4,25 @@
 package integration
 
 import (
+	"bytes"
 	"context"
 	"database/sql"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"os/exec"
+	"path"
+	"strings"
+	"sync"
 	"testing"
+	"time"
 
 	"github.com/juliazadorozhnaya/sql-migrator/app"
 	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/processes"
 	"github.com/juliazadorozhnaya/sql-migrator/storage"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
 )
 
 const (
@@ -25,9 +33,13 @@ const (
 	dbPort     = "5432"
 )
 
-func getDBConnection() *sql.DB {
-	connStr := fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
+func connString() string {
+	return fmt.Sprintf("host=%s port=%s user=%s password=%s dbname=%s sslmode=disable",
 		dbHost, dbPort, dbUser, dbPassword, dbName)
+}
+
+func getDBConnection() *sql.DB {
+	connStr := connString()
 	db, err := sql.Open("postgres", connStr)
 	if err != nil {
 		log.Fatal(err)
@@ -35,12 +47,12 @@ func getDBConnection() *sql.DB {
 	return db
 }
 
-func setup() *storage.PostgresStorage {
+func setup(opts ...storage.Option) *storage.PostgresStorage {
 	logger := logger.New()
 	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
 		dbUser, dbPassword, dbHost, dbPort, dbName)
 
-	storage := storage.New(connStr, logger)
+	storage := storage.New(connStr, logger, opts...)
 	ctx := context.Background()
 	if err := storage.Connect(ctx); err != nil {
 		log.Fatal(err)
@@ -66,17 +78,19 @@ func TestMigrations(t *testing.T) {
 	defer teardown(storage)
 
 	logger := logger.New()
-	application := app.New(logger, storage)
+	application := app.New(logger, storage, app.WithMkdir(true))
 
 	migrationDir := "../migrations"
-	os.MkdirAll(migrationDir, os.ModePerm)
 
-	application.Create("create_users", migrationDir, "sql")
+	files, err := application.Create(context.Background(), "create_users", migrationDir, "sql")
+	if err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
 
-	application.Up(migrationDir)
+	application.Up(context.Background(), migrationDir)
 
 	var tableName string
-	err := db.QueryRow("SELECT table_name FROM information_schema.tables WHERE table_name = 'users'").Scan(&tableName)
+	err = db.QueryRow("SELECT table_name FROM information_schema.tables WHERE table_name = 'users'").Scan(&tableName)
 	if err != nil {
 		t.Fatalf("Expected table 'users' to be created, but got error: %v", err)
 	}
@@ -84,13 +98,598 @@ func TestMigrations(t *testing.T) {
 		t.Fatalf("Expected table 'users', but got: %s", tableName)
 	}
 
-	application.Down(migrationDir)
+	application.Down(context.Background(), migrationDir)
 
 	err = db.QueryRow("SELECT table_name FROM information_schema.tables WHERE table_name = 'users'").Scan(&tableName)
 	if err == nil || tableName == "users" {
 		t.Fatalf("Expected table 'users' to be dropped, but it still exists")
 	}
 
-	os.Remove(fmt.Sprintf("%s/00001_%s_up.sql", migrationDir, "create_users"))
-	os.Remove(fmt.Sprintf("%s/00001_%s_down.sql", migrationDir, "create_users"))
+	for _, file := range files {
+		os.Remove(file)
+	}
+}
+
+func countAdvisoryLocks(t *testing.T, db *sql.DB) int {
+	var count int
+	err := db.QueryRow("SELECT count(*) FROM pg_locks WHERE locktype = 'advisory'").Scan(&count)
+	if err != nil {
+		t.Fatalf("Failed to query pg_locks: %v", err)
+	}
+	return count
+}
+
+func TestAdvisoryLockSingleConnection(t *testing.T) {
+	db := getDBConnection()
+	defer db.Close()
+
+	storage := setup()
+	defer teardown(storage)
+
+	ctx := context.Background()
+	if err := storage.Lock(ctx); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	if count := countAdvisoryLocks(t, db); count != 1 {
+		t.Fatalf("Expected advisory lock to be held, got %d locks", count)
+	}
+
+	if err := storage.Migrate(ctx, "SELECT 1;"); err != nil {
+		t.Fatalf("Failed to migrate: %v", err)
+	}
+
+	if err := storage.Unlock(ctx); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+	if count := countAdvisoryLocks(t, db); count != 0 {
+		t.Fatalf("Expected advisory lock to be released, got %d locks", count)
+	}
+}
+
+func TestTransactionLockReleasedOnRollback(t *testing.T) {
+	db := getDBConnection()
+	defer db.Close()
+
+	pgStorage := setup(storage.WithLockMode(storage.LockModeTransaction))
+	defer teardown(pgStorage)
+
+	ctx := context.Background()
+	if err := pgStorage.Lock(ctx); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	if count := countAdvisoryLocks(t, db); count != 1 {
+		t.Fatalf("Expected advisory lock to be held, got %d locks", count)
+	}
+
+	if err := pgStorage.Migrate(ctx, "SELECT * FROM missing_table;"); err == nil {
+		t.Fatalf("Expected migration to fail")
+	}
+
+	if err := pgStorage.Unlock(ctx); err != nil {
+		t.Fatalf("Failed to end lock transaction: %v", err)
+	}
+	if count := countAdvisoryLocks(t, db); count != 0 {
+		t.Fatalf("Expected advisory lock to be released on rollback, got %d locks", count)
+	}
+}
+
+func TestAdvisoryLockReleasedAfterRun(t *testing.T) {
+	db := getDBConnection()
+	defer db.Close()
+
+	logger := logger.New()
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		dbUser, dbPassword, dbHost, dbPort, dbName)
+	pgStorage := storage.New(connStr, logger, storage.WithMaxConns(4))
+	defer func() {
+		ctx := context.Background()
+		if err := pgStorage.Connect(ctx); err == nil {
+			pgStorage.DeleteMigrations(ctx)
+			pgStorage.Close()
+		}
+	}()
+
+	migrationDir := t.TempDir()
+	application := app.New(logger, pgStorage)
+	if _, err := application.Create(context.Background(), "noop", migrationDir, "sql"); err != nil {
+		t.Fatalf("Create failed: %v", err)
+	}
+	application.Up(context.Background(), migrationDir)
+
+	if count := countAdvisoryLocks(t, db); count != 0 {
+		t.Fatalf("Expected advisory lock to be released after up, got %d locks", count)
+	}
+}
+
+func TestConcurrentConnect(t *testing.T) {
+	db := getDBConnection()
+	defer db.Close()
+
+	const tableName = "concurrent_connect_migrations"
+	defer db.Exec("DROP TABLE IF EXISTS " + tableName)
+
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable",
+		dbUser, dbPassword, dbHost, dbPort, dbName)
+
+	var (
+		wg   sync.WaitGroup
+		errs = make(chan error, 8)
+	)
+	for i := 0; i < cap(errs); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			pgStorage := storage.New(connStr, logger.New(), storage.WithTableName(tableName))
+			if err := pgStorage.Connect(context.Background()); err != nil {
+				errs <- err
+				return
+			}
+			errs <- pgStorage.Close()
+		}()
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Fatalf("Expected concurrent Connect to succeed, got %v", err)
+		}
+	}
+}
+
+func TestRecordSQL(t *testing.T) {
+	pgStorage := setup()
+	defer teardown(pgStorage)
+
+	// Повторный Connect не должен падать на уже добавленной колонке
+	if err := pgStorage.Connect(context.Background()); err != nil {
+		t.Fatalf("Expected a second Connect to succeed, got %v", err)
+	}
+
+	migrator := processes.New(pgStorage, logger.New())
+	migrator.SetRecordSQL(true)
+	migrator.Create("record_sql", "CREATE TABLE record_sql (id INT);", "DROP TABLE record_sql;", nil, nil)
+	if _, err := migrator.Up(context.Background()); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+	defer migrator.Down(context.Background())
+
+	row, err := pgStorage.SelectMigrationByVersion(context.Background(), 1)
+	if err != nil {
+		t.Fatalf("SelectMigrationByVersion failed: %v", err)
+	}
+	if row.GetAppliedSQL() != "CREATE TABLE record_sql (id INT);" {
+		t.Fatalf("Expected the applied SQL to be recorded, got %q", row.GetAppliedSQL())
+	}
+}
+
+func TestConcurrentIndexFailureThenRetry(t *testing.T) {
+	db := getDBConnection()
+	defer db.Close()
+
+	pgStorage := setup(storage.WithTransaction(true))
+	defer teardown(pgStorage)
+	defer db.Exec("DROP TABLE IF EXISTS concurrent_users;")
+
+	ctx := context.Background()
+	if _, err := db.Exec("CREATE TABLE concurrent_users (email TEXT); INSERT INTO concurrent_users VALUES ('a'), ('a');"); err != nil {
+		t.Fatalf("Failed to prepare table: %v", err)
+	}
+
+	err := pgStorage.Migrate(ctx, "CREATE UNIQUE INDEX CONCURRENTLY concurrent_users_email_idx ON concurrent_users (email);")
+	if !errors.Is(err, storage.ErrInvalidIndex) {
+		t.Fatalf("Expected invalid index to be reported, got: %v", err)
+	}
+
+	err = pgStorage.Migrate(ctx, "CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS concurrent_users_email_idx ON concurrent_users (email);")
+	if !errors.Is(err, storage.ErrInvalidIndex) {
+		t.Fatalf("Expected IF NOT EXISTS not to hide the invalid index, got: %v", err)
+	}
+
+	if _, err := db.Exec("DELETE FROM concurrent_users; DROP INDEX concurrent_users_email_idx;"); err != nil {
+		t.Fatalf("Failed to clean up invalid index: %v", err)
+	}
+
+	err = pgStorage.Migrate(ctx, "CREATE UNIQUE INDEX CONCURRENTLY IF NOT EXISTS concurrent_users_email_idx ON concurrent_users (email);")
+	if err != nil {
+		t.Fatalf("Expected retry to succeed, got: %v", err)
+	}
+}
+
+func TestTxModeAllRollsBackEarlierMigrations(t *testing.T) {
+	db := getDBConnection()
+	defer db.Close()
+
+	pgStorage := setup()
+	defer teardown(pgStorage)
+
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"00001_create_tx_first_up.sql":  "CREATE TABLE tx_first (id SERIAL PRIMARY KEY);",
+		"00002_create_tx_second_up.sql": "CREATE TABLE tx_second (id SERIAL PRIMARY KEY);",
+		"00003_broken_up.sql":           "SELECT * FROM tx_missing_table;",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write migration: %v", err)
+		}
+	}
+
+	application := app.New(logger.New(), pgStorage, app.WithTxMode(processes.TxModeAll))
+	if err := application.Up(context.Background(), migrationDir); err == nil {
+		t.Fatalf("Expected up to fail on the last migration")
+	}
+
+	for _, table := range []string{"tx_first", "tx_second"} {
+		var exists bool
+		err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = $1)", table).Scan(&exists)
+		if err != nil {
+			t.Fatalf("Failed to query information_schema: %v", err)
+		}
+		if exists {
+			t.Fatalf("Expected table %s to be rolled back", table)
+		}
+	}
+}
+
+func TestDryRunValidate(t *testing.T) {
+	db := getDBConnection()
+	defer db.Close()
+
+	pgStorage := setup()
+	defer teardown(pgStorage)
+
+	validDir := t.TempDir()
+	if err := os.WriteFile(path.Join(validDir, "00001_create_dry_run_up.sql"), []byte("CREATE TABLE dry_run (id SERIAL PRIMARY KEY);"), 0644); err != nil {
+		t.Fatalf("Failed to write migration: %v", err)
+	}
+
+	application := app.New(logger.New(), pgStorage)
+	if err := application.DryRun(context.Background(), validDir, processes.DryRunValidate); err != nil {
+		t.Fatalf("Expected valid migration to pass validation: %v", err)
+	}
+
+	invalidDir := t.TempDir()
+	if err := os.WriteFile(path.Join(invalidDir, "00001_broken_up.sql"), []byte("CREATE TABLE dry_run_broken (id SERIAL PRIMARY KEY,);"), 0644); err != nil {
+		t.Fatalf("Failed to write migration: %v", err)
+	}
+
+	if err := application.DryRun(context.Background(), invalidDir, processes.DryRunValidate); !errors.Is(err, processes.ErrDryRunFailed) {
+		t.Fatalf("Expected ErrDryRunFailed for invalid migration, got %v", err)
+	}
+
+	var exists bool
+	err := db.QueryRow("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'dry_run')").Scan(&exists)
+	if err != nil {
+		t.Fatalf("Failed to query information_schema: %v", err)
+	}
+	if exists {
+		t.Fatalf("Expected validation to roll back the created table")
+	}
+
+	var rows int
+	if err := db.QueryRow("SELECT count(*) FROM " + pgStorage.TableName()).Scan(&rows); err != nil {
+		t.Fatalf("Failed to count migrations: %v", err)
+	}
+	if rows != 0 {
+		t.Fatalf("Expected dry-run not to record statuses, got %d rows", rows)
+	}
+}
+
+func TestMinServerVersionGuard(t *testing.T) {
+	pgStorage := setup()
+	defer teardown(pgStorage)
+
+	serverVersion, err := pgStorage.ServerVersion(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get server version: %v", err)
+	}
+	major := serverVersion / 10000
+
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"00001_supported_up.sql": fmt.Sprintf("-- migrator:min-server %d\nSELECT 1;", major),
+		"00002_too_new_up.sql":   fmt.Sprintf("-- migrator:min-server %d\nSELECT 2;", major+1),
+	}
+	for name, content := range files {
+		if err := os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write migration: %v", err)
+		}
+	}
+
+	application := app.New(logger.New(), pgStorage)
+	err = application.Up(context.Background(), migrationDir)
+	if !errors.Is(err, processes.ErrServerTooOld) {
+		t.Fatalf("Expected ErrServerTooOld for a migration requiring Postgres %d, got %v", major+1, err)
+	}
+
+	migration, err := pgStorage.SelectMigrationByVersion(context.Background(), 1)
+	if err != nil || migration.GetStatus() != storage.StatusSuccess {
+		t.Fatalf("Expected the migration matching the server version to be applied, got %v, %v", migration, err)
+	}
+	if _, err := pgStorage.SelectMigrationByVersion(context.Background(), 2); !errors.Is(err, storage.ErrMigrationNotFound) {
+		t.Fatalf("Expected the guarded migration not to be recorded, got %v", err)
+	}
+}
+
+// lockedBuffer — буфер лога, в который пишут Up и наблюдение за активностью из разных горутин
+type lockedBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *lockedBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *lockedBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func TestWatchActivityReportsSlowMigration(t *testing.T) {
+	pgStorage := setup(storage.WithAppName("sql-migrator-activity-test"))
+	defer teardown(pgStorage)
+
+	migrationDir := t.TempDir()
+	if err := os.WriteFile(path.Join(migrationDir, "00001_slow_up.sql"), []byte("SELECT pg_sleep(3);"), 0644); err != nil {
+		t.Fatalf("Failed to write migration: %v", err)
+	}
+
+	var out lockedBuffer
+	application := app.New(logger.NewWithOutput(&out, "info"), pgStorage, app.WithWatchActivity(500*time.Millisecond))
+	if err := application.Up(context.Background(), migrationDir); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "Activity: pid") || !strings.Contains(out.String(), "SELECT pg_sleep(3);") {
+		t.Fatalf("Expected an activity line for the slow migration, got:\n%s", out.String())
+	}
+}
+
+func TestPsqlMigration(t *testing.T) {
+	psqlPath, err := exec.LookPath("psql")
+	if err != nil {
+		t.Skip("psql is not installed")
+	}
+
+	db := getDBConnection()
+	defer db.Close()
+
+	pgStorage := setup(storage.WithPsql(psqlPath))
+	defer teardown(pgStorage)
+	defer db.Exec("DROP TABLE IF EXISTS psql_loaded")
+
+	migrationDir := t.TempDir()
+	content := storage.PsqlMarker + "\n\\echo loading through psql\nCREATE TABLE psql_loaded (id INT);\n"
+	if err := os.WriteFile(path.Join(migrationDir, "00001_psql_loaded_up.sql"), []byte(content), 0644); err != nil {
+		t.Fatalf("Failed to write migration: %v", err)
+	}
+
+	var out lockedBuffer
+	application := app.New(logger.NewWithOutput(&out, "info"), pgStorage)
+	if err := application.Up(context.Background(), migrationDir); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	if !strings.Contains(out.String(), "loading through psql") {
+		t.Fatalf("Expected \\echo output from psql in the log, got:\n%s", out.String())
+	}
+
+	var exists bool
+	err = db.QueryRow("SELECT EXISTS (SELECT 1 FROM information_schema.tables WHERE table_name = 'psql_loaded')").Scan(&exists)
+	if err != nil {
+		t.Fatalf("Failed to query information_schema: %v", err)
+	}
+	if !exists {
+		t.Fatalf("Expected psql to create table psql_loaded")
+	}
+}
+
+func TestUpNotifiesChannel(t *testing.T) {
+	listener := pq.NewListener(connString(), time.Second, time.Minute, nil)
+	defer listener.Close()
+	if err := listener.Listen("migrator_channel"); err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+
+	pgStorage := setup()
+	defer teardown(pgStorage)
+
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"00001_create_notify_first_up.sql":  "CREATE TABLE notify_first (id SERIAL PRIMARY KEY);",
+		"00002_create_notify_second_up.sql": "CREATE TABLE notify_second (id SERIAL PRIMARY KEY);",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write migration: %v", err)
+		}
+	}
+	defer func() {
+		db := getDBConnection()
+		defer db.Close()
+		db.Exec("DROP TABLE IF EXISTS notify_first, notify_second;")
+	}()
+
+	application := app.New(logger.New(), pgStorage, app.WithNotifyChannel("migrator_channel"))
+	if err := application.Up(context.Background(), migrationDir); err != nil {
+		t.Fatalf("Up failed: %v", err)
+	}
+
+	select {
+	case notification := <-listener.Notify:
+		if notification.Extra != "2" {
+			t.Fatalf("Expected payload 2, got %q", notification.Extra)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatalf("Expected a notification on migrator_channel")
+	}
+}
+
+func TestPing(t *testing.T) {
+	pgStorage := setup()
+	defer teardown(pgStorage)
+
+	if err := pgStorage.Ping(context.Background()); err != nil {
+		t.Fatalf("Expected ping to succeed: %v", err)
+	}
+}
+
+func TestLockHeldByAnotherMigrator(t *testing.T) {
+	first := setup()
+	defer teardown(first)
+	second := setup(storage.WithLockWait(100 * time.Millisecond))
+	defer second.Close()
+
+	ctx := context.Background()
+	if err := first.Lock(ctx); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+
+	if err := second.Lock(ctx); !errors.Is(err, storage.ErrLockHeld) {
+		t.Fatalf("Expected ErrLockHeld, got %v", err)
+	}
+
+	if err := first.Unlock(ctx); err != nil {
+		t.Fatalf("Failed to release lock: %v", err)
+	}
+	if err := second.Lock(ctx); err != nil {
+		t.Fatalf("Expected lock to be available after release: %v", err)
+	}
+	second.Unlock(ctx)
+}
+
+func TestWaitForLockReportsHolder(t *testing.T) {
+	connStr := fmt.Sprintf("postgres://%s:%s@%s:%s/%s?sslmode=disable", dbUser, dbPassword, dbHost, dbPort, dbName)
+	ctx := context.Background()
+
+	first := storage.New(connStr, logger.New(), storage.WithAppName("sql-migrator-holder-test"))
+	if err := first.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer teardown(first)
+
+	var out lockedBuffer
+	second := storage.New(connStr, logger.NewWithOutput(&out, "info"), storage.WithLockWait(1500*time.Millisecond))
+	if err := second.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer second.Close()
+
+	if err := first.Lock(ctx); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	defer first.Unlock(ctx)
+
+	if err := second.Lock(ctx); !errors.Is(err, storage.ErrLockHeld) {
+		t.Fatalf("Expected ErrLockHeld, got %v", err)
+	}
+
+	if !strings.Contains(out.String(), "Advisory lock is held by pid") || !strings.Contains(out.String(), "sql-migrator-holder-test") {
+		t.Fatalf("Expected the lock holder to be reported, got:\n%s", out.String())
+	}
+}
+
+func TestVerifyConnectionWithRestrictedRole(t *testing.T) {
+	pgStorage := setup()
+	defer teardown(pgStorage)
+
+	db := getDBConnection()
+	defer db.Close()
+
+	for _, stmt := range []string{
+		"DROP ROLE IF EXISTS migrator_restricted",
+		"CREATE ROLE migrator_restricted LOGIN PASSWORD 'restricted'",
+		"GRANT SELECT ON schema_migrations, migration_events TO migrator_restricted",
+	} {
+		if _, err := db.Exec(stmt); err != nil {
+			t.Fatalf("Failed to prepare role: %v", err)
+		}
+	}
+	defer func() {
+		db.Exec("DROP OWNED BY migrator_restricted")
+		db.Exec("DROP ROLE migrator_restricted")
+	}()
+
+	connStr := fmt.Sprintf("postgres://migrator_restricted:restricted@%s:%s/%s?sslmode=disable", dbHost, dbPort, dbName)
+	restricted := storage.New(connStr, logger.New())
+
+	var out bytes.Buffer
+	err := app.New(logger.New(), restricted).VerifyConnection(context.Background(), &out)
+	if !errors.Is(err, app.ErrMissingPrivileges) {
+		t.Fatalf("Expected ErrMissingPrivileges, got %v", err)
+	}
+
+	report := out.String()
+	for _, expected := range []string{
+		`[ok]   SELECT on "schema_migrations"`,
+		`[fail] INSERT on "schema_migrations": not granted`,
+		`hint: GRANT INSERT ON "schema_migrations" TO "migrator_restricted"`,
+		`[fail] DELETE on "schema_migrations": not granted`,
+		`[fail] INSERT on "migration_events": not granted`,
+		"[ok]   EXECUTE on pg_try_advisory_lock and pg_advisory_unlock",
+	} {
+		if !strings.Contains(report, expected) {
+			t.Fatalf("Expected %q in the report, got:\n%s", expected, report)
+		}
+	}
+}
+
+func TestDatabaseSQLStorage(t *testing.T) {
+	db := getDBConnection()
+	defer db.Close()
+
+	ctx := context.Background()
+	sqlStorage := storage.NewSQLWithDB(db, logger.New(), storage.WithSQLTableName("sql_schema_migrations"), storage.WithSQLTransaction(true))
+	if err := sqlStorage.Connect(ctx); err != nil {
+		t.Fatalf("Failed to connect: %v", err)
+	}
+	defer func() {
+		db.Exec("DROP TABLE IF EXISTS sql_schema_migrations, sql_users;")
+	}()
+
+	migrationDir := t.TempDir()
+	files := map[string]string{
+		"00001_create_sql_users_up.sql":   "CREATE TABLE sql_users (id SERIAL PRIMARY KEY);\nALTER TABLE sql_users ADD COLUMN name TEXT;",
+		"00001_create_sql_users_down.sql": "DROP TABLE sql_users;",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(path.Join(migrationDir, name), []byte(content), 0644); err != nil {
+			t.Fatalf("Failed to write migration: %v", err)
+		}
+	}
+
+	application := app.New(logger.New(), sqlStorage)
+	if err := application.Up(ctx, migrationDir); err != nil {
+		t.Fatalf("Expected up to succeed: %v", err)
+	}
+
+	migration, err := sqlStorage.SelectMigrationByVersion(ctx, 1)
+	if err != nil {
+		t.Fatalf("Failed to select migration: %v", err)
+	}
+	if migration.GetStatus() != storage.StatusSuccess {
+		t.Fatalf("Expected status %s, got %s", storage.StatusSuccess, migration.GetStatus())
+	}
+
+	other := storage.NewSQLWithDB(db, logger.New(), storage.WithSQLTableName("sql_schema_migrations"))
+	if err := sqlStorage.Lock(ctx); err != nil {
+		t.Fatalf("Failed to acquire lock: %v", err)
+	}
+	if err := other.Lock(ctx); !errors.Is(err, storage.ErrLockHeld) {
+		t.Fatalf("Expected ErrLockHeld, got %v", err)
+	}
+	sqlStorage.Unlock(ctx)
+
+	if err := application.Down(ctx, migrationDir); err != nil {
+		t.Fatalf("Expected down to succeed: %v", err)
+	}
+	migration, err = sqlStorage.SelectLastMigrationByStatus(ctx, storage.StatusCancel)
+	if err != nil || migration.GetVersion() != 1 {
+		t.Fatalf("Expected version 1 to be rolled back: %v", err)
+	}
 }
@@ -0,0 +1,165 @@
+package lint
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+const (
+	SeverityWarning = "warning"
+	SeverityError   = "error"
+
+	DirectionUp   = "up"
+	DirectionDown = "down"
+)
+
+// Statement — отдельное SQL-выражение миграции без комментариев
+type Statement struct {
+	Direction string
+	SQL       string
+}
+
+// Rule — правило проверки: Match вызывается для каждого выражения миграции
+type Rule struct {
+	Name     string
+	Severity string
+	Message  string
+	Match    func(stmt Statement) bool
+}
+
+// Issue — срабатывание правила на выражении миграции
+type Issue struct {
+	Version   int
+	Name      string
+	Direction string
+	Rule      string
+	Severity  string
+	Message   string
+	Statement string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%05d_%s %s: %s [%s] %s: %s", i.Version, i.Name, i.Direction, i.Severity, i.Rule, i.Message, i.Statement)
+}
+
+var (
+	regDropTable         = regexp.MustCompile(`(?i)^DROP\s+TABLE\s`)
+	regDropTableIfExists = regexp.MustCompile(`(?i)^DROP\s+TABLE\s+IF\s+EXISTS\s`)
+	regCreateIndex       = regexp.MustCompile(`(?i)^CREATE\s+(UNIQUE\s+)?INDEX\s`)
+	regConcurrently      = regexp.MustCompile(`(?i)^CREATE\s+(UNIQUE\s+)?INDEX\s+CONCURRENTLY\s`)
+	regDestructive       = regexp.MustCompile(`(?i)^(DROP\s+(TABLE|SCHEMA|DATABASE)\s|TRUNCATE\s|ALTER\s+TABLE\s+.+\sDROP\s+COLUMN\s|DELETE\s+FROM\s+[^\s]+\s*$)`)
+	regLineComment       = regexp.MustCompile(`--[^\n]*`)
+	regBlockComment      = regexp.MustCompile(`(?s)/\*.*?\*/`)
+	regSpaces            = regexp.MustCompile(`\s+`)
+
+	registryMu sync.RWMutex
+	registry   = []Rule{
+		{
+			Name:     "drop-table-if-exists",
+			Severity: SeverityWarning,
+			Message:  "DROP TABLE without IF EXISTS fails when the table is missing",
+			Match: func(stmt Statement) bool {
+				return regDropTable.MatchString(stmt.SQL) && !regDropTableIfExists.MatchString(stmt.SQL)
+			},
+		},
+		{
+			Name:     "create-index-concurrently",
+			Severity: SeverityWarning,
+			Message:  "CREATE INDEX without CONCURRENTLY locks writes to the table while the index is built",
+			Match: func(stmt Statement) bool {
+				return regCreateIndex.MatchString(stmt.SQL) && !regConcurrently.MatchString(stmt.SQL)
+			},
+		},
+		{
+			Name:     "destructive-up",
+			Severity: SeverityError,
+			Message:  "destructive statement in up migration loses data",
+			Match: func(stmt Statement) bool {
+				return stmt.Direction == DirectionUp && regDestructive.MatchString(stmt.SQL)
+			},
+		},
+	}
+)
+
+// Register добавляет правило в набор, которым пользуются линтеры, созданные через New
+func Register(rule Rule) {
+	registryMu.Lock()
+	defer registryMu.Unlock()
+
+	registry = append(registry, rule)
+}
+
+// Rules возвращает копию зарегистрированных правил
+func Rules() []Rule {
+	registryMu.RLock()
+	defer registryMu.RUnlock()
+
+	return append([]Rule(nil), registry...)
+}
+
+type Linter struct {
+	rules []Rule
+}
+
+// New создает линтер с зарегистрированными правилами или с переданными, если они указаны
+func New(rules ...Rule) *Linter {
+	if len(rules) == 0 {
+		rules = Rules()
+	}
+
+	return &Linter{rules: rules}
+}
+
+// Lint проверяет up- и down-части миграции
+func (l *Linter) Lint(version int, name, up, down string) []Issue {
+	var issues []Issue
+	for _, part := range []struct{ direction, sql string }{{DirectionUp, up}, {DirectionDown, down}} {
+		for _, stmt := range SplitStatements(part.direction, part.sql) {
+			for _, rule := range l.rules {
+				if rule.Match(stmt) {
+					issues = append(issues, Issue{
+						Version:   version,
+						Name:      name,
+						Direction: part.direction,
+						Rule:      rule.Name,
+						Severity:  rule.Severity,
+						Message:   rule.Message,
+						Statement: stmt.SQL,
+					})
+				}
+			}
+		}
+	}
+
+	return issues
+}
+
+// HasErrors проверяет, есть ли среди замечаний ошибки
+func HasErrors(issues []Issue) bool {
+	for _, issue := range issues {
+		if issue.Severity == SeverityError {
+			return true
+		}
+	}
+
+	return false
+}
+
+// SplitStatements убирает комментарии и делит SQL на выражения по ";".
+// Разбор упрощенный: точка с запятой внутри строк и $$-блоков тоже считается разделителем.
+func SplitStatements(direction, sql string) []Statement {
+	sql = regBlockComment.ReplaceAllString(sql, " ")
+	sql = regLineComment.ReplaceAllString(sql, " ")
+
+	var statements []Statement
+	for _, part := range strings.Split(sql, ";") {
+		part = strings.TrimSpace(regSpaces.ReplaceAllString(part, " "))
+		if part != "" {
+			statements = append(statements, Statement{Direction: direction, SQL: part})
+		}
+	}
+
+	return statements
+}
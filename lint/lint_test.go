@@ -0,0 +1,74 @@
+package lint
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ruleNames(issues []Issue) []string {
+	var names []string
+	for _, issue := range issues {
+		names = append(names, issue.Rule)
+	}
+	return names
+}
+
+func TestDropTableWithoutIfExists(t *testing.T) {
+	issues := New().Lint(1, "drop_users", "", "DROP TABLE users;")
+	assert.Equal(t, []string{"drop-table-if-exists"}, ruleNames(issues))
+	assert.Equal(t, SeverityWarning, issues[0].Severity)
+	assert.Equal(t, DirectionDown, issues[0].Direction)
+
+	assert.Empty(t, New().Lint(1, "drop_users", "", "DROP TABLE IF EXISTS users;"))
+}
+
+func TestCreateIndexWithoutConcurrently(t *testing.T) {
+	issues := New().Lint(2, "index_email", "CREATE INDEX users_email_idx ON users (email);", "")
+	assert.Equal(t, []string{"create-index-concurrently"}, ruleNames(issues))
+
+	assert.Empty(t, New().Lint(2, "index_email", "CREATE UNIQUE INDEX CONCURRENTLY users_email_idx ON users (email);", ""))
+}
+
+func TestDestructiveStatementInUp(t *testing.T) {
+	up := `
+		-- cleanup before the new schema
+		ALTER TABLE users DROP COLUMN legacy;
+		TRUNCATE audit_log;
+		DELETE FROM sessions;
+		DELETE FROM tokens WHERE expired;
+	`
+	issues := New().Lint(3, "cleanup", up, "")
+	assert.Equal(t, []string{"destructive-up", "destructive-up", "destructive-up"}, ruleNames(issues))
+	assert.True(t, HasErrors(issues))
+
+	assert.False(t, HasErrors(New().Lint(3, "cleanup", "", "TRUNCATE audit_log;")), "Expected destructive down to be allowed")
+}
+
+func TestCommentsAreIgnored(t *testing.T) {
+	up := "/* DROP TABLE users; */\nSELECT 1; -- TRUNCATE users"
+	assert.Empty(t, New().Lint(4, "noop", up, ""))
+}
+
+func TestRegisterRule(t *testing.T) {
+	rule := Rule{
+		Name:     "no-serial",
+		Severity: SeverityWarning,
+		Message:  "prefer identity columns over SERIAL",
+		Match: func(stmt Statement) bool {
+			return regexp.MustCompile(`(?i)\sSERIAL\b`).MatchString(stmt.SQL)
+		},
+	}
+
+	assert.Empty(t, New().Lint(5, "create_users", "CREATE TABLE users (id SERIAL PRIMARY KEY);", ""))
+
+	registered := Rules()
+	defer func() { registry = registered }()
+
+	Register(rule)
+	issues := New().Lint(5, "create_users", "CREATE TABLE users (id SERIAL PRIMARY KEY);", "")
+	assert.Equal(t, []string{"no-serial"}, ruleNames(issues))
+
+	assert.Empty(t, New(rule).Lint(5, "create_users", "DROP TABLE users;", ""), "Expected explicit rules to replace the registered set")
+}
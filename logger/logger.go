@@ -1,11 +1,11 @@
 package logger
 
 import (
+	"io"
 	"os"
 	"strings"
 
 	"github.com/rs/zerolog"
-	"github.com/rs/zerolog/log"
 )
 
 type Logger interface {
@@ -16,21 +16,20 @@ type Logger interface {
 	Debug(msg string, v ...interface{})
 }
 
+// ZeroLogger пишет через собственный экземпляр zerolog.Logger и не трогает глобальные настройки zerolog,
+// поэтому не мешает логированию приложения, в которое встроен мигратор
 type ZeroLogger struct {
+	logger zerolog.Logger
 }
 
+// New создает логгер, который пишет в stderr с уровнем из переменной окружения LOG_LEVEL
 func New() *ZeroLogger {
-	zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
-	log.Logger = log.Output(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: "2006-01-02 15:04:05"})
-
-	level := getLevelFromEnv()
-	zerolog.SetGlobalLevel(level)
-	return &ZeroLogger{}
+	return NewWithOutput(zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: "2006-01-02 15:04:05"}, os.Getenv("LOG_LEVEL"))
 }
 
-func getLevelFromEnv() zerolog.Level {
-	level := os.Getenv("LOG_LEVEL")
-	return getLevel(level)
+// NewWithOutput создает логгер, который пишет в out с уровнем level: fatal, error, warn, info или debug
+func NewWithOutput(out io.Writer, level string) *ZeroLogger {
+	return &ZeroLogger{logger: zerolog.New(out).Level(getLevel(level)).With().Timestamp().Logger()}
 }
 
 func getLevel(level string) zerolog.Level {
@@ -51,21 +50,21 @@ func getLevel(level string) zerolog.Level {
 }
 
 func (l *ZeroLogger) Fatal(msg string, v ...interface{}) {
-	log.Fatal().Msgf(msg, v...)
+	l.logger.Fatal().Msgf(msg, v...)
 }
 
 func (l *ZeroLogger) Error(msg string, v ...interface{}) {
-	log.Error().Msgf(msg, v...)
+	l.logger.Error().Msgf(msg, v...)
 }
 
 func (l *ZeroLogger) Warn(msg string, v ...interface{}) {
-	log.Warn().Msgf(msg, v...)
+	l.logger.Warn().Msgf(msg, v...)
 }
 
 func (l *ZeroLogger) Info(msg string, v ...interface{}) {
-	log.Info().Msgf(msg, v...)
+	l.logger.Info().Msgf(msg, v...)
 }
 
 func (l *ZeroLogger) Debug(msg string, v ...interface{}) {
-	log.Debug().Msgf(msg, v...)
+	l.logger.Debug().Msgf(msg, v...)
 }
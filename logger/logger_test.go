@@ -0,0 +1,33 @@
+package logger
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoggersAreIndependent(t *testing.T) {
+	globalLogger, globalLevel := log.Logger, zerolog.GlobalLevel()
+
+	var quietOut, verboseOut bytes.Buffer
+	quiet := NewWithOutput(&quietOut, "error")
+	verbose := NewWithOutput(&verboseOut, "debug")
+
+	quiet.Info("quiet info")
+	quiet.Error("quiet error")
+	verbose.Debug("verbose debug")
+	verbose.Info("verbose info")
+
+	assert.NotContains(t, quietOut.String(), "quiet info")
+	assert.Contains(t, quietOut.String(), "quiet error")
+	assert.NotContains(t, quietOut.String(), "verbose", "Expected each logger to write only to its own output")
+	assert.Contains(t, verboseOut.String(), "verbose debug")
+	assert.Contains(t, verboseOut.String(), "verbose info")
+
+	New()
+	assert.Equal(t, globalLogger, log.Logger, "Expected New not to replace the global zerolog logger")
+	assert.Equal(t, globalLevel, zerolog.GlobalLevel())
+}
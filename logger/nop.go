@@ -0,0 +1,29 @@
+package logger
+
+import "os"
+
+// NopLogger отбрасывает все сообщения и, в отличие от New, не меняет глобальные настройки zerolog.
+// Подходит для встраивания мигратора в приложения со своим логированием.
+type NopLogger struct {
+}
+
+func NewNop() *NopLogger {
+	return &NopLogger{}
+}
+
+// Fatal не пишет сообщение, но, как и ZeroLogger, завершает процесс
+func (l *NopLogger) Fatal(msg string, v ...interface{}) {
+	os.Exit(1)
+}
+
+func (l *NopLogger) Error(msg string, v ...interface{}) {
+}
+
+func (l *NopLogger) Warn(msg string, v ...interface{}) {
+}
+
+func (l *NopLogger) Info(msg string, v ...interface{}) {
+}
+
+func (l *NopLogger) Debug(msg string, v ...interface{}) {
+}
@@ -0,0 +1,40 @@
+package logger_test
+
+import (
+	"context"
+	"io"
+	"os"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/processes"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNopLoggerIsSilent(t *testing.T) {
+	reader, writer, err := os.Pipe()
+	assert.NoError(t, err)
+	stderr := os.Stderr
+	os.Stderr = writer
+	defer func() { os.Stderr = stderr }()
+
+	globalLogger, globalLevel := log.Logger, zerolog.GlobalLevel()
+
+	migrator := processes.New(&storage.MockSqlStorage{}, logger.NewNop())
+	migrator.Create("create_users", "CREATE TABLE users ();", "DROP TABLE users;", nil, nil)
+	_, err = migrator.Up(context.Background())
+	assert.NoError(t, err)
+	_, err = migrator.Down(context.Background())
+	assert.NoError(t, err)
+
+	assert.Equal(t, globalLogger, log.Logger, "Expected the global zerolog logger to stay untouched")
+	assert.Equal(t, globalLevel, zerolog.GlobalLevel())
+
+	assert.NoError(t, writer.Close())
+	output, err := io.ReadAll(reader)
+	assert.NoError(t, err)
+	assert.Empty(t, string(output))
+}
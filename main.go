@@ -1,42 +1,172 @@
 package main
 
 import (
+	"context"
 	"errors"
 	"flag"
 	"fmt"
+	"io"
 	"os"
+	"os/signal"
+	"os/user"
+	"strings"
+	"syscall"
+	"time"
 
 	"github.com/juliazadorozhnaya/sql-migrator/app"
+	"github.com/juliazadorozhnaya/sql-migrator/audit"
+	"github.com/juliazadorozhnaya/sql-migrator/buildinfo"
 	"github.com/juliazadorozhnaya/sql-migrator/config"
 	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/processes"
 	"github.com/juliazadorozhnaya/sql-migrator/storage"
 )
 
 var (
 	ErrInvalidFlagNumber = errors.New("invalid flag number")
+	ErrMissingConnection = errors.New("path to migrations and database connection string must be provided")
+	ErrMissingCommand    = errors.New("command must be provided")
+	ErrUnknownCommand    = errors.New("invalid operation, use one of: create, up, down, redo, skip, apply, status, dbversion, history, cleanup, check, diff, lint, squash, generate-from-schema, export, import, watch, fmt, plan, doctor, verify-connection, version")
+	ErrDatabaseBehind    = errors.New("database is behind the latest migration")
+	ErrMissingOtherDSN   = errors.New("diff requires -other-dsn")
+	ErrMissingSchema     = errors.New("generate-from-schema requires -schema")
+	ErrStepsWithTag      = errors.New("use either -until-tag or -steps, not both")
+	ErrFromVersionMixed  = errors.New("-from-version cannot be combined with -until-tag or -steps")
+	ErrUnknownTimezone   = errors.New("unknown timezone")
+	ErrMultipleDSNs      = errors.New("several databases are supported only by the up command")
 
 	configPath    string
+	env           string
 	path          string
 	database      string
+	shardDSNs     dsnList
+	dsnFile       string
+	otherDatabase string
 	migrationName string
+	migrationType string
 	command       string
+	idempotent    bool
+	force         bool
+	yes           bool
+	batch         bool
+	continueOnErr bool
+	expandEnv     bool
+	failFast      bool
+	mkdir         bool
+	withSeed      bool
+	withSeeds     bool
+	lang          string
+	statusFilter  string
+	statusJSON    bool
+	outPath       string
+	schemaFile    string
+	untilTag      string
+	steps         int
+	fromVersion   int
+	waitForLock   time.Duration
+	since         time.Duration
+	version       int
+	errorFormat   string
+	dryRun        dryRunMode
+	watchActivity bool
+	amend         bool
+	only          string
+	resume        bool
+	recordSQL     bool
+	lockCreate    bool
+	sqlTemplate   string
+	emptyFiles    bool
+	verbose       bool
+	failOnPending bool
 )
 
 func init() {
 	flag.StringVar(&configPath, "config", "config.yaml", "Path to config file")
-	flag.StringVar(&path, "path", "", "Path to migrations file")
+	flag.StringVar(&env, "env", "", "Environment whose config.<env>.yaml next to -config is merged over it, e.g. prod")
+	flag.StringVar(&path, "path", "", "Path to migrations directory or file, or an http(s):// or s3:// URL; s3 buckets must allow public read, credentials are not supported")
 	flag.StringVar(&database, "dsn", "", "Database connection string")
+	flag.Var(&shardDSNs, "shard-dsn", "Connection string of a database to run up on, repeat the flag to run up on each database in turn; takes precedence over -dsn")
+	flag.StringVar(&dsnFile, "dsn-file", "", "File with the database connection string, e.g. a mounted secret; -dsn takes precedence")
+	flag.StringVar(&otherDatabase, "other-dsn", "", "Connection string of the database to compare with in the diff command")
 	flag.StringVar(&migrationName, "name", "", "Migration name")
-	flag.StringVar(&command, "command", "", "Command to run: create, up, down, redo, status, dbversion")
+	flag.StringVar(&migrationType, "type", "", "Type of migration made by create: sql, go or a type from templates_dir; defaults to type from config")
+	flag.StringVar(&command, "command", "", "Command to run: create, up, down, redo, skip, apply, status, dbversion, history, cleanup, check, diff, lint, squash, generate-from-schema, export, import, watch, fmt, plan, doctor, verify-connection, version")
+	flag.StringVar(&statusFilter, "status-filter", "", "Show only migrations with this status in status output")
+	flag.BoolVar(&statusJSON, "json", false, "Print status output as JSON")
+	flag.BoolVar(&failOnPending, "fail-on-pending", false, "Make status exit non-zero when the database has pending migrations, for CI gating")
+	flag.BoolVar(&verbose, "verbose", false, "In status output, also show whether each migration's up and down files exist and still match the SQL recorded with -record-sql")
+	flag.DurationVar(&since, "since", 0, "Show only migrations whose status changed within this period in status output, e.g. 24h")
+	flag.StringVar(&outPath, "out", "", "Write status output to this file instead of the log")
+	flag.StringVar(&schemaFile, "schema", "", "SQL file with the desired schema for the generate-from-schema command")
+	flag.StringVar(&untilTag, "until-tag", "", "Apply migrations up to and including the last one with this tag")
+	flag.Var(&dryRun, "dry-run", "Show what up or fmt would do without doing it; for up, print (the default) prints the SQL and validate runs it in a rolled back transaction")
+	flag.StringVar(&only, "only", "", "Apply only sql or only go migrations during up, stopping at the first migration of the other kind; fails if migrations of the selected kind follow it")
+	flag.BoolVar(&resume, "resume", false, "During up, check a migration left in process status by an interrupted run with its probe header and record it instead of running it again")
+	flag.BoolVar(&recordSQL, "record-sql", false, "Store the SQL each migration ran in the migrations table for auditing; makes the table larger")
+	flag.IntVar(&steps, "steps", 0, "Apply only the next N pending migrations during up; 0 applies all")
+	flag.IntVar(&fromVersion, "from-version", 0, "Apply migrations during up starting from this version regardless of the db version; requires -force")
+	flag.IntVar(&version, "version", 0, "Migration version for the skip and apply commands")
+	flag.BoolVar(&force, "force", false, "Allow commands that overwrite existing data")
+	flag.BoolVar(&yes, "yes", false, "Run destructive commands (down, redo, cleanup) without a confirmation prompt")
+	flag.StringVar(&errorFormat, "error-format", app.ErrorFormatText, "Format of error output on stderr: text or json; with json the logs on stderr are JSON lines too")
+	flag.BoolVar(&batch, "batch", false, "Write only final migration statuses, batching bookkeeping queries")
+	flag.BoolVar(&continueOnErr, "continue-on-error", false, "Keep applying migrations after a failure during up and report all failed versions; not available with tx_mode all or lock_mode transaction")
+	flag.BoolVar(&expandEnv, "expand-env", false, "Substitute ${VAR} environment variables in migration SQL outside string literals")
+	flag.BoolVar(&failFast, "fail-fast", true, "With several databases, stop at the first one that fails")
+	flag.DurationVar(&waitForLock, "wait-for-lock", 0, "How long to wait for another migrator to release the advisory lock, e.g. 5m; 0 fails at once")
+	flag.BoolVar(&watchActivity, "watch-activity", false, "While migrations run, periodically print the query the migrator is executing and for how long")
+	flag.BoolVar(&mkdir, "mkdir", false, "Create the migrations directory if it does not exist")
+	flag.BoolVar(&amend, "amend", false, "With create, rename the latest not yet applied migration to -name instead of creating a new one")
+	flag.StringVar(&sqlTemplate, "template", "", "Path to a text/template file used by create for sql migration files; also sql_template in the config")
+	flag.BoolVar(&emptyFiles, "empty", false, "Create empty sql migration files without the header template")
+	flag.BoolVar(&lockCreate, "lock-create", false, "Serialize create through a lock file in the migrations directory so concurrent creates get distinct versions; also create_lock in the config")
+	flag.BoolVar(&withSeed, "with-seed", false, "Also create a seed data file with the create command")
+	flag.BoolVar(&withSeeds, "with-seeds", false, "Apply seed data files after their up migrations; keep off in production")
+	flag.StringVar(&lang, "lang", processes.LangRu, "Language of status and history table headers: ru or en")
+	flag.BoolVar(&idempotent, "idempotent", false, "Skip already applied versions during up")
 }
 
 func main() {
 	flag.Parse()
+	os.Exit(execute(os.Stdout, os.Stderr))
+}
+
+// execute запускает команду и возвращает код завершения процесса: 0 — успех, 1 — ошибка команды, 2 — неверные флаги
+func execute(stdout, stderr io.Writer) int {
+	if errorFormat != app.ErrorFormatText && errorFormat != app.ErrorFormatJSON {
+		fmt.Fprintf(stderr, "%v: %s\n", app.ErrUnknownErrorFormat, errorFormat)
+		return 2
+	}
+
+	if command == "version" {
+		fmt.Fprintln(stdout, buildinfo.Get())
+		return 0
+	}
+
+	if err := run(); err != nil {
+		app.WriteError(stderr, errorFormat, command, err)
+		return 1
+	}
 
-	config, err := config.LoadConfig(configPath)
+	return 0
+}
+
+// newLogger создает логгер команды. С -error-format json логи пишутся в out строками JSON,
+// чтобы stderr целиком разбирался как JSON вместе с итоговой ошибкой
+func newLogger(out io.Writer) logger.Logger {
+	if errorFormat == app.ErrorFormatJSON {
+		return logger.NewWithOutput(out, os.Getenv("LOG_LEVEL"))
+	}
+	return logger.New()
+}
+
+func run() error {
+	config, err := config.LoadConfigForEnv(configPath, env)
 	if err != nil {
-		fmt.Printf("Error loading config file: %v\n", err)
-		return
+		if command == "doctor" {
+			app.WriteDoctorReport(os.Stdout, []app.DoctorCheck{{Name: app.CheckConfig, Err: err, Hint: "fix the file or pass its path with -config"}})
+		}
+		return fmt.Errorf("error loading config file: %w", err)
 	}
 
 	if path == "" {
@@ -45,44 +175,352 @@ func main() {
 		path = os.ExpandEnv(path)
 	}
 
-	if database == "" {
-		database = config.MigratorOpt.DSN
-	} else {
-		database = os.ExpandEnv(database)
+	dsns, err := resolveDSNs(config.MigratorOpt)
+	if err != nil {
+		return err
+	}
+	if len(dsns) == 1 {
+		database = dsns[0]
 	}
 
 	if migrationName == "" {
 		migrationName = os.Getenv("NAME")
 	}
 
-	if path == "" || database == "" {
-		fmt.Println("Path to migrations and database connection string must be provided.")
-		return
+	if migrationType == "" {
+		migrationType = config.MigratorOpt.Type
+		if migrationType == "" {
+			migrationType = "sql"
+		}
+	}
+
+	if path == "" || (len(dsns) == 0 && command != "lint") {
+		return ErrMissingConnection
 	}
 
 	if command == "" {
-		fmt.Println("Command must be provided.")
-		return
+		return ErrMissingCommand
+	}
+
+	if steps < 0 {
+		return fmt.Errorf("%w: -steps %d", ErrInvalidFlagNumber, steps)
+	}
+
+	if fromVersion < 0 {
+		return fmt.Errorf("%w: -from-version %d", ErrInvalidFlagNumber, fromVersion)
+	}
+
+	if since < 0 {
+		return fmt.Errorf("%w: -since %s", ErrInvalidFlagNumber, since)
+	}
+
+	if steps > 0 && untilTag != "" {
+		return ErrStepsWithTag
+	}
+
+	if fromVersion > 0 && (steps > 0 || untilTag != "") {
+		return ErrFromVersionMixed
 	}
 
-	l := logger.New()
-	db := storage.New(database, l)
-	application := app.New(l, db)
+	if dryRun != "" && !processes.IsKnownDryRunMode(string(dryRun)) {
+		return fmt.Errorf("%w: %s", processes.ErrUnknownDryRunMode, dryRun)
+	}
+
+	if only != "" && !processes.IsKnownKind(only) {
+		return fmt.Errorf("%w: %s", processes.ErrUnknownKind, only)
+	}
+
+	if !processes.IsKnownLang(lang) {
+		return fmt.Errorf("%w: %s", processes.ErrUnknownLang, lang)
+	}
+
+	if lockMode := config.MigratorOpt.LockMode; lockMode != "" && !storage.IsKnownLockMode(lockMode) {
+		return fmt.Errorf("%w: %s", storage.ErrUnknownLockMode, lockMode)
+	}
+
+	if txMode := config.MigratorOpt.TxMode; txMode != "" && !processes.IsKnownTxMode(txMode) {
+		return fmt.Errorf("%w: %s", processes.ErrUnknownTxMode, txMode)
+	}
+
+	convention, err := app.ConventionByName(config.MigratorOpt.Convention)
+	if err != nil {
+		return err
+	}
+
+	fileMode, err := app.ParseFileMode(config.MigratorOpt.FileMode, app.DefaultFileMode)
+	if err != nil {
+		return err
+	}
+
+	dirMode, err := app.ParseFileMode(config.MigratorOpt.DirMode, app.DefaultDirMode)
+	if err != nil {
+		return err
+	}
+
+	var templates map[string]app.Template
+	if dir := config.MigratorOpt.TemplatesDir; dir != "" {
+		if templates, err = app.LoadTemplates(os.ExpandEnv(dir)); err != nil {
+			return fmt.Errorf("error loading templates: %w", err)
+		}
+	}
+
+	templateFile := sqlTemplate
+	if templateFile == "" {
+		templateFile = config.MigratorOpt.SQLTemplate
+	}
+
+	var sqlTemplateContent string
+	if templateFile != "" {
+		content, err := os.ReadFile(os.ExpandEnv(templateFile))
+		if err != nil {
+			return fmt.Errorf("error loading sql template: %w", err)
+		}
+		sqlTemplateContent = string(content)
+	}
+
+	var location *time.Location
+	if timezone := config.MigratorOpt.Timezone; timezone != "" {
+		if location, err = time.LoadLocation(timezone); err != nil {
+			return fmt.Errorf("%w: %s", ErrUnknownTimezone, timezone)
+		}
+	}
+
+	var auditWriter *audit.Writer
+	if sink := config.MigratorOpt.AuditFile; sink != "" {
+		if auditWriter, err = audit.Open(os.ExpandEnv(sink), command, auditActor()); err != nil {
+			return fmt.Errorf("error opening audit file: %w", err)
+		}
+		defer auditWriter.Close()
+	}
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	l := newLogger(os.Stderr)
+	storageOpts := []storage.Option{
+		storage.WithTLS(storage.TLSConfig{
+			SSLMode:     config.MigratorOpt.SSLMode,
+			SSLRootCert: os.ExpandEnv(config.MigratorOpt.SSLRootCert),
+			SSLCert:     os.ExpandEnv(config.MigratorOpt.SSLCert),
+			SSLKey:      os.ExpandEnv(config.MigratorOpt.SSLKey),
+		}),
+		storage.WithMaxConns(config.MigratorOpt.MaxConns),
+		storage.WithTableName(config.MigratorOpt.TableName),
+		storage.WithTransaction(config.MigratorOpt.Transaction),
+		storage.WithLockMode(config.MigratorOpt.LockMode),
+		storage.WithLockKey(config.MigratorOpt.LockKey),
+		storage.WithLockPerDatabase(config.MigratorOpt.LockPerDB),
+		storage.WithPsql(config.MigratorOpt.PsqlPath),
+		storage.WithAppName(appName(config.MigratorOpt.AppName, command)),
+		storage.WithLockWait(waitForLock),
+	}
+	var activityInterval time.Duration
+	if watchActivity {
+		activityInterval = app.DefaultActivityInterval
+	}
+
+	newApplication := func(dsn string) app.App {
+		db := storage.New(dsn, l, storageOpts...)
+		return app.New(l, db, app.WithIdempotent(idempotent), app.WithForce(force), app.WithBatch(batch), app.WithMkdir(mkdir), app.WithSeedFile(withSeed), app.WithSeeds(withSeeds), app.WithLang(lang), app.WithConvention(convention), app.WithContinueOnError(continueOnErr), app.WithTxMode(config.MigratorOpt.TxMode), app.WithTransaction(config.MigratorOpt.Transaction), app.WithOnly(only), app.WithResume(resume), app.WithRecordSQL(recordSQL), app.WithCreateLock(lockCreate || config.MigratorOpt.CreateLock), app.WithExpandEnv(expandEnv), app.WithNotifyChannel(config.MigratorOpt.NotifyChannel), app.WithFileMode(fileMode), app.WithDirMode(dirMode), app.WithTimezone(location), app.WithTemplates(templates), app.WithSQLTemplate(sqlTemplateContent), app.WithEmptyFiles(emptyFiles), app.WithWatchActivity(activityInterval), app.WithAudit(auditWriter))
+	}
+
+	if len(dsns) > 1 {
+		if command != "up" {
+			return ErrMultipleDSNs
+		}
+
+		shards := make([]app.Shard, 0, len(dsns))
+		for _, dsn := range dsns {
+			shards = append(shards, app.Shard{Name: storage.RedactDSN(dsn), App: newApplication(dsn)})
+		}
+		return app.RunShards(l, shards, failFast, func(application app.App) error {
+			return runUp(ctx, application)
+		})
+	}
+
+	if err := confirmDestructive(command, yes); err != nil {
+		return err
+	}
+
+	application := newApplication(database)
 
 	switch command {
 	case "create":
-		application.Create(migrationName, path, "sql")
+		if amend {
+			_, err := application.Amend(ctx, path, migrationName)
+			return err
+		}
+		_, err := application.Create(ctx, migrationName, path, migrationType)
+		return err
 	case "up":
-		application.Up(path)
+		return runUp(ctx, application)
 	case "down":
-		application.Down(path)
+		return application.Down(ctx, path)
 	case "redo":
-		application.Redo(path)
+		return application.Redo(ctx, path)
+	case "skip":
+		return application.Skip(ctx, path, version)
+	case "apply":
+		return application.ApplyOutOfOrder(ctx, path, migrationName, version)
 	case "status":
-		application.Status()
+		opts := processes.StatusOptions{Filter: statusFilter, JSON: statusJSON, Since: since, Verbose: verbose, FailOnPending: failOnPending}
+		if outPath != "" {
+			return application.StatusToFile(ctx, path, outPath, opts)
+		}
+		return application.Status(ctx, path, opts)
 	case "dbversion":
-		application.DbVersion()
+		return application.DbVersion(ctx)
+	case "history":
+		return application.History(ctx)
+	case "cleanup":
+		return application.Cleanup(ctx, true)
+	case "check":
+		upToDate, err := application.Check(ctx, path)
+		if err == nil && !upToDate {
+			err = ErrDatabaseBehind
+		}
+		return err
+	case "diff":
+		if otherDatabase == "" {
+			return ErrMissingOtherDSN
+		}
+		return application.Diff(ctx, storage.New(os.ExpandEnv(otherDatabase), l, storageOpts...))
+	case "lint":
+		return application.Lint(ctx, path)
+	case "squash":
+		return application.Squash(ctx, path, database)
+	case "watch":
+		return application.Watch(ctx, path)
+	case "generate-from-schema":
+		if schemaFile == "" {
+			return ErrMissingSchema
+		}
+		if migrationName == "" {
+			migrationName = "sync_schema"
+		}
+		return application.GenerateFromSchema(ctx, schemaFile, path, migrationName)
+	case "export":
+		return application.Export(ctx, os.Stdout)
+	case "import":
+		return application.Import(ctx, path, os.Stdin)
+	case "doctor":
+		return application.Doctor(ctx, path, os.Stdout, app.DoctorCheck{Name: app.CheckConfig})
+	case "verify-connection":
+		return application.VerifyConnection(ctx, os.Stdout)
+	case "plan":
+		return application.Plan(ctx, path, os.Stdout)
+	case "fmt":
+		_, err := application.Format(ctx, path, dryRun != "")
+		return err
+	default:
+		return ErrUnknownCommand
+	}
+}
+
+// runUp выполняет up с учетом -until-tag, -steps и -from-version
+func runUp(ctx context.Context, application app.App) error {
+	switch {
+	case dryRun != "":
+		return application.DryRun(ctx, path, string(dryRun))
+	case fromVersion > 0:
+		return application.UpFrom(ctx, path, fromVersion)
+	case untilTag != "":
+		return application.UpToTag(ctx, path, untilTag)
+	case steps > 0:
+		return application.UpN(ctx, path, steps)
+	default:
+		return application.Up(ctx, path)
+	}
+}
+
+// appName добавляет к application_name выполняемую команду, чтобы в pg_stat_activity было видно, чем занята сессия
+func appName(name, command string) string {
+	if name == "" {
+		name = storage.DefaultAppName
+	}
+	return name + "/" + command
+}
+
+// auditActor — пользователь ОС, запустивший мигратор, для журнала действий
+func auditActor() string {
+	if current, err := user.Current(); err == nil && current.Username != "" {
+		return current.Username
+	}
+	return os.Getenv("USER")
+}
+
+// resolveDSNs выбирает строки подключения: -shard-dsn, затем -dsn, затем файл из -dsn-file или dsn_file конфига,
+// затем dsns и dsn конфига. Строки не делятся по запятым: в них бывают запятые multi-host URL и параметров.
+func resolveDSNs(opt *config.Migrator) ([]string, error) {
+	if len(shardDSNs) > 0 {
+		return expandDSNs(shardDSNs), nil
+	}
+
+	if database != "" {
+		return []string{os.ExpandEnv(database)}, nil
+	}
+
+	file := dsnFile
+	if file == "" {
+		file = opt.DSNFile
+	}
+	if file != "" {
+		dsn, err := config.ReadDSNFile(file)
+		if err != nil {
+			return nil, err
+		}
+		return []string{dsn}, nil
+	}
+
+	if len(opt.DSNs) > 0 {
+		return opt.DSNs, nil
+	}
+	if opt.DSN == "" {
+		return nil, nil
+	}
+	return []string{opt.DSN}, nil
+}
+
+// expandDSNs раскрывает переменные окружения в строках подключения из флагов, как и в -dsn
+func expandDSNs(values []string) []string {
+	dsns := make([]string, 0, len(values))
+	for _, dsn := range values {
+		dsns = append(dsns, os.ExpandEnv(dsn))
+	}
+	return dsns
+}
+
+// dsnList — значение повторяемого флага -shard-dsn
+type dsnList []string
+
+func (list *dsnList) String() string {
+	return strings.Join(*list, " ")
+}
+
+func (list *dsnList) Set(value string) error {
+	*list = append(*list, value)
+	return nil
+}
+
+// dryRunMode — значение флага -dry-run: без значения означает режим print
+type dryRunMode string
+
+func (mode *dryRunMode) String() string {
+	return string(*mode)
+}
+
+func (mode *dryRunMode) Set(value string) error {
+	switch value {
+	case "true":
+		*mode = processes.DryRunPrint
+	case "false":
+		*mode = ""
 	default:
-		fmt.Println("Invalid operation. Use one of the following: create, up, down, redo, status, dbversion.")
+		*mode = dryRunMode(value)
 	}
+	return nil
+}
+
+func (mode *dryRunMode) IsBoolFlag() bool {
+	return true
 }
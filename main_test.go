@@ -0,0 +1,122 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func writeTestConfig(t *testing.T) string {
+	configFile := filepath.Join(t.TempDir(), "config.toml")
+	content := "[migrator]\ndsn = \"postgres://localhost/db\"\ndir = \"" + t.TempDir() + "\"\n"
+	assert.NoError(t, os.WriteFile(configFile, []byte(content), 0644))
+
+	return configFile
+}
+
+func TestExecuteFailingCommandExitsNonZero(t *testing.T) {
+	configPath = writeTestConfig(t)
+	command = "unknown"
+	errorFormat = "json"
+
+	var stderr bytes.Buffer
+	assert.Equal(t, 1, execute(&bytes.Buffer{}, &stderr))
+
+	var output map[string]interface{}
+	assert.NoError(t, json.Unmarshal(stderr.Bytes(), &output))
+	assert.Equal(t, "unknown", output["command"])
+	assert.Equal(t, ErrUnknownCommand.Error(), output["error"])
+}
+
+func TestJSONErrorFormatWritesJSONLogs(t *testing.T) {
+	errorFormat = "json"
+	t.Cleanup(func() { errorFormat = "text" })
+
+	var stderr bytes.Buffer
+	newLogger(&stderr).Info("Starting migrations")
+
+	var output map[string]interface{}
+	assert.NoError(t, json.Unmarshal(stderr.Bytes(), &output), "Expected a JSON log line, got %q", stderr.String())
+	assert.Equal(t, "info", output["level"])
+	assert.Equal(t, "Starting migrations", output["message"])
+}
+
+func TestExecuteMissingConfigExitsNonZero(t *testing.T) {
+	configPath = filepath.Join(t.TempDir(), "missing.toml")
+	command = "up"
+	errorFormat = "text"
+
+	var stderr bytes.Buffer
+	assert.Equal(t, 1, execute(&bytes.Buffer{}, &stderr))
+	assert.Contains(t, stderr.String(), "Command up failed: error loading config file")
+}
+
+func TestExecuteRejectsUnknownErrorFormat(t *testing.T) {
+	errorFormat = "xml"
+
+	var stderr bytes.Buffer
+	assert.Equal(t, 2, execute(&bytes.Buffer{}, &stderr))
+}
+
+func TestExecuteVersionNeedsNoConfig(t *testing.T) {
+	configPath = filepath.Join(t.TempDir(), "missing.toml")
+	command = "version"
+	errorFormat = "text"
+
+	var stdout bytes.Buffer
+	assert.Equal(t, 0, execute(&stdout, &bytes.Buffer{}))
+	assert.Equal(t, "sql-migrator dev (commit dev, built dev)\n", stdout.String())
+}
+
+func TestResolveDSNFromFile(t *testing.T) {
+	secret := filepath.Join(t.TempDir(), "dsn")
+	assert.NoError(t, os.WriteFile(secret, []byte("postgres://db/secret\n"), 0600))
+	database, dsnFile = "", ""
+	t.Cleanup(func() { database, dsnFile = "", "" })
+
+	opt := &config.Migrator{DSN: "postgres://db/config", DSNFile: secret}
+	dsns, err := resolveDSNs(opt)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"postgres://db/secret"}, dsns, "Expected dsn_file to take precedence over dsn")
+
+	database = "postgres://db/flag"
+	dsns, err = resolveDSNs(opt)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"postgres://db/flag"}, dsns, "Expected -dsn to take precedence over the file")
+
+	database, dsnFile = "", filepath.Join(t.TempDir(), "missing")
+	_, err = resolveDSNs(opt)
+	assert.ErrorIs(t, err, os.ErrNotExist)
+}
+
+func TestResolveDSNsKeepsCommas(t *testing.T) {
+	database, dsnFile, shardDSNs = "", "", nil
+	t.Cleanup(func() { database, dsnFile, shardDSNs = "", "", nil })
+
+	multiHost := "postgres://u@h1:5432,h2:5432/db"
+	keyValue := "host=db dbname=app options='-c search_path=a,b'"
+	database = multiHost
+	dsns, err := resolveDSNs(&config.Migrator{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{multiHost}, dsns, "Expected a multi-host URL to stay one database")
+
+	database = ""
+	dsns, err = resolveDSNs(&config.Migrator{DSN: keyValue})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{keyValue}, dsns)
+
+	dsns, err = resolveDSNs(&config.Migrator{DSN: keyValue, DSNs: []string{multiHost, "postgres://db/shard2"}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{multiHost, "postgres://db/shard2"}, dsns, "Expected config dsns to be taken whole")
+
+	assert.NoError(t, shardDSNs.Set("postgres://db/shard1"))
+	assert.NoError(t, shardDSNs.Set(multiHost))
+	dsns, err = resolveDSNs(&config.Migrator{DSN: keyValue})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"postgres://db/shard1", multiHost}, dsns, "Expected each -shard-dsn to be one database")
+}
@@ -0,0 +1,74 @@
+// Package metrics считает переходы статусов миграций и отдает их в текстовом формате Prometheus
+package metrics
+
+import (
+	"fmt"
+	"net/http"
+	"sync/atomic"
+)
+
+// ContentType — тип ответа текстового формата Prometheus
+const ContentType = "text/plain; version=0.0.4; charset=utf-8"
+
+// Metrics — счетчики примененных, упавших и откаченных миграций и текущая версия базы.
+// Методы безопасны для вызова из нескольких горутин.
+type Metrics struct {
+	applied    atomic.Uint64
+	failed     atomic.Uint64
+	rolledBack atomic.Uint64
+	version    atomic.Int64
+}
+
+func New() *Metrics {
+	return &Metrics{}
+}
+
+func (m *Metrics) IncApplied() {
+	m.applied.Add(1)
+}
+
+func (m *Metrics) IncFailed() {
+	m.failed.Add(1)
+}
+
+func (m *Metrics) IncRolledBack() {
+	m.rolledBack.Add(1)
+}
+
+func (m *Metrics) SetVersion(version int) {
+	m.version.Store(int64(version))
+}
+
+func (m *Metrics) Applied() uint64 {
+	return m.applied.Load()
+}
+
+func (m *Metrics) Failed() uint64 {
+	return m.failed.Load()
+}
+
+func (m *Metrics) RolledBack() uint64 {
+	return m.rolledBack.Load()
+}
+
+func (m *Metrics) Version() int {
+	return int(m.version.Load())
+}
+
+// ServeHTTP отдает метрики в текстовом формате Prometheus
+func (m *Metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", ContentType)
+
+	fmt.Fprintf(w, "# HELP migrator_migrations_applied_total Migrations applied successfully.\n")
+	fmt.Fprintf(w, "# TYPE migrator_migrations_applied_total counter\n")
+	fmt.Fprintf(w, "migrator_migrations_applied_total %d\n", m.Applied())
+	fmt.Fprintf(w, "# HELP migrator_migrations_failed_total Migrations that failed to apply or roll back.\n")
+	fmt.Fprintf(w, "# TYPE migrator_migrations_failed_total counter\n")
+	fmt.Fprintf(w, "migrator_migrations_failed_total %d\n", m.Failed())
+	fmt.Fprintf(w, "# HELP migrator_migrations_rolled_back_total Migrations rolled back.\n")
+	fmt.Fprintf(w, "# TYPE migrator_migrations_rolled_back_total counter\n")
+	fmt.Fprintf(w, "migrator_migrations_rolled_back_total %d\n", m.RolledBack())
+	fmt.Fprintf(w, "# HELP migrator_db_version Current database migration version.\n")
+	fmt.Fprintf(w, "# TYPE migrator_db_version gauge\n")
+	fmt.Fprintf(w, "migrator_db_version %d\n", m.Version())
+}
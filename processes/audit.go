@@ -0,0 +1,50 @@
+package processes
+
+import (
+	"github.com/juliazadorozhnaya/sql-migrator/audit"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+// SetAudit включает журнал действий: каждый сохраненный переход статуса дописывается в writer.
+// Ошибка записи журнала выводится в лог и не прерывает миграции.
+func (m *Migrator) SetAudit(writer *audit.Writer) {
+	m.audit = writer
+}
+
+// auditRecord собирает запись журнала о переходе. previous — статус миграции до перехода:
+// по нему определяется направление, в котором упала миграция.
+func auditRecord(migration storage.IMigration, previous, status string) audit.Record {
+	return audit.Record{
+		Version:   migration.GetVersion(),
+		Name:      migration.GetName(),
+		Direction: statusDirection(previous, status),
+		Status:    status,
+		Timestamp: migration.GetStatusChangeTime(),
+	}
+}
+
+func (m *Migrator) writeAudit(record audit.Record) {
+	if m.audit == nil {
+		return
+	}
+
+	if err := m.audit.Write(record); err != nil {
+		m.logger.Error("Error in audit: %v", err)
+	}
+}
+
+func statusDirection(previous, status string) string {
+	switch status {
+	case storage.StatusCancellation, storage.StatusCancel:
+		return DirectionDown
+	case storage.StatusError:
+		if previous == storage.StatusCancellation {
+			return DirectionDown
+		}
+		return DirectionUp
+	case storage.StatusSkipped:
+		return ""
+	default:
+		return DirectionUp
+	}
+}
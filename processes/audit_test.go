@@ -0,0 +1,116 @@
+package processes
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/juliazadorozhnaya/sql-migrator/audit"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+func auditRecords(t *testing.T, buf *bytes.Buffer) []audit.Record {
+	var records []audit.Record
+	scanner := bufio.NewScanner(buf)
+	for scanner.Scan() {
+		var record audit.Record
+		assert.NoError(t, json.Unmarshal(scanner.Bytes(), &record))
+		records = append(records, record)
+	}
+	return records
+}
+
+func TestAuditRecordsUpDownCycle(t *testing.T) {
+	h := newHarness(t, 2)
+	now := time.Date(2024, 3, 1, 12, 0, 0, 0, time.UTC)
+	h.migrator.SetClock(&fixedClock{now: now})
+	var buf bytes.Buffer
+	h.migrator.SetAudit(audit.New(&buf, "redo", "deploy"))
+
+	_, err := h.migrator.Up(h.ctx)
+	assert.NoError(t, err)
+	_, err = h.migrator.Down(h.ctx)
+	assert.NoError(t, err)
+
+	expected := []audit.Record{
+		{Version: 1, Name: "create_t1", Direction: DirectionUp, Status: storage.StatusProcess},
+		{Version: 1, Name: "create_t1", Direction: DirectionUp, Status: storage.StatusSuccess},
+		{Version: 2, Name: "create_t2", Direction: DirectionUp, Status: storage.StatusProcess},
+		{Version: 2, Name: "create_t2", Direction: DirectionUp, Status: storage.StatusSuccess},
+		{Version: 2, Name: "create_t2", Direction: DirectionDown, Status: storage.StatusCancellation},
+		{Version: 2, Name: "create_t2", Direction: DirectionDown, Status: storage.StatusCancel},
+	}
+	for i := range expected {
+		expected[i].Command = "redo"
+		expected[i].Actor = "deploy"
+		expected[i].Timestamp = now
+	}
+	assert.Equal(t, expected, auditRecords(t, &buf))
+}
+
+func TestAuditRecordsFailedDownInBatchMode(t *testing.T) {
+	h := newHarness(t, 0)
+	h.migrator.Create("create_users", "", "", func(ctx context.Context) error {
+		return nil
+	}, func(ctx context.Context) error {
+		return errors.New("boom")
+	})
+	h.migrator.SetBatch(true)
+	var buf bytes.Buffer
+	h.migrator.SetAudit(audit.New(&buf, "down", "deploy"))
+
+	_, err := h.migrator.Up(h.ctx)
+	assert.NoError(t, err)
+	_, err = h.migrator.Down(h.ctx)
+	assert.Error(t, err)
+
+	records := auditRecords(t, &buf)
+	if assert.Len(t, records, 2, "Expected batch mode to audit only final statuses") {
+		assert.Equal(t, storage.StatusSuccess, records[0].Status)
+		assert.Equal(t, DirectionUp, records[0].Direction)
+		assert.Equal(t, storage.StatusError, records[1].Status)
+		assert.Equal(t, DirectionDown, records[1].Direction, "Expected the failed rollback to be audited as down")
+	}
+}
+
+func TestAuditRecordsOnlyCommittedTransitionsInTxModeAll(t *testing.T) {
+	h := newHarness(t, 2)
+	h.migrator.SetTxMode(TxModeAll)
+	h.migrator.Create("broken", "", "", func(ctx context.Context) error {
+		return errors.New("boom")
+	}, nil)
+	var buf bytes.Buffer
+	h.migrator.SetAudit(audit.New(&buf, "up", "deploy"))
+
+	_, err := h.migrator.Up(h.ctx)
+	assert.ErrorIs(t, err, ErrMigrationUp)
+
+	records := auditRecords(t, &buf)
+	if assert.Len(t, records, 1, "Expected transitions rolled back with the run not to be audited") {
+		assert.Equal(t, 3, records[0].Version)
+		assert.Equal(t, storage.StatusError, records[0].Status)
+		assert.Equal(t, DirectionUp, records[0].Direction)
+	}
+}
+
+func TestAuditRecordsCommittedRunInTxModeAll(t *testing.T) {
+	h := newHarness(t, 2)
+	h.migrator.SetTxMode(TxModeAll)
+	var buf bytes.Buffer
+	h.migrator.SetAudit(audit.New(&buf, "up", "deploy"))
+
+	_, err := h.migrator.Up(h.ctx)
+	assert.NoError(t, err)
+
+	var statuses []string
+	for _, record := range auditRecords(t, &buf) {
+		statuses = append(statuses, record.Status)
+	}
+	assert.Equal(t, []string{storage.StatusProcess, storage.StatusSuccess, storage.StatusProcess, storage.StatusSuccess}, statuses)
+}
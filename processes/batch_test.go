@@ -0,0 +1,85 @@
+package processes
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// countingStorage считает обращения к хранилищу, которые пишут статусы миграций
+type countingStorage struct {
+	storage.MockSqlStorage
+	writes int
+}
+
+func (s *countingStorage) InsertMigration(ctx context.Context, migration storage.IMigration) error {
+	s.writes++
+	return s.MockSqlStorage.InsertMigration(ctx, migration)
+}
+
+func (s *countingStorage) InsertMigrationEvent(ctx context.Context, migration storage.IMigration) error {
+	s.writes++
+	return s.MockSqlStorage.InsertMigrationEvent(ctx, migration)
+}
+
+func (s *countingStorage) InsertMigrations(ctx context.Context, migrations []storage.IMigration) error {
+	s.writes++
+	return s.MockSqlStorage.InsertMigrations(ctx, migrations)
+}
+
+func newBatchMigrator(sqlStorage storage.SqlStorage, count int, batch bool) *Migrator {
+	migrator := New(sqlStorage, logger.New())
+	migrator.SetBatch(batch)
+	for i := 1; i <= count; i++ {
+		migrator.Create(fmt.Sprintf("migration_%d", i), "SELECT 1;", "SELECT 1;", nil, nil)
+	}
+
+	return migrator
+}
+
+func TestBatchModeReducesStorageWrites(t *testing.T) {
+	ctx := context.Background()
+
+	auditStorage := &countingStorage{}
+	_, err := newBatchMigrator(auditStorage, 10, false).Up(ctx)
+	assert.NoError(t, err)
+
+	batchStorage := &countingStorage{}
+	result, err := newBatchMigrator(batchStorage, 10, true).Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 10, result.Applied)
+
+	assert.Equal(t, 40, auditStorage.writes, "Expected process and success rows and events per migration")
+	assert.Equal(t, 10, batchStorage.writes, "Expected a single batched write per migration")
+
+	migrations, _ := batchStorage.SelectMigrations(ctx)
+	assert.Equal(t, 10, len(migrations))
+	for _, migration := range migrations {
+		assert.Equal(t, storage.StatusSuccess, migration.GetStatus())
+	}
+
+	events, _ := batchStorage.SelectMigrationEvents(ctx)
+	assert.Equal(t, 10, len(events), "Expected only final status events in batch mode")
+}
+
+func BenchmarkUpAudit(b *testing.B) {
+	benchmarkUp(b, false)
+}
+
+func BenchmarkUpBatch(b *testing.B) {
+	benchmarkUp(b, true)
+}
+
+func benchmarkUp(b *testing.B, batch bool) {
+	ctx := context.Background()
+	for i := 0; i < b.N; i++ {
+		migrator := newBatchMigrator(&storage.MockSqlStorage{}, 200, batch)
+		if _, err := migrator.Up(ctx); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
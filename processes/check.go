@@ -0,0 +1,84 @@
+package processes
+
+import (
+	"context"
+	"errors"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+var ErrCheck = errors.New("error db check")
+
+// CheckResult — состояние базы относительно известных мигратору миграций
+type CheckResult struct {
+	Version int
+	Latest  int
+	Pending []int
+}
+
+// Behind возвращает количество миграций, которые еще не применены
+func (r CheckResult) Behind() int {
+	return len(r.Pending)
+}
+
+func (r CheckResult) UpToDate() bool {
+	return len(r.Pending) == 0
+}
+
+// Check проверяет, что таблица учета миграций доступна, и сравнивает версию базы с последней известной миграцией
+func (m *Migrator) Check(ctx context.Context) (CheckResult, error) {
+	var result CheckResult
+
+	if err := m.storage.Ping(ctx); err != nil {
+		m.logger.Error("Error in Check: %v", err)
+		return result, err
+	}
+
+	pending, err := m.pendingVersions(ctx)
+	if err != nil {
+		m.logger.Error("Error in Check: %v", err)
+		return result, ErrCheck
+	}
+	result.Pending = pending
+
+	if len(m.migrations) > 0 {
+		result.Latest = m.migrations[len(m.migrations)-1].Version
+	}
+
+	result.Version, err = m.currentVersion(ctx)
+	if err != nil {
+		m.logger.Error("Error in Check: %v", err)
+		return result, ErrCheck
+	}
+
+	return result, nil
+}
+
+// pendingVersions возвращает версии загруженных миграций, которые Up еще применит
+func (m *Migrator) pendingVersions(ctx context.Context) ([]int, error) {
+	rows, err := m.storage.SelectMigrations(ctx)
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
+		return nil, err
+	}
+
+	applied := make(map[int]bool, len(rows))
+	for _, row := range rows {
+		if row.GetStatus() == storage.StatusSuccess {
+			applied[row.GetVersion()] = true
+		}
+	}
+
+	pending, err := m.pending(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var versions []int
+	for _, migration := range pending {
+		if !applied[migration.Version] {
+			versions = append(versions, migration.Version)
+		}
+	}
+
+	return versions, nil
+}
@@ -0,0 +1,67 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+type unreachableStorage struct {
+	storage.MockSqlStorage
+}
+
+func (s *unreachableStorage) SelectMigrations(ctx context.Context) ([]storage.IMigration, error) {
+	return nil, errors.New("connection refused")
+}
+
+func newCheckMigrator(sqlStorage storage.SqlStorage) *Migrator {
+	migrator := New(sqlStorage, logger.New())
+	for _, name := range []string{"first", "second", "third"} {
+		migrator.Create(name, "SELECT 1;", "SELECT 1;", nil, nil)
+	}
+
+	return migrator
+}
+
+func TestCheckUpToDate(t *testing.T) {
+	ctx := context.Background()
+	migrator := newCheckMigrator(&storage.MockSqlStorage{})
+
+	_, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+
+	result, err := migrator.Check(ctx)
+	assert.NoError(t, err)
+	assert.True(t, result.UpToDate())
+	assert.Equal(t, 3, result.Version)
+	assert.Equal(t, 3, result.Latest)
+	assert.Equal(t, 0, result.Behind())
+}
+
+func TestCheckBehind(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := newCheckMigrator(mockStorage)
+
+	_, err := migrator.Apply(ctx, 1)
+	assert.NoError(t, err)
+
+	result, err := migrator.Check(ctx)
+	assert.NoError(t, err)
+	assert.False(t, result.UpToDate())
+	assert.Equal(t, 1, result.Version)
+	assert.Equal(t, 3, result.Latest)
+	assert.Equal(t, 2, result.Behind())
+	assert.Equal(t, []int{2, 3}, result.Pending)
+}
+
+func TestCheckUnreachable(t *testing.T) {
+	migrator := newCheckMigrator(&unreachableStorage{})
+
+	_, err := migrator.Check(context.Background())
+	assert.ErrorIs(t, err, ErrCheck)
+}
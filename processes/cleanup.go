@@ -0,0 +1,58 @@
+package processes
+
+import (
+	"context"
+	"errors"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+var ErrCleanupNotConfirmed = errors.New("cleanup deletes migration rows, pass -yes to confirm")
+
+// CleanupStatuses — статусы незавершенных и упавших запусков, которые удаляет Cleanup.
+// skipped и out_of_order — осознанные решения, а не следы сбоев, поэтому они не удаляются.
+var CleanupStatuses = []string{storage.StatusError, storage.StatusProcess, storage.StatusCancellation}
+
+// Cleanup удаляет из таблицы учета строки со статусами CleanupStatuses и возвращает их количество.
+// Без confirm только выводит строки, которые были бы удалены. Журнал событий не меняется.
+func (m *Migrator) Cleanup(ctx context.Context, confirm bool) (int, error) {
+	if err := m.storage.Lock(ctx); err != nil {
+		m.logger.Error("Error in Cleanup: %v", err)
+		return 0, err
+	}
+	defer m.storage.Unlock(ctx)
+
+	rows, err := m.storage.SelectMigrations(ctx)
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
+		m.logger.Error("Error in Cleanup: %v", err)
+		return 0, err
+	}
+
+	stale := 0
+	for _, row := range rows {
+		for _, status := range CleanupStatuses {
+			if row.GetStatus() == status {
+				m.logger.Info("Stale migration %s version %d is %s", row.GetName(), row.GetVersion(), status)
+				stale++
+			}
+		}
+	}
+
+	if stale == 0 {
+		m.logger.Info("No stale migration rows to clean up")
+		return 0, nil
+	}
+
+	if !confirm {
+		m.logger.Warn("Error in Cleanup: %v", ErrCleanupNotConfirmed)
+		return 0, ErrCleanupNotConfirmed
+	}
+
+	if err := m.storage.DeleteMigrationsByStatus(ctx, CleanupStatuses...); err != nil {
+		m.logger.Error("Error in Cleanup: %v", err)
+		return 0, err
+	}
+
+	m.logger.Info("Removed %d stale migration rows", stale)
+	return stale, nil
+}
@@ -0,0 +1,44 @@
+package processes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCleanupRemovesOnlyStaleRows(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	for version, status := range []string{
+		storage.StatusSuccess, storage.StatusError, storage.StatusProcess, storage.StatusCancel,
+		storage.StatusCancellation, storage.StatusSkipped, storage.StatusOutOfOrder,
+	} {
+		mockStorage.InsertMigration(ctx, storage.NewMigration(status, status, version+1, time.Now()))
+	}
+	migrator := New(mockStorage, logger.New())
+
+	removed, err := migrator.Cleanup(ctx, false)
+	assert.ErrorIs(t, err, ErrCleanupNotConfirmed)
+	assert.Equal(t, 0, removed)
+	rows, _ := mockStorage.SelectMigrations(ctx)
+	assert.Equal(t, 7, len(rows), "Expected nothing to be removed without confirmation")
+
+	removed, err = migrator.Cleanup(ctx, true)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, removed)
+
+	rows, _ = mockStorage.SelectMigrations(ctx)
+	var left []string
+	for _, row := range rows {
+		left = append(left, row.GetStatus())
+	}
+	assert.Equal(t, []string{storage.StatusOutOfOrder, storage.StatusSkipped, storage.StatusCancel, storage.StatusSuccess}, left)
+
+	removed, err = migrator.Cleanup(ctx, false)
+	assert.NoError(t, err, "Expected a clean table not to need confirmation")
+	assert.Equal(t, 0, removed)
+}
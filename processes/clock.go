@@ -0,0 +1,27 @@
+package processes
+
+import "time"
+
+// Clock — источник текущего времени для StatusChangeTime; в тестах его заменяют фиксированным
+type Clock interface {
+	Now() time.Time
+}
+
+type systemClock struct{}
+
+func (systemClock) Now() time.Time {
+	return time.Now()
+}
+
+// SetClock задает источник времени записей о статусах; nil возвращает системные часы
+func (m *Migrator) SetClock(clock Clock) {
+	m.clock = clock
+}
+
+// now возвращает текущее время в UTC, чтобы записи с машин в разных часовых поясах были сравнимы
+func (m *Migrator) now() time.Time {
+	if m.clock == nil {
+		return systemClock{}.Now().UTC()
+	}
+	return m.clock.Now().UTC()
+}
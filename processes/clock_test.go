@@ -0,0 +1,37 @@
+package processes
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type fixedClock struct {
+	now time.Time
+}
+
+func (c *fixedClock) Now() time.Time {
+	return c.now
+}
+
+func TestStatusChangeTimeFromClock(t *testing.T) {
+	h := newHarness(t, 1)
+	clock := &fixedClock{now: time.Date(2024, 3, 1, 15, 30, 0, 0, time.FixedZone("UTC+3", 3*60*60))}
+	h.migrator.SetClock(clock)
+
+	_, err := h.migrator.Up(h.ctx)
+	assert.NoError(t, err)
+
+	migration, err := h.storage.SelectMigrationByVersion(h.ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 12, 30, 0, 0, time.UTC), migration.GetStatusChangeTime(), "Expected the clock time in UTC")
+
+	clock.now = clock.now.Add(time.Hour)
+	_, err = h.migrator.Down(h.ctx)
+	assert.NoError(t, err)
+
+	migration, err = h.storage.SelectMigrationByVersion(h.ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, time.Date(2024, 3, 1, 13, 30, 0, 0, time.UTC), migration.GetStatusChangeTime())
+}
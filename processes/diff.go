@@ -0,0 +1,96 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"sort"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+var ErrDiff = errors.New("error db diff")
+
+// StatusMismatch — версия, записанная в обеих базах с разными статусами
+type StatusMismatch struct {
+	Version     int
+	Name        string
+	Status      string
+	OtherStatus string
+}
+
+// DiffResult — различия в учете миграций между базой мигратора и другой базой
+type DiffResult struct {
+	// OnlyThis — версии, которые есть только в базе мигратора
+	OnlyThis []storage.IMigration
+	// OnlyOther — версии, которые есть только в другой базе
+	OnlyOther  []storage.IMigration
+	Mismatched []StatusMismatch
+}
+
+func (r DiffResult) Equal() bool {
+	return len(r.OnlyThis) == 0 && len(r.OnlyOther) == 0 && len(r.Mismatched) == 0
+}
+
+// Diff сравнивает таблицы учета миграций двух баз. Обе базы только читаются.
+func (m *Migrator) Diff(ctx context.Context, other storage.SqlStorage) (DiffResult, error) {
+	var result DiffResult
+
+	rows, err := m.storage.SelectMigrations(ctx)
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
+		m.logger.Error("Error in Diff: %v", err)
+		return result, ErrDiff
+	}
+
+	otherRows, err := other.SelectMigrations(ctx)
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
+		m.logger.Error("Error in Diff: %v", err)
+		return result, ErrDiff
+	}
+
+	byVersion := make(map[int]storage.IMigration, len(rows))
+	for _, row := range rows {
+		byVersion[row.GetVersion()] = row
+	}
+
+	otherByVersion := make(map[int]storage.IMigration, len(otherRows))
+	for _, row := range otherRows {
+		otherByVersion[row.GetVersion()] = row
+	}
+
+	for version, row := range byVersion {
+		otherRow, ok := otherByVersion[version]
+		if !ok {
+			result.OnlyThis = append(result.OnlyThis, row)
+			continue
+		}
+
+		if row.GetStatus() != otherRow.GetStatus() {
+			result.Mismatched = append(result.Mismatched, StatusMismatch{
+				Version:     version,
+				Name:        row.GetName(),
+				Status:      row.GetStatus(),
+				OtherStatus: otherRow.GetStatus(),
+			})
+		}
+	}
+
+	for version, row := range otherByVersion {
+		if _, ok := byVersion[version]; !ok {
+			result.OnlyOther = append(result.OnlyOther, row)
+		}
+	}
+
+	sortByVersion(result.OnlyThis)
+	sortByVersion(result.OnlyOther)
+	sort.Slice(result.Mismatched, func(i, j int) bool {
+		return result.Mismatched[i].Version < result.Mismatched[j].Version
+	})
+
+	return result, nil
+}
+
+func sortByVersion(migrations []storage.IMigration) {
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].GetVersion() < migrations[j].GetVersion()
+	})
+}
@@ -0,0 +1,44 @@
+package processes
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDiffReportsDifferences(t *testing.T) {
+	ctx := context.Background()
+	staging := &storage.MockSqlStorage{}
+	prod := &storage.MockSqlStorage{}
+
+	staging.InsertMigration(ctx, storage.NewMigration("create_users", storage.StatusSuccess, 1, time.Now()))
+	staging.InsertMigration(ctx, storage.NewMigration("create_posts", storage.StatusSuccess, 2, time.Now()))
+	staging.InsertMigration(ctx, storage.NewMigration("add_tags", storage.StatusSuccess, 3, time.Now()))
+
+	prod.InsertMigration(ctx, storage.NewMigration("create_users", storage.StatusSuccess, 1, time.Now()))
+	prod.InsertMigration(ctx, storage.NewMigration("create_posts", storage.StatusError, 2, time.Now()))
+	prod.InsertMigration(ctx, storage.NewMigration("legacy_fix", storage.StatusSuccess, 4, time.Now()))
+
+	result, err := New(staging, logger.New()).Diff(ctx, prod)
+	assert.NoError(t, err)
+	assert.False(t, result.Equal())
+
+	if assert.Equal(t, 1, len(result.OnlyThis)) {
+		assert.Equal(t, 3, result.OnlyThis[0].GetVersion())
+	}
+	if assert.Equal(t, 1, len(result.OnlyOther)) {
+		assert.Equal(t, 4, result.OnlyOther[0].GetVersion())
+	}
+	assert.Equal(t, []StatusMismatch{{Version: 2, Name: "create_posts", Status: storage.StatusSuccess, OtherStatus: storage.StatusError}}, result.Mismatched)
+	assert.Equal(t, 0, len(staging.Executed())+len(prod.Executed()), "Expected diff to be read-only")
+}
+
+func TestDiffEmptyDatabasesAreEqual(t *testing.T) {
+	result, err := New(&storage.MockSqlStorage{}, logger.New()).Diff(context.Background(), &storage.MockSqlStorage{})
+	assert.NoError(t, err)
+	assert.True(t, result.Equal())
+}
@@ -0,0 +1,152 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+const (
+	// DryRunPrint — печатает SQL ожидающих миграций, не обращаясь к их таблицам
+	DryRunPrint = "print"
+	// DryRunValidate — выполняет ожидающие миграции в транзакции и откатывает ее, чтобы сервер проверил синтаксис и права.
+	// Миграции, которые нельзя выполнить в транзакции (CREATE INDEX CONCURRENTLY и т.п.), и Go-миграции не проверяются.
+	DryRunValidate = "validate"
+)
+
+var (
+	ErrUnknownDryRunMode = errors.New("unknown dry-run mode, use print or validate")
+	ErrDryRunFailed      = errors.New("dry-run validation failed")
+)
+
+// IsKnownDryRunMode проверяет, что режим dry-run поддерживается
+func IsKnownDryRunMode(mode string) bool {
+	return mode == DryRunPrint || mode == DryRunValidate
+}
+
+// DryRun показывает, что сделает Up, ничего не сохраняя: печатает SQL ожидающих миграций или, в режиме
+// DryRunValidate, выполняет их по порядку в одной транзакции и откатывает ее, сообщая результат каждой.
+// Вывод уходит в w, а если он nil — в логгер.
+func (m *Migrator) DryRun(ctx context.Context, mode string, w io.Writer) error {
+	if !IsKnownDryRunMode(mode) {
+		m.logger.Error("Error in DryRun: %v: %s", ErrUnknownDryRunMode, mode)
+		return ErrUnknownDryRunMode
+	}
+
+	if err := m.storage.Lock(ctx); err != nil {
+		m.logger.Error("Error in DryRun: %v", err)
+		return err
+	}
+	defer m.storage.Unlock(ctx)
+
+	pending, err := m.pending(ctx)
+	if err != nil {
+		m.logger.Error("Error in DryRun: %v", err)
+		return err
+	}
+
+	var lines []string
+	if mode == DryRunPrint {
+		lines, err = m.dryRunPrint(ctx, pending)
+	} else {
+		lines, err = m.dryRunValidate(ctx, pending)
+	}
+
+	if writeErr := m.writeLines(w, lines); writeErr != nil {
+		m.logger.Error("Error in DryRun: %v", writeErr)
+		return writeErr
+	}
+
+	return err
+}
+
+func (m *Migrator) dryRunPrint(ctx context.Context, pending []*storage.Migration) ([]string, error) {
+	lines := make([]string, 0, len(pending))
+	for _, migration := range pending {
+		lines = append(lines, fmt.Sprintf("-- version %d %s", migration.Version, migration.Name))
+		if migration.UpGo != nil {
+			lines = append(lines, "-- go migration, SQL is not known before it runs")
+			continue
+		}
+		sql, err := m.buildSQL(ctx, migration, DirectionUp, migration.Up)
+		if err != nil {
+			m.logger.Error("Error in DryRun: %v", err)
+			return lines, err
+		}
+		lines = append(lines, sql)
+		if seed := m.seed(migration); seed != "" {
+			lines = append(lines, seed)
+		}
+	}
+	return lines, nil
+}
+
+// dryRunValidate выполняет миграции в общей транзакции, чтобы каждая проверялась поверх предыдущих.
+// После первой ошибки транзакция прервана, поэтому следующие миграции отмечаются как непроверенные.
+func (m *Migrator) dryRunValidate(ctx context.Context, pending []*storage.Migration) ([]string, error) {
+	if err := m.storage.Begin(ctx); err != nil {
+		m.logger.Error("Error in DryRun: %v", err)
+		return nil, err
+	}
+	defer func() {
+		if err := m.storage.Rollback(ctx); err != nil {
+			m.logger.Error("Error in DryRun: %v", err)
+		}
+	}()
+
+	var (
+		lines  []string
+		failed error
+	)
+	for _, migration := range pending {
+		prefix := fmt.Sprintf("version %d %s: ", migration.Version, migration.Name)
+		if failed != nil {
+			lines = append(lines, prefix+"not validated, a previous migration failed")
+			continue
+		}
+		if migration.UpGo != nil {
+			lines = append(lines, prefix+"skipped, go migrations are not validated")
+			continue
+		}
+
+		sql, err := m.buildSQL(ctx, migration, DirectionUp, migration.Up)
+		if err == nil {
+			err = m.storage.Migrate(ctx, sql)
+		}
+		if err == nil {
+			if seed := m.seed(migration); seed != "" {
+				err = m.storage.Migrate(ctx, seed)
+			}
+		}
+
+		switch {
+		case errors.Is(err, storage.ErrNonTransactionalMigration):
+			lines = append(lines, prefix+"skipped, cannot run in a transaction")
+		case err != nil:
+			m.logger.Error("Error in DryRun: %v", err)
+			lines = append(lines, prefix+"failed: "+err.Error())
+			failed = fmt.Errorf("%w: version %d: %v", ErrDryRunFailed, migration.Version, err)
+		default:
+			lines = append(lines, prefix+"ok")
+		}
+	}
+
+	return lines, failed
+}
+
+// writeLines пишет строки в w или, если он nil, в логгер
+func (m *Migrator) writeLines(w io.Writer, lines []string) error {
+	for _, line := range lines {
+		if w == nil {
+			m.logger.Info(line)
+			continue
+		}
+		if _, err := fmt.Fprintln(w, line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,71 @@
+package processes
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+var errSyntax = errors.New("syntax error")
+
+// rejectingStorage отклоняет SQL с BROKEN, как сервер отклоняет неверный запрос
+type rejectingStorage struct {
+	storage.MockSqlStorage
+}
+
+func (s *rejectingStorage) Migrate(ctx context.Context, sql string) error {
+	if strings.Contains(sql, "BROKEN") {
+		return errSyntax
+	}
+	if strings.Contains(sql, "CONCURRENTLY") && s.InTransaction() {
+		return storage.ErrNonTransactionalMigration
+	}
+	return s.MockSqlStorage.Migrate(ctx, sql)
+}
+
+func TestDryRunPrint(t *testing.T) {
+	h := newHarness(t, 2)
+	_, err := h.migrator.UpN(h.ctx, 1)
+	assert.NoError(t, err)
+
+	var out bytes.Buffer
+	assert.NoError(t, h.migrator.DryRun(h.ctx, DryRunPrint, &out))
+	assert.Equal(t, "-- version 2 create_t2\nCREATE TABLE t2 ();\n", out.String())
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess}, h.statuses(), "Expected dry-run not to record statuses")
+}
+
+func TestDryRunValidate(t *testing.T) {
+	ctx := context.Background()
+	sqlStorage := &rejectingStorage{}
+	migrator := New(sqlStorage, logger.New())
+	migrator.Create("create_users", "CREATE TABLE users ();", "DROP TABLE users;", nil, nil)
+	migrator.Create("index_users", "CREATE INDEX CONCURRENTLY users_idx ON users (id);", "DROP INDEX users_idx;", nil, nil)
+	migrator.Create("broken", "CREATE BROKEN;", "SELECT 1;", nil, nil)
+	migrator.Create("create_orders", "CREATE TABLE orders ();", "DROP TABLE orders;", nil, nil)
+
+	var out bytes.Buffer
+	err := migrator.DryRun(ctx, DryRunValidate, &out)
+	assert.ErrorIs(t, err, ErrDryRunFailed)
+	assert.Equal(t, []string{
+		"version 1 create_users: ok",
+		"version 2 index_users: skipped, cannot run in a transaction",
+		"version 3 broken: failed: syntax error",
+		"version 4 create_orders: not validated, a previous migration failed",
+	}, strings.Split(strings.TrimSpace(out.String()), "\n"))
+
+	assert.Empty(t, sqlStorage.Executed(), "Expected validation to be rolled back")
+	assert.False(t, sqlStorage.InTransaction())
+	_, err = sqlStorage.SelectMigrations(ctx)
+	assert.ErrorIs(t, err, storage.ErrMigrationNotFound)
+}
+
+func TestDryRunUnknownMode(t *testing.T) {
+	h := newHarness(t, 1)
+	assert.ErrorIs(t, h.migrator.DryRun(h.ctx, "apply", nil), ErrUnknownDryRunMode)
+}
@@ -0,0 +1,151 @@
+package processes
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+// exportTimeLayout — время выгружается в UTC с явной зоной; legacyExportTimeLayout — формат старых выгрузок без зоны
+const (
+	exportTimeLayout       = "2006-01-02 15:04:05.999999Z07:00"
+	legacyExportTimeLayout = "2006-01-02 15:04:05.999999"
+)
+
+var (
+	ErrExport          = errors.New("error export")
+	ErrInvalidExport   = errors.New("invalid export line")
+	ErrHistoryNotEmpty = errors.New("migration history is not empty, use force to overwrite")
+	regExportStatement = regexp.MustCompile(`^INSERT INTO (.+) \(Version, Name, Status, StatusChangeTime\) VALUES \((\d+), '((?:[^']|'')*)', '((?:[^']|'')*)', '([^']*)'\);$`)
+)
+
+// Export выгружает записи таблицы учета миграций в виде переносимых INSERT-выражений
+func (m *Migrator) Export(ctx context.Context, w io.Writer) error {
+	migrations, err := m.storage.SelectMigrations(ctx)
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
+		m.logger.Error("Error in Export: %v", err)
+		return ErrExport
+	}
+
+	sort.Slice(migrations, func(i, j int) bool {
+		return migrations[i].GetVersion() < migrations[j].GetVersion()
+	})
+
+	table := pgx.Identifier(strings.Split(m.storage.TableName(), ".")).Sanitize()
+	for _, migration := range migrations {
+		_, err := fmt.Fprintf(w, "INSERT INTO %s (Version, Name, Status, StatusChangeTime) VALUES (%d, %s, %s, '%s');\n",
+			table, migration.GetVersion(), quoteLiteral(migration.GetName()), quoteLiteral(migration.GetStatus()),
+			migration.GetStatusChangeTime().UTC().Format(exportTimeLayout))
+		if err != nil {
+			m.logger.Error("Error in Export: %v", err)
+			return err
+		}
+	}
+
+	m.logger.Info("Exported %d migrations", len(migrations))
+	return nil
+}
+
+// Import загружает историю, выгруженную Export. Непустая история перезаписывается только в режиме force.
+func (m *Migrator) Import(ctx context.Context, r io.Reader) error {
+	imported, err := ParseExport(r)
+	if err != nil {
+		m.logger.Error("Error in Import: %v", err)
+		return err
+	}
+
+	for _, migration := range imported {
+		if migration.GetVersion() < 1 || migration.GetVersion() > len(m.migrations) {
+			m.logger.Error("Error in Import: version %d exceeds known migrations count %d", migration.GetVersion(), len(m.migrations))
+			return ErrUnexpectedMigrationVersion
+		}
+	}
+
+	if err := m.storage.Lock(ctx); err != nil {
+		m.logger.Error("Error in Import: %v", err)
+		return err
+	}
+	defer m.storage.Unlock(ctx)
+
+	existing, err := m.storage.SelectMigrations(ctx)
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
+		m.logger.Error("Error in Import: %v", err)
+		return err
+	}
+
+	if len(existing) > 0 {
+		if !m.force {
+			m.logger.Error("Error in Import: %v", ErrHistoryNotEmpty)
+			return ErrHistoryNotEmpty
+		}
+
+		m.logger.Warn("Overwriting existing migration history with %d records", len(existing))
+		if err := m.storage.DeleteMigrations(ctx); err != nil {
+			m.logger.Error("Error in Import: %v", err)
+			return err
+		}
+	}
+
+	for _, migration := range imported {
+		if err := m.storage.InsertMigration(ctx, migration); err != nil {
+			m.logger.Error("Error in Import: %v", err)
+			return err
+		}
+	}
+
+	m.logger.Info("Imported %d migrations", len(imported))
+	return nil
+}
+
+// ParseExport разбирает вывод Export обратно в записи учета миграций
+func ParseExport(r io.Reader) ([]storage.IMigration, error) {
+	var migrations []storage.IMigration
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "--") {
+			continue
+		}
+
+		matches := regExportStatement.FindStringSubmatch(line)
+		if matches == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidExport, line)
+		}
+
+		version, err := strconv.Atoi(matches[2])
+		if err != nil {
+			return nil, err
+		}
+
+		statusChangeTime, err := time.Parse(exportTimeLayout, matches[5])
+		if err != nil {
+			statusChangeTime, err = time.Parse(legacyExportTimeLayout, matches[5])
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		migrations = append(migrations, storage.NewMigration(unquoteLiteral(matches[3]), unquoteLiteral(matches[4]), version, statusChangeTime))
+	}
+
+	return migrations, scanner.Err()
+}
+
+func quoteLiteral(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", "''") + "'"
+}
+
+func unquoteLiteral(value string) string {
+	return strings.ReplaceAll(value, "''", "'")
+}
@@ -0,0 +1,86 @@
+package processes
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExportRoundTrip(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	changed := time.Date(2024, 3, 1, 12, 30, 15, 123000000, time.UTC)
+	mockStorage.InsertMigration(ctx, storage.NewMigration("create_users", storage.StatusSuccess, 1, changed))
+	mockStorage.InsertMigration(ctx, storage.NewMigration("user's_email", storage.StatusError, 2, changed.Add(time.Minute)))
+
+	var buf bytes.Buffer
+	assert.NoError(t, migrator.Export(ctx, &buf))
+	assert.Contains(t, buf.String(), `INSERT INTO "schema_migrations" (Version, Name, Status, StatusChangeTime) VALUES (1, 'create_users', 'success', '2024-03-01 12:30:15.123Z');`)
+
+	migrations, err := ParseExport(&buf)
+	assert.NoError(t, err)
+	if assert.Equal(t, 2, len(migrations)) {
+		assert.Equal(t, "create_users", migrations[0].GetName())
+		assert.Equal(t, storage.StatusSuccess, migrations[0].GetStatus())
+		assert.True(t, changed.Equal(migrations[0].GetStatusChangeTime()))
+		assert.Equal(t, 2, migrations[1].GetVersion())
+		assert.Equal(t, "user's_email", migrations[1].GetName())
+	}
+}
+
+func TestImportIntoCleanHistory(t *testing.T) {
+	ctx := context.Background()
+	export := "INSERT INTO \"schema_migrations\" (Version, Name, Status, StatusChangeTime) VALUES (1, 'create_users', 'success', '2024-03-01 12:30:15');\n" +
+		"INSERT INTO \"schema_migrations\" (Version, Name, Status, StatusChangeTime) VALUES (2, 'create_posts', 'success', '2024-03-01 12:31:00');\n"
+
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	migrator.Create("create_users", "", "", nil, nil)
+	migrator.Create("create_posts", "", "", nil, nil)
+
+	assert.NoError(t, migrator.Import(ctx, strings.NewReader(export)))
+
+	migrations, _ := mockStorage.SelectMigrations(ctx)
+	assert.Equal(t, 2, len(migrations))
+	assert.Empty(t, mockStorage.Executed(), "Expected import not to run migrations")
+
+	version, err := migrator.currentVersion(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+}
+
+func TestImportRefusesOverwrite(t *testing.T) {
+	ctx := context.Background()
+	export := "INSERT INTO \"schema_migrations\" (Version, Name, Status, StatusChangeTime) VALUES (1, 'create_users', 'success', '2024-03-01 12:30:15');\n"
+
+	mockStorage := &storage.MockSqlStorage{}
+	mockStorage.InsertMigration(ctx, storage.NewMigration("create_users", storage.StatusError, 1, time.Now()))
+
+	migrator := New(mockStorage, logger.New())
+	migrator.Create("create_users", "", "", nil, nil)
+
+	assert.ErrorIs(t, migrator.Import(ctx, strings.NewReader(export)), ErrHistoryNotEmpty)
+
+	migrator.SetForce(true)
+	assert.NoError(t, migrator.Import(ctx, strings.NewReader(export)))
+
+	migrations, _ := mockStorage.SelectMigrations(ctx)
+	assert.Equal(t, storage.StatusSuccess, migrations[0].GetStatus())
+}
+
+func TestImportRejectsUnknownVersions(t *testing.T) {
+	export := "INSERT INTO \"schema_migrations\" (Version, Name, Status, StatusChangeTime) VALUES (3, 'unknown', 'success', '2024-03-01 12:30:15');\n"
+
+	migrator := New(&storage.MockSqlStorage{}, logger.New())
+	migrator.Create("create_users", "", "", nil, nil)
+
+	assert.ErrorIs(t, migrator.Import(context.Background(), strings.NewReader(export)), ErrUnexpectedMigrationVersion)
+}
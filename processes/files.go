@@ -0,0 +1,67 @@
+package processes
+
+import (
+	"strings"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+const (
+	// FilePresent и FileMissing — есть ли файл шага миграции среди загруженных
+	FilePresent = "present"
+	FileMissing = "missing"
+
+	// ChecksumMatch — файл совпадает с SQL, записанным при выполнении миграции
+	ChecksumMatch = "match"
+	// ChecksumChanged — файл изменен после выполнения миграции
+	ChecksumChanged = "changed"
+	// ChecksumUnknown — сравнивать не с чем: SQL не записывался (см. SetRecordSQL) или файла нет
+	ChecksumUnknown = "unknown"
+)
+
+// fileState — состояние файлов миграции для строки учета в подробном выводе status
+type fileState struct {
+	Up       string
+	Down     string
+	Checksum string
+}
+
+// fileState сопоставляет строку учета с загруженной миграцией той же версии и имени. Имя сверяется,
+// потому что после удаления файла версии загруженных миграций сдвигаются. Записанный SQL сравнивается
+// с down-шагом для отмененной миграции и с up-шагом (с seed-данными или без) для остальных.
+func (m *Migrator) fileState(row storage.IMigration) fileState {
+	var loaded *storage.Migration
+	for i := range m.migrations {
+		if m.migrations[i].Version == row.GetVersion() && m.migrations[i].Name == row.GetName() {
+			loaded = &m.migrations[i]
+			break
+		}
+	}
+	state := fileState{Up: FileMissing, Down: FileMissing, Checksum: ChecksumUnknown}
+	if loaded == nil {
+		return state
+	}
+	if loaded.Up != "" || loaded.UpGo != nil {
+		state.Up = FilePresent
+	}
+	if loaded.Down != "" || loaded.DownGo != nil {
+		state.Down = FilePresent
+	}
+
+	applied := row.GetAppliedSQL()
+	if applied == "" {
+		return state
+	}
+
+	state.Checksum = ChecksumChanged
+	if row.GetStatus() == storage.StatusCancel {
+		if applied == loaded.Down {
+			state.Checksum = ChecksumMatch
+		}
+		return state
+	}
+	if applied == loaded.Up || applied == strings.TrimSpace(loaded.Up+"\n"+loaded.Seed) {
+		state.Checksum = ChecksumMatch
+	}
+	return state
+}
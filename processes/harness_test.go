@@ -0,0 +1,104 @@
+package processes
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+// harness прогоняет набор SQL-миграций через Migrator поверх MockSqlStorage
+type harness struct {
+	t        *testing.T
+	ctx      context.Context
+	storage  *storage.MockSqlStorage
+	migrator *Migrator
+}
+
+// newHarness создает мигратор с count миграциями: версия N создает и удаляет таблицу tN
+func newHarness(t *testing.T, count int) *harness {
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	for i := 1; i <= count; i++ {
+		migrator.Create(fmt.Sprintf("create_t%d", i), fmt.Sprintf("CREATE TABLE t%d ();", i), fmt.Sprintf("DROP TABLE t%d;", i), nil, nil)
+	}
+
+	return &harness{t: t, ctx: context.Background(), storage: mockStorage, migrator: migrator}
+}
+
+// statuses возвращает статус каждой записанной в хранилище версии
+func (h *harness) statuses() map[int]string {
+	migrations, err := h.storage.SelectMigrations(h.ctx)
+	if err != nil {
+		assert.ErrorIs(h.t, err, storage.ErrMigrationNotFound)
+	}
+
+	statuses := make(map[int]string, len(migrations))
+	for _, migration := range migrations {
+		statuses[migration.GetVersion()] = migration.GetStatus()
+	}
+	return statuses
+}
+
+func (h *harness) assertVersion(expected int) {
+	version, err := h.migrator.currentVersion(h.ctx)
+	assert.NoError(h.t, err)
+	assert.Equal(h.t, expected, version)
+}
+
+func TestHarnessUpDownCycle(t *testing.T) {
+	h := newHarness(t, 3)
+	h.assertVersion(0)
+	assert.Empty(t, h.statuses())
+
+	result, err := h.migrator.Up(h.ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Applied)
+	assert.Equal(t, 3, result.Version)
+	h.assertVersion(3)
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess, 2: storage.StatusSuccess, 3: storage.StatusSuccess}, h.statuses())
+
+	result, err = h.migrator.Down(h.ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Version)
+
+	result, err = h.migrator.Down(h.ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Version)
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess, 2: storage.StatusCancel, 3: storage.StatusCancel}, h.statuses())
+
+	result, err = h.migrator.Redo(h.ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Version, "Expected redo to keep the version")
+
+	result, err = h.migrator.Up(h.ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Applied)
+	h.assertVersion(3)
+
+	assert.Equal(t, []string{
+		"CREATE TABLE t1 ();",
+		"CREATE TABLE t2 ();",
+		"CREATE TABLE t3 ();",
+		"DROP TABLE t3;",
+		"DROP TABLE t2;",
+		"DROP TABLE t1;",
+		"CREATE TABLE t1 ();",
+		"CREATE TABLE t2 ();",
+		"CREATE TABLE t3 ();",
+	}, h.storage.Executed())
+}
+
+func TestHarnessRowsDoNotAliasMigrations(t *testing.T) {
+	h := newHarness(t, 2)
+
+	_, err := h.migrator.Up(h.ctx)
+	assert.NoError(t, err)
+
+	h.migrator.migrations[1].Status = storage.StatusError
+	assert.Equal(t, storage.StatusSuccess, h.statuses()[2], "Expected storage to keep its own copy of the row")
+}
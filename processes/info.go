@@ -0,0 +1,94 @@
+package processes
+
+import (
+	"fmt"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+const (
+	// KindSQL — миграция из SQL-шагов
+	KindSQL = "sql"
+	// KindGo — миграция, у которой хотя бы один шаг задан Go-функцией
+	KindGo = "go"
+)
+
+// IsKnownKind проверяет, что вид миграций поддерживается
+func IsKnownKind(kind string) bool {
+	return kind == KindSQL || kind == KindGo
+}
+
+// upKind возвращает вид up-шага миграции: KindGo, если он задан Go-функцией
+func upKind(migration *storage.Migration) string {
+	if migration.UpGo != nil {
+		return KindGo
+	}
+	return KindSQL
+}
+
+// stopsAt проверяет, что Up с SetOnly должен остановиться на миграции другого вида
+func (m *Migrator) stopsAt(migration *storage.Migration) bool {
+	return m.only != "" && upKind(migration) != m.only
+}
+
+// checkOnly возвращает ErrInterleavedKinds, если с SetOnly среди ожидающих миграций до версии version
+// после миграции другого вида идет миграция выбранного: Up остановился бы на первой и молча не применил вторую
+func (m *Migrator) checkOnly(pending []*storage.Migration, version int) error {
+	var stop *storage.Migration
+	for _, migration := range pending {
+		if version > 0 && migration.Version > version {
+			break
+		}
+		if !m.stopsAt(migration) {
+			if stop != nil {
+				return fmt.Errorf("%w: %s migration version %d follows %s migration version %d",
+					ErrInterleavedKinds, m.only, migration.Version, upKind(stop), stop.Version)
+			}
+			continue
+		}
+		if stop == nil {
+			stop = migration
+		}
+	}
+	return nil
+}
+
+// MigrationInfo — описание загруженной миграции без ее содержимого
+type MigrationInfo struct {
+	Version     int
+	Name        string
+	Kind        string
+	HasDown     bool
+	Reversible  bool
+	Description string
+	Author      string
+	Tag         string
+	Requires    []int
+	MinServer   int
+}
+
+// Migrations возвращает описания загруженных миграций в порядке загрузки.
+// Это копии: изменение результата не влияет на мигратор.
+func (m *Migrator) Migrations() []MigrationInfo {
+	infos := make([]MigrationInfo, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		kind := KindSQL
+		if migration.UpGo != nil || migration.DownGo != nil {
+			kind = KindGo
+		}
+
+		infos = append(infos, MigrationInfo{
+			Version:     migration.Version,
+			Name:        migration.Name,
+			Kind:        kind,
+			HasDown:     migration.Down != "" || migration.DownGo != nil,
+			Reversible:  migration.DownGo != nil || !isIrreversible(migration.Down),
+			Description: migration.Description,
+			Author:      migration.Author,
+			Tag:         migration.Tag,
+			Requires:    append([]int(nil), migration.Requires...),
+			MinServer:   migration.MinServer,
+		})
+	}
+	return infos
+}
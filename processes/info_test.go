@@ -0,0 +1,31 @@
+package processes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMigrationsDescribesLoadedSet(t *testing.T) {
+	migrator := New(&storage.MockSqlStorage{}, logger.New())
+	migrator.Create("create_users", "CREATE TABLE users (id integer);", "DROP TABLE users;", nil, nil)
+	migrator.Create("backfill", "UPDATE users SET id = id;", IrreversibleMarker+"\n", nil, nil)
+	migrator.Create("seed", "", "", func(ctx context.Context) error { return nil }, nil)
+	migrator.AddMigration(storage.Migration{Version: 4, Name: "tagged", Up: "SELECT 1;", Tag: "v1", Requires: []int{1}})
+
+	infos := migrator.Migrations()
+	assert.Equal(t, []MigrationInfo{
+		{Version: 1, Name: "create_users", Kind: KindSQL, HasDown: true, Reversible: true},
+		{Version: 2, Name: "backfill", Kind: KindSQL, HasDown: true, Reversible: false},
+		{Version: 3, Name: "seed", Kind: KindGo, HasDown: false, Reversible: true},
+		{Version: 4, Name: "tagged", Kind: KindSQL, Reversible: true, Tag: "v1", Requires: []int{1}},
+	}, infos)
+
+	infos[3].Requires[0] = 42
+	infos[0].Name = "changed"
+	assert.Equal(t, []int{1}, migrator.Migrations()[3].Requires, "Expected the view to be a copy")
+	assert.Equal(t, "create_users", migrator.Migrations()[0].Name)
+}
@@ -0,0 +1,74 @@
+package processes
+
+import (
+	"net/http"
+
+	"github.com/juliazadorozhnaya/sql-migrator/metrics"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+// MetricsHandler включает сбор метрик и возвращает обработчик, отдающий их в формате Prometheus.
+// Пока метод не вызван, мигратор метрики не считает. Переходы до вызова не учитываются.
+func (m *Migrator) MetricsHandler() http.Handler {
+	if m.metrics == nil {
+		m.metrics = metrics.New()
+	}
+	return m.metrics
+}
+
+// observeTransition учитывает сохраненный переход в метриках и журнале действий. Внутри транзакции,
+// которую открыл мигратор, переход откладывается до ее завершения: откаченных статусов в базе нет,
+// и их нельзя показывать как примененные.
+func (m *Migrator) observeTransition(migration storage.IMigration, previous, status string) {
+	record := auditRecord(migration, previous, status)
+	observe := func() {
+		m.observeStatus(status)
+		m.writeAudit(record)
+	}
+
+	if m.deferring {
+		m.deferred = append(m.deferred, observe)
+		return
+	}
+	observe()
+}
+
+// deferObservations откладывает переходы до finishObservations; вызывается сразу после BEGIN
+func (m *Migrator) deferObservations() {
+	m.deferring = true
+}
+
+// finishObservations учитывает отложенные переходы после COMMIT и отбрасывает их после ROLLBACK
+func (m *Migrator) finishObservations(committed bool) {
+	deferred := m.deferred
+	m.deferring, m.deferred = false, nil
+	if !committed {
+		return
+	}
+
+	for _, observe := range deferred {
+		observe()
+	}
+}
+
+// observeStatus учитывает в метриках итоговые переходы статусов; промежуточные и пропуски не считаются
+func (m *Migrator) observeStatus(status string) {
+	if m.metrics == nil {
+		return
+	}
+
+	switch status {
+	case storage.StatusSuccess, storage.StatusOutOfOrder:
+		m.metrics.IncApplied()
+	case storage.StatusError:
+		m.metrics.IncFailed()
+	case storage.StatusCancel:
+		m.metrics.IncRolledBack()
+	}
+}
+
+func (m *Migrator) observeVersion(version int) {
+	if m.metrics != nil {
+		m.metrics.SetVersion(version)
+	}
+}
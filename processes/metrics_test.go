@@ -0,0 +1,64 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMetricsCountStatusTransitions(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	handler := migrator.MetricsHandler()
+
+	migrator.Create("create_users", "CREATE TABLE users (id SERIAL PRIMARY KEY);", "DROP TABLE users;", nil, nil)
+	migrator.Create("create_orders", "CREATE TABLE orders (id SERIAL PRIMARY KEY);", "DROP TABLE orders;", nil, nil)
+
+	_, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	_, err = migrator.Down(ctx)
+	assert.NoError(t, err)
+
+	migrator.Create("broken", "", "", func(ctx context.Context) error {
+		return errors.New("boom")
+	}, nil)
+	_, err = migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrMigrationUp)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	assert.Contains(t, body, "migrator_migrations_applied_total 3\n", "Expected the re-applied version to count again")
+	assert.Contains(t, body, "migrator_migrations_failed_total 1\n")
+	assert.Contains(t, body, "migrator_migrations_rolled_back_total 1\n")
+	assert.Contains(t, body, "migrator_db_version 2\n")
+}
+
+func TestMetricsIgnoreRolledBackRun(t *testing.T) {
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	migrator.SetTxMode(TxModeAll)
+	handler := migrator.MetricsHandler()
+
+	migrator.Create("first", "SELECT 1;", "", nil, nil)
+	migrator.Create("broken", "", "", func(ctx context.Context) error {
+		return errors.New("boom")
+	}, nil)
+
+	_, err := migrator.Up(context.Background())
+	assert.ErrorIs(t, err, ErrMigrationUp)
+
+	recorder := httptest.NewRecorder()
+	handler.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+	body := recorder.Body.String()
+
+	assert.Contains(t, body, "migrator_migrations_applied_total 0\n", "Expected migrations rolled back with the run not to count as applied")
+	assert.Contains(t, body, "migrator_migrations_failed_total 1\n")
+}
@@ -2,11 +2,19 @@ package processes
 
 import (
 	"context"
+	"encoding/json"
 	"errors"
 	"fmt"
+	"io"
+	"strconv"
+	"strings"
 	"time"
 
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/juliazadorozhnaya/sql-migrator/audit"
 	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/metrics"
 	"github.com/juliazadorozhnaya/sql-migrator/storage"
 )
 
@@ -14,17 +22,51 @@ type IMigration interface {
 	Connect(context.Context) error
 	Close(context.Context) error
 	Create(name, up, down string, upGo, downGo func(ctx context.Context) error)
-	Up(context.Context) error
-	Down(context.Context) error
-	Redo(context.Context) error
-	Status(context.Context) error
+	Up(context.Context) (Result, error)
+	Down(context.Context) (Result, error)
+	Redo(context.Context) (Result, error)
+	Status(context.Context, StatusOptions) error
 	DbVersion(context.Context) error
+	History(context.Context) error
 }
 
 type Migrator struct {
 	logger     logger.Logger
 	storage    storage.SqlStorage
 	migrations []storage.Migration
+	idempotent bool
+	force      bool
+	batch      bool
+	lang       string
+	// continueOnError — Up не останавливается на первой ошибке, а собирает все упавшие версии
+	continueOnError bool
+	txMode          string
+	transaction     bool
+	seeds           bool
+	notifyChannel   string
+	// location — часовой пояс вывода времени в status и history; время в базе всегда хранится в UTC
+	location *time.Location
+	// clock — источник времени статусов; nil — системные часы
+	clock Clock
+	// only — вид миграций, которые применяет Up: KindSQL, KindGo или пустая строка для всех
+	only string
+	// resume — Up проверяет прерванные миграции их probe-запросом, прежде чем выполнять их снова
+	resume bool
+	// recordSQL — выполненный SQL миграции записывается в таблицу учета
+	recordSQL bool
+
+	progressCallback func(event ProgressEvent)
+	// metrics — счетчики переходов статусов; nil, пока не вызван MetricsHandler
+	metrics *metrics.Metrics
+	// tracer — источник спанов OpenTelemetry; nil, пока не вызван SetTracerProvider
+	tracer trace.Tracer
+	// audit — журнал действий в JSON Lines; nil, пока не вызван SetAudit
+	audit *audit.Writer
+	// deferring — мигратор открыл транзакцию, и переходы статусов ждут в deferred ее COMMIT или ROLLBACK
+	deferring bool
+	deferred  []func()
+	// sqlMigrations — шаги, возвращающие SQL, зарегистрированные через RegisterSQL, по версиям
+	sqlMigrations map[int]sqlMigration
 }
 
 var (
@@ -32,10 +74,48 @@ var (
 	ErrMigrationDown              = errors.New("error processes down")
 	ErrMigrationRedo              = errors.New("error processes redo")
 	ErrGetStatus                  = errors.New("error db status")
+	ErrWriteStatus                = errors.New("error writing status output")
+	ErrPendingMigrations          = errors.New("database has pending migrations")
 	ErrGetVersion                 = errors.New("error db version")
+	ErrGetHistory                 = errors.New("error db history")
 	ErrUnexpectedMigrationVersion = errors.New("unexpected processes version")
+	ErrIrreversibleMigration      = errors.New("migration is irreversible and cannot be rolled back")
+	ErrForceRequired              = errors.New("operation requires force")
+	ErrAlreadyApplied             = errors.New("migration is already applied")
+	ErrNotApplied                 = errors.New("migration is not applied")
+	ErrNoDownStep                 = errors.New("migration has no down step, pass the down file or a file with both steps")
+	ErrMissingPrerequisite        = errors.New("required migration is not applied")
+	ErrServerTooOld               = errors.New("database server is older than the migration requires")
+	ErrUnknownTag                 = errors.New("no migrations with this tag")
+	ErrNoMigrations               = errors.New("no migrations loaded, nothing to roll back")
+	ErrUnknownTxMode              = errors.New("unknown tx mode, use per-migration or all")
+	ErrUnknownKind                = errors.New("unknown migration kind, use sql or go")
+	ErrInterleavedKinds           = errors.New("pending migrations of the selected kind follow a migration of the other kind, run up without -only")
+	ErrUnverifiable               = errors.New("interrupted migration has no probe, add a -- migrator:probe header or resolve it with skip or apply")
+	ErrContinueInTransaction      = errors.New("continue on error needs per-migration transactions, a failed migration aborts the run transaction")
+	ErrResumeWithBatch            = errors.New("resume cannot tell an interrupted migration from a pending one in batch mode, run up without batch")
+)
+
+const (
+	// TxModePerMigration — каждая миграция фиксируется отдельно
+	TxModePerMigration = "per-migration"
+	// TxModeAll — Up выполняет все ожидающие миграции и их учет в одной транзакции: применяются все или ни одна.
+	// Не подходит для миграций, которые нельзя выполнять в транзакции (CREATE INDEX CONCURRENTLY и т.п.).
+	TxModeAll = "all"
 )
 
+// IsKnownTxMode проверяет, что режим транзакций поддерживается
+func IsKnownTxMode(txMode string) bool {
+	return txMode == TxModePerMigration || txMode == TxModeAll
+}
+
+// StatusTimeout — сколько ждать записи статуса ошибки. Она идет не в контексте запуска,
+// чтобы статус сохранился и после его отмены.
+const StatusTimeout = 10 * time.Second
+
+// IrreversibleMarker — строка в down-миграции, запрещающая ее откат
+const IrreversibleMarker = "-- migrator:irreversible"
+
 func New(connString storage.SqlStorage, logger logger.Logger) *Migrator {
 	return &Migrator{
 		storage:    connString,
@@ -44,6 +124,85 @@ func New(connString storage.SqlStorage, logger logger.Logger) *Migrator {
 	}
 }
 
+// SetIdempotent включает режим, в котором Up проходит по всем миграциям
+// и пропускает версии, уже отмеченные как успешно примененные.
+func (m *Migrator) SetIdempotent(idempotent bool) {
+	m.idempotent = idempotent
+}
+
+// SetForce разрешает операции, перезаписывающие существующую историю миграций
+func (m *Migrator) SetForce(force bool) {
+	m.force = force
+}
+
+// SetBatch включает пакетный режим: промежуточные статусы process и cancellation не пишутся,
+// а итоговый статус и событие сохраняются одним запросом. Без него в журнал попадает каждый переход.
+func (m *Migrator) SetBatch(batch bool) {
+	m.batch = batch
+}
+
+// SetContinueOnError включает режим, в котором Up применяет все миграции, которые удается применить,
+// и в конце возвращает ошибку со списком упавших версий; следующий Up повторяет упавшие версии, хотя версия
+// в базе ушла дальше них. По умолчанию Up останавливается на первой ошибке.
+// В общей транзакции запуска режим не работает, и Up возвращает ErrContinueInTransaction.
+func (m *Migrator) SetContinueOnError(continueOnError bool) {
+	m.continueOnError = continueOnError
+}
+
+// SetTxMode задает границы транзакций Up: TxModePerMigration (по умолчанию) или TxModeAll
+func (m *Migrator) SetTxMode(txMode string) {
+	m.txMode = txMode
+}
+
+// SetTransaction включает транзакционный режим для Go-миграций: шаг и запись его итогового статуса
+// выполняются в одной транзакции и при ошибке откатываются вместе
+func (m *Migrator) SetTransaction(transaction bool) {
+	m.transaction = transaction
+}
+
+// SetOnly ограничивает Up миграциями одного вида: KindSQL или KindGo; пустая строка снимает ограничение.
+// Up останавливается на первой миграции другого вида, а не пропускает ее: следующий Up начинает
+// после последней успешной версии, и пропущенная миграция иначе никогда бы не применилась. Если после нее
+// ожидают миграции выбранного вида, Up ничего не применяет и возвращает ErrInterleavedKinds.
+func (m *Migrator) SetOnly(kind string) {
+	m.only = kind
+}
+
+// SetResume включает восстановление учета после прерванного запуска: если миграция осталась в статусе process,
+// Up выполняет ее probe-запрос и при true записывает success, не выполняя миграцию снова. Прерванная миграция
+// без probe-запроса останавливает Up с ErrUnverifiable. Пакетный режим не пишет process, поэтому вместе с ним
+// Up возвращает ErrResumeWithBatch.
+func (m *Migrator) SetResume(resume bool) {
+	m.resume = resume
+}
+
+// SetRecordSQL включает запись выполненного SQL в колонку AppliedSQL таблицы учета: при up — SQL миграции
+// вместе с seed-данными, при down — SQL отката. Выключено по умолчанию, потому что таблица заметно растет.
+func (m *Migrator) SetRecordSQL(recordSQL bool) {
+	m.recordSQL = recordSQL
+}
+
+// SetSeeds включает применение seed-файлов сразу после up-шага миграции
+func (m *Migrator) SetSeeds(seeds bool) {
+	m.seeds = seeds
+}
+
+// SetNotifyChannel задает канал, в который после успешного Up с примененными миграциями
+// отправляется NOTIFY с версией базы; пустая строка отключает уведомления
+func (m *Migrator) SetNotifyChannel(channel string) {
+	m.notifyChannel = channel
+}
+
+// SetTimezone задает часовой пояс, в котором status и history показывают время; по умолчанию UTC
+func (m *Migrator) SetTimezone(location *time.Location) {
+	m.location = location
+}
+
+// SetLang задает язык заголовков таблиц status и history: ru (по умолчанию) или en
+func (m *Migrator) SetLang(lang string) {
+	m.lang = lang
+}
+
 func (m *Migrator) Connect(ctx context.Context) error {
 	m.logger.Info("Connecting to database")
 
@@ -71,171 +230,968 @@ func (m *Migrator) Close(ctx context.Context) error {
 }
 
 func (m *Migrator) Create(name, up, down string, upGo, downGo func(ctx context.Context) error) {
-	m.logger.Info("Creating migration: %s", name)
-	m.migrations = append(m.migrations, storage.Migration{
-		Version: len(m.migrations) + 1,
+	m.CreateVersion(len(m.migrations)+1, name, up, down, upGo, downGo)
+}
+
+// CreateVersion добавляет миграцию с явно заданной версией, например для разового применения отдельного файла
+func (m *Migrator) CreateVersion(version int, name, up, down string, upGo, downGo func(ctx context.Context) error) {
+	m.AddMigration(storage.Migration{
+		Version: version,
 		Name:    name,
 		Up:      up,
 		Down:    down,
 		UpGo:    upGo,
 		DownGo:  downGo,
 	})
-	m.logger.Info("Migration %s created", name)
 }
 
-func (m *Migrator) Up(ctx context.Context) error {
+// AddMigration добавляет миграцию целиком, вместе с метаданными из заголовка файла
+func (m *Migrator) AddMigration(migration storage.Migration) {
+	m.logger.Info("Creating migration: %s", migration.Name)
+	m.migrations = append(m.migrations, migration)
+	m.logger.Info("Migration %s created", migration.Name)
+}
+
+func (m *Migrator) Up(ctx context.Context) (result Result, err error) {
+	return m.UpTo(ctx, 0)
+}
+
+// UpTo применяет ожидающие миграции до версии version включительно; 0 — все ожидающие
+func (m *Migrator) UpTo(ctx context.Context, version int) (Result, error) {
+	return m.up(ctx, m.pending, version, 0)
+}
+
+// UpN применяет только n ближайших ожидающих миграций, например чтобы продвигаться по одной
+// и наблюдать за результатом; n <= 0 — все ожидающие
+func (m *Migrator) UpN(ctx context.Context, n int) (Result, error) {
+	return m.up(ctx, m.pending, 0, n)
+}
+
+// UpFrom применяет миграции начиная с версии from, не сверяясь с версией в базе. Проверки
+// непрерывности при этом не выполняются, поэтому операция требует force.
+func (m *Migrator) UpFrom(ctx context.Context, from int) (Result, error) {
+	if !m.force {
+		m.logger.Error("Error in UpFrom: %v", ErrForceRequired)
+		return Result{}, ErrForceRequired
+	}
+	if _, err := m.findMigration(from); err != nil {
+		m.logger.Error("Error in UpFrom: %v", err)
+		return Result{}, err
+	}
+
+	m.logger.Warn("Applying migrations from version %d: continuity checks against the db version are bypassed", from)
+	return m.up(ctx, func(ctx context.Context) ([]*storage.Migration, error) {
+		return m.pendingFrom(ctx, from)
+	}, 0, 0)
+}
+
+// up применяет миграции из pending до версии version включительно, но не больше count; нули снимают ограничения
+func (m *Migrator) up(ctx context.Context, pending func(ctx context.Context) ([]*storage.Migration, error), version, count int) (result Result, err error) {
 	m.logger.Info("Starting migrations")
+	ctx, endTrace := m.traceBatch(ctx, DirectionUp)
+	defer func() { endTrace(&result, err) }()
+	defer m.finishResult(ctx, &result, time.Now())
+	defer m.notifyUp(ctx, &result, &err)
+
+	if m.resume && m.batch {
+		m.logger.Error("Error in Up: %v", ErrResumeWithBatch)
+		return result, ErrResumeWithBatch
+	}
 
 	if err := m.storage.Lock(ctx); err != nil {
 		m.logger.Error("Error in Up: %v", err)
-		return err
+		return result, err
 	}
 	defer m.storage.Unlock(ctx)
 
-	lastVersion := 0
-	lastMigration, err := m.storage.SelectLastMigrationByStatus(ctx, storage.StatusSuccess)
-	if err == nil {
-		lastVersion = lastMigration.GetVersion()
-	} else if !errors.Is(err, storage.ErrMigrationNotFound) {
+	// в общей транзакции (tx_mode all, lock_mode transaction) после первой ошибки Postgres отвергает
+	// все следующие запросы, так что продолжать нечего, а весь запуск все равно откатывается
+	if m.continueOnError && (m.txMode == TxModeAll || m.storage.InTransaction()) {
+		m.logger.Error("Error in Up: %v", ErrContinueInTransaction)
+		return result, ErrContinueInTransaction
+	}
+
+	migrations, err := pending(ctx)
+	if err != nil {
 		m.logger.Error("Error in Up: %v", err)
-		return err
+		return result, err
 	}
 
-	if lastMigration != nil && lastMigration.GetVersion()-1 > len(m.migrations) {
-		m.logger.Error("Error in Up: %v", ErrUnexpectedMigrationVersion)
-		return ErrUnexpectedMigrationVersion
+	if err := m.checkOnly(migrations, version); err != nil {
+		m.logger.Error("Error in Up: %v", err)
+		return result, err
+	}
+
+	if m.txMode == TxModeAll {
+		if err := m.storage.Begin(ctx); err != nil {
+			m.logger.Error("Error in Up: %v", err)
+			return result, err
+		}
+		m.deferObservations()
+	}
+
+	err = m.applyPending(ctx, migrations, version, count, &result)
+	if m.txMode == TxModeAll {
+		err = m.finishTransaction(ctx, &result, err)
 	}
+	if err != nil {
+		return result, err
+	}
+
+	m.logger.Info("Migrations completed")
+	return result, nil
+}
+
+// applyPending применяет ожидающие миграции до версии version включительно, останавливаясь после count
+// примененных; нули снимают ограничения. Пропущенные в режиме idempotent версии в count не считаются.
+func (m *Migrator) applyPending(ctx context.Context, pending []*storage.Migration, version, count int, result *Result) error {
+	for _, migration := range pending {
+		if version > 0 && migration.Version > version {
+			break
+		}
+		if count > 0 && result.Applied >= count {
+			break
+		}
+		if err := ctx.Err(); err != nil {
+			m.logger.Error("Error in Up: %v", err)
+			return err
+		}
+		if m.stopsAt(migration) {
+			m.logger.Info("Migration %s version %d is a %s migration, stopping; run up with -only %s to continue", migration.Name, migration.Version, upKind(migration), upKind(migration))
+			break
+		}
 
-	for i := lastVersion; i < len(m.migrations); i++ {
-		err = m.upMigration(ctx, &m.migrations[i], m.migrations[i].Up, m.migrations[i].UpGo)
+		if m.resume {
+			resumed, err := m.resumeMigration(ctx, migration)
+			if err != nil {
+				m.logger.Error("Error in Up: %v", err)
+				return err
+			}
+			if resumed {
+				result.count(true)
+				continue
+			}
+		}
+
+		applied, err := m.upMigration(ctx, migration, migration.Up, migration.UpGo)
 		if err != nil {
+			result.Failed = append(result.Failed, migration.Version)
 			m.logger.Error("Error in Up: %v", err)
+			if m.continueOnError {
+				continue
+			}
+			if errors.Is(err, ErrMissingPrerequisite) || errors.Is(err, ErrServerTooOld) {
+				return err
+			}
 			return ErrMigrationUp
 		}
+		result.count(applied)
+	}
+
+	if len(result.Failed) > 0 {
+		return fmt.Errorf("%w: failed versions %v", ErrMigrationUp, result.Failed)
 	}
 
-	m.logger.Info("Migrations completed")
 	return nil
 }
 
-func (m *Migrator) Down(ctx context.Context) error {
+// resumeMigration проверяет, не была ли миграция применена запуском, который прервался до записи success.
+// Возвращает true, если миграция применена и учет исправлен.
+func (m *Migrator) resumeMigration(ctx context.Context, migration *storage.Migration) (bool, error) {
+	row, err := m.storage.SelectMigrationByVersion(ctx, migration.Version)
+	switch {
+	case errors.Is(err, storage.ErrMigrationNotFound):
+		return false, nil
+	case err != nil:
+		return false, err
+	case row.GetStatus() != storage.StatusProcess:
+		return false, nil
+	}
+
+	if migration.Probe == "" {
+		return false, fmt.Errorf("%w: %s version %d", ErrUnverifiable, migration.Name, migration.Version)
+	}
+
+	applied, err := m.storage.Probe(ctx, migration.Probe)
+	if err != nil {
+		return false, err
+	}
+	if !applied {
+		m.logger.Info("Probe shows migration %s version %d was not applied, running it again", migration.Name, migration.Version)
+		return false, nil
+	}
+
+	if err := m.saveStatus(ctx, migration, storage.StatusSuccess); err != nil {
+		return false, err
+	}
+	m.logger.Info("Probe shows migration %s version %d was applied before the interruption, recorded it as success", migration.Name, migration.Version)
+	return true, nil
+}
+
+// finishTransaction завершает общую транзакцию запуска: фиксирует ее при успехе,
+// а при ошибке откатывает все миграции запуска и отдельно записывает статус упавших
+func (m *Migrator) finishTransaction(ctx context.Context, result *Result, err error) error {
+	if err == nil {
+		if err := m.storage.Commit(ctx); err != nil {
+			m.finishObservations(false)
+			m.logger.Error("Error in Up: %v", err)
+			return err
+		}
+		m.finishObservations(true)
+		return nil
+	}
+
+	m.finishObservations(false)
+	if rollbackErr := m.storage.Rollback(ctx); rollbackErr != nil {
+		m.logger.Error("Error in Up: %v", rollbackErr)
+		return err
+	}
+	m.logger.Warn("Rolled back all %d migrations applied in this run", result.Applied)
+	result.Applied = 0
+
+	for _, version := range result.Failed {
+		if migration, findErr := m.findMigration(version); findErr == nil {
+			m.saveErrorStatus(migration)
+		}
+	}
+
+	return err
+}
+
+// UpToTag применяет ожидающие миграции до последней миграции с тегом tag включительно
+func (m *Migrator) UpToTag(ctx context.Context, tag string) (Result, error) {
+	version, err := m.TagVersion(tag)
+	if err != nil {
+		m.logger.Error("Error in Up: %v", err)
+		return Result{}, err
+	}
+
+	return m.UpTo(ctx, version)
+}
+
+// TagVersion возвращает наибольшую версию среди миграций с тегом tag
+func (m *Migrator) TagVersion(tag string) (int, error) {
+	version := 0
+	for _, migration := range m.migrations {
+		if migration.Tag == tag && migration.Version > version {
+			version = migration.Version
+		}
+	}
+
+	if version == 0 {
+		return 0, fmt.Errorf("%w: %s", ErrUnknownTag, tag)
+	}
+	return version, nil
+}
+
+func (m *Migrator) Down(ctx context.Context) (result Result, err error) {
 	m.logger.Info("Starting rollback")
+	ctx, endTrace := m.traceBatch(ctx, DirectionDown)
+	defer func() { endTrace(&result, err) }()
+	defer m.finishResult(ctx, &result, time.Now())
 
 	if err := m.storage.Lock(ctx); err != nil {
 		m.logger.Error("Error in Down: %v", err)
-		return err
+		return result, err
 	}
 	defer m.storage.Unlock(ctx)
 
+	if len(m.migrations) == 0 {
+		m.logger.Error("Error in Down: %v", ErrNoMigrations)
+		return result, ErrNoMigrations
+	}
+
 	lastMigration, err := m.storage.SelectLastMigrationByStatus(ctx, storage.StatusSuccess)
 	if err != nil {
 		m.logger.Error("Error in Down: %v", err)
-		return err
+		return result, err
 	}
 
-	if lastMigration != nil && lastMigration.GetVersion()-1 > len(m.migrations) {
-		m.logger.Error("Error in Down: %v", ErrUnexpectedMigrationVersion)
-		return ErrUnexpectedMigrationVersion
+	downMigrationIndex := lastMigration.GetVersion() - 1
+	if !m.hasIndex(downMigrationIndex) {
+		m.logger.Error("Error in Down: %v: db version %d, %d migrations loaded", ErrUnexpectedMigrationVersion, lastMigration.GetVersion(), len(m.migrations))
+		return result, ErrUnexpectedMigrationVersion
 	}
 
-	downMigrationIndex := lastMigration.GetVersion() - 1
 	err = m.downMigration(ctx, &m.migrations[downMigrationIndex], m.migrations[downMigrationIndex].Down, m.migrations[downMigrationIndex].DownGo)
 	if err != nil {
+		result.Failed = append(result.Failed, m.migrations[downMigrationIndex].Version)
 		m.logger.Error("Error in Down: %v", err)
-		return ErrMigrationDown
+		if errors.Is(err, ErrIrreversibleMigration) {
+			return result, err
+		}
+		return result, ErrMigrationDown
 	}
+	result.RolledBack++
 
 	m.logger.Info("Rollback completed")
+	return result, nil
+}
+
+// pending возвращает миграции, которые применит Up: все после последней успешной версии
+// (в идемпотентном режиме — все) и упавшие до нее, кроме отмеченных как пропущенные
+func (m *Migrator) pending(ctx context.Context) ([]*storage.Migration, error) {
+	lastMigration, err := m.storage.SelectLastMigrationByStatus(ctx, storage.StatusSuccess)
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
+		return nil, err
+	}
+
+	lastVersion := 0
+	if lastMigration != nil {
+		lastVersion = lastMigration.GetVersion()
+	}
+
+	if lastVersion > len(m.migrations) {
+		return nil, ErrUnexpectedMigrationVersion
+	}
+
+	startIndex := lastVersion
+	if m.idempotent {
+		startIndex = 0
+	}
+
+	skipped, err := m.skippedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	failed, err := m.failedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]*storage.Migration, 0, len(m.migrations))
+	for i := range m.migrations {
+		if i < startIndex {
+			if !failed[m.migrations[i].Version] {
+				continue
+			}
+			m.logger.Info("Migration %s version %d failed behind the db version, retrying", m.migrations[i].Name, m.migrations[i].Version)
+		}
+		if status, ok := skipped[m.migrations[i].Version]; ok {
+			m.logger.Info("Migration %s version %d is marked as %s", m.migrations[i].Name, m.migrations[i].Version, status)
+			continue
+		}
+		pending = append(pending, &m.migrations[i])
+	}
+
+	return pending, nil
+}
+
+// pendingFrom возвращает миграции начиная с версии from без учета версии в базе;
+// пропущенные и примененные вне очереди версии по-прежнему не применяются
+func (m *Migrator) pendingFrom(ctx context.Context, from int) ([]*storage.Migration, error) {
+	skipped, err := m.skippedVersions(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	pending := make([]*storage.Migration, 0, len(m.migrations))
+	for i := range m.migrations {
+		if m.migrations[i].Version < from {
+			continue
+		}
+		if status, ok := skipped[m.migrations[i].Version]; ok {
+			m.logger.Info("Migration %s version %d is marked as %s", m.migrations[i].Name, m.migrations[i].Version, status)
+			continue
+		}
+		pending = append(pending, &m.migrations[i])
+	}
+
+	return pending, nil
+}
+
+// skippedVersions возвращает версии, которые Up не должен применять: пропущенные
+// и уже примененные вне очереди, вместе с их статусом
+func (m *Migrator) skippedVersions(ctx context.Context) (map[int]string, error) {
+	skipped := make(map[int]string)
+
+	migrations, err := m.storage.SelectMigrations(ctx)
+	if errors.Is(err, storage.ErrMigrationNotFound) {
+		return skipped, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range migrations {
+		if status := migration.GetStatus(); status == storage.StatusSkipped || status == storage.StatusOutOfOrder {
+			skipped[migration.GetVersion()] = status
+		}
+	}
+
+	return skipped, nil
+}
+
+// failedVersions возвращает версии в статусе error. С continue-on-error версия в базе уходит дальше упавших,
+// и без этого следующий Up их бы не повторил
+func (m *Migrator) failedVersions(ctx context.Context) (map[int]bool, error) {
+	failed := make(map[int]bool)
+
+	migrations, err := m.storage.SelectMigrations(ctx)
+	if errors.Is(err, storage.ErrMigrationNotFound) {
+		return failed, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, migration := range migrations {
+		if migration.GetStatus() == storage.StatusError {
+			failed[migration.GetVersion()] = true
+		}
+	}
+
+	return failed, nil
+}
+
+// Skip отмечает миграцию как намеренно пропущенную, не выполняя ее
+func (m *Migrator) Skip(ctx context.Context, version int) (result Result, err error) {
+	m.logger.Info("Skipping migration version %d", version)
+	defer m.finishResult(ctx, &result, time.Now())
+
+	migration, err := m.findMigration(version)
+	if err != nil {
+		m.logger.Error("Error in Skip: %v", err)
+		return result, err
+	}
+
+	if err := m.storage.Lock(ctx); err != nil {
+		m.logger.Error("Error in Skip: %v", err)
+		return result, err
+	}
+	defer m.storage.Unlock(ctx)
+
+	if err := m.saveStatus(ctx, migration, storage.StatusSkipped); err != nil {
+		m.logger.Error("Error in Skip: %v", err)
+		return result, err
+	}
+	result.Skipped++
+
+	m.logger.Info("Migration %s version %d marked as skipped", migration.Name, migration.Version)
+	return result, nil
+}
+
+// Apply применяет одну загруженную миграцию с указанной версией независимо от текущей версии базы
+func (m *Migrator) Apply(ctx context.Context, version int) (result Result, err error) {
+	m.logger.Info("Applying migration version %d", version)
+	defer m.finishResult(ctx, &result, time.Now())
+
+	migration, err := m.findMigration(version)
+	if err != nil {
+		m.logger.Error("Error in Apply: %v", err)
+		return result, err
+	}
+
+	if err := m.storage.Lock(ctx); err != nil {
+		m.logger.Error("Error in Apply: %v", err)
+		return result, err
+	}
+	defer m.storage.Unlock(ctx)
+
+	applied, err := m.upMigration(ctx, migration, migration.Up, migration.UpGo)
+	if err != nil {
+		result.Failed = append(result.Failed, version)
+		m.logger.Error("Error in Apply: %v", err)
+		if errors.Is(err, ErrMissingPrerequisite) {
+			return result, err
+		}
+		return result, ErrMigrationUp
+	}
+	result.count(applied)
+
+	return result, nil
+}
+
+// ApplyOutOfOrder применяет одну миграцию в обход очереди, не сдвигая текущую версию базы:
+// если перед ней есть непримененные миграции, она сохраняется со статусом out_of_order,
+// и Up ее больше не выполняет. Требует режима force.
+func (m *Migrator) ApplyOutOfOrder(ctx context.Context, version int) (result Result, err error) {
+	defer m.finishResult(ctx, &result, time.Now())
+
+	if !m.force {
+		m.logger.Error("Error in ApplyOutOfOrder: %v", ErrForceRequired)
+		return result, ErrForceRequired
+	}
+
+	migration, err := m.findMigration(version)
+	if err != nil {
+		m.logger.Error("Error in ApplyOutOfOrder: %v", err)
+		return result, err
+	}
+
+	if err := m.storage.Lock(ctx); err != nil {
+		m.logger.Error("Error in ApplyOutOfOrder: %v", err)
+		return result, err
+	}
+	defer m.storage.Unlock(ctx)
+
+	skipped, err := m.skippedVersions(ctx)
+	if err != nil {
+		m.logger.Error("Error in ApplyOutOfOrder: %v", err)
+		return result, err
+	}
+	applied, err := m.isApplied(ctx, version)
+	if err != nil {
+		m.logger.Error("Error in ApplyOutOfOrder: %v", err)
+		return result, err
+	}
+	if applied || skipped[version] == storage.StatusOutOfOrder {
+		m.logger.Error("Error in ApplyOutOfOrder: %v: %d", ErrAlreadyApplied, version)
+		return result, ErrAlreadyApplied
+	}
+
+	current, err := m.currentVersion(ctx)
+	if err != nil {
+		m.logger.Error("Error in ApplyOutOfOrder: %v", err)
+		return result, err
+	}
+
+	status := storage.StatusSuccess
+	if version > current+1 {
+		status = storage.StatusOutOfOrder
+		m.logger.Warn("!!! Applying migration %s version %d out of order: database is at version %d, versions in between are not applied !!!",
+			migration.Name, version, current)
+	}
+
+	if _, err := m.runUp(ctx, migration, migration.Up, migration.UpGo, status); err != nil {
+		result.Failed = append(result.Failed, version)
+		m.logger.Error("Error in ApplyOutOfOrder: %v", err)
+		if errors.Is(err, ErrMissingPrerequisite) {
+			return result, err
+		}
+		return result, ErrMigrationUp
+	}
+	result.Applied++
+
+	return result, nil
+}
+
+// FindVersion возвращает версию загруженной миграции по ее имени
+func (m *Migrator) FindVersion(name string) (int, error) {
+	for _, migration := range m.migrations {
+		if migration.Name == name {
+			return migration.Version, nil
+		}
+	}
+
+	return 0, ErrUnexpectedMigrationVersion
+}
+
+// Revert откатывает одну загруженную миграцию с указанной версией. Миграция должна быть применена
+// и иметь down-шаг: иначе Revert записал бы cancel, ничего не выполнив, или выполнил бы откат неприменного.
+func (m *Migrator) Revert(ctx context.Context, version int) (result Result, err error) {
+	m.logger.Info("Reverting migration version %d", version)
+	defer m.finishResult(ctx, &result, time.Now())
+
+	migration, err := m.findMigration(version)
+	if err != nil {
+		m.logger.Error("Error in Revert: %v", err)
+		return result, err
+	}
+
+	if err := m.storage.Lock(ctx); err != nil {
+		m.logger.Error("Error in Revert: %v", err)
+		return result, err
+	}
+	defer m.storage.Unlock(ctx)
+
+	if err := m.checkRevertible(ctx, migration); err != nil {
+		m.logger.Error("Error in Revert: %v", err)
+		return result, err
+	}
+
+	if err := m.downMigration(ctx, migration, migration.Down, migration.DownGo); err != nil {
+		result.Failed = append(result.Failed, version)
+		m.logger.Error("Error in Revert: %v", err)
+		if errors.Is(err, ErrIrreversibleMigration) {
+			return result, err
+		}
+		return result, ErrMigrationDown
+	}
+	result.RolledBack++
+
+	return result, nil
+}
+
+// checkRevertible проверяет, что миграция применена и что у нее есть down-шаг
+func (m *Migrator) checkRevertible(ctx context.Context, migration *storage.Migration) error {
+	if migration.Down == "" && migration.DownGo == nil && !m.hasRegisteredDown(migration.Version) {
+		return fmt.Errorf("%w: %s version %d", ErrNoDownStep, migration.Name, migration.Version)
+	}
+
+	row, err := m.storage.SelectMigrationByVersion(ctx, migration.Version)
+	if errors.Is(err, storage.ErrMigrationNotFound) {
+		return fmt.Errorf("%w: %s version %d is pending", ErrNotApplied, migration.Name, migration.Version)
+	}
+	if err != nil {
+		return err
+	}
+
+	if status := row.GetStatus(); status != storage.StatusSuccess && status != storage.StatusOutOfOrder {
+		return fmt.Errorf("%w: %s version %d is %s", ErrNotApplied, migration.Name, migration.Version, status)
+	}
 	return nil
 }
 
-func (m *Migrator) upMigration(ctx context.Context, migration storage.IMigration, sql string, upGo func(ctx context.Context) error) error {
-	migration.SetStatus(storage.StatusProcess)
-	migration.SetStatusChangeTime(time.Now())
+// hasIndex проверяет, что индекс, вычисленный из версии базы, указывает на загруженную миграцию
+func (m *Migrator) hasIndex(index int) bool {
+	return index >= 0 && index < len(m.migrations)
+}
 
-	if err := m.storage.InsertMigration(ctx, migration); err != nil {
+func (m *Migrator) findMigration(version int) (*storage.Migration, error) {
+	for i := range m.migrations {
+		if m.migrations[i].Version == version {
+			return &m.migrations[i], nil
+		}
+	}
+
+	return nil, ErrUnexpectedMigrationVersion
+}
+
+// upMigration применяет миграцию и возвращает false, если она была пропущена как уже примененная
+func (m *Migrator) upMigration(ctx context.Context, migration storage.IMigration, sql string, upGo func(ctx context.Context) error) (bool, error) {
+	return m.runUp(ctx, migration, sql, upGo, storage.StatusSuccess)
+}
+
+// runUp применяет миграцию и сохраняет для нее итоговый статус finalStatus
+func (m *Migrator) runUp(ctx context.Context, migration storage.IMigration, sql string, upGo func(ctx context.Context) error, finalStatus string) (_ bool, err error) {
+	ctx, endTrace := m.traceMigration(ctx, migration, DirectionUp)
+	defer func() { endTrace(err) }()
+
+	if m.idempotent {
+		applied, err := m.isApplied(ctx, migration.GetVersion())
+		if err != nil {
+			m.logger.Error("Error in upMigration: %v", err)
+			return false, err
+		}
+
+		if applied {
+			m.logger.Info("Migration %s version %d already applied, skipping", migration.GetName(), migration.GetVersion())
+			return false, nil
+		}
+	}
+
+	if err := m.checkRequires(ctx, migration); err != nil {
 		m.logger.Error("Error in upMigration: %v", err)
-		return err
+		return false, err
+	}
+
+	if err := m.checkMinServer(ctx, migration); err != nil {
+		m.logger.Error("Error in upMigration: %v", err)
+		return false, err
+	}
+
+	start := time.Now()
+	m.reportProgress(migration, DirectionUp, PhaseStart, start, nil)
+
+	if err := m.saveIntermediateStatus(ctx, migration, storage.StatusProcess); err != nil {
+		m.reportProgress(migration, DirectionUp, PhaseError, start, err)
+		m.logger.Error("Error in upMigration: %v", err)
+		return false, err
 	}
 
 	if upGo != nil {
-		if err := upGo(ctx); err != nil {
-			migration.SetStatus(storage.StatusError)
-			migration.SetStatusChangeTime(time.Now())
-			m.storage.InsertMigration(ctx, migration)
+		if err := m.runGo(ctx, migration, upGo, finalStatus); err != nil {
+			m.saveErrorStatus(migration)
+			m.reportProgress(migration, DirectionUp, PhaseError, start, err)
 
 			m.logger.Error("Error in upMigration: %v", err)
-			return err
+			return false, err
 		}
-	} else if sql != "" {
-		if err := m.storage.Migrate(ctx, sql); err != nil {
-			migration.SetStatus(storage.StatusError)
-			migration.SetStatusChangeTime(time.Now())
-			m.storage.InsertMigration(ctx, migration)
+	} else {
+		if sql, err = m.buildSQL(ctx, migration, DirectionUp, sql); err != nil {
+			m.saveErrorStatus(migration)
+			m.reportProgress(migration, DirectionUp, PhaseError, start, err)
 
 			m.logger.Error("Error in upMigration: %v", err)
+			return false, err
+		}
+		if sql != "" {
+			if err := m.storage.Migrate(ctx, sql); err != nil {
+				m.saveErrorStatus(migration)
+				m.reportProgress(migration, DirectionUp, PhaseError, start, err)
+
+				m.logger.Error("Error in upMigration: %v", err)
+				return false, err
+			}
+		}
+
+		applied := sql
+		if seed := m.seed(migration); seed != "" {
+			m.logger.Info("Applying seed data of migration %s version %d", migration.GetName(), migration.GetVersion())
+			if err := m.storage.Migrate(ctx, seed); err != nil {
+				m.saveErrorStatus(migration)
+				m.reportProgress(migration, DirectionUp, PhaseError, start, err)
+
+				m.logger.Error("Error in upMigration: %v", err)
+				return false, err
+			}
+			applied = strings.TrimSpace(applied + "\n" + seed)
+		}
+		m.recordAppliedSQL(migration, applied)
+
+		if err := m.saveStatus(ctx, migration, finalStatus); err != nil {
+			m.reportProgress(migration, DirectionUp, PhaseError, start, err)
+			m.logger.Error("Error in upMigration: %v", err)
+			return false, err
+		}
+	}
+	m.reportProgress(migration, DirectionUp, PhaseSuccess, start, nil)
+
+	m.logger.Info("Migration %s to version %d applied successfully", migration.GetName(), migration.GetVersion())
+	return true, nil
+}
+
+// seed возвращает seed-данные миграции, если они разрешены SetSeeds
+func (m *Migrator) seed(migration storage.IMigration) string {
+	loaded, ok := migration.(*storage.Migration)
+	if !m.seeds || !ok {
+		return ""
+	}
+	return loaded.Seed
+}
+
+// checkRequires проверяет, что применены все миграции, перечисленные в requires заголовка
+func (m *Migrator) checkRequires(ctx context.Context, migration storage.IMigration) error {
+	loaded, ok := migration.(*storage.Migration)
+	if !ok || len(loaded.Requires) == 0 {
+		return nil
+	}
+
+	migrations, err := m.storage.SelectMigrations(ctx)
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
+		return err
+	}
+
+	statuses := make(map[int]string, len(migrations))
+	for _, applied := range migrations {
+		statuses[applied.GetVersion()] = applied.GetStatus()
+	}
+
+	for _, version := range loaded.Requires {
+		if status := statuses[version]; status != storage.StatusSuccess && status != storage.StatusOutOfOrder {
+			return fmt.Errorf("%w: %s version %d requires version %d", ErrMissingPrerequisite, loaded.Name, loaded.Version, version)
+		}
+	}
+
+	return nil
+}
+
+// checkMinServer проверяет, что версия сервера не ниже min-server из заголовка миграции
+func (m *Migrator) checkMinServer(ctx context.Context, migration storage.IMigration) error {
+	loaded, ok := migration.(*storage.Migration)
+	if !ok || loaded.MinServer == 0 {
+		return nil
+	}
+
+	version, err := m.storage.ServerVersion(ctx)
+	if err != nil {
+		return err
+	}
+
+	// server_version_num с Postgres 10 записывается как MMmmpp: 140005 — 14.5
+	if version < loaded.MinServer*10000 {
+		return fmt.Errorf("%w: %s version %d requires Postgres %d or newer, server_version_num is %d",
+			ErrServerTooOld, loaded.Name, loaded.Version, loaded.MinServer, version)
+	}
+
+	return nil
+}
+
+// displayTimeLayout — формат времени в status и history; смещение показывается явно, для UTC — Z
+const displayTimeLayout = "2006-01-02 15:04:05Z07:00"
+
+func (m *Migrator) inLocation(t time.Time) time.Time {
+	if m.location == nil {
+		return t.UTC()
+	}
+	return t.In(m.location)
+}
+
+func (m *Migrator) formatTime(t time.Time) string {
+	return m.inLocation(t).Format(displayTimeLayout)
+}
+
+// saveStatus обновляет статус миграции и дописывает переход в журнал событий
+func (m *Migrator) saveStatus(ctx context.Context, migration storage.IMigration, status string) error {
+	previous := migration.GetStatus()
+	migration.SetStatus(status)
+	migration.SetStatusChangeTime(m.now())
+
+	if m.batch {
+		if err := m.storage.InsertMigrations(ctx, []storage.IMigration{migration}); err != nil {
 			return err
 		}
+		m.observeTransition(migration, previous, status)
+		return nil
 	}
 
-	migration.SetStatus(storage.StatusSuccess)
-	migration.SetStatusChangeTime(time.Now())
 	if err := m.storage.InsertMigration(ctx, migration); err != nil {
-		m.logger.Error("Error in upMigration: %v", err)
 		return err
 	}
 
-	m.logger.Info("Migration %s to version %d applied successfully", migration.GetName(), migration.GetVersion())
+	if err := m.storage.InsertMigrationEvent(ctx, migration); err != nil {
+		return err
+	}
+	m.observeTransition(migration, previous, status)
 	return nil
 }
 
-func (m *Migrator) downMigration(ctx context.Context, migration storage.IMigration, sql string, downGo func(ctx context.Context) error) error {
-	migration.SetStatus(storage.StatusCancellation)
-	migration.SetStatusChangeTime(time.Now())
+// recordAppliedSQL запоминает в миграции выполненный SQL, чтобы saveStatus записал его, если это включено SetRecordSQL
+func (m *Migrator) recordAppliedSQL(migration storage.IMigration, sql string) {
+	if m.recordSQL && sql != "" {
+		migration.SetAppliedSQL(sql)
+	}
+}
 
-	if err := m.storage.InsertMigration(ctx, migration); err != nil {
+// saveErrorStatus сохраняет статус ошибки в новом контексте: контекст запуска к этому моменту может быть отменен
+func (m *Migrator) saveErrorStatus(migration storage.IMigration) {
+	ctx, cancel := context.WithTimeout(context.Background(), StatusTimeout)
+	defer cancel()
+
+	if err := m.saveStatus(ctx, migration, storage.StatusError); err != nil {
+		m.logger.Error("Error in saveErrorStatus: %v", err)
+	}
+}
+
+// saveIntermediateStatus сохраняет статус, который сразу сменится итоговым; в пакетном режиме он не пишется,
+// а только запоминается в миграции, чтобы журнал действий знал направление упавшей миграции
+func (m *Migrator) saveIntermediateStatus(ctx context.Context, migration storage.IMigration, status string) error {
+	if m.batch {
+		migration.SetStatus(status)
+		return nil
+	}
+
+	return m.saveStatus(ctx, migration, status)
+}
+
+func (m *Migrator) isApplied(ctx context.Context, version int) (bool, error) {
+	migrations, err := m.storage.SelectMigrations(ctx)
+	if errors.Is(err, storage.ErrMigrationNotFound) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	for _, migration := range migrations {
+		if migration.GetVersion() == version && migration.GetStatus() == storage.StatusSuccess {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (m *Migrator) downMigration(ctx context.Context, migration storage.IMigration, sql string, downGo func(ctx context.Context) error) (err error) {
+	if downGo == nil && isIrreversible(sql) {
+		return fmt.Errorf("%w: %s version %d", ErrIrreversibleMigration, migration.GetName(), migration.GetVersion())
+	}
+
+	ctx, endTrace := m.traceMigration(ctx, migration, DirectionDown)
+	defer func() { endTrace(err) }()
+
+	start := time.Now()
+	m.reportProgress(migration, DirectionDown, PhaseStart, start, nil)
+
+	if err := m.saveIntermediateStatus(ctx, migration, storage.StatusCancellation); err != nil {
+		m.reportProgress(migration, DirectionDown, PhaseError, start, err)
 		m.logger.Error("Error in downMigration: %v", err)
 		return err
 	}
 
 	if downGo != nil {
-		if err := downGo(ctx); err != nil {
-			migration.SetStatus(storage.StatusError)
-			migration.SetStatusChangeTime(time.Now())
-			m.storage.InsertMigration(ctx, migration)
+		if err := m.runGo(ctx, migration, downGo, storage.StatusCancel); err != nil {
+			m.saveErrorStatus(migration)
+			m.reportProgress(migration, DirectionDown, PhaseError, start, err)
 
 			m.logger.Error("Error in downMigration: %v", err)
 			return err
 		}
-	} else if sql != "" {
-		if err := m.storage.Migrate(ctx, sql); err != nil {
-			migration.SetStatus(storage.StatusError)
-			migration.SetStatusChangeTime(time.Now())
-			m.storage.InsertMigration(ctx, migration)
+	} else {
+		if sql, err = m.buildSQL(ctx, migration, DirectionDown, sql); err != nil {
+			m.saveErrorStatus(migration)
+			m.reportProgress(migration, DirectionDown, PhaseError, start, err)
 
 			m.logger.Error("Error in downMigration: %v", err)
 			return err
 		}
-	}
+		if sql != "" {
+			if err := m.storage.Migrate(ctx, sql); err != nil {
+				m.saveErrorStatus(migration)
+				m.reportProgress(migration, DirectionDown, PhaseError, start, err)
 
-	migration.SetStatus(storage.StatusCancel)
-	migration.SetStatusChangeTime(time.Now())
-	if err := m.storage.InsertMigration(ctx, migration); err != nil {
-		m.logger.Error("Error in downMigration: %v", err)
-		return err
+				m.logger.Error("Error in downMigration: %v", err)
+				return err
+			}
+		}
+		m.recordAppliedSQL(migration, sql)
+
+		if err := m.saveStatus(ctx, migration, storage.StatusCancel); err != nil {
+			m.reportProgress(migration, DirectionDown, PhaseError, start, err)
+			m.logger.Error("Error in downMigration: %v", err)
+			return err
+		}
 	}
+	m.reportProgress(migration, DirectionDown, PhaseSuccess, start, nil)
 
 	m.logger.Info("Rollback of migration %s to version %d applied successfully", migration.GetName(), migration.GetVersion())
 	return nil
 }
 
-func (m *Migrator) Redo(ctx context.Context) error {
+// runGo выполняет Go-шаг миграции и сохраняет статус status. В транзакционном режиме шаг и статус пишутся
+// в одной транзакции, поэтому хранилище из ctx видит ее, а при ошибке запросы шага откатываются вместе с учетом.
+// Внутри уже открытой общей транзакции запуска (tx_mode all, lock_mode transaction) отдельная не открывается.
+func (m *Migrator) runGo(ctx context.Context, migration storage.IMigration, step func(ctx context.Context) error, status string) error {
+	if !m.transaction || m.storage.InTransaction() {
+		if err := step(storage.NewContext(ctx, m.storage)); err != nil {
+			return err
+		}
+		return m.saveStatus(ctx, migration, status)
+	}
+
+	if err := m.storage.Begin(ctx); err != nil {
+		return err
+	}
+	m.deferObservations()
+
+	err := step(storage.NewContext(ctx, m.storage))
+	if err == nil {
+		err = m.saveStatus(ctx, migration, status)
+	}
+	if err != nil {
+		m.finishObservations(false)
+		if rollbackErr := m.storage.Rollback(ctx); rollbackErr != nil {
+			m.logger.Warn("Error in runGo: %v", rollbackErr)
+		}
+		return err
+	}
+
+	err = m.storage.Commit(ctx)
+	m.finishObservations(err == nil)
+	return err
+}
+
+// isIrreversible проверяет, отмечена ли down-миграция строкой IrreversibleMarker
+func isIrreversible(sql string) bool {
+	for _, line := range strings.Split(sql, "\n") {
+		if strings.TrimSpace(line) == IrreversibleMarker {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (m *Migrator) Redo(ctx context.Context) (result Result, err error) {
 	m.logger.Info("Starting redo process")
+	defer m.finishResult(ctx, &result, time.Now())
 
-	err := m.Down(ctx)
+	result, err = m.Down(ctx)
 	if err != nil {
 		m.logger.Error("Error in Redo: %v", err)
-		return err
+		return result, err
 	}
 
 	lastVersion := 0
@@ -244,52 +1200,212 @@ func (m *Migrator) Redo(ctx context.Context) error {
 		lastVersion = lastMigration.GetVersion()
 	} else if !errors.Is(err, storage.ErrMigrationNotFound) {
 		m.logger.Error("Error in Redo: %v", err)
-		return err
+		return result, err
 	}
 
-	if lastMigration != nil && lastMigration.GetVersion()-1 > len(m.migrations) {
-		m.logger.Error("Error in Redo: %v", ErrUnexpectedMigrationVersion)
-		return ErrUnexpectedMigrationVersion
+	if !m.hasIndex(lastVersion) {
+		m.logger.Error("Error in Redo: %v: db version %d, %d migrations loaded", ErrUnexpectedMigrationVersion, lastVersion, len(m.migrations))
+		return result, ErrUnexpectedMigrationVersion
 	}
 
-	err = m.upMigration(ctx, &m.migrations[lastVersion], m.migrations[lastVersion].Up, m.migrations[lastVersion].UpGo)
+	applied, err := m.upMigration(ctx, &m.migrations[lastVersion], m.migrations[lastVersion].Up, m.migrations[lastVersion].UpGo)
 	if err != nil {
+		result.Failed = append(result.Failed, m.migrations[lastVersion].Version)
 		m.logger.Error("Error in Redo: %v", err)
-		return ErrMigrationRedo
+		return result, ErrMigrationRedo
 	}
+	result.count(applied)
 
 	m.logger.Info("Redo process completed")
-	return nil
+	return result, nil
 }
 
-func (m *Migrator) Status(ctx context.Context) error {
-	migrations, err := m.storage.SelectMigrations(ctx)
-	if err != nil {
+// StatusOptions — параметры вывода команды status
+type StatusOptions struct {
+	// Filter оставляет в выводе только миграции с указанным статусом
+	Filter string
+	// JSON выводит статусы массивом json вместо таблицы
+	JSON bool
+	// Since оставляет только миграции, статус которых менялся за этот период; 0 — все
+	Since time.Duration
+	// Output — куда писать вывод; по умолчанию строки уходят в логгер
+	Output io.Writer
+	// Verbose дополняет каждую строку наличием up- и down-файла и совпадением файла с записанным SQL
+	Verbose bool
+	// FailOnPending возвращает ErrPendingMigrations после вывода, если в базе применены не все миграции
+	FailOnPending bool
+}
+
+// statusEntry — строка статуса в формате json
+type statusEntry struct {
+	Version     int       `json:"version"`
+	Name        string    `json:"name"`
+	Status      string    `json:"status"`
+	Time        time.Time `json:"time"`
+	Description string    `json:"description,omitempty"`
+	UpFile      string    `json:"up_file,omitempty"`
+	DownFile    string    `json:"down_file,omitempty"`
+	Checksum    string    `json:"checksum,omitempty"`
+}
+
+func (m *Migrator) Status(ctx context.Context, opts StatusOptions) error {
+	if opts.Filter != "" && !storage.IsKnownStatus(opts.Filter) {
+		m.logger.Error("Error in Status: %v: %s", storage.ErrUnexpectedStatus, opts.Filter)
+		return storage.ErrUnexpectedStatus
+	}
+
+	var (
+		migrations []storage.IMigration
+		err        error
+	)
+	if opts.Since > 0 {
+		migrations, err = m.storage.SelectMigrationsSince(ctx, m.now().Add(-opts.Since))
+	} else {
+		migrations, err = m.storage.SelectMigrations(ctx)
+	}
+	if err != nil && !errors.Is(err, storage.ErrMigrationNotFound) {
 		m.logger.Error("Error in Status: %v", err)
 		return ErrGetStatus
 	}
 
-	m.logger.Info("._____________________._____________________._____________________.")
-	m.logger.Info("| %-19s | %-19s | %-19s |", "Название", "Статус", "Время")
+	migrations = filterByStatus(migrations, opts.Filter)
+
+	var lines []string
+	if opts.JSON {
+		output, err := json.Marshal(m.statusEntries(migrations, opts.Verbose))
+		if err != nil {
+			m.logger.Error("Error in Status: %v", err)
+			return ErrGetStatus
+		}
+		lines = []string{string(output)}
+	} else {
+		lines = m.statusTable(migrations, opts.Verbose)
+	}
+
+	if opts.Output == nil {
+		for _, line := range lines {
+			m.logger.Info(line)
+		}
+	} else {
+		for _, line := range lines {
+			if _, err := fmt.Fprintln(opts.Output, line); err != nil {
+				m.logger.Error("Error in Status: %v", err)
+				return ErrWriteStatus
+			}
+		}
+	}
+
+	if opts.FailOnPending {
+		pending, err := m.pendingVersions(ctx)
+		if err != nil {
+			m.logger.Error("Error in Status: %v", err)
+			return ErrGetStatus
+		}
+		if len(pending) > 0 {
+			m.logger.Warn("Database is behind by %d migrations: pending versions %v", len(pending), pending)
+			return fmt.Errorf("%w: versions %v", ErrPendingMigrations, pending)
+		}
+	}
+	return nil
+}
+
+func (m *Migrator) statusEntries(migrations []storage.IMigration, verbose bool) []statusEntry {
+	descriptions := make(map[int]string, len(m.migrations))
+	for _, migration := range m.migrations {
+		descriptions[migration.Version] = migration.Description
+	}
+
+	entries := make([]statusEntry, 0, len(migrations))
+	for _, migr := range migrations {
+		entry := statusEntry{
+			Version:     migr.GetVersion(),
+			Name:        migr.GetName(),
+			Status:      migr.GetStatus(),
+			Time:        m.inLocation(migr.GetStatusChangeTime()),
+			Description: descriptions[migr.GetVersion()],
+		}
+		if verbose {
+			files := m.fileState(migr)
+			entry.UpFile, entry.DownFile, entry.Checksum = files.Up, files.Down, files.Checksum
+		}
+		entries = append(entries, entry)
+	}
+	return entries
+}
+
+// statusTable строит таблицу статусов; колонка описания добавляется, только если оно есть у загруженных миграций,
+// а с verbose — колонки с состоянием файлов миграции
+func (m *Migrator) statusTable(migrations []storage.IMigration, verbose bool) []string {
+	descriptions := make(map[int]string, len(m.migrations))
+	for _, migration := range m.migrations {
+		if migration.Description != "" {
+			descriptions[migration.Version] = migration.Description
+		}
+	}
+
+	headers := []string{m.header("name"), m.header("status"), m.header("time")}
+	if len(descriptions) > 0 {
+		headers = append(headers, m.header("description"))
+	}
+	if verbose {
+		headers = append(headers, m.header("up_file"), m.header("down_file"), m.header("checksum"))
+	}
 
+	rows := make([][]string, 0, len(migrations))
 	for _, migr := range migrations {
-		formatMigration := fmt.Sprintf("| %-19s | %-19s | %s |",
-			migr.GetName(), migr.GetStatus(), migr.GetStatusChangeTime().Format("2006-01-02 15:04:05"))
+		row := []string{migr.GetName(), migr.GetStatus(), m.formatTime(migr.GetStatusChangeTime())}
+		if len(descriptions) > 0 {
+			row = append(row, descriptions[migr.GetVersion()])
+		}
+		if verbose {
+			files := m.fileState(migr)
+			row = append(row, files.Up, files.Down, files.Checksum)
+		}
+		rows = append(rows, row)
+	}
+
+	return renderTable(headers, rows)
+}
+
+func filterByStatus(migrations []storage.IMigration, status string) []storage.IMigration {
+	if status == "" {
+		return migrations
+	}
+
+	filtered := make([]storage.IMigration, 0, len(migrations))
+	for _, migration := range migrations {
+		if migration.GetStatus() == status {
+			filtered = append(filtered, migration)
+		}
+	}
+
+	return filtered
+}
 
-		m.logger.Info(formatMigration)
+// History выводит хронологический журнал смены статусов миграций
+func (m *Migrator) History(ctx context.Context) error {
+	events, err := m.storage.SelectMigrationEvents(ctx)
+	if err != nil {
+		m.logger.Error("Error in History: %v", err)
+		return ErrGetHistory
+	}
+
+	rows := make([][]string, 0, len(events))
+	for _, event := range events {
+		rows = append(rows, []string{m.formatTime(event.GetStatusChangeTime()),
+			strconv.Itoa(event.GetVersion()), event.GetName(), event.GetStatus()})
 	}
 
-	m.logger.Info("|_____________________|_____________________|_____________________|")
+	headers := []string{m.header("time"), m.header("version"), m.header("name"), m.header("status")}
+	for _, line := range renderTable(headers, rows) {
+		m.logger.Info(line)
+	}
 	return nil
 }
 
 func (m *Migrator) DbVersion(ctx context.Context) error {
-	lastVersion := 0
-
-	lastMigration, err := m.storage.SelectLastMigrationByStatus(ctx, storage.StatusSuccess)
-	if err == nil {
-		lastVersion = lastMigration.GetVersion()
-	} else if !errors.Is(err, storage.ErrMigrationNotFound) {
+	lastVersion, err := m.currentVersion(ctx)
+	if err != nil {
 		m.logger.Error("Error in DbVersion: %v", err)
 		return ErrGetVersion
 	}
@@ -297,3 +1413,47 @@ func (m *Migrator) DbVersion(ctx context.Context) error {
 	m.logger.Info("Version: %d", lastVersion)
 	return nil
 }
+
+// currentVersion возвращает версию последней успешно примененной миграции или 0
+func (m *Migrator) currentVersion(ctx context.Context) (int, error) {
+	lastMigration, err := m.storage.SelectLastMigrationByStatus(ctx, storage.StatusSuccess)
+	if errors.Is(err, storage.ErrMigrationNotFound) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return lastMigration.GetVersion(), nil
+}
+
+// notifyUp отправляет уведомление о новой версии базы; вызывается после Unlock,
+// чтобы в режиме lock_mode=transaction уведомление не зависело от еще не зафиксированной транзакции.
+// Ошибка отправки не делает Up неуспешным: миграции уже применены.
+func (m *Migrator) notifyUp(ctx context.Context, result *Result, err *error) {
+	if m.notifyChannel == "" || *err != nil || result.Applied == 0 {
+		return
+	}
+
+	version, versionErr := m.currentVersion(ctx)
+	if versionErr != nil {
+		m.logger.Warn("Failed to read db version for notification: %v", versionErr)
+		return
+	}
+
+	if notifyErr := m.storage.Notify(ctx, m.notifyChannel, strconv.Itoa(version)); notifyErr != nil {
+		m.logger.Warn("Failed to notify channel %s: %v", m.notifyChannel, notifyErr)
+	}
+}
+
+func (m *Migrator) finishResult(ctx context.Context, result *Result, start time.Time) {
+	result.Elapsed = time.Since(start)
+
+	version, err := m.currentVersion(ctx)
+	if err != nil {
+		m.logger.Warn("Failed to read resulting db version: %v", err)
+		return
+	}
+	result.Version = version
+	m.observeVersion(version)
+}
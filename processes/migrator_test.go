@@ -0,0 +1,831 @@
+package processes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUpIdempotentSkipsAppliedVersions(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	migrator.SetIdempotent(true)
+
+	var applied []string
+	for _, name := range []string{"first", "second", "third"} {
+		name := name
+		migrator.Create(name, "", "", func(ctx context.Context) error {
+			applied = append(applied, name)
+			return nil
+		}, nil)
+	}
+
+	err := mockStorage.InsertMigration(ctx, storage.NewMigration("second", storage.StatusSuccess, 2, time.Now()))
+	assert.NoError(t, err)
+
+	result, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Applied)
+	assert.Equal(t, 1, result.Skipped)
+	assert.Equal(t, []string{"first", "third"}, applied, "Expected already applied migration to be skipped")
+
+	migrations, _ := mockStorage.SelectMigrations(ctx)
+	assert.Equal(t, 3, len(migrations), "Expected three migrations")
+	for _, migration := range migrations {
+		assert.Equal(t, storage.StatusSuccess, migration.GetStatus())
+	}
+}
+
+func TestGoMigrationGetsStorageFromContext(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	migrator.Create("create_users", "", "", func(ctx context.Context) error {
+		db, err := storage.FromContext(ctx)
+		if err != nil {
+			return err
+		}
+		return db.Migrate(ctx, "CREATE TABLE users (id SERIAL PRIMARY KEY);")
+	}, nil)
+
+	_, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CREATE TABLE users (id SERIAL PRIMARY KEY);"}, mockStorage.Executed())
+}
+
+func TestGoMigrationRollsBackWithBookkeeping(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	migrator.SetTransaction(true)
+
+	var inTransaction bool
+	migrator.Create("create_users", "", "", func(ctx context.Context) error {
+		db, err := storage.FromContext(ctx)
+		if err != nil {
+			return err
+		}
+		inTransaction = db.InTransaction()
+		return db.Migrate(ctx, "CREATE TABLE users (id SERIAL PRIMARY KEY);")
+	}, nil)
+	migrator.Create("fill_users", "", "", func(ctx context.Context) error {
+		db, err := storage.FromContext(ctx)
+		if err != nil {
+			return err
+		}
+		if err := db.Migrate(ctx, "INSERT INTO users DEFAULT VALUES;"); err != nil {
+			return err
+		}
+		return errors.New("boom")
+	}, nil)
+
+	_, err := migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrMigrationUp)
+	assert.True(t, inTransaction, "Expected the Go migration to run inside a transaction")
+	assert.Equal(t, []string{"CREATE TABLE users (id SERIAL PRIMARY KEY);"}, mockStorage.Executed(), "Expected the failed migration's queries to be rolled back")
+
+	migrations, err := mockStorage.SelectMigrations(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(migrations))
+	assert.Equal(t, storage.StatusError, migrations[0].GetStatus(), "Expected the failure to be recorded after the rollback")
+	assert.Equal(t, storage.StatusSuccess, migrations[1].GetStatus(), "Expected the committed migration to keep its status")
+}
+
+func TestUpStopsWhenContextIsCancelled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	migrator.Create("create_users", "", "", func(ctx context.Context) error {
+		cancel()
+		return nil
+	}, nil)
+	migrator.Create("create_orders", "CREATE TABLE orders (id SERIAL PRIMARY KEY);", "", nil, nil)
+
+	result, err := migrator.Up(ctx)
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Equal(t, 1, result.Applied, "Expected the run to stop before the next migration")
+	assert.Empty(t, mockStorage.Executed())
+}
+
+func TestHistoryRecordsStatusTransitions(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	migrator.Create("create_users", "CREATE TABLE users (id SERIAL PRIMARY KEY);", "", nil, nil)
+	migrator.Create("broken", "", "", func(ctx context.Context) error {
+		return errors.New("boom")
+	}, nil)
+
+	_, err := migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrMigrationUp)
+
+	events, err := mockStorage.SelectMigrationEvents(ctx)
+	assert.NoError(t, err)
+
+	var statuses []string
+	for _, event := range events {
+		statuses = append(statuses, fmt.Sprintf("%d:%s", event.GetVersion(), event.GetStatus()))
+	}
+	assert.Equal(t, []string{
+		"1:" + storage.StatusProcess,
+		"1:" + storage.StatusSuccess,
+		"2:" + storage.StatusProcess,
+		"2:" + storage.StatusError,
+	}, statuses)
+
+	assert.NoError(t, migrator.History(ctx))
+}
+
+func TestUpResultCounts(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	migrator.Create("create_users", "CREATE TABLE users (id SERIAL PRIMARY KEY);", "DROP TABLE users;", nil, nil)
+	migrator.Create("create_posts", "CREATE TABLE posts (id SERIAL PRIMARY KEY);", "DROP TABLE posts;", nil, nil)
+	migrator.Create("broken", "", "", func(ctx context.Context) error {
+		return errors.New("boom")
+	}, nil)
+
+	result, err := migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrMigrationUp)
+	assert.Equal(t, 2, result.Applied)
+	assert.Equal(t, []int{3}, result.Failed)
+	assert.Equal(t, 2, result.Version)
+
+	result, err = migrator.Down(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.RolledBack)
+	assert.Equal(t, 0, result.Applied)
+}
+
+func TestStatusFilter(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	mockStorage.InsertMigration(ctx, storage.NewMigration("first", storage.StatusSuccess, 1, time.Now()))
+	mockStorage.InsertMigration(ctx, storage.NewMigration("second", storage.StatusError, 2, time.Now()))
+	mockStorage.InsertMigration(ctx, storage.NewMigration("third", storage.StatusProcess, 3, time.Now()))
+	mockStorage.InsertMigration(ctx, storage.NewMigration("fourth", storage.StatusError, 4, time.Now()))
+
+	migrations, _ := mockStorage.SelectMigrations(ctx)
+	filtered := filterByStatus(migrations, storage.StatusError)
+
+	var names []string
+	for _, migration := range filtered {
+		names = append(names, migration.GetName())
+	}
+	assert.Equal(t, []string{"fourth", "second"}, names)
+
+	assert.NoError(t, migrator.Status(ctx, StatusOptions{Filter: storage.StatusError}))
+	assert.ErrorIs(t, migrator.Status(ctx, StatusOptions{Filter: "unknown"}), storage.ErrUnexpectedStatus)
+}
+
+func TestStatusSince(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	mockStorage.InsertMigration(ctx, storage.NewMigration("old", storage.StatusSuccess, 1, time.Now().Add(-48*time.Hour)))
+	mockStorage.InsertMigration(ctx, storage.NewMigration("recent", storage.StatusError, 2, time.Now().Add(-time.Hour)))
+	mockStorage.InsertMigration(ctx, storage.NewMigration("fresh", storage.StatusSuccess, 3, time.Now()))
+
+	var output bytes.Buffer
+	assert.NoError(t, migrator.Status(ctx, StatusOptions{JSON: true, Since: 24 * time.Hour, Output: &output}))
+
+	var entries []statusEntry
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &entries))
+	var names []string
+	for _, entry := range entries {
+		names = append(names, entry.Name)
+	}
+	assert.Equal(t, []string{"fresh", "recent"}, names, "Expected rows older than the window to be excluded")
+
+	output.Reset()
+	assert.NoError(t, migrator.Status(ctx, StatusOptions{JSON: true, Since: time.Minute, Filter: storage.StatusError, Output: &output}))
+	assert.Equal(t, "[]\n", output.String())
+}
+
+func TestSkippedMigrationIsNotApplied(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	migrator.Create("first", "SELECT 1;", "", nil, nil)
+	migrator.Create("superseded", "SELECT 2;", "", nil, nil)
+	migrator.Create("third", "SELECT 3;", "", nil, nil)
+
+	_, err := migrator.Skip(ctx, 2)
+	assert.NoError(t, err)
+
+	pending, err := migrator.pending(ctx)
+	assert.NoError(t, err)
+
+	var versions []int
+	for _, migration := range pending {
+		versions = append(versions, migration.Version)
+	}
+	assert.Equal(t, []int{1, 3}, versions, "Expected skipped version not to be pending")
+
+	result, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Applied)
+	assert.Equal(t, []string{"SELECT 1;", "SELECT 3;"}, mockStorage.Executed())
+
+	migrations, _ := mockStorage.SelectMigrations(ctx)
+	statuses := make(map[int]string)
+	for _, migration := range migrations {
+		statuses[migration.GetVersion()] = migration.GetStatus()
+	}
+	assert.Equal(t, storage.StatusSkipped, statuses[2])
+}
+
+func TestProgressCallbackEvents(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	var events []ProgressEvent
+	migrator.SetProgressCallback(func(event ProgressEvent) {
+		events = append(events, event)
+	})
+
+	migrator.Create("create_users", "CREATE TABLE users (id SERIAL PRIMARY KEY);", "", nil, nil)
+	migrator.Create("create_posts", "CREATE TABLE posts (id SERIAL PRIMARY KEY);", "", nil, nil)
+
+	_, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+
+	var steps []string
+	for _, event := range events {
+		steps = append(steps, fmt.Sprintf("%d:%s:%s:%s", event.Version, event.Name, event.Direction, event.Phase))
+		assert.NoError(t, event.Err)
+		assert.GreaterOrEqual(t, int64(event.Elapsed), int64(0))
+	}
+	assert.Equal(t, []string{
+		"1:create_users:up:start",
+		"1:create_users:up:success",
+		"2:create_posts:up:start",
+		"2:create_posts:up:success",
+	}, steps)
+}
+
+func TestDownRefusesIrreversibleMigration(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	migrator.Create("backfill_emails", "UPDATE users SET email = lower(email);", IrreversibleMarker+"\n", nil, nil)
+
+	_, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+
+	result, err := migrator.Down(ctx)
+	assert.ErrorIs(t, err, ErrIrreversibleMigration)
+	assert.Contains(t, err.Error(), "irreversible")
+	assert.Contains(t, err.Error(), "backfill_emails version 1")
+	assert.Equal(t, 0, result.RolledBack)
+	assert.Equal(t, []int{1}, result.Failed)
+
+	_, err = migrator.Redo(ctx)
+	assert.ErrorIs(t, err, ErrIrreversibleMigration)
+
+	_, err = migrator.Revert(ctx, 1)
+	assert.ErrorIs(t, err, ErrIrreversibleMigration)
+
+	migrations, _ := mockStorage.SelectMigrations(ctx)
+	assert.Equal(t, storage.StatusSuccess, migrations[0].GetStatus(), "Expected irreversible migration to stay applied")
+	assert.Equal(t, []string{"UPDATE users SET email = lower(email);"}, mockStorage.Executed())
+}
+
+func TestApplyOutOfOrderRunsOnlyTargetMigration(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	migrator.Create("create_users", "SELECT 1;", "", nil, nil)
+	migrator.Create("create_posts", "SELECT 2;", "", nil, nil)
+	migrator.Create("index_emails", "SELECT 3;", "", nil, nil)
+
+	_, err := migrator.ApplyOutOfOrder(ctx, 3)
+	assert.ErrorIs(t, err, ErrForceRequired)
+	assert.Empty(t, mockStorage.Executed())
+
+	migrator.SetForce(true)
+	version, err := migrator.FindVersion("index_emails")
+	assert.NoError(t, err)
+
+	result, err := migrator.ApplyOutOfOrder(ctx, version)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Applied)
+	assert.Equal(t, 0, result.Version, "Expected current version not to advance past missing neighbors")
+	assert.Equal(t, []string{"SELECT 3;"}, mockStorage.Executed())
+
+	migrations, _ := mockStorage.SelectMigrations(ctx)
+	assert.Equal(t, 1, len(migrations))
+	assert.Equal(t, storage.StatusOutOfOrder, migrations[0].GetStatus())
+
+	_, err = migrator.ApplyOutOfOrder(ctx, 3)
+	assert.ErrorIs(t, err, ErrAlreadyApplied)
+
+	result, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Applied)
+	assert.Equal(t, []string{"SELECT 3;", "SELECT 1;", "SELECT 2;"}, mockStorage.Executed())
+}
+
+func TestApplyOutOfOrderNextVersionIsRecordedAsSuccess(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	migrator.SetForce(true)
+
+	migrator.Create("create_users", "SELECT 1;", "", nil, nil)
+	migrator.Create("create_posts", "SELECT 2;", "", nil, nil)
+
+	result, err := migrator.ApplyOutOfOrder(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Version)
+
+	_, err = migrator.ApplyOutOfOrder(ctx, 1)
+	assert.ErrorIs(t, err, ErrAlreadyApplied)
+}
+
+func TestUpChecksRequiredMigrations(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	migrator.Create("create_users", "SELECT 1;", "", nil, nil)
+	migrator.AddMigration(storage.Migration{Version: 2, Name: "create_posts", Up: "SELECT 2;", Requires: []int{1, 3}})
+	migrator.Create("add_tags", "SELECT 3;", "", nil, nil)
+
+	result, err := migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrMissingPrerequisite)
+	assert.EqualError(t, err, "required migration is not applied: create_posts version 2 requires version 3")
+	assert.Equal(t, 1, result.Applied)
+	assert.Equal(t, []int{2}, result.Failed)
+	assert.Equal(t, []string{"SELECT 1;"}, mockStorage.Executed())
+}
+
+func TestUpToTagAppliesTaggedGroup(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	for i, tag := range []string{"release-2024.1", "release-2024.1", "", "release-2024.2"} {
+		migrator.AddMigration(storage.Migration{Name: fmt.Sprintf("m%d", i+1), Version: i + 1, Up: fmt.Sprintf("SELECT %d;", i+1), Tag: tag})
+	}
+
+	version, err := migrator.TagVersion("release-2024.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, version)
+
+	version, err = migrator.TagVersion("release-2024.2")
+	assert.NoError(t, err)
+	assert.Equal(t, 4, version)
+
+	_, err = migrator.UpToTag(ctx, "release-2023.9")
+	assert.ErrorIs(t, err, ErrUnknownTag)
+	assert.Empty(t, mockStorage.Executed())
+
+	result, err := migrator.UpToTag(ctx, "release-2024.1")
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Applied)
+	assert.Equal(t, 2, result.Version)
+	assert.Equal(t, []string{"SELECT 1;", "SELECT 2;"}, mockStorage.Executed())
+}
+
+func TestUpRejectsVersionBeyondLoadedMigrations(t *testing.T) {
+	ctx := context.Background()
+
+	for _, version := range []int{3, 4} {
+		mockStorage := &storage.MockSqlStorage{}
+		migrator := New(mockStorage, logger.New())
+		migrator.Create("first", "SELECT 1;", "SELECT -1;", nil, nil)
+		migrator.Create("second", "SELECT 2;", "SELECT -2;", nil, nil)
+
+		mockStorage.InsertMigration(ctx, storage.NewMigration("removed", storage.StatusSuccess, version, time.Now()))
+
+		_, err := migrator.Up(ctx)
+		assert.ErrorIs(t, err, ErrUnexpectedMigrationVersion, "version %d", version)
+		assert.Empty(t, mockStorage.Executed())
+	}
+}
+
+func TestDownAndRedoRejectVersionBeyondLoadedMigrations(t *testing.T) {
+	ctx := context.Background()
+
+	for _, version := range []int{3, 4} {
+		mockStorage := &storage.MockSqlStorage{}
+		migrator := New(mockStorage, logger.New())
+		migrator.Create("first", "SELECT 1;", "SELECT -1;", nil, nil)
+		migrator.Create("second", "SELECT 2;", "SELECT -2;", nil, nil)
+
+		mockStorage.InsertMigration(ctx, storage.NewMigration("removed", storage.StatusSuccess, version, time.Now()))
+
+		_, err := migrator.Down(ctx)
+		assert.ErrorIs(t, err, ErrUnexpectedMigrationVersion, "version %d", version)
+
+		_, err = migrator.Redo(ctx)
+		assert.ErrorIs(t, err, ErrUnexpectedMigrationVersion, "version %d", version)
+		assert.Empty(t, mockStorage.Executed())
+	}
+}
+
+func TestUpContinueOnErrorAppliesRemainingMigrations(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	migrator.SetContinueOnError(true)
+
+	migrator.Create("first", "SELECT 1;", "", nil, nil)
+	migrator.Create("broken", "", "", func(ctx context.Context) error {
+		return errors.New("boom")
+	}, nil)
+	migrator.Create("third", "SELECT 3;", "", nil, nil)
+
+	result, err := migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrMigrationUp)
+	assert.Contains(t, err.Error(), "[2]")
+	assert.Equal(t, 2, result.Applied)
+	assert.Equal(t, []int{2}, result.Failed)
+	assert.Equal(t, []string{"SELECT 1;", "SELECT 3;"}, mockStorage.Executed())
+
+	statuses := make(map[int]string)
+	migrations, _ := mockStorage.SelectMigrations(ctx)
+	for _, migration := range migrations {
+		statuses[migration.GetVersion()] = migration.GetStatus()
+	}
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess, 2: storage.StatusError, 3: storage.StatusSuccess}, statuses)
+}
+
+func TestUpRetriesVersionFailedWithContinueOnError(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	migrator.SetContinueOnError(true)
+
+	broken := true
+	migrator.Create("first", "SELECT 1;", "", nil, nil)
+	migrator.Create("flaky", "", "", func(ctx context.Context) error {
+		if broken {
+			return errors.New("boom")
+		}
+		return nil
+	}, nil)
+	migrator.Create("third", "SELECT 3;", "", nil, nil)
+
+	_, err := migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrMigrationUp)
+
+	broken = false
+	result, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Applied, "Expected only the failed version to be retried")
+	assert.Equal(t, []string{"SELECT 1;", "SELECT 3;"}, mockStorage.Executed())
+
+	migration, err := mockStorage.SelectMigrationByVersion(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, storage.StatusSuccess, migration.GetStatus())
+
+	result, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Applied)
+}
+
+func TestUpStopsOnFirstErrorByDefault(t *testing.T) {
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	migrator.Create("first", "SELECT 1;", "", nil, nil)
+	migrator.Create("broken", "", "", func(ctx context.Context) error {
+		return errors.New("boom")
+	}, nil)
+	migrator.Create("third", "SELECT 3;", "", nil, nil)
+
+	result, err := migrator.Up(context.Background())
+	assert.ErrorIs(t, err, ErrMigrationUp)
+	assert.Equal(t, 1, result.Applied)
+	assert.Equal(t, []string{"SELECT 1;"}, mockStorage.Executed())
+}
+
+func TestUpTxModeAllRollsBackWholeRun(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	migrator.SetTxMode(TxModeAll)
+
+	migrator.Create("first", "SELECT 1;", "", nil, nil)
+	migrator.Create("second", "SELECT 2;", "", nil, nil)
+	migrator.Create("broken", "", "", func(ctx context.Context) error {
+		return errors.New("boom")
+	}, nil)
+
+	result, err := migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrMigrationUp)
+	assert.Equal(t, 0, result.Applied)
+	assert.Equal(t, 0, result.Version)
+	assert.Empty(t, mockStorage.Executed(), "Expected earlier migrations to be rolled back")
+
+	migrations, err := mockStorage.SelectMigrations(ctx)
+	assert.NoError(t, err)
+	if assert.Equal(t, 1, len(migrations), "Expected only the failure to be recorded") {
+		assert.Equal(t, 3, migrations[0].GetVersion())
+		assert.Equal(t, storage.StatusError, migrations[0].GetStatus())
+	}
+}
+
+func TestUpTxModeAllRejectsContinueOnError(t *testing.T) {
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	migrator.SetTxMode(TxModeAll)
+	migrator.SetContinueOnError(true)
+
+	migrator.Create("first", "SELECT 1;", "", nil, nil)
+	migrator.Create("second", "SELECT 2;", "", nil, nil)
+
+	result, err := migrator.Up(context.Background())
+	assert.ErrorIs(t, err, ErrContinueInTransaction)
+	assert.Equal(t, 0, result.Applied)
+	assert.Empty(t, mockStorage.Executed(), "Expected nothing to run when the combination is rejected")
+}
+
+func TestUpTxModeAllCommitsOnSuccess(t *testing.T) {
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	migrator.SetTxMode(TxModeAll)
+
+	migrator.Create("first", "SELECT 1;", "", nil, nil)
+	migrator.Create("second", "SELECT 2;", "", nil, nil)
+
+	result, err := migrator.Up(context.Background())
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Applied)
+	assert.Equal(t, 2, result.Version)
+}
+
+func TestUpNotifiesChannel(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	migrator.SetNotifyChannel("migrator_channel")
+	migrator.AddMigration(storage.Migration{Name: "first", Version: 1, Up: "SELECT 1;"})
+	migrator.AddMigration(storage.Migration{Name: "second", Version: 2, Up: "SELECT 2;"})
+
+	_, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.Notification{{Channel: "migrator_channel", Payload: "2"}}, mockStorage.Notified())
+
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, len(mockStorage.Notified()), "Expected no notification when nothing was applied")
+
+	silent := &storage.MockSqlStorage{}
+	migrator = New(silent, logger.New())
+	migrator.AddMigration(storage.Migration{Name: "first", Version: 1, Up: "SELECT 1;"})
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Empty(t, silent.Notified(), "Expected no notification without a channel")
+}
+
+func TestStatusChangeTimeIsUTC(t *testing.T) {
+	defer func(local *time.Location) { time.Local = local }(time.Local)
+	time.Local = time.FixedZone("UTC+5", 5*60*60)
+
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	migrator.AddMigration(storage.Migration{Name: "first", Version: 1, Up: "SELECT 1;"})
+
+	_, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+
+	migration, err := mockStorage.SelectMigrationByVersion(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, time.UTC, migration.GetStatusChangeTime().Location(), "Expected stored time to be UTC regardless of the machine zone")
+
+	changed := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	assert.Equal(t, "2024-01-02 03:04:05Z", migrator.formatTime(changed))
+	migrator.SetTimezone(time.FixedZone("", 3*60*60))
+	assert.Equal(t, "2024-01-02 06:04:05+03:00", migrator.formatTime(changed))
+}
+
+func TestUpNAppliesOnlyNextMigrations(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	for i := 1; i <= 3; i++ {
+		migrator.Create(fmt.Sprintf("m%d", i), fmt.Sprintf("SELECT %d;", i), "", nil, nil)
+	}
+
+	result, err := migrator.UpN(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Applied)
+	assert.Equal(t, 1, result.Version, "Expected the db version to advance by exactly one")
+	assert.Equal(t, []string{"SELECT 1;"}, mockStorage.Executed())
+
+	result, err = migrator.UpN(ctx, 1)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Version)
+
+	result, err = migrator.UpN(ctx, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Applied, "Expected zero steps to apply everything left")
+	assert.Equal(t, 3, result.Version)
+}
+
+func TestUpFromAppliesFromMidRange(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	for i := 1; i <= 5; i++ {
+		migrator.Create(fmt.Sprintf("m%d", i), fmt.Sprintf("SELECT %d;", i), "", nil, nil)
+	}
+
+	_, err := migrator.UpFrom(ctx, 3)
+	assert.ErrorIs(t, err, ErrForceRequired)
+	assert.Empty(t, mockStorage.Executed())
+
+	migrator.SetForce(true)
+	_, err = migrator.UpFrom(ctx, 9)
+	assert.ErrorIs(t, err, ErrUnexpectedMigrationVersion)
+
+	result, err := migrator.UpFrom(ctx, 3)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Applied)
+	assert.Equal(t, 5, result.Version)
+	assert.Equal(t, []string{"SELECT 3;", "SELECT 4;", "SELECT 5;"}, mockStorage.Executed(), "Expected versions below 3 to stay untouched")
+
+	_, err = mockStorage.SelectMigrationByVersion(ctx, 2)
+	assert.ErrorIs(t, err, storage.ErrMigrationNotFound)
+}
+
+// cancellingStorage отменяет контекст запуска во время миграции и, как pgx, отклоняет запросы с отмененным контекстом
+type cancellingStorage struct {
+	storage.MockSqlStorage
+	cancel context.CancelFunc
+}
+
+func (s *cancellingStorage) Migrate(ctx context.Context, sql string) error {
+	s.cancel()
+	return ctx.Err()
+}
+
+func (s *cancellingStorage) InsertMigration(ctx context.Context, migration storage.IMigration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.MockSqlStorage.InsertMigration(ctx, migration)
+}
+
+func (s *cancellingStorage) InsertMigrationEvent(ctx context.Context, migration storage.IMigration) error {
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return s.MockSqlStorage.InsertMigrationEvent(ctx, migration)
+}
+
+func TestUpRecordsErrorStatusAfterCancel(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	mockStorage := &cancellingStorage{cancel: cancel}
+	migrator := New(mockStorage, logger.New())
+	migrator.Create("create_users", "CREATE TABLE users ();", "DROP TABLE users;", nil, nil)
+
+	_, err := migrator.Up(ctx)
+	assert.Error(t, err)
+
+	migration, err := mockStorage.SelectMigrationByVersion(context.Background(), 1)
+	assert.NoError(t, err)
+	assert.Equal(t, storage.StatusError, migration.GetStatus(), "Expected the error status to be recorded after cancellation")
+}
+
+func TestUpOnlyAppliesSelectedKind(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	var backfills []string
+	backfill := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			backfills = append(backfills, name)
+			return nil
+		}
+	}
+	migrator.Create("create_users", "CREATE TABLE users ();", "DROP TABLE users;", nil, nil)
+	migrator.Create("add_email", "ALTER TABLE users ADD email TEXT;", "ALTER TABLE users DROP email;", nil, nil)
+	migrator.Create("backfill_email", "", "", backfill("email"), nil)
+
+	migrator.SetOnly(KindSQL)
+	result, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Applied, "Expected sql migrations before the first go one to be applied")
+	assert.Empty(t, backfills)
+	assert.Equal(t, []string{"CREATE TABLE users ();", "ALTER TABLE users ADD email TEXT;"}, mockStorage.Executed())
+	_, err = mockStorage.SelectMigrationByVersion(ctx, 3)
+	assert.ErrorIs(t, err, storage.ErrMigrationNotFound, "Expected the go migration not to be recorded")
+
+	migrator.SetOnly(KindGo)
+	result, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Applied)
+	assert.Equal(t, []string{"email"}, backfills)
+	assert.Len(t, mockStorage.Executed(), 2, "Expected sql migrations not to run with -only go")
+
+	migrator.SetOnly("")
+	result, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 0, result.Applied)
+
+	last, err := mockStorage.SelectLastMigrationByStatus(ctx, storage.StatusSuccess)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, last.GetVersion())
+}
+
+func TestUpOnlyRejectsInterleavedKinds(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	var backfills []string
+	backfill := func(name string) func(ctx context.Context) error {
+		return func(ctx context.Context) error {
+			backfills = append(backfills, name)
+			return nil
+		}
+	}
+	migrator.Create("create_users", "CREATE TABLE users ();", "DROP TABLE users;", nil, nil)
+	migrator.Create("backfill_users", "", "", backfill("users"), nil)
+	migrator.Create("add_email", "ALTER TABLE users ADD email TEXT;", "ALTER TABLE users DROP email;", nil, nil)
+	migrator.Create("backfill_email", "", "", backfill("email"), nil)
+
+	migrator.SetOnly(KindSQL)
+	_, err := migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrInterleavedKinds, "Expected sql version 3 after go version 2 to be rejected")
+	assert.ErrorContains(t, err, "sql migration version 3 follows go migration version 2")
+	_, err = migrator.Plan(ctx)
+	assert.ErrorIs(t, err, ErrInterleavedKinds, "Expected plan to reject the same run")
+	assert.Empty(t, mockStorage.Executed(), "Expected nothing to be applied")
+	_, err = mockStorage.SelectMigrations(ctx)
+	assert.ErrorIs(t, err, storage.ErrMigrationNotFound, "Expected nothing to be recorded")
+
+	migrator.SetOnly(KindGo)
+	_, err = migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrInterleavedKinds)
+	assert.Empty(t, backfills)
+
+	migrator.SetOnly(KindSQL)
+	result, err := migrator.UpTo(ctx, 1)
+	assert.NoError(t, err, "Expected the target version to bound the check")
+	assert.Equal(t, 1, result.Applied)
+
+	migrator.SetOnly("")
+	result, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 3, result.Applied)
+	assert.Equal(t, []string{"users", "email"}, backfills)
+}
+
+func TestRecordSQL(t *testing.T) {
+	ctx := context.Background()
+
+	for _, recordSQL := range []bool{false, true} {
+		mockStorage := &storage.MockSqlStorage{}
+		migrator := New(mockStorage, logger.New())
+		migrator.Create("create_users", "CREATE TABLE users ();", "DROP TABLE users;", nil, nil)
+		migrator.SetRecordSQL(recordSQL)
+
+		_, err := migrator.Up(ctx)
+		assert.NoError(t, err)
+		row, err := mockStorage.SelectMigrationByVersion(ctx, 1)
+		assert.NoError(t, err)
+		if !recordSQL {
+			assert.Empty(t, row.GetAppliedSQL(), "Expected no SQL to be recorded by default")
+			continue
+		}
+		assert.Equal(t, "CREATE TABLE users ();", row.GetAppliedSQL())
+
+		_, err = migrator.Down(ctx)
+		assert.NoError(t, err)
+		row, err = mockStorage.SelectMigrationByVersion(ctx, 1)
+		assert.NoError(t, err)
+		assert.Equal(t, storage.StatusCancel, row.GetStatus())
+		assert.Equal(t, "DROP TABLE users;", row.GetAppliedSQL())
+	}
+}
@@ -0,0 +1,81 @@
+package processes
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+// PlanStep — шаг плана: миграция, которую выполнит Up, и то, как она выполнится
+type PlanStep struct {
+	Version       int    `json:"version"`
+	Name          string `json:"name"`
+	Direction     string `json:"direction"`
+	Kind          string `json:"kind"`
+	Transactional bool   `json:"transactional"`
+	Seed          bool   `json:"seed,omitempty"`
+}
+
+// Plan — упорядоченный список шагов, которые выполнит Up
+type Plan struct {
+	Steps []PlanStep `json:"steps"`
+}
+
+// Plan возвращает план Up, ничего не выполняя: ожидающие миграции по порядку с их видом и тем,
+// пойдут ли они в транзакции. Транзакционность для SQL определяется по тексту миграции так же, как при выполнении.
+func (m *Migrator) Plan(ctx context.Context) (Plan, error) {
+	pending, err := m.pending(ctx)
+	if err != nil {
+		m.logger.Error("Error in Plan: %v", err)
+		return Plan{}, err
+	}
+	if err := m.checkOnly(pending, 0); err != nil {
+		m.logger.Error("Error in Plan: %v", err)
+		return Plan{}, err
+	}
+
+	plan := Plan{Steps: make([]PlanStep, 0, len(pending))}
+	for _, migration := range pending {
+		if m.stopsAt(migration) {
+			break
+		}
+
+		step := PlanStep{
+			Version:   migration.Version,
+			Name:      migration.Name,
+			Direction: DirectionUp,
+			Kind:      KindSQL,
+			Seed:      m.seed(migration) != "",
+		}
+
+		inTransaction := m.transaction || m.txMode == TxModeAll
+		if upKind(migration) == KindGo {
+			step.Kind = KindGo
+			step.Transactional = inTransaction
+		} else {
+			step.Transactional = inTransaction && storage.IsWrappable(migration.Up)
+		}
+
+		plan.Steps = append(plan.Steps, step)
+	}
+
+	return plan, nil
+}
+
+// WritePlan пишет план Up в w в виде JSON
+func (m *Migrator) WritePlan(ctx context.Context, w io.Writer) error {
+	plan, err := m.Plan(ctx)
+	if err != nil {
+		return err
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(plan); err != nil {
+		m.logger.Error("Error in Plan: %v", err)
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,54 @@
+package processes
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPlanListsPendingMigrations(t *testing.T) {
+	h := newHarness(t, 2)
+	h.migrator.Create("index_t1", "CREATE INDEX CONCURRENTLY t1_idx ON t1 (id);", "DROP INDEX t1_idx;", nil, nil)
+	h.migrator.Create("backfill", "", "", func(ctx context.Context) error { return nil }, nil)
+	h.migrator.SetTransaction(true)
+
+	_, err := h.migrator.UpN(h.ctx, 1)
+	assert.NoError(t, err)
+
+	plan, err := h.migrator.Plan(h.ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []PlanStep{
+		{Version: 2, Name: "create_t2", Direction: DirectionUp, Kind: KindSQL, Transactional: true},
+		{Version: 3, Name: "index_t1", Direction: DirectionUp, Kind: KindSQL, Transactional: false},
+		{Version: 4, Name: "backfill", Direction: DirectionUp, Kind: KindGo, Transactional: true},
+	}, plan.Steps)
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess}, h.statuses(), "Expected plan not to run migrations")
+
+	h.migrator.SetTransaction(false)
+	plan, err = h.migrator.Plan(h.ctx)
+	assert.NoError(t, err)
+	for _, step := range plan.Steps {
+		assert.False(t, step.Transactional, "Expected no transactions with transaction off")
+	}
+}
+
+func TestWritePlanJSON(t *testing.T) {
+	h := newHarness(t, 1)
+
+	var output bytes.Buffer
+	assert.NoError(t, h.migrator.WritePlan(h.ctx, &output))
+
+	var plan Plan
+	assert.NoError(t, json.Unmarshal(output.Bytes(), &plan))
+	assert.Equal(t, []PlanStep{{Version: 1, Name: "create_t1", Direction: DirectionUp, Kind: KindSQL}}, plan.Steps)
+
+	_, err := h.migrator.Up(h.ctx)
+	assert.NoError(t, err)
+	output.Reset()
+	assert.NoError(t, h.migrator.WritePlan(h.ctx, &output))
+	assert.JSONEq(t, `{"steps": []}`, output.String())
+}
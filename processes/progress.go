@@ -0,0 +1,46 @@
+package processes
+
+import (
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+const (
+	DirectionUp   = "up"
+	DirectionDown = "down"
+
+	PhaseStart   = "start"
+	PhaseSuccess = "success"
+	PhaseError   = "error"
+)
+
+// ProgressEvent описывает шаг выполнения одной миграции для внешних наблюдателей
+type ProgressEvent struct {
+	Version   int
+	Name      string
+	Direction string
+	Phase     string
+	Elapsed   time.Duration
+	Err       error
+}
+
+// SetProgressCallback задает функцию, получающую события о ходе применения и отката миграций
+func (m *Migrator) SetProgressCallback(callback func(event ProgressEvent)) {
+	m.progressCallback = callback
+}
+
+func (m *Migrator) reportProgress(migration storage.IMigration, direction, phase string, start time.Time, err error) {
+	if m.progressCallback == nil {
+		return
+	}
+
+	m.progressCallback(ProgressEvent{
+		Version:   migration.GetVersion(),
+		Name:      migration.GetName(),
+		Direction: direction,
+		Phase:     phase,
+		Elapsed:   time.Since(start),
+		Err:       err,
+	})
+}
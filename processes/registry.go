@@ -0,0 +1,55 @@
+package processes
+
+import (
+	"context"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+// SQLMigrationFunc — шаг Go-миграции, который не выполняет запросы сам, а собирает и возвращает SQL.
+// Мигратор выполняет его так же, как SQL из файла: через Migrate, с учетом статуса и записью в -record-sql.
+type SQLMigrationFunc func(ctx context.Context) (string, error)
+
+type sqlMigration struct {
+	up   SQLMigrationFunc
+	down SQLMigrationFunc
+}
+
+// RegisterSQL регистрирует шаги загруженной миграции версии version, которые возвращают SQL вместо выполнения.
+// Шаг заменяет SQL миграции; nil оставляет SQL миграции как есть. Для незагруженной версии — ErrUnexpectedMigrationVersion.
+func (m *Migrator) RegisterSQL(version int, up, down func(ctx context.Context) (string, error)) error {
+	if _, err := m.findMigration(version); err != nil {
+		m.logger.Error("Error in RegisterSQL: %v: %d", err, version)
+		return err
+	}
+
+	if m.sqlMigrations == nil {
+		m.sqlMigrations = map[int]sqlMigration{}
+	}
+	m.sqlMigrations[version] = sqlMigration{up: up, down: down}
+	return nil
+}
+
+// hasRegisteredDown проверяет, что для версии зарегистрирован шаг отката, возвращающий SQL
+func (m *Migrator) hasRegisteredDown(version int) bool {
+	return m.sqlMigrations[version].down != nil
+}
+
+// buildSQL возвращает SQL шага direction: результат зарегистрированной функции, если она есть, иначе sql.
+// Функция получает хранилище через storage.FromContext, чтобы собирать SQL по текущему состоянию базы.
+func (m *Migrator) buildSQL(ctx context.Context, migration storage.IMigration, direction, sql string) (string, error) {
+	registered, ok := m.sqlMigrations[migration.GetVersion()]
+	if !ok {
+		return sql, nil
+	}
+
+	build := registered.up
+	if direction == DirectionDown {
+		build = registered.down
+	}
+	if build == nil {
+		return sql, nil
+	}
+
+	return build(storage.NewContext(ctx, m.storage))
+}
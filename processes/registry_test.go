@@ -0,0 +1,80 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+func TestSQLReturningGoMigration(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+	migrator.SetRecordSQL(true)
+	migrator.Create("create_users", "CREATE TABLE users ();", "DROP TABLE users;", nil, nil)
+	migrator.Create("create_partitions", "", "", nil, nil)
+
+	err := migrator.RegisterSQL(2, func(ctx context.Context) (string, error) {
+		if _, err := storage.FromContext(ctx); err != nil {
+			return "", err
+		}
+		var statements []string
+		for month := 1; month <= 3; month++ {
+			statements = append(statements, fmt.Sprintf("CREATE TABLE events_%02d ();", month))
+		}
+		return strings.Join(statements, "\n"), nil
+	}, func(ctx context.Context) (string, error) {
+		return "DROP TABLE events_01, events_02, events_03;", nil
+	})
+	assert.NoError(t, err)
+
+	result, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Version)
+	assert.Equal(t, []string{"CREATE TABLE users ();", "CREATE TABLE events_01 ();\nCREATE TABLE events_02 ();\nCREATE TABLE events_03 ();"}, mockStorage.Executed())
+
+	row, err := mockStorage.SelectMigrationByVersion(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, storage.StatusSuccess, row.GetStatus())
+	assert.Contains(t, row.GetAppliedSQL(), "events_03", "Expected the built SQL to be recorded")
+
+	_, err = migrator.Down(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, "DROP TABLE events_01, events_02, events_03;", mockStorage.Executed()[2])
+}
+
+func TestSQLReturningGoMigrationError(t *testing.T) {
+	h := newHarness(t, 2)
+	assert.NoError(t, h.migrator.RegisterSQL(2, func(ctx context.Context) (string, error) {
+		return "", errors.New("no partitions configured")
+	}, nil))
+
+	_, err := h.migrator.Up(h.ctx)
+	assert.ErrorIs(t, err, ErrMigrationUp)
+	assert.Equal(t, map[int]string{1: storage.StatusSuccess, 2: storage.StatusError}, h.statuses())
+	assert.Equal(t, []string{"CREATE TABLE t1 ();"}, h.storage.Executed())
+}
+
+func TestRegisterSQLRejectsUnloadedVersion(t *testing.T) {
+	h := newHarness(t, 1)
+	err := h.migrator.RegisterSQL(2, func(ctx context.Context) (string, error) {
+		return "CREATE TABLE t2 ();", nil
+	}, nil)
+	assert.ErrorIs(t, err, ErrUnexpectedMigrationVersion)
+
+	other := newHarness(t, 1)
+	assert.NoError(t, h.migrator.RegisterSQL(1, func(ctx context.Context) (string, error) {
+		return "CREATE TABLE registered ();", nil
+	}, nil))
+
+	_, err = other.migrator.Up(other.ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CREATE TABLE t1 ();"}, other.storage.Executed(), "Expected steps registered on one migrator not to leak into another")
+}
@@ -0,0 +1,21 @@
+package processes
+
+import "time"
+
+// Result — итог выполнения Up/Down/Redo: сколько миграций применено и откачено, какие упали и с какой версией осталась база
+type Result struct {
+	Applied    int
+	RolledBack int
+	Skipped    int
+	Failed     []int
+	Version    int
+	Elapsed    time.Duration
+}
+
+func (r *Result) count(applied bool) {
+	if applied {
+		r.Applied++
+	} else {
+		r.Skipped++
+	}
+}
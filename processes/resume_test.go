@@ -0,0 +1,104 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+var errCrash = errors.New("process killed")
+
+// crashingStorage теряет запись success версии crashAt, как процесс, убитый после фиксации DDL миграции
+type crashingStorage struct {
+	storage.MockSqlStorage
+	crashAt int
+}
+
+func (s *crashingStorage) InsertMigration(ctx context.Context, migration storage.IMigration) error {
+	if migration.GetVersion() == s.crashAt && migration.GetStatus() == storage.StatusSuccess {
+		s.crashAt = 0
+		return errCrash
+	}
+	return s.MockSqlStorage.InsertMigration(ctx, migration)
+}
+
+const probeUsers = "SELECT to_regclass('users') IS NOT NULL"
+
+func newResumeMigrator(mockStorage storage.SqlStorage, probe string) *Migrator {
+	migrator := New(mockStorage, logger.New())
+	migrator.Create("create_posts", "CREATE TABLE posts ();", "DROP TABLE posts;", nil, nil)
+	migrator.AddMigration(storage.Migration{Name: "create_users", Version: 2, Up: "CREATE TABLE users ();", Down: "DROP TABLE users;", Probe: probe})
+	return migrator
+}
+
+func TestUpResumeRecordsAppliedMigration(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &crashingStorage{crashAt: 2}
+	migrator := newResumeMigrator(mockStorage, probeUsers)
+
+	_, err := migrator.Up(ctx)
+	assert.Error(t, err, "Expected the crash to fail the run")
+	row, err := mockStorage.SelectMigrationByVersion(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, storage.StatusProcess, row.GetStatus())
+
+	mockStorage.SetProbe(probeUsers, true)
+	migrator.SetResume(true)
+	result, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 1, result.Applied)
+	assert.Equal(t, []string{"CREATE TABLE posts ();", "CREATE TABLE users ();"}, mockStorage.Executed(), "Expected the applied migration not to run again")
+
+	row, err = mockStorage.SelectMigrationByVersion(ctx, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, storage.StatusSuccess, row.GetStatus())
+}
+
+func TestUpResumeRerunsWhenProbeFails(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &crashingStorage{crashAt: 2}
+	migrator := newResumeMigrator(mockStorage, probeUsers)
+
+	_, err := migrator.Up(ctx)
+	assert.Error(t, err)
+
+	migrator.SetResume(true)
+	_, err = migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"CREATE TABLE posts ();", "CREATE TABLE users ();", "CREATE TABLE users ();"}, mockStorage.Executed())
+}
+
+func TestUpResumeWithoutProbe(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &crashingStorage{crashAt: 2}
+	migrator := newResumeMigrator(mockStorage, "")
+
+	_, err := migrator.Up(ctx)
+	assert.Error(t, err)
+
+	migrator.SetResume(true)
+	_, err = migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrUnverifiable)
+	assert.Len(t, mockStorage.Executed(), 2, "Expected the unverifiable migration not to run again")
+}
+
+func TestUpResumeRejectsBatchMode(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := newResumeMigrator(mockStorage, "")
+	migrator.SetBatch(true)
+	migrator.SetResume(true)
+
+	_, err := migrator.Up(ctx)
+	assert.ErrorIs(t, err, ErrResumeWithBatch)
+	assert.Empty(t, mockStorage.Executed(), "Expected fresh pending migrations not to be treated as interrupted")
+
+	migrator.SetResume(false)
+	result, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, result.Applied)
+}
@@ -0,0 +1,88 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+var (
+	ErrNothingToSquash = errors.New("no applied migrations to squash")
+	ErrSquashNotClean  = errors.New("squashed versions must all be applied or skipped")
+)
+
+// Squash сворачивает учет примененных миграций 1..N, где N — текущая версия базы, в одну базовую миграцию name
+// с версией 1; последующие версии сдвигаются. writeFiles вызывается до перезаписи учета с версией N,
+// чтобы вызывающий код заменил файлы миграций; при его ошибке учет не меняется.
+func (m *Migrator) Squash(ctx context.Context, name string, writeFiles func(version int) error) (int, error) {
+	if err := m.storage.Lock(ctx); err != nil {
+		m.logger.Error("Error in Squash: %v", err)
+		return 0, err
+	}
+	defer m.storage.Unlock(ctx)
+
+	version, err := m.currentVersion(ctx)
+	if err != nil {
+		m.logger.Error("Error in Squash: %v", err)
+		return 0, err
+	}
+	if version == 0 {
+		return 0, ErrNothingToSquash
+	}
+
+	rows, err := m.storage.SelectMigrations(ctx)
+	if err != nil {
+		m.logger.Error("Error in Squash: %v", err)
+		return 0, err
+	}
+
+	collapsed, err := collapseRows(rows, version, name, m.now())
+	if err != nil {
+		m.logger.Error("Error in Squash: %v", err)
+		return 0, err
+	}
+
+	if err := writeFiles(version); err != nil {
+		m.logger.Error("Error in Squash: %v", err)
+		return 0, err
+	}
+
+	if err := m.storage.DeleteMigrations(ctx); err != nil {
+		m.logger.Error("Error in Squash: %v", err)
+		return 0, err
+	}
+	if err := m.storage.InsertMigrations(ctx, collapsed); err != nil {
+		m.logger.Error("Error in Squash: %v", err)
+		return 0, err
+	}
+
+	m.logger.Info("Squashed versions 1..%d into %s", version, name)
+	return version, nil
+}
+
+// collapseRows заменяет строки версий 1..upTo одной успешной строкой версии 1, а версии после upTo
+// сдвигает на upTo-1, чтобы они совпали с номерами миграций после замены файлов базовой миграцией
+func collapseRows(rows []storage.IMigration, upTo int, name string, now time.Time) ([]storage.IMigration, error) {
+	collapsed := []storage.IMigration{storage.NewMigration(name, storage.StatusSuccess, 1, now)}
+
+	for _, row := range rows {
+		if row.GetVersion() > upTo {
+			collapsed = append(collapsed, storage.NewMigration(row.GetName(), row.GetStatus(), row.GetVersion()-upTo+1, row.GetStatusChangeTime()))
+			continue
+		}
+
+		if status := row.GetStatus(); status != storage.StatusSuccess && status != storage.StatusSkipped {
+			return nil, fmt.Errorf("%w: version %d is %s", ErrSquashNotClean, row.GetVersion(), status)
+		}
+	}
+
+	sort.Slice(collapsed, func(i, j int) bool {
+		return collapsed[i].GetVersion() < collapsed[j].GetVersion()
+	})
+
+	return collapsed, nil
+}
@@ -0,0 +1,67 @@
+package processes
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCollapseRows(t *testing.T) {
+	now := time.Now()
+	rows := []storage.IMigration{
+		storage.NewMigration("fourth", storage.StatusError, 4, now),
+		storage.NewMigration("third", storage.StatusSuccess, 3, now),
+		storage.NewMigration("second", storage.StatusSkipped, 2, now),
+		storage.NewMigration("first", storage.StatusSuccess, 1, now),
+	}
+
+	collapsed, err := collapseRows(rows, 3, "baseline", now)
+	assert.NoError(t, err)
+	assert.Equal(t, []storage.IMigration{
+		storage.NewMigration("baseline", storage.StatusSuccess, 1, now),
+		storage.NewMigration("fourth", storage.StatusError, 2, now),
+	}, collapsed)
+
+	rows[2] = storage.NewMigration("second", storage.StatusCancel, 2, now)
+	_, err = collapseRows(rows, 3, "baseline", now)
+	assert.ErrorIs(t, err, ErrSquashNotClean)
+}
+
+func TestSquashRewritesBookkeeping(t *testing.T) {
+	ctx := context.Background()
+	mockStorage := &storage.MockSqlStorage{}
+	migrator := New(mockStorage, logger.New())
+
+	_, err := migrator.Squash(ctx, "baseline", func(int) error { return nil })
+	assert.ErrorIs(t, err, ErrNothingToSquash)
+
+	for version, name := range map[int]string{1: "first", 2: "second", 3: "third"} {
+		mockStorage.InsertMigration(ctx, storage.NewMigration(name, storage.StatusSuccess, version, time.Now()))
+	}
+
+	_, err = migrator.Squash(ctx, "baseline", func(int) error { return errors.New("disk full") })
+	assert.Error(t, err)
+	rows, _ := mockStorage.SelectMigrations(ctx)
+	assert.Equal(t, 3, len(rows), "Expected bookkeeping to stay untouched when files were not written")
+
+	var written int
+	version, err := migrator.Squash(ctx, "baseline", func(version int) error {
+		written = version
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, 3, version)
+	assert.Equal(t, 3, written)
+
+	rows, _ = mockStorage.SelectMigrations(ctx)
+	if assert.Equal(t, 1, len(rows)) {
+		assert.Equal(t, "baseline", rows[0].GetName())
+		assert.Equal(t, 1, rows[0].GetVersion())
+		assert.Equal(t, storage.StatusSuccess, rows[0].GetStatus())
+	}
+}
@@ -0,0 +1,84 @@
+package processes
+
+import (
+	"errors"
+	"strings"
+	"unicode/utf8"
+)
+
+const (
+	LangRu = "ru"
+	LangEn = "en"
+)
+
+var ErrUnknownLang = errors.New("unknown language")
+
+var tableHeaders = map[string]map[string]string{
+	LangRu: {"name": "Название", "status": "Статус", "time": "Время", "version": "Версия", "description": "Описание", "up_file": "Файл up", "down_file": "Файл down", "checksum": "Контрольная сумма"},
+	LangEn: {"name": "Name", "status": "Status", "time": "Time", "version": "Version", "description": "Description", "up_file": "Up file", "down_file": "Down file", "checksum": "Checksum"},
+}
+
+// IsKnownLang проверяет, что для языка есть заголовки таблиц
+func IsKnownLang(lang string) bool {
+	_, ok := tableHeaders[lang]
+	return ok
+}
+
+// header возвращает заголовок колонки на языке мигратора, по умолчанию — на русском
+func (m *Migrator) header(key string) string {
+	headers, ok := tableHeaders[m.lang]
+	if !ok {
+		headers = tableHeaders[LangRu]
+	}
+
+	return headers[key]
+}
+
+// renderTable выводит строки таблицы, подбирая ширину колонок по самому длинному значению
+func renderTable(headers []string, rows [][]string) []string {
+	widths := make([]int, len(headers))
+	for i, header := range headers {
+		widths[i] = utf8.RuneCountInString(header)
+	}
+	for _, row := range rows {
+		for i, cell := range row {
+			if width := utf8.RuneCountInString(cell); width > widths[i] {
+				widths[i] = width
+			}
+		}
+	}
+
+	lines := make([]string, 0, len(rows)+3)
+	lines = append(lines, separatorLine(widths, "."))
+	lines = append(lines, rowLine(widths, headers))
+	for _, row := range rows {
+		lines = append(lines, rowLine(widths, row))
+	}
+	lines = append(lines, separatorLine(widths, "|"))
+
+	return lines
+}
+
+func separatorLine(widths []int, edge string) string {
+	var b strings.Builder
+	b.WriteString(edge)
+	for _, width := range widths {
+		b.WriteString(strings.Repeat("_", width+2))
+		b.WriteString(edge)
+	}
+
+	return b.String()
+}
+
+func rowLine(widths []int, cells []string) string {
+	var b strings.Builder
+	b.WriteString("|")
+	for i, cell := range cells {
+		b.WriteString(" ")
+		b.WriteString(cell)
+		b.WriteString(strings.Repeat(" ", widths[i]-utf8.RuneCountInString(cell)))
+		b.WriteString(" |")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,68 @@
+package processes
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStatusTableFitsLongNames(t *testing.T) {
+	longName := "add_notification_preferences_to_users_and_backfill_defaults"
+	migrations := []storage.IMigration{
+		storage.NewMigration("init", storage.StatusSuccess, 1, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+		storage.NewMigration(longName, storage.StatusError, 2, time.Date(2024, 1, 2, 3, 4, 6, 0, time.UTC)),
+	}
+
+	lines := New(&storage.MockSqlStorage{}, logger.New()).statusTable(migrations, false)
+	assert.Equal(t, 5, len(lines))
+	assert.Equal(t, "| Название | Статус | Время |", strings.Join(strings.Fields(lines[1]), " "))
+	assert.Contains(t, lines[3], "| "+longName+" | error   | 2024-01-02 03:04:06Z |")
+
+	width := utf8.RuneCountInString(lines[0])
+	for _, line := range lines {
+		assert.Equal(t, width, utf8.RuneCountInString(line), "Expected all rows to have the same width: %q", line)
+	}
+	assert.True(t, strings.HasPrefix(lines[0], "."))
+	assert.True(t, strings.HasPrefix(lines[4], "|_"))
+}
+
+func TestStatusTableEnglishHeaders(t *testing.T) {
+	migrator := New(&storage.MockSqlStorage{}, logger.New())
+	migrator.SetLang(LangEn)
+
+	lines := migrator.statusTable(nil, false)
+	assert.Equal(t, []string{
+		".______.________.______.",
+		"| Name | Status | Time |",
+		"|______|________|______|",
+	}, []string{lines[0], lines[1], lines[2]})
+}
+
+func TestStatusTableShowsDescriptions(t *testing.T) {
+	migrator := New(&storage.MockSqlStorage{}, logger.New())
+	migrator.SetLang(LangEn)
+	migrator.AddMigration(storage.Migration{Version: 1, Name: "create_users", Description: "users and their emails"})
+
+	lines := migrator.statusTable([]storage.IMigration{
+		storage.NewMigration("create_users", storage.StatusSuccess, 1, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+	}, false)
+	assert.Equal(t, "| Name | Status | Time | Description |", strings.Join(strings.Fields(lines[1]), " "))
+	assert.Contains(t, lines[2], "| users and their emails |")
+}
+
+func TestStatusTableVerbose(t *testing.T) {
+	migrator := New(&storage.MockSqlStorage{}, logger.New())
+	migrator.SetLang(LangEn)
+	migrator.Create("create_users", "CREATE TABLE users ();", "", nil, nil)
+
+	lines := migrator.statusTable([]storage.IMigration{
+		storage.NewMigration("create_users", storage.StatusSuccess, 1, time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)),
+	}, true)
+	assert.Equal(t, "| Name | Status | Time | Up file | Down file | Checksum |", strings.Join(strings.Fields(lines[1]), " "))
+	assert.Equal(t, "| create_users | success | 2024-01-02 03:04:05Z | present | missing | unknown |", strings.Join(strings.Fields(lines[2]), " "))
+}
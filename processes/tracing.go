@@ -0,0 +1,68 @@
+package processes
+
+import (
+	"context"
+	"time"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+// TracerName — имя инструментирующей библиотеки в спанах мигратора
+const TracerName = "github.com/juliazadorozhnaya/sql-migrator"
+
+// SetTracerProvider включает трассировку: Up и Down получают span на весь запуск и дочерний span
+// на каждую миграцию. Без провайдера спаны не создаются.
+func (m *Migrator) SetTracerProvider(provider trace.TracerProvider) {
+	if provider == nil {
+		m.tracer = nil
+		return
+	}
+	m.tracer = provider.Tracer(TracerName)
+}
+
+// traceBatch открывает span запуска; возвращаемая функция закрывает его с итогом запуска
+func (m *Migrator) traceBatch(ctx context.Context, direction string) (context.Context, func(result *Result, err error)) {
+	if m.tracer == nil {
+		return ctx, func(*Result, error) {}
+	}
+
+	ctx, span := m.tracer.Start(ctx, "migrator."+direction, trace.WithAttributes(attribute.String("migration.direction", direction)))
+	return ctx, func(result *Result, err error) {
+		span.SetAttributes(
+			attribute.Int("migrator.applied", result.Applied),
+			attribute.Int("migrator.rolled_back", result.RolledBack),
+			attribute.Int("migrator.version", result.Version),
+		)
+		endSpan(span, err)
+	}
+}
+
+// traceMigration открывает дочерний span миграции; возвращаемая функция закрывает его и записывает длительность
+func (m *Migrator) traceMigration(ctx context.Context, migration storage.IMigration, direction string) (context.Context, func(err error)) {
+	if m.tracer == nil {
+		return ctx, func(error) {}
+	}
+
+	start := time.Now()
+	ctx, span := m.tracer.Start(ctx, "migrator.migration", trace.WithAttributes(
+		attribute.Int("migration.version", migration.GetVersion()),
+		attribute.String("migration.name", migration.GetName()),
+		attribute.String("migration.direction", direction),
+	))
+	return ctx, func(err error) {
+		span.SetAttributes(attribute.Int64("migration.duration_ms", time.Since(start).Milliseconds()))
+		endSpan(span, err)
+	}
+}
+
+func endSpan(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
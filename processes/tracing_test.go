@@ -0,0 +1,72 @@
+package processes
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel/attribute"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/storage"
+)
+
+func spanAttributes(span sdktrace.ReadOnlySpan) map[attribute.Key]attribute.Value {
+	attributes := make(map[attribute.Key]attribute.Value)
+	for _, kv := range span.Attributes() {
+		attributes[kv.Key] = kv.Value
+	}
+	return attributes
+}
+
+func TestTracingSpansForUpAndDown(t *testing.T) {
+	ctx := context.Background()
+	recorder := tracetest.NewSpanRecorder()
+	provider := sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(recorder))
+
+	migrator := New(&storage.MockSqlStorage{}, logger.New())
+	migrator.SetTracerProvider(provider)
+	migrator.Create("create_users", "CREATE TABLE users ();", "DROP TABLE users;", nil, nil)
+	migrator.Create("create_posts", "CREATE TABLE posts ();", "DROP TABLE posts;", nil, nil)
+
+	_, err := migrator.Up(ctx)
+	assert.NoError(t, err)
+
+	spans := recorder.Ended()
+	assert.Len(t, spans, 3)
+	batch := spans[2]
+	assert.Equal(t, "migrator.up", batch.Name())
+	assert.Equal(t, int64(2), spanAttributes(batch)["migrator.applied"].AsInt64())
+	assert.Equal(t, int64(2), spanAttributes(batch)["migrator.version"].AsInt64())
+
+	for i, name := range []string{"create_users", "create_posts"} {
+		span := spans[i]
+		attributes := spanAttributes(span)
+		assert.Equal(t, "migrator.migration", span.Name())
+		assert.Equal(t, batch.SpanContext().SpanID(), span.Parent().SpanID(), "Expected migration spans to be children of the batch span")
+		assert.Equal(t, int64(i+1), attributes["migration.version"].AsInt64())
+		assert.Equal(t, name, attributes["migration.name"].AsString())
+		assert.Equal(t, DirectionUp, attributes["migration.direction"].AsString())
+		assert.Contains(t, attributes, attribute.Key("migration.duration_ms"))
+	}
+
+	_, err = migrator.Down(ctx)
+	assert.NoError(t, err)
+
+	spans = recorder.Ended()[3:]
+	assert.Len(t, spans, 2)
+	assert.Equal(t, "migrator.down", spans[1].Name())
+	assert.Equal(t, spans[1].SpanContext().SpanID(), spans[0].Parent().SpanID())
+	assert.Equal(t, DirectionDown, spanAttributes(spans[0])["migration.direction"].AsString())
+	assert.Equal(t, int64(2), spanAttributes(spans[0])["migration.version"].AsInt64())
+}
+
+func TestNoSpansWithoutTracerProvider(t *testing.T) {
+	h := newHarness(t, 2)
+
+	_, err := h.migrator.Up(h.ctx)
+	assert.NoError(t, err)
+	assert.Nil(t, h.migrator.tracer)
+}
@@ -0,0 +1,272 @@
+// Package schema сравнивает желаемую схему из SQL-файла со схемой базы и строит черновик миграции.
+// Разбор упрощенный и учитывает только таблицы и колонки: результат нужно проверить перед применением.
+package schema
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/juliazadorozhnaya/sql-migrator/lint"
+)
+
+var ErrInvalidTable = errors.New("cannot parse CREATE TABLE statement")
+
+var (
+	regIsCreate    = regexp.MustCompile(`(?i)^CREATE\s+(?:UNLOGGED\s+)?TABLE\b`)
+	regCreateTable = regexp.MustCompile(`(?is)^CREATE\s+(?:UNLOGGED\s+)?TABLE\s+(?:IF\s+NOT\s+EXISTS\s+)?("?[\w.]+"?)\s*\((.*)\)[^)]*$`)
+	regConstraint  = regexp.MustCompile(`(?i)^(CONSTRAINT|PRIMARY\s+KEY|UNIQUE|FOREIGN\s+KEY|CHECK|EXCLUDE)\b`)
+	regPrimaryKey  = regexp.MustCompile(`(?i)PRIMARY\s+KEY\s*\(([^)]*)\)`)
+	regTypeEnd     = regexp.MustCompile(`(?i)\s(NOT\s+NULL|NULL|DEFAULT|PRIMARY|REFERENCES|UNIQUE|CHECK|CONSTRAINT|GENERATED|COLLATE)\b`)
+	regTypeArgs    = regexp.MustCompile(`\s*\(.*\)`)
+)
+
+// Column — колонка таблицы. Type хранится в нормализованном виде, как его отдает information_schema.
+type Column struct {
+	Table    string
+	Name     string
+	Type     string
+	Nullable bool
+
+	// definition — определение колонки из файла схемы, используется в ADD COLUMN
+	definition string
+}
+
+// Table — таблица желаемой схемы
+type Table struct {
+	Name    string
+	Columns []Column
+
+	// statement — исходный CREATE TABLE, используется для создания отсутствующей таблицы
+	statement string
+}
+
+// Plan — черновик миграции
+type Plan struct {
+	Up   []string
+	Down []string
+}
+
+// Empty сообщает, что схема базы уже совпадает с желаемой
+func (p Plan) Empty() bool {
+	return len(p.Up) == 0
+}
+
+// Parse извлекает таблицы и колонки из выражений CREATE TABLE; остальные выражения пропускаются
+func Parse(sql string) ([]Table, error) {
+	var tables []Table
+	for _, stmt := range lint.SplitStatements(lint.DirectionUp, sql) {
+		if !regIsCreate.MatchString(stmt.SQL) {
+			continue
+		}
+
+		match := regCreateTable.FindStringSubmatch(stmt.SQL)
+		if match == nil {
+			return nil, fmt.Errorf("%w: %s", ErrInvalidTable, stmt.SQL)
+		}
+
+		table := Table{Name: unquote(match[1]), statement: stmt.SQL}
+		primaryKey := make(map[string]bool)
+		for _, item := range splitTopLevel(match[2]) {
+			if regConstraint.MatchString(item) {
+				if pk := regPrimaryKey.FindStringSubmatch(item); pk != nil {
+					for _, name := range strings.Split(pk[1], ",") {
+						primaryKey[unquote(strings.TrimSpace(name))] = true
+					}
+				}
+				continue
+			}
+
+			column, err := parseColumn(table.Name, item)
+			if err != nil {
+				return nil, err
+			}
+			table.Columns = append(table.Columns, column)
+		}
+
+		for i := range table.Columns {
+			if primaryKey[table.Columns[i].Name] {
+				table.Columns[i].Nullable = false
+			}
+		}
+		tables = append(tables, table)
+	}
+
+	return tables, nil
+}
+
+func parseColumn(table, definition string) (Column, error) {
+	parts := strings.SplitN(definition, " ", 2)
+	if len(parts) < 2 {
+		return Column{}, fmt.Errorf("%w: column %q in %s", ErrInvalidTable, definition, table)
+	}
+
+	rest := " " + parts[1]
+	columnType := rest
+	if loc := regTypeEnd.FindStringIndex(rest); loc != nil {
+		columnType = rest[:loc[0]]
+	}
+	constraints := strings.ToUpper(rest[len(columnType):])
+
+	return Column{
+		Table:      table,
+		Name:       unquote(parts[0]),
+		Type:       NormalizeType(columnType),
+		Nullable:   !strings.Contains(constraints, "NOT NULL") && !strings.Contains(constraints, "PRIMARY KEY"),
+		definition: definition,
+	}, nil
+}
+
+// typeAliases приводит типы из DDL к названиям information_schema.columns.data_type
+var typeAliases = map[string]string{
+	"int":         "integer",
+	"int4":        "integer",
+	"serial":      "integer",
+	"serial4":     "integer",
+	"int8":        "bigint",
+	"bigserial":   "bigint",
+	"serial8":     "bigint",
+	"int2":        "smallint",
+	"smallserial": "smallint",
+	"bool":        "boolean",
+	"varchar":     "character varying",
+	"char":        "character",
+	"float4":      "real",
+	"float8":      "double precision",
+	"float":       "double precision",
+	"decimal":     "numeric",
+	"timestamp":   "timestamp without time zone",
+	"timestamptz": "timestamp with time zone",
+	"time":        "time without time zone",
+	"timetz":      "time with time zone",
+}
+
+// NormalizeType приводит тип колонки к виду information_schema; длина сохраняется только у строковых типов
+func NormalizeType(columnType string) string {
+	columnType = strings.ToLower(strings.Join(strings.Fields(columnType), " "))
+
+	args := regTypeArgs.FindString(columnType)
+	base := strings.TrimSpace(strings.Replace(columnType, args, "", 1))
+	if alias, ok := typeAliases[base]; ok {
+		base = alias
+	}
+
+	if base == "character varying" || base == "character" {
+		return base + strings.ReplaceAll(strings.TrimSpace(args), " ", "")
+	}
+	return base
+}
+
+// Diff строит миграцию, приводящую actual к desired. Лишние таблицы и колонки базы
+// не удаляются, а попадают в up закомментированными, чтобы удаление было осознанным решением.
+func Diff(desired []Table, actual []Column) Plan {
+	existing := make(map[string]map[string]Column)
+	for _, column := range actual {
+		if existing[column.Table] == nil {
+			existing[column.Table] = make(map[string]Column)
+		}
+		existing[column.Table][column.Name] = column
+	}
+
+	var plan Plan
+	declared := make(map[string]bool, len(desired))
+	for _, table := range desired {
+		declared[table.Name] = true
+
+		columns, ok := existing[table.Name]
+		if !ok {
+			plan.add(table.statement+";", fmt.Sprintf("DROP TABLE IF EXISTS %s;", table.Name))
+			continue
+		}
+
+		wanted := make(map[string]bool, len(table.Columns))
+		for _, column := range table.Columns {
+			wanted[column.Name] = true
+
+			current, ok := columns[column.Name]
+			if !ok {
+				plan.add(fmt.Sprintf("ALTER TABLE %s ADD COLUMN %s;", table.Name, column.definition),
+					fmt.Sprintf("ALTER TABLE %s DROP COLUMN %s;", table.Name, column.Name))
+				continue
+			}
+
+			if current.Type != column.Type {
+				plan.add(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;", table.Name, column.Name, column.Type),
+					fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s TYPE %s;", table.Name, column.Name, current.Type))
+			}
+			if current.Nullable != column.Nullable {
+				plan.add(fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s;", table.Name, column.Name, nullability(column.Nullable)),
+					fmt.Sprintf("ALTER TABLE %s ALTER COLUMN %s %s;", table.Name, column.Name, nullability(current.Nullable)))
+			}
+		}
+
+		for _, name := range sortedKeys(columns) {
+			if !wanted[name] {
+				plan.Up = append(plan.Up, fmt.Sprintf("-- review: ALTER TABLE %s DROP COLUMN %s;", table.Name, name))
+			}
+		}
+	}
+
+	for _, name := range sortedKeys(existing) {
+		if !declared[name] {
+			plan.Up = append(plan.Up, fmt.Sprintf("-- review: DROP TABLE %s;", name))
+		}
+	}
+
+	return plan
+}
+
+// add добавляет шаг в up и обратный ему шаг в начало down
+func (p *Plan) add(up, down string) {
+	p.Up = append(p.Up, up)
+	p.Down = append([]string{down}, p.Down...)
+}
+
+func nullability(nullable bool) string {
+	if nullable {
+		return "DROP NOT NULL"
+	}
+	return "SET NOT NULL"
+}
+
+// splitTopLevel делит список определений таблицы по запятым вне скобок
+func splitTopLevel(s string) []string {
+	var items []string
+	depth, start := 0, 0
+	for i, r := range s {
+		switch r {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				items = append(items, strings.TrimSpace(s[start:i]))
+				start = i + 1
+			}
+		}
+	}
+	if item := strings.TrimSpace(s[start:]); item != "" {
+		items = append(items, item)
+	}
+	return items
+}
+
+func unquote(name string) string {
+	name = strings.Trim(name, `"`)
+	if i := strings.LastIndex(name, "."); i >= 0 {
+		name = name[i+1:]
+	}
+	return name
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
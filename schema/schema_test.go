@@ -0,0 +1,85 @@
+package schema
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const desiredSchema = `
+-- users and their posts
+CREATE TABLE users (
+	id SERIAL PRIMARY KEY,
+	email VARCHAR(255) NOT NULL,
+	name text,
+	balance NUMERIC(10, 2) DEFAULT 0,
+	UNIQUE (email)
+);
+
+CREATE INDEX users_email_idx ON users (email);
+
+CREATE TABLE IF NOT EXISTS public.posts (
+	id bigint,
+	user_id integer NOT NULL REFERENCES users (id),
+	created_at TIMESTAMPTZ NOT NULL DEFAULT now(),
+	PRIMARY KEY (id)
+);
+`
+
+func TestParse(t *testing.T) {
+	tables, err := Parse(desiredSchema)
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(tables))
+
+	assert.Equal(t, "users", tables[0].Name)
+	assert.Equal(t, []Column{
+		{Table: "users", Name: "id", Type: "integer", Nullable: false, definition: "id SERIAL PRIMARY KEY"},
+		{Table: "users", Name: "email", Type: "character varying(255)", Nullable: false, definition: "email VARCHAR(255) NOT NULL"},
+		{Table: "users", Name: "name", Type: "text", Nullable: true, definition: "name text"},
+		{Table: "users", Name: "balance", Type: "numeric", Nullable: true, definition: "balance NUMERIC(10, 2) DEFAULT 0"},
+	}, tables[0].Columns)
+
+	assert.Equal(t, "posts", tables[1].Name)
+	assert.False(t, tables[1].Columns[0].Nullable, "Expected table-level primary key columns to be not null")
+	assert.Equal(t, "timestamp with time zone", tables[1].Columns[2].Type)
+
+	_, err = Parse("CREATE TABLE broken AS SELECT 1;")
+	assert.ErrorIs(t, err, ErrInvalidTable)
+}
+
+func TestDiff(t *testing.T) {
+	tables, err := Parse(desiredSchema)
+	assert.NoError(t, err)
+
+	actual := []Column{
+		{Table: "users", Name: "id", Type: "integer", Nullable: false},
+		{Table: "users", Name: "email", Type: "character varying(100)", Nullable: true},
+		{Table: "users", Name: "balance", Type: "numeric", Nullable: true},
+		{Table: "users", Name: "legacy", Type: "text", Nullable: true},
+		{Table: "audit", Name: "id", Type: "integer", Nullable: false},
+	}
+
+	plan := Diff(tables, actual)
+	assert.Equal(t, []string{
+		"ALTER TABLE users ALTER COLUMN email TYPE character varying(255);",
+		"ALTER TABLE users ALTER COLUMN email SET NOT NULL;",
+		"ALTER TABLE users ADD COLUMN name text;",
+		"-- review: ALTER TABLE users DROP COLUMN legacy;",
+		"CREATE TABLE IF NOT EXISTS public.posts ( id bigint, user_id integer NOT NULL REFERENCES users (id), created_at TIMESTAMPTZ NOT NULL DEFAULT now(), PRIMARY KEY (id) );",
+		"-- review: DROP TABLE audit;",
+	}, plan.Up)
+	assert.Equal(t, []string{
+		"DROP TABLE IF EXISTS posts;",
+		"ALTER TABLE users DROP COLUMN name;",
+		"ALTER TABLE users ALTER COLUMN email DROP NOT NULL;",
+		"ALTER TABLE users ALTER COLUMN email TYPE character varying(100);",
+	}, plan.Down)
+
+	matching := []Column{
+		{Table: "users", Name: "id", Type: "integer"},
+		{Table: "users", Name: "email", Type: "character varying(255)"},
+		{Table: "users", Name: "name", Type: "text", Nullable: true},
+		{Table: "users", Name: "balance", Type: "numeric", Nullable: true},
+	}
+	assert.True(t, Diff(tables[:1], matching).Empty(), "Expected no statements when the database matches")
+}
@@ -0,0 +1,96 @@
+package storage
+
+import (
+	"context"
+	"time"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Activity — запрос, который сейчас выполняет сессия мигратора, по данным pg_stat_activity
+type Activity struct {
+	PID     int
+	State   string
+	Query   string
+	Elapsed time.Duration
+}
+
+// ActivityWatcher — хранилище, которое может показывать, чем заняты его сессии на сервере
+type ActivityWatcher interface {
+	// WatchActivity каждые interval передает в report активные запросы сессий мигратора, пока не отменен ctx
+	WatchActivity(ctx context.Context, interval time.Duration, report func(Activity)) error
+}
+
+const selectActivitySQL = `SELECT pid, COALESCE(state, ''), COALESCE(query, ''),
+	COALESCE(EXTRACT(EPOCH FROM now() - query_start), 0)::float8
+FROM pg_stat_activity
+WHERE application_name = $1 AND pid <> pg_backend_pid() AND state <> 'idle'
+ORDER BY query_start;`
+
+// WatchActivity опрашивает pg_stat_activity через отдельное соединение: единственное соединение пула
+// занято блокировкой и миграцией. Сессии мигратора находятся по application_name, поэтому в вывод
+// попадут и другие миграторы с тем же именем приложения.
+func (storage *PostgresStorage) WatchActivity(ctx context.Context, interval time.Duration, report func(Activity)) error {
+	config, err := storage.poolConfig()
+	if err != nil {
+		return err
+	}
+
+	appName := config.ConnConfig.RuntimeParams["application_name"]
+	connConfig := config.ConnConfig.Copy()
+	connConfig.RuntimeParams["application_name"] = appName + "/activity"
+
+	conn, err := pgx.ConnectConfig(ctx, connConfig)
+	if err != nil {
+		err = describeConnectError(err)
+		storage.logger.Error("Failed to connect for activity watching: %v", err)
+		return err
+	}
+	defer conn.Close(context.Background())
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+
+		activities, err := selectActivity(ctx, conn, appName)
+		if err != nil {
+			if ctx.Err() != nil {
+				return nil
+			}
+			storage.logger.Warn("Failed to select activity: %v", err)
+			continue
+		}
+		for _, activity := range activities {
+			report(activity)
+		}
+	}
+}
+
+func selectActivity(ctx context.Context, conn *pgx.Conn, appName string) ([]Activity, error) {
+	rows, err := conn.Query(ctx, selectActivitySQL, appName)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var activities []Activity
+	for rows.Next() {
+		var (
+			activity Activity
+			seconds  float64
+		)
+		if err := rows.Scan(&activity.PID, &activity.State, &activity.Query, &seconds); err != nil {
+			return nil, err
+		}
+		activity.Elapsed = time.Duration(seconds * float64(time.Second))
+		activities = append(activities, activity)
+	}
+
+	return activities, rows.Err()
+}
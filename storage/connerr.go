@@ -0,0 +1,45 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	"github.com/jackc/pgconn"
+)
+
+var (
+	ErrHostUnreachable  = errors.New("database host is unreachable, check host and port in the DSN")
+	ErrAuthFailed       = errors.New("database authentication failed, check user and password in the DSN")
+	ErrDatabaseNotExist = errors.New("database does not exist, create it or fix the database name in the DSN")
+)
+
+// SQLSTATE ошибок подключения, по которым выбирается понятное сообщение
+const (
+	codeInvalidAuthorization = "28000"
+	codeInvalidPassword      = "28P01"
+	codeInvalidCatalogName   = "3D000"
+)
+
+// describeConnectError дополняет ошибку подключения причиной, понятной без чтения логов pgx:
+// хост недоступен, неверные учетные данные или базы не существует. Остальные ошибки возвращаются как есть.
+func describeConnectError(err error) error {
+	var pgErr *pgconn.PgError
+	if errors.As(err, &pgErr) {
+		switch pgErr.Code {
+		case codeInvalidPassword, codeInvalidAuthorization:
+			return fmt.Errorf("%w: %v", ErrAuthFailed, err)
+		case codeInvalidCatalogName:
+			return fmt.Errorf("%w: %v", ErrDatabaseNotExist, err)
+		}
+		return err
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) || errors.Is(err, context.DeadlineExceeded) || pgconn.Timeout(err) {
+		return fmt.Errorf("%w: %v", ErrHostUnreachable, err)
+	}
+
+	return err
+}
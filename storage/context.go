@@ -0,0 +1,55 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"os"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+)
+
+// ContextKey — ключ, под которым мигратор кладет хранилище в контекст Go-миграций
+type ContextKey struct{}
+
+// DSNEnv и TableEnv — переменные окружения, в которых мигратор передает подключение Go-миграции,
+// запущенной через go run: отдельный процесс не получает хранилище мигратора в ctx
+const (
+	DSNEnv   = "MIGRATOR_DSN"
+	TableEnv = "MIGRATOR_TABLE"
+)
+
+var (
+	ErrStorageNotInContext = errors.New("storage not found in context")
+	ErrDSNNotInEnv         = errors.New(DSNEnv + " is not set, run the migration through the migrator")
+)
+
+func NewContext(ctx context.Context, storage SqlStorage) context.Context {
+	return context.WithValue(ctx, ContextKey{}, storage)
+}
+
+// FromContext возвращает хранилище, переданное мигратором в Go-миграцию
+func FromContext(ctx context.Context) (SqlStorage, error) {
+	storage, ok := ctx.Value(ContextKey{}).(SqlStorage)
+	if !ok || storage == nil {
+		return nil, ErrStorageNotInContext
+	}
+
+	return storage, nil
+}
+
+// RunFromEnv подключается к базе из DSNEnv и TableEnv и вызывает step с хранилищем в ctx.
+// Вызывается из main Go-миграции, которую мигратор запускает через go run.
+func RunFromEnv(ctx context.Context, step func(ctx context.Context) error) error {
+	dsn := os.Getenv(DSNEnv)
+	if dsn == "" {
+		return ErrDSNNotInEnv
+	}
+
+	storage := New(dsn, logger.New(), WithTableName(os.Getenv(TableEnv)))
+	if err := storage.Connect(ctx); err != nil {
+		return err
+	}
+	defer storage.Close()
+
+	return step(NewContext(ctx, storage))
+}
@@ -10,13 +10,20 @@ type IMigration interface {
 	GetStatus() string
 	GetVersion() int
 	GetStatusChangeTime() time.Time
+	// GetAppliedSQL возвращает SQL, выполненный последним шагом миграции; пусто, если он не записывался
+	GetAppliedSQL() string
 
 	SetName(name string)
 	SetStatus(status string)
 	SetVersion(version int)
 	SetStatusChangeTime(statusChangeTime time.Time)
+	SetAppliedSQL(sql string)
 }
 
+// GoMigrationFunc — шаг Go-миграции. Хранилище для запросов берется из ctx через FromContext:
+// в транзакционном режиме оно работает внутри транзакции шага, и запросы откатываются вместе с учетом миграции.
+type GoMigrationFunc func(ctx context.Context) error
+
 type Migration struct {
 	Name             string
 	Version          int
@@ -24,8 +31,22 @@ type Migration struct {
 	StatusChangeTime time.Time
 	Up               string
 	Down             string
-	UpGo             func(ctx context.Context) error
-	DownGo           func(ctx context.Context) error
+	UpGo             GoMigrationFunc
+	DownGo           GoMigrationFunc
+	// Seed — тестовые данные, которые применяются после Up, если мигратору разрешены seed-файлы
+	Seed string
+
+	// Метаданные из заголовка файла миграции
+	Description string
+	Author      string
+	Requires    []int
+	Tag         string
+	// MinServer — минимальная основная версия сервера, например 14; 0 — без ограничения
+	MinServer int
+	// AppliedSQL — SQL, выполненный последним шагом, для записи в таблицу учета
+	AppliedSQL string
+	// Probe — запрос, возвращающий true, если миграция уже применена; по нему -resume восстанавливает учет
+	Probe string
 }
 
 func NewMigration(name, status string, version int, statusChangeTime time.Time) IMigration {
@@ -53,6 +74,10 @@ func (m *Migration) GetStatusChangeTime() time.Time {
 	return m.StatusChangeTime
 }
 
+func (m *Migration) GetAppliedSQL() string {
+	return m.AppliedSQL
+}
+
 func (m *Migration) SetName(name string) {
 	m.Name = name
 }
@@ -68,3 +93,7 @@ func (m *Migration) SetVersion(version int) {
 func (m *Migration) SetStatusChangeTime(statusChangeTime time.Time) {
 	m.StatusChangeTime = statusChangeTime
 }
+
+func (m *Migration) SetAppliedSQL(sql string) {
+	m.AppliedSQL = sql
+}
@@ -2,10 +2,27 @@ package storage
 
 import (
 	"context"
+	"sort"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/schema"
 )
 
+// MockSqlStorage — хранилище в памяти, повторяющее семантику PostgresStorage: upsert по версии,
+// сортировку по убыванию версии и ErrMigrationNotFound для пустой таблицы
 type MockSqlStorage struct {
 	migrations []IMigration
+	executed   []string
+	events     []IMigration
+	notified   []Notification
+	columns    []schema.Column
+	// serverVersion — значение, которое возвращает ServerVersion
+	serverVersion int
+	// probes — ответы Probe по тексту запроса; неизвестный запрос возвращает false
+	probes map[string]bool
+
+	// saved — состояние таблиц на момент Begin, к которому возвращает Rollback
+	saved *MockSqlStorage
 }
 
 func (m *MockSqlStorage) Connect(ctx context.Context) error {
@@ -16,6 +33,31 @@ func (m *MockSqlStorage) Close() error {
 	return nil
 }
 
+func (m *MockSqlStorage) Ping(ctx context.Context) error {
+	return nil
+}
+
+func (m *MockSqlStorage) ServerVersion(ctx context.Context) (int, error) {
+	return m.serverVersion, nil
+}
+
+// SetServerVersion задает версию сервера в формате server_version_num
+func (m *MockSqlStorage) SetServerVersion(version int) {
+	m.serverVersion = version
+}
+
+func (m *MockSqlStorage) Probe(ctx context.Context, sql string) (bool, error) {
+	return m.probes[sql], nil
+}
+
+// SetProbe задает ответ Probe на запрос sql
+func (m *MockSqlStorage) SetProbe(sql string, applied bool) {
+	if m.probes == nil {
+		m.probes = make(map[string]bool)
+	}
+	m.probes[sql] = applied
+}
+
 func (m *MockSqlStorage) Lock(ctx context.Context) error {
 	return nil
 }
@@ -25,33 +67,200 @@ func (m *MockSqlStorage) Unlock(ctx context.Context) error {
 }
 
 func (m *MockSqlStorage) InsertMigration(ctx context.Context, migration IMigration) error {
-	m.migrations = append(m.migrations, migration)
+	row := snapshot(migration)
+	for i, existing := range m.migrations {
+		if existing.GetVersion() == migration.GetVersion() {
+			// Как и в базе, пустой AppliedSQL не затирает записанный ранее
+			if row.GetAppliedSQL() == "" {
+				row.SetAppliedSQL(existing.GetAppliedSQL())
+			}
+			m.migrations[i] = row
+			return nil
+		}
+	}
+
+	m.migrations = append(m.migrations, row)
+	return nil
+}
+
+// snapshot копирует строку таблицы, чтобы последующие изменения миграции не меняли сохранённое состояние
+func snapshot(migration IMigration) IMigration {
+	row := NewMigration(migration.GetName(), migration.GetStatus(), migration.GetVersion(), migration.GetStatusChangeTime())
+	row.SetAppliedSQL(migration.GetAppliedSQL())
+	return row
+}
+
+func (m *MockSqlStorage) Begin(ctx context.Context) error {
+	m.saved = &MockSqlStorage{
+		migrations: append([]IMigration(nil), m.migrations...),
+		executed:   append([]string(nil), m.executed...),
+		events:     append([]IMigration(nil), m.events...),
+		notified:   append([]Notification(nil), m.notified...),
+	}
 	return nil
 }
 
+func (m *MockSqlStorage) Commit(ctx context.Context) error {
+	m.saved = nil
+	return nil
+}
+
+func (m *MockSqlStorage) Rollback(ctx context.Context) error {
+	if m.saved != nil {
+		m.migrations, m.executed, m.events, m.notified = m.saved.migrations, m.saved.executed, m.saved.events, m.saved.notified
+		m.saved = nil
+	}
+	return nil
+}
+
+func (m *MockSqlStorage) InTransaction() bool {
+	return m.saved != nil
+}
+
 func (m *MockSqlStorage) Migrate(ctx context.Context, sql string) error {
+	m.executed = append(m.executed, sql)
+	return nil
+}
+
+// Executed возвращает SQL, переданный в Migrate, в порядке выполнения
+func (m *MockSqlStorage) Executed() []string {
+	return m.executed
+}
+
+func (m *MockSqlStorage) SelectColumns(ctx context.Context) ([]schema.Column, error) {
+	return m.columns, nil
+}
+
+// SetColumns задает колонки, которые возвращает SelectColumns
+func (m *MockSqlStorage) SetColumns(columns []schema.Column) {
+	m.columns = columns
+}
+
+// Notification — уведомление, отправленное через Notify
+type Notification struct {
+	Channel string
+	Payload string
+}
+
+func (m *MockSqlStorage) Notify(ctx context.Context, channel, payload string) error {
+	m.notified = append(m.notified, Notification{Channel: channel, Payload: payload})
 	return nil
 }
 
+// Notified возвращает уведомления в порядке отправки
+func (m *MockSqlStorage) Notified() []Notification {
+	return m.notified
+}
+
 func (m *MockSqlStorage) SelectMigrations(ctx context.Context) ([]IMigration, error) {
-	return m.migrations, nil
+	if len(m.migrations) == 0 {
+		return nil, ErrMigrationNotFound
+	}
+
+	migrations := make([]IMigration, 0, len(m.migrations))
+	for _, migration := range m.migrations {
+		migrations = append(migrations, snapshot(migration))
+	}
+	sort.SliceStable(migrations, func(i, j int) bool {
+		return migrations[i].GetVersion() > migrations[j].GetVersion()
+	})
+
+	return migrations, nil
+}
+
+func (m *MockSqlStorage) SelectMigrationsSince(ctx context.Context, since time.Time) ([]IMigration, error) {
+	migrations, err := m.SelectMigrations(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var recent []IMigration
+	for _, migration := range migrations {
+		if !migration.GetStatusChangeTime().Before(since) {
+			recent = append(recent, migration)
+		}
+	}
+	if len(recent) == 0 {
+		return nil, ErrMigrationNotFound
+	}
+
+	return recent, nil
 }
 
 func (m *MockSqlStorage) SelectLastMigrationByStatus(ctx context.Context, status string) (IMigration, error) {
-	if len(m.migrations) == 0 {
+	if !IsKnownStatus(status) {
+		return nil, ErrUnexpectedStatus
+	}
+
+	var last IMigration
+	for _, migration := range m.migrations {
+		if migration.GetStatus() == status && (last == nil || migration.GetVersion() > last.GetVersion()) {
+			last = migration
+		}
+	}
+
+	if last == nil {
 		return nil, ErrMigrationNotFound
 	}
 
-	for i := len(m.migrations) - 1; i >= 0; i-- {
-		if m.migrations[i].GetStatus() == status {
-			return m.migrations[i], nil
+	return snapshot(last), nil
+}
+
+func (m *MockSqlStorage) SelectMigrationByVersion(ctx context.Context, version int) (IMigration, error) {
+	for _, migration := range m.migrations {
+		if migration.GetVersion() == version {
+			return snapshot(migration), nil
 		}
 	}
 
 	return nil, ErrMigrationNotFound
 }
 
+func (m *MockSqlStorage) TableName() string {
+	return DefaultTableName
+}
+
 func (m *MockSqlStorage) DeleteMigrations(ctx context.Context) error {
 	m.migrations = []IMigration{}
 	return nil
 }
+
+func (m *MockSqlStorage) DeleteMigrationsByStatus(ctx context.Context, statuses ...string) error {
+	remove := make(map[string]bool, len(statuses))
+	for _, status := range statuses {
+		if !IsKnownStatus(status) {
+			return ErrUnexpectedStatus
+		}
+		remove[status] = true
+	}
+
+	var kept []IMigration
+	for _, migration := range m.migrations {
+		if !remove[migration.GetStatus()] {
+			kept = append(kept, migration)
+		}
+	}
+	m.migrations = kept
+	return nil
+}
+
+func (m *MockSqlStorage) InsertMigrationEvent(ctx context.Context, migration IMigration) error {
+	m.events = append(m.events, snapshot(migration))
+	return nil
+}
+
+func (m *MockSqlStorage) InsertMigrations(ctx context.Context, migrations []IMigration) error {
+	for _, migration := range migrations {
+		if err := m.InsertMigration(ctx, migration); err != nil {
+			return err
+		}
+		if err := m.InsertMigrationEvent(ctx, migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (m *MockSqlStorage) SelectMigrationEvents(ctx context.Context) ([]IMigration, error) {
+	return m.events, nil
+}
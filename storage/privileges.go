@@ -0,0 +1,136 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jackc/pgx/v4"
+)
+
+// Privilege — право, которое нужно мигратору, и есть ли оно у текущей роли
+type Privilege struct {
+	Name    string
+	Granted bool
+	// Hint — как выдать недостающее право
+	Hint string
+}
+
+// PrivilegeChecker — хранилище, которое может проверить права текущей роли до запуска миграций
+type PrivilegeChecker interface {
+	// CheckPrivileges подключается, не создавая таблиц учета, и проверяет каждое нужное мигратору право
+	CheckPrivileges(ctx context.Context) ([]Privilege, error)
+}
+
+// CheckPrivileges проверяет CREATE на схеме таблицы учета, права на уже существующие таблицы учета
+// и вызов advisory-блокировки. Права на еще не созданные таблицы не проверяются: их владельцем станет
+// создавшая роль. Если хранилище не подключено, пул открывается без создания таблиц и закрывается в Close.
+func (storage *PostgresStorage) CheckPrivileges(ctx context.Context) ([]Privilege, error) {
+	if storage.pool == nil {
+		if err := storage.open(ctx); err != nil {
+			return nil, err
+		}
+	}
+
+	var role, schema string
+	qualifiedSchema, _, qualified := strings.Cut(storage.tableName, ".")
+	if !qualified {
+		qualifiedSchema = ""
+	}
+	if err := queryRow(ctx, storage.pool, "SELECT current_user::text, COALESCE(NULLIF($1::text, ''), current_schema(), '');", []interface{}{qualifiedSchema}, &role, &schema); err != nil {
+		return nil, err
+	}
+
+	var privileges []Privilege
+	create := Privilege{
+		Name: "CREATE on the current schema",
+		Hint: "search_path has no existing schema; set it for the role or qualify table_name with a schema",
+	}
+	if schema != "" {
+		create.Name = fmt.Sprintf("CREATE on schema %s", schema)
+		create.Hint = fmt.Sprintf("GRANT CREATE ON SCHEMA %s TO %s; the migrations tables and most migrations create objects", pgx.Identifier{schema}.Sanitize(), pgx.Identifier{role}.Sanitize())
+		if err := queryRow(ctx, storage.pool, "SELECT has_schema_privilege($1::text, 'CREATE');", []interface{}{schema}, &create.Granted); err != nil {
+			return nil, err
+		}
+	}
+	privileges = append(privileges, create)
+
+	tables := []struct {
+		name       string
+		privileges []string
+	}{
+		{storage.table(), []string{"SELECT", "INSERT", "UPDATE", "DELETE"}},
+		{storage.eventsTable(), []string{"SELECT", "INSERT"}},
+	}
+	for _, table := range tables {
+		var exists bool
+		if err := queryRow(ctx, storage.pool, "SELECT to_regclass($1::text) IS NOT NULL;", []interface{}{table.name}, &exists); err != nil {
+			return nil, err
+		}
+		if !exists {
+			continue
+		}
+
+		for _, name := range table.privileges {
+			privilege := Privilege{
+				Name: fmt.Sprintf("%s on %s", name, table.name),
+				Hint: fmt.Sprintf("GRANT %s ON %s TO %s", name, table.name, pgx.Identifier{role}.Sanitize()),
+			}
+			if err := queryRow(ctx, storage.pool, "SELECT has_table_privilege($1::text, $2::text);", []interface{}{table.name, name}, &privilege.Granted); err != nil {
+				return nil, err
+			}
+			privileges = append(privileges, privilege)
+		}
+	}
+
+	privileges = append(privileges, storage.checkLockPrivilege(ctx, role))
+	return privileges, nil
+}
+
+// checkLockPrivilege вызывает pg_try_advisory_lock на отдельном соединении и сразу снимает блокировку.
+// Занятая другим мигратором блокировка право не отменяет, поэтому ответ false тоже считается успехом.
+func (storage *PostgresStorage) checkLockPrivilege(ctx context.Context, role string) Privilege {
+	privilege := Privilege{
+		Name: "EXECUTE on pg_try_advisory_lock and pg_advisory_unlock",
+		Hint: fmt.Sprintf("GRANT EXECUTE ON FUNCTION pg_try_advisory_lock(bigint), pg_advisory_unlock(bigint) TO %s", pgx.Identifier{role}.Sanitize()),
+	}
+
+	conn, err := storage.pool.AcquireConn(ctx)
+	if err != nil {
+		storage.logger.Warn("Failed to acquire connection for lock check: %v", err)
+		return privilege
+	}
+	defer conn.Release()
+
+	var locked bool
+	if err := queryRow(ctx, conn, "SELECT pg_try_advisory_lock($1);", []interface{}{storage.lockID()}, &locked); err != nil {
+		storage.logger.Warn("Failed to take advisory lock: %v", err)
+		return privilege
+	}
+	if locked {
+		if _, err := conn.Exec(ctx, "SELECT pg_advisory_unlock($1);", storage.lockID()); err != nil {
+			storage.logger.Warn("Failed to release advisory lock: %v", err)
+			return privilege
+		}
+	}
+
+	privilege.Granted = true
+	return privilege
+}
+
+// queryRow выполняет запрос, возвращающий одну строку, и читает ее в dest
+func queryRow(ctx context.Context, db executor, sql string, args []interface{}, dest ...interface{}) error {
+	rows, err := db.Query(ctx, sql, args...)
+	if err != nil {
+		return err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return err
+		}
+		return pgx.ErrNoRows
+	}
+	return rows.Scan(dest...)
+}
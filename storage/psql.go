@@ -0,0 +1,81 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+)
+
+// PsqlMarker — строка в миграции, которую нужно выполнить через psql, а не по протоколу:
+// так работают \copy и другие мета-команды psql. Требует установленного psql и WithPsql.
+const PsqlMarker = "-- migrator:use-psql"
+
+var ErrPsqlDisabled = errors.New("migration is marked " + PsqlMarker + ", set psql_path to run it through psql")
+
+// WithPsql разрешает миграции с PsqlMarker и задает путь к psql; пустой путь их запрещает
+func WithPsql(psqlPath string) Option {
+	return func(storage *PostgresStorage) {
+		storage.psqlPath = psqlPath
+	}
+}
+
+// usesPsql проверяет, что миграция отмечена PsqlMarker
+func usesPsql(sql string) bool {
+	for _, line := range strings.Split(sql, "\n") {
+		if strings.TrimSpace(line) == PsqlMarker {
+			return true
+		}
+	}
+	return false
+}
+
+// runPsql выполняет файл через psql и возвращает его вывод. Переменная, чтобы тесты обходились без psql.
+var runPsql = func(ctx context.Context, psqlPath string, args []string, env []string) ([]byte, error) {
+	cmd := exec.CommandContext(ctx, psqlPath, args...)
+	cmd.Env = append(os.Environ(), env...)
+	return cmd.CombinedOutput()
+}
+
+// migrateWithPsql выполняет миграцию через psql -f в отдельной сессии. Блокировку мигратора эта сессия
+// не видит, поэтому в общую транзакцию запуска такая миграция войти не может.
+func (storage *PostgresStorage) migrateWithPsql(ctx context.Context, sql string) error {
+	if storage.psqlPath == "" {
+		return ErrPsqlDisabled
+	}
+	if storage.inRunTransaction() {
+		return fmt.Errorf("%w: psql runs in its own session", ErrNonTransactionalMigration)
+	}
+
+	file, err := os.CreateTemp("", "migration-*.sql")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(file.Name())
+
+	if _, err := file.WriteString(sql); err != nil {
+		file.Close()
+		return err
+	}
+	if err := file.Close(); err != nil {
+		return err
+	}
+
+	args := []string{"--no-psqlrc", "--set", "ON_ERROR_STOP=1", "--file", file.Name(), "--dbname", storage.dsn()}
+	if storage.transaction && !hasTransactionControl(sql) {
+		args = append(args, "--single-transaction")
+	}
+
+	storage.logger.Info("Executing migration SQL through %s", storage.psqlPath)
+	output, err := runPsql(ctx, storage.psqlPath, args, []string{"PGAPPNAME=" + storage.appName})
+	if text := strings.TrimSpace(string(output)); text != "" {
+		storage.logger.Info("psql: %s", text)
+	}
+	if err != nil {
+		return fmt.Errorf("psql: %w", err)
+	}
+
+	return nil
+}
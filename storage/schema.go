@@ -0,0 +1,57 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/juliazadorozhnaya/sql-migrator/schema"
+)
+
+// selectColumnsSQL читает колонки таблиц текущей схемы; пользовательские типы отдаются по имени,
+// у строковых типов к имени добавляется длина, как в DDL
+const selectColumnsSQL = `SELECT table_name,
+	column_name,
+	CASE WHEN data_type = 'USER-DEFINED' THEN udt_name ELSE data_type END,
+	COALESCE(character_maximum_length, 0),
+	is_nullable = 'YES'
+FROM information_schema.columns
+WHERE table_schema = current_schema() AND table_name NOT IN ($1, $2)
+ORDER BY table_name, ordinal_position;`
+
+// SelectColumns возвращает колонки таблиц базы, кроме таблиц самого мигратора
+func (storage *PostgresStorage) SelectColumns(ctx context.Context) ([]schema.Column, error) {
+	tableName, eventsTableName := storage.tableName, EventsTableName(storage.tableName)
+	if i := strings.LastIndex(tableName, "."); i >= 0 {
+		tableName, eventsTableName = tableName[i+1:], eventsTableName[i+1:]
+	}
+
+	rows, err := storage.executor().Query(ctx, selectColumnsSQL, tableName, eventsTableName)
+	if err != nil {
+		storage.logger.Error("Failed to select columns: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var columns []schema.Column
+	for rows.Next() {
+		var (
+			column    schema.Column
+			dataType  string
+			maxLength int
+		)
+
+		if err := rows.Scan(&column.Table, &column.Name, &dataType, &maxLength, &column.Nullable); err != nil {
+			storage.logger.Error("Failed to scan column row: %v", err)
+			return nil, err
+		}
+
+		if maxLength > 0 {
+			dataType = fmt.Sprintf("%s(%d)", dataType, maxLength)
+		}
+		column.Type = schema.NormalizeType(dataType)
+		columns = append(columns, column)
+	}
+
+	return columns, rows.Err()
+}
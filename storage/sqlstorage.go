@@ -0,0 +1,671 @@
+package storage
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/schema"
+)
+
+const (
+	// PlaceholderDollar — параметры запросов вида $1, $2 (lib/pq, pgx/stdlib)
+	PlaceholderDollar = "dollar"
+	// PlaceholderQuestion — параметры запросов вида ? (MySQL, SQLite и большинство других драйверов)
+	PlaceholderQuestion = "question"
+)
+
+// lockTableName — таблица, строка в которой означает, что мигратор держит блокировку
+const lockTableName = "migration_lock"
+
+var ErrNotSupported = errors.New("not supported by the database/sql storage")
+
+// sqlQuerier выполняет запросы через *sql.DB, *sql.Conn или *sql.Tx
+type sqlQuerier interface {
+	ExecContext(ctx context.Context, query string, args ...interface{}) (sql.Result, error)
+	QueryContext(ctx context.Context, query string, args ...interface{}) (*sql.Rows, error)
+}
+
+// SQLStorage — хранилище поверх database/sql для окружений, где разрешен только определенный драйвер.
+// Использует переносимый SQL: без advisory-блокировок, ON CONFLICT и пакетов pgx. Блокировкой служит
+// строка в таблице migration_lock; если мигратор упал, не сняв ее, строку нужно удалить вручную.
+// Драйвер должен сканировать TIMESTAMP в time.Time (для MySQL — parseTime=true).
+type SQLStorage struct {
+	driverName  string
+	dataSource  string
+	db          *sql.DB
+	tx          *sql.Tx
+	ownsDB      bool
+	tableName   string
+	placeholder string
+	transaction bool
+	lockWait    time.Duration
+	logger      logger.Logger
+}
+
+type SQLOption func(*SQLStorage)
+
+// WithSQLTableName задает таблицу учета миграций; имя подставляется в запросы как есть
+func WithSQLTableName(tableName string) SQLOption {
+	return func(storage *SQLStorage) {
+		if tableName != "" {
+			storage.tableName = tableName
+		}
+	}
+}
+
+// WithSQLPlaceholder задает вид параметров запросов драйвера: PlaceholderDollar (по умолчанию) или PlaceholderQuestion
+func WithSQLPlaceholder(placeholder string) SQLOption {
+	return func(storage *SQLStorage) {
+		if placeholder != "" {
+			storage.placeholder = placeholder
+		}
+	}
+}
+
+// WithSQLTransaction оборачивает SQL каждой миграции в транзакцию
+func WithSQLTransaction(enabled bool) SQLOption {
+	return func(storage *SQLStorage) {
+		storage.transaction = enabled
+	}
+}
+
+// WithSQLLockWait задает, сколько ждать блокировку другого мигратора; 0 — сразу вернуть ErrLockHeld
+func WithSQLLockWait(lockWait time.Duration) SQLOption {
+	return func(storage *SQLStorage) {
+		storage.lockWait = lockWait
+	}
+}
+
+// NewSQL создает хранилище, которое в Connect открывает базу через зарегистрированный драйвер driverName
+func NewSQL(driverName, dataSource string, logger logger.Logger, opts ...SQLOption) *SQLStorage {
+	storage := &SQLStorage{
+		driverName:  driverName,
+		dataSource:  dataSource,
+		ownsDB:      true,
+		tableName:   DefaultTableName,
+		placeholder: PlaceholderDollar,
+		logger:      logger,
+	}
+
+	for _, opt := range opts {
+		opt(storage)
+	}
+
+	return storage
+}
+
+// NewSQLWithDB создает хранилище поверх уже открытого *sql.DB; Close его не закрывает
+func NewSQLWithDB(db *sql.DB, logger logger.Logger, opts ...SQLOption) *SQLStorage {
+	storage := NewSQL("", "", logger, opts...)
+	storage.db = db
+	storage.ownsDB = false
+
+	return storage
+}
+
+func (storage *SQLStorage) Connect(ctx context.Context) error {
+	if storage.ownsDB {
+		storage.logger.Info("Connecting to the database %s via %s driver", RedactDSN(storage.dataSource), storage.driverName)
+
+		db, err := sql.Open(storage.driverName, storage.dataSource)
+		if err != nil {
+			storage.logger.Error("Failed to open the database: %v", err)
+			return err
+		}
+		storage.db = db
+	} else {
+		storage.logger.Info("Using borrowed database handle")
+	}
+
+	if err := storage.Ping(ctx); err != nil {
+		storage.closeOwned()
+		return err
+	}
+
+	if err := storage.createTables(ctx); err != nil {
+		storage.closeOwned()
+		return err
+	}
+
+	storage.logger.Info("Connected to the database and ensured %s table exists", storage.tableName)
+	return nil
+}
+
+func (storage *SQLStorage) closeOwned() {
+	if storage.ownsDB && storage.db != nil {
+		storage.db.Close()
+		storage.db = nil
+	}
+}
+
+func (storage *SQLStorage) createTables(ctx context.Context) error {
+	statements := []string{
+		`CREATE TABLE IF NOT EXISTS ` + storage.tableName + ` (
+			Version INTEGER PRIMARY KEY,
+			Name VARCHAR(100),
+			Status VARCHAR(20),
+			StatusChangeTime TIMESTAMP,
+			AppliedSQL TEXT
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + EventsTableName(storage.tableName) + ` (
+			Version INTEGER,
+			Name VARCHAR(100),
+			Status VARCHAR(20),
+			StatusChangeTime TIMESTAMP
+		)`,
+		`CREATE TABLE IF NOT EXISTS ` + lockTableName + ` (
+			Id INTEGER PRIMARY KEY,
+			LockedAt TIMESTAMP
+		)`,
+	}
+
+	for _, statement := range statements {
+		if _, err := storage.db.ExecContext(ctx, statement); err != nil {
+			storage.logger.Error("Failed to create migrations tables: %v", err)
+			return err
+		}
+	}
+
+	return storage.addAppliedSQLColumn(ctx)
+}
+
+// addAppliedSQLColumn добавляет колонку AppliedSQL в таблицу, созданную старой версией. ADD COLUMN IF NOT EXISTS
+// есть не во всех базах, поэтому наличие колонки проверяется запросом к ней.
+func (storage *SQLStorage) addAppliedSQLColumn(ctx context.Context) error {
+	rows, err := storage.db.QueryContext(ctx, `SELECT AppliedSQL FROM `+storage.tableName+` WHERE 1 = 0`)
+	if err == nil {
+		return rows.Close()
+	}
+
+	if _, err := storage.db.ExecContext(ctx, `ALTER TABLE `+storage.tableName+` ADD AppliedSQL TEXT`); err != nil {
+		storage.logger.Error("Failed to add AppliedSQL column: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (storage *SQLStorage) Ping(ctx context.Context) error {
+	if storage.db == nil {
+		return ErrNotConnected
+	}
+
+	if err := storage.db.PingContext(ctx); err != nil {
+		storage.logger.Error("Failed to ping the database: %v", err)
+		return err
+	}
+	return nil
+}
+
+// ServerVersion читает server_version_num, поэтому работает только с драйверами Postgres
+func (storage *SQLStorage) ServerVersion(ctx context.Context) (int, error) {
+	if storage.db == nil {
+		return 0, ErrNotConnected
+	}
+
+	var version string
+	if err := storage.db.QueryRowContext(ctx, "SHOW server_version_num").Scan(&version); err != nil {
+		storage.logger.Error("Failed to get server version: %v", err)
+		return 0, err
+	}
+
+	return parseServerVersion(version)
+}
+
+func (storage *SQLStorage) Probe(ctx context.Context, sql string) (bool, error) {
+	if storage.db == nil {
+		return false, ErrNotConnected
+	}
+
+	rows, err := storage.querier().QueryContext(ctx, sql)
+	if err != nil {
+		storage.logger.Error("Failed to run probe: %v", err)
+		return false, err
+	}
+	defer rows.Close()
+
+	var applied bool
+	if rows.Next() {
+		if err := rows.Scan(&applied); err != nil {
+			storage.logger.Error("Failed to run probe: %v", err)
+			return false, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		storage.logger.Error("Failed to run probe: %v", err)
+		return false, err
+	}
+
+	return applied, nil
+}
+
+func (storage *SQLStorage) Close() error {
+	if !storage.ownsDB {
+		storage.logger.Info("Leaving borrowed database handle open")
+		return nil
+	}
+
+	storage.logger.Info("Closing database handle")
+	if storage.db != nil {
+		return storage.db.Close()
+	}
+	return nil
+}
+
+// Lock вставляет строку в migration_lock. Если строка уже есть, блокировку держит другой мигратор:
+// Lock повторяет попытки не дольше WithSQLLockWait и возвращает ErrLockHeld.
+func (storage *SQLStorage) Lock(ctx context.Context) error {
+	storage.logger.Info("Acquiring lock in %s table", lockTableName)
+
+	deadline := time.Now().Add(storage.lockWait)
+	for {
+		_, err := storage.db.ExecContext(ctx, storage.rebind(`INSERT INTO `+lockTableName+` (Id, LockedAt) VALUES (1, ?)`), time.Now().UTC())
+		if err == nil {
+			return nil
+		}
+
+		held, checkErr := storage.lockHeld(ctx)
+		if checkErr != nil || !held {
+			storage.logger.Error("Failed to acquire lock: %v", err)
+			return err
+		}
+		if !time.Now().Before(deadline) {
+			storage.logger.Error("Failed to acquire lock: %v", ErrLockHeld)
+			return ErrLockHeld
+		}
+
+		storage.logger.Info("Lock is held by another migrator, waiting")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// lockHeld отличает занятую блокировку от других ошибок вставки без разбора кодов ошибок драйвера
+func (storage *SQLStorage) lockHeld(ctx context.Context) (bool, error) {
+	var count int
+	err := storage.db.QueryRowContext(ctx, `SELECT COUNT(*) FROM `+lockTableName+` WHERE Id = 1`).Scan(&count)
+	return count > 0, err
+}
+
+func (storage *SQLStorage) Unlock(ctx context.Context) error {
+	storage.logger.Info("Releasing lock in %s table", lockTableName)
+
+	if storage.tx != nil {
+		storage.logger.Warn("Run transaction is still open on unlock, rolling it back")
+		storage.Rollback(ctx)
+	}
+
+	_, err := storage.db.ExecContext(ctx, `DELETE FROM `+lockTableName+` WHERE Id = 1`)
+	if err != nil {
+		storage.logger.Error("Failed to release lock: %v", err)
+	}
+	return err
+}
+
+func (storage *SQLStorage) TableName() string {
+	return storage.tableName
+}
+
+// querier возвращает открытую транзакцию запуска либо *sql.DB
+func (storage *SQLStorage) querier() sqlQuerier {
+	if storage.tx != nil {
+		return storage.tx
+	}
+	return storage.db
+}
+
+// rebind заменяет ? на параметры вида $n, если драйвер их ожидает
+func (storage *SQLStorage) rebind(query string) string {
+	if storage.placeholder != PlaceholderDollar {
+		return query
+	}
+
+	var builder strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			builder.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		builder.WriteRune(r)
+	}
+	return builder.String()
+}
+
+func (storage *SQLStorage) Begin(ctx context.Context) error {
+	if storage.tx != nil {
+		return nil
+	}
+
+	tx, err := storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		storage.logger.Error("Failed to begin run transaction: %v", err)
+		return err
+	}
+
+	storage.tx = tx
+	return nil
+}
+
+func (storage *SQLStorage) Commit(ctx context.Context) error {
+	if storage.tx == nil {
+		return nil
+	}
+
+	tx := storage.tx
+	storage.tx = nil
+	if err := tx.Commit(); err != nil {
+		storage.logger.Error("Failed to commit run transaction: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (storage *SQLStorage) Rollback(ctx context.Context) error {
+	if storage.tx == nil {
+		return nil
+	}
+
+	tx := storage.tx
+	storage.tx = nil
+	if err := tx.Rollback(); err != nil {
+		storage.logger.Error("Failed to roll back run transaction: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (storage *SQLStorage) InTransaction() bool {
+	return storage.tx != nil
+}
+
+// Migrate выполняет выражения миграции по одному, потому что не все драйверы принимают несколько выражений в запросе.
+// Без транзакции выражения идут через одно соединение, чтобы собственные BEGIN/COMMIT миграции работали.
+func (storage *SQLStorage) Migrate(ctx context.Context, sql string) error {
+	storage.logger.Info("Executing migration SQL")
+
+	var err error
+	switch {
+	case usesPsql(sql):
+		err = fmt.Errorf("%w: %s", ErrNotSupported, PsqlMarker)
+	case isNonTransactional(sql) && storage.tx != nil:
+		err = ErrNonTransactionalMigration
+	case hasTransactionControl(sql) && storage.tx != nil:
+		err = fmt.Errorf("%w: migration manages its own transaction", ErrNonTransactionalMigration)
+	case storage.tx != nil:
+		err = execStatements(ctx, storage.tx, sql)
+	case storage.transaction && !isNonTransactional(sql) && !hasTransactionControl(sql):
+		err = storage.migrateInTransaction(ctx, sql)
+	default:
+		err = storage.migrateOnConn(ctx, sql)
+	}
+	if err != nil {
+		storage.logger.Error("Failed to execute migration SQL: %v", err)
+	}
+	return err
+}
+
+func (storage *SQLStorage) migrateInTransaction(ctx context.Context, sql string) error {
+	tx, err := storage.db.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+
+	if err := execStatements(ctx, tx, sql); err != nil {
+		if rollbackErr := tx.Rollback(); rollbackErr != nil {
+			storage.logger.Warn("Failed to roll back migration transaction: %v", rollbackErr)
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func (storage *SQLStorage) migrateOnConn(ctx context.Context, sql string) error {
+	conn, err := storage.db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	return execStatements(ctx, conn, sql)
+}
+
+func execStatements(ctx context.Context, querier sqlQuerier, sql string) error {
+	for _, statement := range splitStatements(sql) {
+		if strings.TrimSpace(statement.code) == "" {
+			continue
+		}
+		if _, err := querier.ExecContext(ctx, statement.text); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (storage *SQLStorage) DeleteMigrations(ctx context.Context) error {
+	storage.logger.Info("Deleting all migrations from %s table", storage.tableName)
+	_, err := storage.querier().ExecContext(ctx, `DELETE FROM `+storage.tableName)
+	if err != nil {
+		storage.logger.Error("Failed to delete migrations: %v", err)
+	}
+	return err
+}
+
+func (storage *SQLStorage) DeleteMigrationsByStatus(ctx context.Context, statuses ...string) error {
+	if len(statuses) == 0 {
+		return nil
+	}
+
+	args := make([]interface{}, 0, len(statuses))
+	for _, status := range statuses {
+		if !IsKnownStatus(status) {
+			storage.logger.Error("Failed to delete migrations: %v: %s", ErrUnexpectedStatus, status)
+			return ErrUnexpectedStatus
+		}
+		args = append(args, status)
+	}
+
+	storage.logger.Info("Deleting migrations with statuses %v from %s table", statuses, storage.tableName)
+	query := `DELETE FROM ` + storage.tableName + ` WHERE Status IN (?` + strings.Repeat(", ?", len(statuses)-1) + `)`
+	_, err := storage.querier().ExecContext(ctx, storage.rebind(query), args...)
+	if err != nil {
+		storage.logger.Error("Failed to delete migrations: %v", err)
+	}
+	return err
+}
+
+// selectRows читает строки учета миграций в порядке запроса
+func (storage *SQLStorage) selectRows(ctx context.Context, query string, args ...interface{}) ([]IMigration, error) {
+	rows, err := storage.querier().QueryContext(ctx, storage.rebind(query), args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var migrations []IMigration
+	for rows.Next() {
+		var (
+			name             string
+			version          int
+			status           string
+			statusChangeTime time.Time
+			appliedSQL       string
+		)
+
+		if err := rows.Scan(&name, &status, &version, &statusChangeTime, &appliedSQL); err != nil {
+			return nil, err
+		}
+
+		migration := NewMigration(name, status, version, statusChangeTime.UTC())
+		migration.SetAppliedSQL(appliedSQL)
+		migrations = append(migrations, migration)
+	}
+
+	return migrations, rows.Err()
+}
+
+func (storage *SQLStorage) SelectMigrations(ctx context.Context) ([]IMigration, error) {
+	storage.logger.Info("Selecting all migrations from %s table", storage.tableName)
+
+	migrations, err := storage.selectRows(ctx, `SELECT `+migrationColumns+` FROM `+storage.tableName+` ORDER BY Version DESC`)
+	if err != nil {
+		storage.logger.Error("Failed to select migrations: %v", err)
+		return nil, err
+	}
+
+	if len(migrations) == 0 {
+		storage.logger.Warn("No migrations found")
+		return nil, ErrMigrationNotFound
+	}
+
+	return migrations, nil
+}
+
+func (storage *SQLStorage) SelectMigrationsSince(ctx context.Context, since time.Time) ([]IMigration, error) {
+	storage.logger.Info("Selecting migrations changed since %s from %s table", since.Format(time.RFC3339), storage.tableName)
+
+	migrations, err := storage.selectRows(ctx, `SELECT `+migrationColumns+` FROM `+storage.tableName+` WHERE StatusChangeTime >= ? ORDER BY Version DESC`, since.UTC())
+	if err != nil {
+		storage.logger.Error("Failed to select migrations: %v", err)
+		return nil, err
+	}
+
+	if len(migrations) == 0 {
+		storage.logger.Warn("No migrations found")
+		return nil, ErrMigrationNotFound
+	}
+
+	return migrations, nil
+}
+
+// SelectLastMigrationByStatus выбирает строку с наибольшей версией без LIMIT, синтаксис которого у баз разный
+func (storage *SQLStorage) SelectLastMigrationByStatus(ctx context.Context, status string) (IMigration, error) {
+	storage.logger.Info("Selecting last migration with status: %s", status)
+
+	if !IsKnownStatus(status) {
+		storage.logger.Error("Unexpected status: %s", status)
+		return nil, ErrUnexpectedStatus
+	}
+
+	migrations, err := storage.selectRows(ctx, `SELECT `+migrationColumns+` FROM `+storage.tableName+
+		` WHERE Version = (SELECT MAX(Version) FROM `+storage.tableName+` WHERE Status = ?)`, status)
+	if err != nil {
+		storage.logger.Error("Failed to select last migration by status: %v", err)
+		return nil, err
+	}
+
+	if len(migrations) == 0 {
+		storage.logger.Warn("No migration found with status: %s", status)
+		return nil, ErrMigrationNotFound
+	}
+
+	return migrations[0], nil
+}
+
+func (storage *SQLStorage) SelectMigrationByVersion(ctx context.Context, version int) (IMigration, error) {
+	storage.logger.Info("Selecting migration with version: %d", version)
+
+	migrations, err := storage.selectRows(ctx, `SELECT `+migrationColumns+` FROM `+storage.tableName+` WHERE Version = ?`, version)
+	if err != nil {
+		storage.logger.Error("Failed to select migration by version: %v", err)
+		return nil, err
+	}
+
+	if len(migrations) == 0 {
+		storage.logger.Warn("No migration found with version: %d", version)
+		return nil, ErrMigrationNotFound
+	}
+
+	return migrations[0], nil
+}
+
+// InsertMigration обновляет строку версии, а если ее нет — вставляет: ON CONFLICT есть не во всех базах.
+// Гонки нет, потому что запись идет под блокировкой.
+func (storage *SQLStorage) InsertMigration(ctx context.Context, migration IMigration) error {
+	storage.logger.Info("Inserting/updating migration: %s", migration.GetName())
+
+	if err := storage.upsertMigration(ctx, migration); err != nil {
+		storage.logger.Error("Failed to insert/update migration: %v", err)
+		return err
+	}
+	return nil
+}
+
+func (storage *SQLStorage) upsertMigration(ctx context.Context, migration IMigration) error {
+	changeTime := migration.GetStatusChangeTime().UTC()
+
+	appliedSQL := nullString(migration.GetAppliedSQL())
+
+	result, err := storage.querier().ExecContext(ctx, storage.rebind(`UPDATE `+storage.tableName+` SET Name = ?, Status = ?, StatusChangeTime = ?, AppliedSQL = COALESCE(?, AppliedSQL) WHERE Version = ?`),
+		migration.GetName(), migration.GetStatus(), changeTime, appliedSQL, migration.GetVersion())
+	if err != nil {
+		return err
+	}
+
+	updated, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if updated > 0 {
+		return nil
+	}
+
+	_, err = storage.querier().ExecContext(ctx, storage.rebind(`INSERT INTO `+storage.tableName+` (Version, Name, Status, StatusChangeTime, AppliedSQL) VALUES (?, ?, ?, ?, ?)`),
+		migration.GetVersion(), migration.GetName(), migration.GetStatus(), changeTime, appliedSQL)
+	return err
+}
+
+func (storage *SQLStorage) InsertMigrationEvent(ctx context.Context, migration IMigration) error {
+	storage.logger.Debug("Recording migration event: %s -> %s", migration.GetName(), migration.GetStatus())
+
+	_, err := storage.querier().ExecContext(ctx, storage.rebind(`INSERT INTO `+EventsTableName(storage.tableName)+` (Version, Name, Status, StatusChangeTime) VALUES (?, ?, ?, ?)`),
+		migration.GetVersion(), migration.GetName(), migration.GetStatus(), migration.GetStatusChangeTime().UTC())
+	if err != nil {
+		storage.logger.Error("Failed to record migration event: %v", err)
+	}
+	return err
+}
+
+// InsertMigrations сохраняет статусы и события нескольких миграций; пакетов в database/sql нет, запросы идут по одному
+func (storage *SQLStorage) InsertMigrations(ctx context.Context, migrations []IMigration) error {
+	for _, migration := range migrations {
+		if err := storage.InsertMigration(ctx, migration); err != nil {
+			return err
+		}
+		if err := storage.InsertMigrationEvent(ctx, migration); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (storage *SQLStorage) SelectMigrationEvents(ctx context.Context) ([]IMigration, error) {
+	storage.logger.Info("Selecting migration events from %s table", EventsTableName(storage.tableName))
+
+	events, err := storage.selectRows(ctx, `SELECT Name, Status, Version, StatusChangeTime, '' FROM `+EventsTableName(storage.tableName)+` ORDER BY StatusChangeTime, Version`)
+	if err != nil {
+		storage.logger.Error("Failed to select migration events: %v", err)
+		return nil, err
+	}
+	return events, nil
+}
+
+// SelectColumns не поддерживается: состав information_schema и понятие текущей схемы у баз разные
+func (storage *SQLStorage) SelectColumns(ctx context.Context) ([]schema.Column, error) {
+	return nil, fmt.Errorf("%w: schema introspection", ErrNotSupported)
+}
+
+// Notify не поддерживается: LISTEN/NOTIFY есть только в Postgres
+func (storage *SQLStorage) Notify(ctx context.Context, channel, payload string) error {
+	return fmt.Errorf("%w: notify", ErrNotSupported)
+}
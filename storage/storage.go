@@ -3,26 +3,101 @@ package storage
 import (
 	"context"
 	"errors"
+	"fmt"
+	"hash/fnv"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
 	"time"
 
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"github.com/juliazadorozhnaya/sql-migrator/logger"
+	"github.com/juliazadorozhnaya/sql-migrator/schema"
 )
 
 // advisoryLockID — это идентификатор, используемый для создания уникальной блокировки.
 // Он должен быть уникальным для приложения и не пересекаться с другими существующими возможными блокировками в бд.
+// Используется по умолчанию; ключ из имени базы включается WithLockPerDatabase, см. lockKeyFor.
 const advisoryLockID = 123456
 
+const DefaultTableName = "schema_migrations"
+
+const (
+	// LockModeSession держит блокировку до явного Unlock через pg_advisory_lock
+	LockModeSession = "session"
+	// LockModeTransaction берет pg_advisory_xact_lock внутри транзакции, которая охватывает весь запуск:
+	// блокировка снимается при COMMIT или ROLLBACK, в том числе при обрыве соединения
+	LockModeTransaction = "transaction"
+)
+
+// IsKnownLockMode проверяет, что режим блокировки поддерживается
+func IsKnownLockMode(lockMode string) bool {
+	return lockMode == LockModeSession || lockMode == LockModeTransaction
+}
+
+// migrationColumns — колонки строки учета в порядке, в котором их читает scanMigration
+const migrationColumns = `Name, Status, Version, StatusChangeTime, COALESCE(AppliedSQL, '')`
+
+// DefaultEventsTableName — журнал переходов таблицы учета по умолчанию; имя сохранено для уже созданных баз
+const DefaultEventsTableName = "migration_events"
+
+// EventsTableName возвращает журнал переходов таблицы учета tableName: для таблицы по умолчанию — migration_events,
+// для остальных — <таблица>_events в той же схеме, чтобы миграторы с разными table_name не смешивали журналы
+func EventsTableName(tableName string) string {
+	schema, table, qualified := strings.Cut(tableName, ".")
+	if !qualified {
+		schema, table = "", tableName
+	}
+
+	events := table + "_events"
+	if table == DefaultTableName {
+		events = DefaultEventsTableName
+	}
+	if qualified {
+		return schema + "." + events
+	}
+	return events
+}
+
 type SqlStorage interface {
 	Connect(ctx context.Context) error
 	Close() error
+	// Ping проверяет доступность базы без запросов к таблицам мигратора
+	Ping(ctx context.Context) error
+	// ServerVersion возвращает версию сервера в формате server_version_num, например 140005 для 14.5
+	ServerVersion(ctx context.Context) (int, error)
+	// Probe выполняет проверочный запрос миграции и возвращает его единственное логическое значение
+	Probe(ctx context.Context, sql string) (bool, error)
 	Lock(ctx context.Context) error
 	Unlock(ctx context.Context) error
 	InsertMigration(ctx context.Context, migration IMigration) error
 	Migrate(ctx context.Context, sql string) error
 	SelectMigrations(ctx context.Context) ([]IMigration, error)
+	// SelectMigrationsSince возвращает строки, статус которых менялся не раньше since
+	SelectMigrationsSince(ctx context.Context, since time.Time) ([]IMigration, error)
 	SelectLastMigrationByStatus(ctx context.Context, status string) (IMigration, error)
+	SelectMigrationByVersion(ctx context.Context, version int) (IMigration, error)
 	DeleteMigrations(ctx context.Context) error
+	// DeleteMigrationsByStatus удаляет из таблицы учета только строки с указанными статусами
+	DeleteMigrationsByStatus(ctx context.Context, statuses ...string) error
+	TableName() string
+	InsertMigrationEvent(ctx context.Context, migration IMigration) error
+	InsertMigrations(ctx context.Context, migrations []IMigration) error
+	SelectMigrationEvents(ctx context.Context) ([]IMigration, error)
+	// SelectColumns возвращает колонки таблиц базы для сравнения с желаемой схемой
+	SelectColumns(ctx context.Context) ([]schema.Column, error)
+	// Notify отправляет payload слушателям канала channel
+	Notify(ctx context.Context, channel, payload string) error
+	// Begin, Commit и Rollback управляют общей транзакцией запуска, вызываются между Lock и Unlock
+	Begin(ctx context.Context) error
+	Commit(ctx context.Context) error
+	Rollback(ctx context.Context) error
+	// InTransaction сообщает, идет ли запуск внутри общей транзакции: открытой Begin или транзакции блокировки
+	InTransaction() bool
 }
 
 const (
@@ -31,56 +106,496 @@ const (
 	StatusError        = "error"
 	StatusCancellation = "cancellation"
 	StatusCancel       = "cancel"
+	StatusSkipped      = "skipped"
+	StatusOutOfOrder   = "out_of_order"
 )
 
+// IsKnownStatus проверяет, что статус входит в число статусов, которые пишет мигратор
+func IsKnownStatus(status string) bool {
+	switch status {
+	case StatusSuccess, StatusError, StatusProcess, StatusCancellation, StatusCancel, StatusSkipped, StatusOutOfOrder:
+		return true
+	default:
+		return false
+	}
+}
+
+// executor выполняет запросы через пул или через захваченное из него соединение
+type executor interface {
+	Exec(ctx context.Context, sql string, arguments ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+	SendBatch(ctx context.Context, b *pgx.Batch) pgx.BatchResults
+}
+
+// pgxConn — соединение, удерживаемое хранилищем между Lock и Unlock
+type pgxConn interface {
+	executor
+	Release()
+}
+
+// pgxPool — подмножество методов *pgxpool.Pool, используемых хранилищем
+type pgxPool interface {
+	executor
+	AcquireConn(ctx context.Context) (pgxConn, error)
+	Ping(ctx context.Context) error
+	Close()
+}
+
+type poolAdapter struct {
+	*pgxpool.Pool
+}
+
+func (p poolAdapter) AcquireConn(ctx context.Context) (pgxConn, error) {
+	conn, err := p.Acquire(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return conn, nil
+}
+
 type PostgresStorage struct {
-	connString string
-	pool       *pgxpool.Pool
-	logger     logger.Logger
+	connString  string
+	tls         TLSConfig
+	maxConns    int32
+	tableName   string
+	pool        pgxPool
+	conn        pgxConn
+	ownsPool    bool
+	transaction bool
+	lockMode    string
+	inTx        bool
+	lockDepth   int
+	appName     string
+	lockWait    time.Duration
+	// database — имя базы из DSN, из которого выводится ключ блокировки при lockPerDatabase; lockKey, если задан, заменяет его
+	database        string
+	lockKey         int64
+	lockPerDatabase bool
+	psqlPath        string
+	logger          logger.Logger
+}
+
+type Option func(*PostgresStorage)
+
+// TLSConfig — параметры SSL/TLS, дополняющие DSN. Пустые поля оставляют значения из DSN.
+type TLSConfig struct {
+	SSLMode     string
+	SSLRootCert string
+	SSLCert     string
+	SSLKey      string
+}
+
+// WithMaxConns задает размер пула. По умолчанию используется одно соединение,
+// чтобы сессионная advisory-блокировка, миграции и снятие блокировки шли через одну сессию.
+func WithMaxConns(maxConns int32) Option {
+	return func(storage *PostgresStorage) {
+		if maxConns > 0 {
+			storage.maxConns = maxConns
+		}
+	}
+}
+
+// WithTableName задает таблицу учета миграций, в том числе с указанием схемы: "public.schema_migrations"
+func WithTableName(tableName string) Option {
+	return func(storage *PostgresStorage) {
+		if tableName != "" {
+			storage.tableName = tableName
+		}
+	}
+}
+
+// WithLockMode задает область действия advisory-блокировки: LockModeSession или LockModeTransaction
+func WithLockMode(lockMode string) Option {
+	return func(storage *PostgresStorage) {
+		if lockMode != "" {
+			storage.lockMode = lockMode
+		}
+	}
+}
+
+// WithLockWait задает, сколько ждать блокировку, которую держит другой мигратор; 0 — сразу вернуть ErrLockHeld
+func WithLockWait(lockWait time.Duration) Option {
+	return func(storage *PostgresStorage) {
+		storage.lockWait = lockWait
+	}
+}
+
+// DefaultAppName — application_name сессий мигратора в pg_stat_activity
+const DefaultAppName = "sql-migrator"
+
+// WithLockKey задает ключ advisory-блокировки; 0 — advisoryLockID или ключ из имени базы при WithLockPerDatabase
+func WithLockKey(lockKey int64) Option {
+	return func(storage *PostgresStorage) {
+		storage.lockKey = lockKey
+	}
+}
+
+// WithLockPerDatabase выводит ключ advisory-блокировки из имени базы вместо общего advisoryLockID.
+// Мигратор со старым ключом не увидит такую блокировку, поэтому включать ее нужно на всех экземплярах сразу.
+func WithLockPerDatabase(lockPerDatabase bool) Option {
+	return func(storage *PostgresStorage) {
+		storage.lockPerDatabase = lockPerDatabase
+	}
+}
+
+// WithAppName задает application_name сессий мигратора; application_name из DSN имеет приоритет
+func WithAppName(appName string) Option {
+	return func(storage *PostgresStorage) {
+		if appName != "" {
+			storage.appName = appName
+		}
+	}
+}
+
+func WithTLS(tls TLSConfig) Option {
+	return func(storage *PostgresStorage) {
+		storage.tls = tls
+	}
 }
 
 var (
-	ErrUnexpectedStatus  = errors.New("unexpected status")
-	ErrMigrationNotFound = errors.New("processes not found")
+	ErrUnexpectedStatus     = errors.New("unexpected status")
+	ErrMigrationNotFound    = errors.New("processes not found")
+	ErrInvalidConnString    = errors.New("invalid connection string")
+	ErrUnknownLockMode      = errors.New("unknown lock mode, use session or transaction")
+	ErrNotConnected         = errors.New("storage is not connected")
+	ErrLockHeld             = errors.New("advisory lock is held by another migrator, retry later or pass -wait-for-lock")
+	ErrInvalidServerVersion = errors.New("invalid server version")
 )
 
-func New(connString string, logger logger.Logger) *PostgresStorage {
-	return &PostgresStorage{
+func New(connString string, logger logger.Logger, opts ...Option) *PostgresStorage {
+	storage := &PostgresStorage{
 		connString: connString,
+		maxConns:   1,
+		tableName:  DefaultTableName,
+		lockMode:   LockModeSession,
+		appName:    DefaultAppName,
+		ownsPool:   true,
+		database:   databaseName(connString),
 		logger:     logger,
 	}
+
+	for _, opt := range opts {
+		opt(storage)
+	}
+
+	return storage
+}
+
+// NewWithPool создает хранилище поверх уже открытого пула соединений.
+// Такой пул принадлежит вызывающему коду: Connect только создает служебную таблицу, а Close его не закрывает.
+func NewWithPool(pool *pgxpool.Pool, logger logger.Logger, opts ...Option) *PostgresStorage {
+	storage := newWithPool(poolAdapter{pool}, logger, opts...)
+	storage.database = pool.Config().ConnConfig.Database
+	return storage
+}
+
+func newWithPool(pool pgxPool, logger logger.Logger, opts ...Option) *PostgresStorage {
+	storage := New("", logger, opts...)
+	storage.pool = pool
+	storage.ownsPool = false
+
+	return storage
 }
 
 func (storage *PostgresStorage) Connect(ctx context.Context) error {
-	storage.logger.Info("Connecting to the database")
+	if err := storage.open(ctx); err != nil {
+		return err
+	}
+
+	if err := storage.createTable(ctx, storage.pool); err != nil {
+		if storage.ownsPool {
+			storage.pool.Close()
+			storage.pool = nil
+		}
+		return err
+	}
+
+	if storage.ownsPool {
+		storage.logger.Info("Connected to the database and ensured %s table exists", storage.tableName)
+	}
+	return nil
+}
+
+// open подключает пул и проверяет соединение, не создавая таблиц учета
+func (storage *PostgresStorage) open(ctx context.Context) error {
+	if !storage.ownsPool {
+		storage.logger.Info("Using borrowed database connection pool")
+		return storage.Ping(ctx)
+	}
+
+	storage.logger.Info("Connecting to the database %s", RedactDSN(storage.connString))
 
-	pool, err := pgxpool.Connect(ctx, storage.connString)
+	poolConfig, err := storage.poolConfig()
 	if err != nil {
+		storage.logger.Error("Failed to parse connection string: %v", err)
+		return err
+	}
+
+	pool, err := pgxpool.ConnectConfig(ctx, poolConfig)
+	if err != nil {
+		err = describeConnectError(err)
 		storage.logger.Error("Failed to connect to the database: %v", err)
 		return err
 	}
 
-	sql := `
-		CREATE TABLE IF NOT EXISTS schema_migrations (
+	storage.pool = poolAdapter{pool}
+	if err = storage.Ping(ctx); err != nil {
+		pool.Close()
+		storage.pool = nil
+		return err
+	}
+
+	return nil
+}
+
+// ConnString возвращает DSN хранилища вместе с настройками TLS: с ним Go-миграция, запущенная через go run,
+// подключается к той же базе. Содержит пароль, в логи не выводится.
+func (storage *PostgresStorage) ConnString() string {
+	return storage.dsn()
+}
+
+// dsn возвращает DSN, дополненный настройками TLS из опций хранилища
+func (storage *PostgresStorage) dsn() string {
+	return mergeConnParams(storage.connString, map[string]string{
+		"sslmode":     storage.tls.SSLMode,
+		"sslrootcert": storage.tls.SSLRootCert,
+		"sslcert":     storage.tls.SSLCert,
+		"sslkey":      storage.tls.SSLKey,
+	})
+}
+
+// poolConfig разбирает DSN и дополняет его настройками из опций хранилища
+func (storage *PostgresStorage) poolConfig() (*pgxpool.Config, error) {
+	connString := storage.dsn()
+
+	config, err := pgxpool.ParseConfig(connString)
+	if err != nil {
+		// Ошибки разбора URL содержат исходную строку вместе с паролем
+		message := strings.ReplaceAll(err.Error(), connString, RedactDSN(connString))
+		return nil, fmt.Errorf("%w: %s", ErrInvalidConnString, message)
+	}
+
+	if _, ok := config.ConnConfig.RuntimeParams["application_name"]; !ok {
+		config.ConnConfig.RuntimeParams["application_name"] = storage.appName
+	}
+
+	config.MaxConns = storage.maxConns
+	if config.MinConns > config.MaxConns {
+		config.MinConns = config.MaxConns
+	}
+
+	return config, nil
+}
+
+// mergeConnParams дописывает непустые параметры в DSN как в URL-формате, так и в формате key=value
+func mergeConnParams(connString string, params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key, value := range params {
+		if value != "" {
+			keys = append(keys, key)
+		}
+	}
+	if len(keys) == 0 {
+		return connString
+	}
+	sort.Strings(keys)
+
+	if strings.HasPrefix(connString, "postgres://") || strings.HasPrefix(connString, "postgresql://") {
+		if u, err := url.Parse(connString); err == nil {
+			query := u.Query()
+			for _, key := range keys {
+				query.Set(key, params[key])
+			}
+			u.RawQuery = query.Encode()
+			return u.String()
+		}
+	}
+
+	for _, key := range keys {
+		value := strings.ReplaceAll(params[key], `\`, `\\`)
+		value = strings.ReplaceAll(value, `'`, `\'`)
+		connString = strings.TrimSpace(connString + fmt.Sprintf(" %s='%s'", key, value))
+	}
+	return connString
+}
+
+const redactedPassword = "xxxxx"
+
+var (
+	regURLPassword      = regexp.MustCompile(`^([a-z]+://[^:/@]*:)[^@/]*@`)
+	regURLQueryPassword = regexp.MustCompile(`([?&]password=)[^&#]*`)
+	regKVPassword       = regexp.MustCompile(`(password\s*=\s*)('(?:\\.|[^'])*'|\S+)`)
+)
+
+// RedactDSN маскирует пароль в DSN, чтобы строку подключения можно было выводить в лог
+func RedactDSN(dsn string) string {
+	if strings.HasPrefix(dsn, "postgres://") || strings.HasPrefix(dsn, "postgresql://") {
+		dsn = regURLPassword.ReplaceAllString(dsn, "${1}"+redactedPassword+"@")
+		return regURLQueryPassword.ReplaceAllString(dsn, "${1}"+redactedPassword)
+	}
+
+	return regKVPassword.ReplaceAllString(dsn, "${1}"+redactedPassword)
+}
+
+// setupLockID — ключ блокировки, на время которой создаются и обновляются таблицы учета. Он отличается от ключа
+// блокировки миграций, чтобы Connect не ждал окончания миграций другого процесса.
+const setupLockID = advisoryLockID + 1
+
+const (
+	codeDuplicateTable  = "42P07"
+	codeUniqueViolation = "23505"
+)
+
+// createTable создает и обновляет таблицы учета. Несколько выражений в одном запросе выполняются в неявной
+// транзакции, поэтому блокировка setupLockID держится до их конца и одновременные Connect выполняют их по очереди.
+// Миграторы старых версий создают таблицы без блокировки, поэтому гонка CREATE TABLE IF NOT EXISTS
+// (ошибка "already exists") тоже допускается: запрос повторяется, когда таблицы уже созданы.
+func (storage *PostgresStorage) createTable(ctx context.Context, pool executor) error {
+	sql := fmt.Sprintf("SELECT pg_advisory_xact_lock(%d);", setupLockID) + `
+		CREATE TABLE IF NOT EXISTS ` + storage.table() + ` (
 			Version INTEGER PRIMARY KEY,
 			Name CHARACTER VARYING(100),
 			Status CHARACTER VARYING(20),
-			StatusChangeTime TIMESTAMP
-		);`
+			StatusChangeTime TIMESTAMPTZ
+		);
+		CREATE TABLE IF NOT EXISTS ` + storage.eventsTable() + ` (
+			Id SERIAL PRIMARY KEY,
+			Version INTEGER,
+			Name CHARACTER VARYING(100),
+			Status CHARACTER VARYING(20),
+			StatusChangeTime TIMESTAMPTZ
+		);` + upgradeTimestampsSQL(storage.table(), storage.eventsTable()) + addAppliedSQLColumnSQL(storage.table())
 
-	_, err = pool.Exec(ctx, sql)
+	_, err := pool.Exec(ctx, sql)
+	if isAlreadyExists(err) {
+		storage.logger.Warn("Migrations tables were created concurrently, retrying: %v", err)
+		_, err = pool.Exec(ctx, sql)
+	}
 	if err != nil {
-		storage.logger.Error("Failed to create schema_migrations table: %v", err)
-		pool.Close()
-		return err
+		storage.logger.Error("Failed to create migrations tables: %v", err)
 	}
+	return err
+}
 
-	storage.pool = pool
-	storage.logger.Info("Connected to the database and ensured schema_migrations table exists")
+// isAlreadyExists проверяет, что ошибка — проигранная гонка одновременного создания таблицы
+func isAlreadyExists(err error) bool {
+	var pgErr *pgconn.PgError
+	if !errors.As(err, &pgErr) {
+		return false
+	}
+	return pgErr.Code == codeDuplicateTable || pgErr.Code == codeUniqueViolation
+}
+
+// upgradeTimestampsSQL переводит StatusChangeTime таблиц, созданных старыми версиями, с TIMESTAMP на TIMESTAMPTZ.
+// Старые значения записаны без зоны, поэтому трактуются в часовом поясе сессии.
+func upgradeTimestampsSQL(tables ...string) string {
+	sql := "\n\t\tDO $$\n\t\tBEGIN\n"
+	for _, table := range tables {
+		literal := strings.ReplaceAll(table, "'", "''")
+		sql += `			IF (SELECT atttypid FROM pg_attribute WHERE attrelid = to_regclass('` + literal + `') AND attname = 'statuschangetime') = 'timestamp'::regtype THEN
+				ALTER TABLE ` + table + ` ALTER COLUMN StatusChangeTime TYPE TIMESTAMPTZ;
+			END IF;
+`
+	}
+	return sql + "\t\tEND $$;"
+}
+
+// addAppliedSQLColumnSQL добавляет колонку AppliedSQL в таблицы, созданные старыми версиями. Каталог проверяется
+// заранее, чтобы ALTER TABLE не брал блокировку таблицы, когда колонка уже есть.
+func addAppliedSQLColumnSQL(table string) string {
+	literal := strings.ReplaceAll(table, "'", "''")
+	return `
+		DO $$
+		BEGIN
+			IF NOT EXISTS (SELECT 1 FROM pg_attribute WHERE attrelid = to_regclass('` + literal + `') AND attname = 'appliedsql' AND NOT attisdropped) THEN
+				ALTER TABLE ` + table + ` ADD COLUMN IF NOT EXISTS AppliedSQL TEXT;
+			END IF;
+		END $$;`
+}
+
+func (storage *PostgresStorage) Ping(ctx context.Context) error {
+	if storage.pool == nil {
+		return ErrNotConnected
+	}
+
+	if err := storage.pool.Ping(ctx); err != nil {
+		err = describeConnectError(err)
+		storage.logger.Error("Failed to ping the database: %v", err)
+		return err
+	}
 	return nil
 }
 
+func (storage *PostgresStorage) ServerVersion(ctx context.Context) (int, error) {
+	if storage.pool == nil {
+		return 0, ErrNotConnected
+	}
+
+	rows, err := storage.executor().Query(ctx, "SHOW server_version_num;")
+	if err != nil {
+		storage.logger.Error("Failed to get server version: %v", err)
+		return 0, err
+	}
+	defer rows.Close()
+
+	var version string
+	if rows.Next() {
+		if err := rows.Scan(&version); err != nil {
+			storage.logger.Error("Failed to get server version: %v", err)
+			return 0, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		storage.logger.Error("Failed to get server version: %v", err)
+		return 0, err
+	}
+
+	return parseServerVersion(version)
+}
+
+func (storage *PostgresStorage) Probe(ctx context.Context, sql string) (bool, error) {
+	if storage.pool == nil {
+		return false, ErrNotConnected
+	}
+
+	rows, err := storage.executor().Query(ctx, sql)
+	if err != nil {
+		storage.logger.Error("Failed to run probe: %v", err)
+		return false, err
+	}
+	defer rows.Close()
+
+	var applied bool
+	if rows.Next() {
+		if err := rows.Scan(&applied); err != nil {
+			storage.logger.Error("Failed to run probe: %v", err)
+			return false, err
+		}
+	}
+	if err := rows.Err(); err != nil {
+		storage.logger.Error("Failed to run probe: %v", err)
+		return false, err
+	}
+
+	return applied, nil
+}
+
+// parseServerVersion разбирает значение server_version_num
+func parseServerVersion(version string) (int, error) {
+	num, err := strconv.Atoi(strings.TrimSpace(version))
+	if err != nil {
+		return 0, fmt.Errorf("%w: %q", ErrInvalidServerVersion, version)
+	}
+	return num, nil
+}
+
 func (storage *PostgresStorage) Close() error {
+	if !storage.ownsPool {
+		storage.logger.Info("Leaving borrowed database connection pool open")
+		return nil
+	}
+
 	storage.logger.Info("Closing database connection pool")
 
 	if storage.pool != nil {
@@ -90,27 +605,268 @@ func (storage *PostgresStorage) Close() error {
 	return nil
 }
 
+// Lock захватывает соединение из пула и берет на нем сессионную advisory-блокировку.
+// До Unlock все запросы хранилища идут через это соединение. Если блокировку держит другой мигратор,
+// Lock ждет ее освобождения не дольше WithLockWait и возвращает ErrLockHeld.
+// Повторный Lock того же хранилища, например Up внутри Up у встраивающего приложения, не берет
+// блокировку еще раз, а увеличивает глубину: снимает ее только Unlock, парный первому Lock.
 func (storage *PostgresStorage) Lock(ctx context.Context) error {
+	if storage.conn != nil {
+		storage.lockDepth++
+		storage.logger.Info("Advisory lock is already held by this migrator, nesting depth %d", storage.lockDepth)
+		return nil
+	}
+
+	if storage.pool == nil {
+		return ErrNotConnected
+	}
+
 	storage.logger.Info("Acquiring advisory lock")
-	_, err := storage.pool.Exec(ctx, "SELECT pg_advisory_lock($1);", advisoryLockID)
+
+	conn, err := storage.pool.AcquireConn(ctx)
 	if err != nil {
+		storage.logger.Error("Failed to acquire connection for advisory lock: %v", err)
+		return err
+	}
+
+	trySQL := "SELECT pg_try_advisory_lock($1);"
+	if storage.lockMode == LockModeTransaction {
+		trySQL = "SELECT pg_try_advisory_xact_lock($1);"
+		if _, err := conn.Exec(ctx, "BEGIN;"); err != nil {
+			storage.logger.Error("Failed to begin lock transaction: %v", err)
+			conn.Release()
+			return err
+		}
+	}
+
+	if err := storage.waitForLock(ctx, conn, trySQL); err != nil {
 		storage.logger.Error("Failed to acquire advisory lock: %v", err)
+		if storage.lockMode == LockModeTransaction {
+			conn.Exec(ctx, "ROLLBACK;")
+		}
+		conn.Release()
+		return err
 	}
-	return err
+
+	storage.conn = conn
+	return nil
+}
+
+// databaseName возвращает имя базы из DSN или пустую строку, если DSN не разбирается
+func databaseName(connString string) string {
+	if connString == "" {
+		return ""
+	}
+
+	config, err := pgconn.ParseConfig(connString)
+	if err != nil {
+		return ""
+	}
+	return config.Database
+}
+
+// lockKeyFor выводит ключ advisory-блокировки из имени базы: запуски против одной базы
+// сериализуются, а против разных баз берут разные ключи
+func lockKeyFor(database string) int64 {
+	if database == "" {
+		return advisoryLockID
+	}
+
+	hash := fnv.New64a()
+	hash.Write([]byte(database))
+	return int64(hash.Sum64())
+}
+
+// lockID возвращает ключ advisory-блокировки хранилища
+func (storage *PostgresStorage) lockID() int64 {
+	if storage.lockKey != 0 {
+		return storage.lockKey
+	}
+	if !storage.lockPerDatabase {
+		return advisoryLockID
+	}
+	return lockKeyFor(storage.database)
+}
+
+// lockRetryInterval — пауза между попытками взять блокировку, которую держит другой мигратор
+var lockRetryInterval = time.Second
+
+// waitForLock пытается взять блокировку без ожидания на сервере и повторяет попытки, пока не истечет lockWait
+func (storage *PostgresStorage) waitForLock(ctx context.Context, conn pgxConn, trySQL string) error {
+	deadline := time.Now().Add(storage.lockWait)
+	reported := false
+	for {
+		var locked bool
+		rows, err := conn.Query(ctx, trySQL, storage.lockID())
+		if err != nil {
+			return err
+		}
+		if rows.Next() {
+			err = rows.Scan(&locked)
+		}
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		if locked {
+			return nil
+		}
+		if !time.Now().Before(deadline) {
+			return ErrLockHeld
+		}
+		if !reported {
+			storage.reportLockHolder(ctx, conn)
+			reported = true
+		}
+
+		storage.logger.Info("Advisory lock is held by another migrator, waiting")
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(lockRetryInterval):
+		}
+	}
+}
+
+// LockHolder — сессия, которая держит advisory-блокировку мигратора, по данным pg_locks и pg_stat_activity
+type LockHolder struct {
+	PID     int
+	AppName string
+	// HeldFor — оценка сверху: Postgres не хранит время захвата блокировки, поэтому берется время
+	// от начала транзакции держателя, а если он вне транзакции — от начала его сессии
+	HeldFor time.Duration
+}
+
+// Ключ bigint advisory-блокировки хранится в pg_locks двумя половинами: старшая в classid, младшая в objid
+const selectLockHolderSQL = `SELECT l.pid, COALESCE(a.application_name, ''),
+	COALESCE(EXTRACT(EPOCH FROM now() - COALESCE(a.xact_start, a.backend_start)), 0)::float8
+FROM pg_locks l JOIN pg_stat_activity a ON a.pid = l.pid
+WHERE l.locktype = 'advisory' AND l.granted AND l.objsubid = 1
+	AND l.classid = (($1::bigint >> 32) & 4294967295)::oid AND l.objid = ($1::bigint & 4294967295)::oid
+	AND l.pid <> pg_backend_pid()
+LIMIT 1;`
+
+// lockHolder находит сессию, которая держит блокировку; false — держатель уже отпустил ее
+func (storage *PostgresStorage) lockHolder(ctx context.Context, conn pgxConn) (LockHolder, bool, error) {
+	rows, err := conn.Query(ctx, selectLockHolderSQL, storage.lockID())
+	if err != nil {
+		return LockHolder{}, false, err
+	}
+	defer rows.Close()
+
+	if !rows.Next() {
+		return LockHolder{}, false, rows.Err()
+	}
+
+	var (
+		holder  LockHolder
+		seconds float64
+	)
+	if err := rows.Scan(&holder.PID, &holder.AppName, &seconds); err != nil {
+		return LockHolder{}, false, err
+	}
+	holder.HeldFor = time.Duration(seconds * float64(time.Second)).Round(time.Second)
+
+	return holder, true, nil
+}
+
+// reportLockHolder пишет в лог, кто держит блокировку, чтобы ожидание -wait-for-lock не выглядело зависанием
+func (storage *PostgresStorage) reportLockHolder(ctx context.Context, conn pgxConn) {
+	holder, found, err := storage.lockHolder(ctx, conn)
+	if err != nil {
+		storage.logger.Warn("Failed to find advisory lock holder: %v", err)
+		return
+	}
+	if !found {
+		return
+	}
+
+	storage.logger.Warn("Advisory lock is held by pid %d, application_name %q, for up to %s; waiting up to %s", holder.PID, holder.AppName, holder.HeldFor, storage.lockWait)
 }
 
 func (storage *PostgresStorage) Unlock(ctx context.Context) error {
+	if storage.lockDepth > 0 {
+		storage.lockDepth--
+		storage.logger.Info("Leaving nested advisory lock, depth %d", storage.lockDepth)
+		return nil
+	}
+
 	storage.logger.Info("Releasing advisory lock")
-	_, err := storage.pool.Exec(ctx, "SELECT pg_advisory_unlock($1);", advisoryLockID)
+
+	if storage.conn == nil {
+		storage.logger.Warn("Advisory lock is not held")
+		return nil
+	}
+
+	if storage.inTx {
+		storage.logger.Warn("Run transaction is still open on unlock, rolling it back")
+		storage.Rollback(ctx)
+	}
+
+	// COMMIT прерванной транзакции Postgres выполняет как ROLLBACK, блокировка снимается в обоих случаях
+	unlockSQL := "SELECT pg_advisory_unlock($1);"
+	args := []interface{}{storage.lockID()}
+	if storage.lockMode == LockModeTransaction {
+		unlockSQL = "COMMIT;"
+		args = nil
+	}
+
+	_, err := storage.conn.Exec(ctx, unlockSQL, args...)
 	if err != nil {
 		storage.logger.Error("Failed to release advisory lock: %v", err)
 	}
+
+	storage.conn.Release()
+	storage.conn = nil
 	return err
 }
 
+func (storage *PostgresStorage) TableName() string {
+	return storage.tableName
+}
+
+// table возвращает экранированное имя таблицы учета миграций для подстановки в запросы
+func (storage *PostgresStorage) table() string {
+	return pgx.Identifier(strings.Split(storage.tableName, ".")).Sanitize()
+}
+
+// eventsTable возвращает экранированное имя журнала переходов для подстановки в запросы
+func (storage *PostgresStorage) eventsTable() string {
+	return pgx.Identifier(strings.Split(EventsTableName(storage.tableName), ".")).Sanitize()
+}
+
+func (storage *PostgresStorage) insertEventSQL() string {
+	return `INSERT INTO ` + storage.eventsTable() + ` (Version, Name, Status, StatusChangeTime) VALUES ($1, $2, $3, $4);`
+}
+
+// executor возвращает соединение, на котором взята блокировка, либо пул
+func (storage *PostgresStorage) executor() executor {
+	if storage.conn != nil {
+		return storage.conn
+	}
+	return storage.pool
+}
+
 func (storage *PostgresStorage) DeleteMigrations(ctx context.Context) error {
-	storage.logger.Info("Deleting all migrations from schema_migrations table")
-	_, err := storage.pool.Exec(ctx, "TRUNCATE schema_migrations;")
+	storage.logger.Info("Deleting all migrations from %s table", storage.tableName)
+	_, err := storage.executor().Exec(ctx, "TRUNCATE "+storage.table()+";")
+	if err != nil {
+		storage.logger.Error("Failed to delete migrations: %v", err)
+	}
+	return err
+}
+
+func (storage *PostgresStorage) DeleteMigrationsByStatus(ctx context.Context, statuses ...string) error {
+	for _, status := range statuses {
+		if !IsKnownStatus(status) {
+			storage.logger.Error("Failed to delete migrations: %v: %s", ErrUnexpectedStatus, status)
+			return ErrUnexpectedStatus
+		}
+	}
+
+	storage.logger.Info("Deleting migrations with statuses %v from %s table", statuses, storage.tableName)
+	_, err := storage.executor().Exec(ctx, "DELETE FROM "+storage.table()+" WHERE Status = ANY($1);", statuses)
 	if err != nil {
 		storage.logger.Error("Failed to delete migrations: %v", err)
 	}
@@ -118,10 +874,18 @@ func (storage *PostgresStorage) DeleteMigrations(ctx context.Context) error {
 }
 
 func (storage *PostgresStorage) SelectMigrations(ctx context.Context) ([]IMigration, error) {
-	storage.logger.Info("Selecting all migrations from schema_migrations table")
-	sql := `SELECT Name, Status, Version, StatusChangeTime FROM schema_migrations ORDER BY Version DESC;`
+	storage.logger.Info("Selecting all migrations from %s table", storage.tableName)
+	return storage.selectMigrations(ctx, `SELECT `+migrationColumns+` FROM `+storage.table()+` ORDER BY Version DESC;`)
+}
 
-	rows, err := storage.pool.Query(ctx, sql)
+func (storage *PostgresStorage) SelectMigrationsSince(ctx context.Context, since time.Time) ([]IMigration, error) {
+	storage.logger.Info("Selecting migrations changed since %s from %s table", since.Format(time.RFC3339), storage.tableName)
+	return storage.selectMigrations(ctx, `SELECT `+migrationColumns+` FROM `+storage.table()+` WHERE StatusChangeTime >= $1 ORDER BY Version DESC;`, since)
+}
+
+// selectMigrations читает строки учета миграций; пустой результат — ErrMigrationNotFound
+func (storage *PostgresStorage) selectMigrations(ctx context.Context, sql string, args ...interface{}) ([]IMigration, error) {
+	rows, err := storage.executor().Query(ctx, sql, args...)
 	if err != nil {
 		storage.logger.Error("Failed to select migrations: %v", err)
 		return nil, err
@@ -130,20 +894,13 @@ func (storage *PostgresStorage) SelectMigrations(ctx context.Context) ([]IMigrat
 
 	var migrations []IMigration
 	for rows.Next() {
-		var (
-			name             string
-			version          int
-			status           string
-			statusChangeTime time.Time
-		)
-
-		err = rows.Scan(&name, &status, &version, &statusChangeTime)
+		migration, err := scanMigration(rows)
 		if err != nil {
 			storage.logger.Error("Failed to scan migration row: %v", err)
 			return nil, err
 		}
 
-		migrations = append(migrations, NewMigration(name, status, version, statusChangeTime))
+		migrations = append(migrations, migration)
 	}
 
 	if len(migrations) == 0 {
@@ -154,19 +911,36 @@ func (storage *PostgresStorage) SelectMigrations(ctx context.Context) ([]IMigrat
 	return migrations, nil
 }
 
+// scanMigration читает строку учета, выбранную с колонками migrationColumns
+func scanMigration(rows pgx.Rows) (IMigration, error) {
+	var (
+		name             string
+		version          int
+		status           string
+		statusChangeTime time.Time
+		appliedSQL       string
+	)
+
+	if err := rows.Scan(&name, &status, &version, &statusChangeTime, &appliedSQL); err != nil {
+		return nil, err
+	}
+
+	migration := NewMigration(name, status, version, statusChangeTime)
+	migration.SetAppliedSQL(appliedSQL)
+	return migration, nil
+}
+
 func (storage *PostgresStorage) SelectLastMigrationByStatus(ctx context.Context, status string) (IMigration, error) {
 	storage.logger.Info("Selecting last migration with status: %s", status)
 
-	switch status {
-	case StatusSuccess, StatusError, StatusProcess, StatusCancellation, StatusCancel:
-	default:
+	if !IsKnownStatus(status) {
 		storage.logger.Error("Unexpected status: %s", status)
 		return nil, ErrUnexpectedStatus
 	}
 
-	sql := `SELECT Name, Status, Version, StatusChangeTime FROM schema_migrations WHERE Status = $1 ORDER BY Version DESC LIMIT 1;`
+	sql := `SELECT ` + migrationColumns + ` FROM ` + storage.table() + ` WHERE Status = $1 ORDER BY Version DESC LIMIT 1;`
 
-	rows, err := storage.pool.Query(ctx, sql, status)
+	rows, err := storage.executor().Query(ctx, sql, status)
 	if err != nil {
 		storage.logger.Error("Failed to select last migration by status: %v", err)
 		return nil, err
@@ -174,54 +948,193 @@ func (storage *PostgresStorage) SelectLastMigrationByStatus(ctx context.Context,
 	defer rows.Close()
 
 	if rows.Next() {
-		var (
-			name             string
-			version          int
-			status           string
-			statusChangeTime time.Time
-		)
-
-		err = rows.Scan(&name, &status, &version, &statusChangeTime)
+		migration, err := scanMigration(rows)
 		if err != nil {
 			storage.logger.Error("Failed to scan migration row: %v", err)
 			return nil, err
 		}
 
-		return NewMigration(name, status, version, statusChangeTime), nil
+		return migration, nil
 	}
 
 	storage.logger.Warn("No migration found with status: %s", status)
 	return nil, ErrMigrationNotFound
 }
 
+// SelectMigrationByVersion возвращает строку учета для версии или ErrMigrationNotFound
+func (storage *PostgresStorage) SelectMigrationByVersion(ctx context.Context, version int) (IMigration, error) {
+	storage.logger.Info("Selecting migration with version: %d", version)
+	sql := `SELECT ` + migrationColumns + ` FROM ` + storage.table() + ` WHERE Version = $1;`
+
+	rows, err := storage.executor().Query(ctx, sql, version)
+	if err != nil {
+		storage.logger.Error("Failed to select migration by version: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	if rows.Next() {
+		migration, err := scanMigration(rows)
+		if err != nil {
+			storage.logger.Error("Failed to scan migration row: %v", err)
+			return nil, err
+		}
+
+		return migration, nil
+	}
+
+	storage.logger.Warn("No migration found with version: %d", version)
+	return nil, ErrMigrationNotFound
+}
+
 func (storage *PostgresStorage) InsertMigration(ctx context.Context, migration IMigration) error {
 	storage.logger.Info("Inserting/updating migration: %s", migration.GetName())
 
-	sql := `
-		DO $$ 
-		BEGIN
-			IF EXISTS (SELECT 1 FROM schema_migrations WHERE Version = $1 AND Name = $2) THEN
-				UPDATE schema_migrations 
-				SET Status = $3, StatusChangeTime = $4 
-				WHERE Version = $1 AND Name = $2;
-			ELSE
-				INSERT INTO schema_migrations (Version, Name, Status, StatusChangeTime)
-				VALUES ($1, $2, $3, $4);
-			END IF;
-		END $$;`
-
-	_, err := storage.pool.Exec(ctx, sql, migration.GetVersion(), migration.GetName(), migration.GetStatus(), migration.GetStatusChangeTime())
+	_, err := storage.executor().Exec(ctx, storage.upsertMigrationSQL(), upsertArgs(migration)...)
 	if err != nil {
 		storage.logger.Error("Failed to insert/update migration: %v", err)
 	}
 	return err
 }
 
+// InsertMigrations сохраняет статусы и события нескольких миграций одним пакетом запросов
+func (storage *PostgresStorage) InsertMigrations(ctx context.Context, migrations []IMigration) error {
+	if len(migrations) == 0 {
+		return nil
+	}
+	storage.logger.Info("Inserting/updating %d migrations in a batch", len(migrations))
+
+	batch := &pgx.Batch{}
+	for _, migration := range migrations {
+		batch.Queue(storage.upsertMigrationSQL(), upsertArgs(migration)...)
+		batch.Queue(storage.insertEventSQL(), migration.GetVersion(), migration.GetName(), migration.GetStatus(), migration.GetStatusChangeTime())
+	}
+
+	results := storage.executor().SendBatch(ctx, batch)
+	for i := 0; i < batch.Len(); i++ {
+		if _, err := results.Exec(); err != nil {
+			results.Close()
+			storage.logger.Error("Failed to insert/update migrations: %v", err)
+			return err
+		}
+	}
+
+	return results.Close()
+}
+
+// upsertMigrationSQL вставляет или обновляет строку учета. AppliedSQL передается NULL, когда не записывается,
+// и тогда сохраняется записанный ранее.
+func (storage *PostgresStorage) upsertMigrationSQL() string {
+	return `
+		INSERT INTO ` + storage.table() + ` AS m (Version, Name, Status, StatusChangeTime, AppliedSQL)
+		VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (Version) DO UPDATE
+		SET Name = EXCLUDED.Name, Status = EXCLUDED.Status, StatusChangeTime = EXCLUDED.StatusChangeTime,
+			AppliedSQL = COALESCE(EXCLUDED.AppliedSQL, m.AppliedSQL);`
+}
+
+func upsertArgs(migration IMigration) []interface{} {
+	return []interface{}{migration.GetVersion(), migration.GetName(), migration.GetStatus(), migration.GetStatusChangeTime(), nullString(migration.GetAppliedSQL())}
+}
+
+// nullString возвращает nil для пустой строки, чтобы она записалась как NULL
+func nullString(value string) interface{} {
+	if value == "" {
+		return nil
+	}
+	return value
+}
+
 func (storage *PostgresStorage) Migrate(ctx context.Context, sql string) error {
 	storage.logger.Info("Executing migration SQL")
-	_, err := storage.pool.Exec(ctx, sql)
+
+	var err error
+	switch {
+	case usesPsql(sql):
+		err = storage.migrateWithPsql(ctx, sql)
+	case isNonTransactional(sql) && storage.inRunTransaction():
+		err = ErrNonTransactionalMigration
+	case isNonTransactional(sql):
+		err = storage.migrateWithoutTransaction(ctx, sql)
+	case hasTransactionControl(sql) && storage.inRunTransaction():
+		// собственный COMMIT зафиксировал бы общую транзакцию запуска посередине и снял бы блокировку транзакции
+		err = fmt.Errorf("%w: migration manages its own transaction", ErrNonTransactionalMigration)
+	case storage.inRunTransaction():
+		_, err = storage.conn.Exec(ctx, sql)
+	case storage.transaction && hasTransactionControl(sql):
+		storage.logger.Warn("Migration SQL manages its own transaction, running it without automatic wrapping")
+		_, err = storage.executor().Exec(ctx, sql)
+	case storage.transaction:
+		err = storage.migrateInTransaction(ctx, sql)
+	default:
+		_, err = storage.executor().Exec(ctx, sql)
+	}
 	if err != nil {
 		storage.logger.Error("Failed to execute migration SQL: %v", err)
 	}
 	return err
 }
+
+// inLockTransaction проверяет, что запуск уже идет внутри транзакции, удерживающей блокировку
+func (storage *PostgresStorage) inLockTransaction() bool {
+	return storage.lockMode == LockModeTransaction && storage.conn != nil
+}
+
+// inRunTransaction проверяет, что запуск идет внутри общей транзакции: блокировки или открытой через Begin
+func (storage *PostgresStorage) inRunTransaction() bool {
+	return storage.inLockTransaction() || storage.inTx
+}
+
+// Notify выполняет NOTIFY через pg_notify, чтобы имя канала передавалось параметром.
+// Внутри транзакции уведомление доставляется только после COMMIT.
+func (storage *PostgresStorage) Notify(ctx context.Context, channel, payload string) error {
+	storage.logger.Debug("Notifying channel %s: %s", channel, payload)
+
+	_, err := storage.executor().Exec(ctx, "SELECT pg_notify($1, $2);", channel, payload)
+	if err != nil {
+		storage.logger.Error("Failed to notify channel %s: %v", channel, err)
+	}
+	return err
+}
+
+func (storage *PostgresStorage) InsertMigrationEvent(ctx context.Context, migration IMigration) error {
+	storage.logger.Debug("Recording migration event: %s -> %s", migration.GetName(), migration.GetStatus())
+
+	_, err := storage.executor().Exec(ctx, storage.insertEventSQL(), migration.GetVersion(), migration.GetName(), migration.GetStatus(), migration.GetStatusChangeTime())
+	if err != nil {
+		storage.logger.Error("Failed to record migration event: %v", err)
+	}
+	return err
+}
+
+func (storage *PostgresStorage) SelectMigrationEvents(ctx context.Context) ([]IMigration, error) {
+	storage.logger.Info("Selecting migration events from %s table", EventsTableName(storage.tableName))
+	sql := `SELECT Name, Status, Version, StatusChangeTime FROM ` + storage.eventsTable() + ` ORDER BY StatusChangeTime, Id;`
+
+	rows, err := storage.executor().Query(ctx, sql)
+	if err != nil {
+		storage.logger.Error("Failed to select migration events: %v", err)
+		return nil, err
+	}
+	defer rows.Close()
+
+	var events []IMigration
+	for rows.Next() {
+		var (
+			name             string
+			version          int
+			status           string
+			statusChangeTime time.Time
+		)
+
+		err = rows.Scan(&name, &status, &version, &statusChangeTime)
+		if err != nil {
+			storage.logger.Error("Failed to scan migration event row: %v", err)
+			return nil, err
+		}
+
+		events = append(events, NewMigration(name, status, version, statusChangeTime))
+	}
+
+	return events, nil
+}
@@ -0,0 +1,289 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+)
+
+// NoTransactionMarker — строка в миграции, отключающая автоматическую транзакцию для нее
+const NoTransactionMarker = "-- migrator:no-transaction"
+
+var (
+	ErrNonTransactionalMigration = errors.New("migration cannot run inside the run transaction, use session lock mode and per-migration tx mode")
+	ErrTransactionWithoutLock    = errors.New("run transaction requires the advisory lock to be held")
+	ErrInvalidIndex              = errors.New("concurrently built index is invalid")
+
+	regConcurrently = regexp.MustCompile(`(?i)\bCONCURRENTLY\b`)
+	regIndexName    = regexp.MustCompile(`(?i)^CREATE\s+(?:UNIQUE\s+)?INDEX\s+CONCURRENTLY\s+(?:IF\s+NOT\s+EXISTS\s+)?("[^"]+"|[\w.]+)\s+ON\s`)
+)
+
+// WithTransaction оборачивает SQL каждой миграции в BEGIN/COMMIT.
+// Миграции, которые сами управляют транзакцией, выполняются без обертки.
+func WithTransaction(enabled bool) Option {
+	return func(storage *PostgresStorage) {
+		storage.transaction = enabled
+	}
+}
+
+// Begin открывает общую транзакцию запуска на соединении, удерживаемом после Lock.
+// В режиме LockModeTransaction запуск уже идет в транзакции, и новая не открывается.
+func (storage *PostgresStorage) Begin(ctx context.Context) error {
+	if storage.conn == nil {
+		return ErrTransactionWithoutLock
+	}
+	if storage.inRunTransaction() {
+		return nil
+	}
+
+	if _, err := storage.conn.Exec(ctx, "BEGIN;"); err != nil {
+		storage.logger.Error("Failed to begin run transaction: %v", err)
+		return err
+	}
+
+	storage.inTx = true
+	return nil
+}
+
+// Commit фиксирует транзакцию, открытую Begin; транзакцию блокировки фиксирует Unlock
+func (storage *PostgresStorage) Commit(ctx context.Context) error {
+	if !storage.inTx {
+		return nil
+	}
+	storage.inTx = false
+
+	_, err := storage.conn.Exec(ctx, "COMMIT;")
+	if err != nil {
+		storage.logger.Error("Failed to commit run transaction: %v", err)
+	}
+	return err
+}
+
+// Rollback откатывает общую транзакцию запуска. В режиме LockModeTransaction вместе с ней снимается блокировка.
+func (storage *PostgresStorage) Rollback(ctx context.Context) error {
+	if !storage.inRunTransaction() {
+		return nil
+	}
+	storage.inTx = false
+
+	_, err := storage.conn.Exec(ctx, "ROLLBACK;")
+	if err != nil {
+		storage.logger.Error("Failed to roll back run transaction: %v", err)
+	}
+	return err
+}
+
+func (storage *PostgresStorage) InTransaction() bool {
+	return storage.inRunTransaction()
+}
+
+// migrateInTransaction выполняет SQL в отдельной транзакции на одном соединении:
+// удерживаемом после Lock или взятом из пула на время вызова
+func (storage *PostgresStorage) migrateInTransaction(ctx context.Context, sql string) error {
+	conn := storage.conn
+	if conn == nil {
+		acquired, err := storage.pool.AcquireConn(ctx)
+		if err != nil {
+			return err
+		}
+		defer acquired.Release()
+		conn = acquired
+	}
+
+	if _, err := conn.Exec(ctx, "BEGIN;"); err != nil {
+		return err
+	}
+
+	if _, err := conn.Exec(ctx, sql); err != nil {
+		if _, rollbackErr := conn.Exec(ctx, "ROLLBACK;"); rollbackErr != nil {
+			storage.logger.Warn("Failed to roll back migration transaction: %v", rollbackErr)
+		}
+		return err
+	}
+
+	_, err := conn.Exec(ctx, "COMMIT;")
+	return err
+}
+
+// migrateWithoutTransaction выполняет выражения по одному: несколько выражений в одном запросе
+// Postgres выполняет в неявной транзакции, где CREATE INDEX CONCURRENTLY запрещен.
+// После выполнения проверяет, что построенные CONCURRENTLY индексы валидны.
+func (storage *PostgresStorage) migrateWithoutTransaction(ctx context.Context, sql string) error {
+	var indexes []string
+	for _, statement := range splitStatements(sql) {
+		if strings.TrimSpace(statement.code) == "" {
+			continue
+		}
+		if name := concurrentIndexName(statement.code); name != "" {
+			indexes = append(indexes, name)
+		}
+
+		if _, err := storage.executor().Exec(ctx, statement.text); err != nil {
+			if invalidErr := storage.checkIndexes(ctx, indexes); invalidErr != nil {
+				return fmt.Errorf("%w: %v", invalidErr, err)
+			}
+			return err
+		}
+	}
+
+	return storage.checkIndexes(ctx, indexes)
+}
+
+// checkIndexes возвращает ErrInvalidIndex, если один из индексов остался невалидным после прерванного построения
+func (storage *PostgresStorage) checkIndexes(ctx context.Context, indexes []string) error {
+	for _, index := range indexes {
+		rows, err := storage.executor().Query(ctx, `SELECT indisvalid FROM pg_index WHERE indexrelid = to_regclass($1);`, index)
+		if err != nil {
+			return err
+		}
+
+		valid := true
+		if rows.Next() {
+			err = rows.Scan(&valid)
+		}
+		rows.Close()
+		if err != nil {
+			return err
+		}
+
+		if !valid {
+			storage.logger.Error("Index %s is invalid, drop it with DROP INDEX CONCURRENTLY %s; and rerun the migration", index, index)
+			return fmt.Errorf("%w: %s", ErrInvalidIndex, index)
+		}
+	}
+
+	return nil
+}
+
+// isNonTransactional проверяет, что миграцию нельзя выполнять в транзакции:
+// она отмечена NoTransactionMarker или содержит выражения с CONCURRENTLY
+func isNonTransactional(sql string) bool {
+	for _, line := range strings.Split(sql, "\n") {
+		if strings.TrimSpace(line) == NoTransactionMarker {
+			return true
+		}
+	}
+
+	for _, statement := range splitStatements(sql) {
+		if regConcurrently.MatchString(statement.code) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// concurrentIndexName возвращает имя индекса из CREATE INDEX CONCURRENTLY или пустую строку
+func concurrentIndexName(code string) string {
+	match := regIndexName.FindStringSubmatch(strings.TrimSpace(code))
+	if match == nil {
+		return ""
+	}
+	return match[1]
+}
+
+// hasTransactionControl проверяет, есть ли в SQL собственные BEGIN/START TRANSACTION/COMMIT/END/ROLLBACK.
+// Комментарии, строки и $$-блоки (например, тела PL/pgSQL-функций) пропускаются.
+func hasTransactionControl(sql string) bool {
+	for _, statement := range splitStatements(sql) {
+		fields := strings.Fields(strings.ToUpper(statement.code))
+		if len(fields) == 0 {
+			continue
+		}
+
+		switch fields[0] {
+		case "BEGIN", "COMMIT", "END", "ROLLBACK", "ABORT":
+			return true
+		case "START":
+			if len(fields) > 1 && fields[1] == "TRANSACTION" {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// statement — выражение SQL: исходный текст и код, в котором комментарии, строки и $$-блоки заменены пробелами
+type statement struct {
+	text string
+	code string
+}
+
+// splitStatements делит SQL на выражения по ";" вне комментариев, строк и $$-блоков
+func splitStatements(sql string) []statement {
+	var (
+		statements []statement
+		code       strings.Builder
+		start      int
+	)
+
+	flush := func(end int) {
+		statements = append(statements, statement{text: strings.TrimSpace(sql[start:end]), code: code.String()})
+		code.Reset()
+	}
+
+	for i := 0; i < len(sql); {
+		switch {
+		case strings.HasPrefix(sql[i:], "--"):
+			i = skipTo(sql, i+2, "\n", 0)
+		case strings.HasPrefix(sql[i:], "/*"):
+			i = skipTo(sql, i+2, "*/", 2)
+			code.WriteByte(' ')
+		case sql[i] == '\'' || sql[i] == '"':
+			i = skipTo(sql, i+1, sql[i:i+1], 1)
+			code.WriteByte(' ')
+		case sql[i] == '$' && dollarTag(sql[i:]) != "":
+			tag := dollarTag(sql[i:])
+			i = skipTo(sql, i+len(tag), tag, len(tag))
+			code.WriteByte(' ')
+		case sql[i] == ';':
+			flush(i + 1)
+			i++
+			start = i
+		default:
+			code.WriteByte(sql[i])
+			i++
+		}
+	}
+
+	if strings.TrimSpace(sql[start:]) != "" {
+		flush(len(sql))
+	}
+
+	return statements
+}
+
+// skipTo возвращает позицию после закрывающей последовательности end, которая ищется начиная с from
+func skipTo(sql string, from int, end string, endLen int) int {
+	idx := strings.Index(sql[from:], end)
+	if idx < 0 {
+		return len(sql)
+	}
+	return from + idx + endLen
+}
+
+// dollarTag возвращает открывающий тег $$-блока ("$$" или "$tag$") в начале строки или пустую строку
+func dollarTag(sql string) string {
+	for i := 1; i < len(sql); i++ {
+		if sql[i] == '$' {
+			return sql[:i+1]
+		}
+
+		r := rune(sql[i])
+		if r != '_' && !unicode.IsLetter(r) && !(i > 1 && unicode.IsDigit(r)) {
+			return ""
+		}
+	}
+
+	return ""
+}
+
+// IsWrappable проверяет, что мигратор может выполнить SQL в своей транзакции: миграция не отмечена
+// NoTransactionMarker, не содержит CONCURRENTLY и не управляет транзакцией сама
+func IsWrappable(sql string) bool {
+	return !isNonTransactional(sql) && !hasTransactionControl(sql)
+}